@@ -0,0 +1,229 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command httpcache-server serves a cache backend over HTTP, so that several
+// application processes can share one cache through the remote driver
+// (github.com/bartventer/httpcache/store/remote).
+//
+// Usage:
+//
+//	httpcache-server -dsn <dsn> [flags]
+//
+// The flags are:
+//
+//	-addr string
+//		address to listen on (default "localhost:7420")
+//	-dsn string
+//		DSN of the cache backend served, e.g. "fscache://?appname=myapp"
+//	-token-file string
+//		file holding the bearer token clients must send; the
+//		HTTPCACHE_SERVER_TOKEN environment variable is used if not set
+//	-tls-cert, -tls-key string
+//		PEM files of the server certificate and key, to serve over TLS
+//	-client-ca string
+//		PEM file of the certificate authorities client certificates must be
+//		signed by; requires -tls-cert
+//	-max-body-size int
+//		largest value accepted, in bytes (default 256 MiB)
+//
+// All built-in backends are available. The server shuts down gracefully on
+// SIGINT or SIGTERM.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bartventer/httpcache/store"
+	_ "github.com/bartventer/httpcache/store/compressed"
+	_ "github.com/bartventer/httpcache/store/encrypted"
+	_ "github.com/bartventer/httpcache/store/fscache"
+	_ "github.com/bartventer/httpcache/store/memcache"
+	_ "github.com/bartventer/httpcache/store/memcached"
+	_ "github.com/bartventer/httpcache/store/namespace"
+	_ "github.com/bartventer/httpcache/store/redis"
+	"github.com/bartventer/httpcache/store/remote"
+	_ "github.com/bartventer/httpcache/store/s3"
+	_ "github.com/bartventer/httpcache/store/tiered"
+)
+
+const (
+	tokenEnv        = "HTTPCACHE_SERVER_TOKEN"
+	shutdownTimeout = 10 * time.Second
+)
+
+type config struct {
+	addr        string
+	dsn         string
+	tokenFile   string
+	tlsCert     string
+	tlsKey      string
+	clientCA    string
+	maxBodySize int64
+}
+
+func parseFlags(args []string, output io.Writer) (*config, error) {
+	cfg := &config{}
+	fs := flag.NewFlagSet("httpcache-server", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&cfg.addr, "addr", "localhost:7420", "address to listen on")
+	fs.StringVar(&cfg.dsn, "dsn", "", `DSN of the cache backend served, e.g. "fscache://?appname=myapp"`)
+	fs.StringVar(&cfg.tokenFile, "token-file", "", "file holding the bearer token clients must send (default $"+tokenEnv+")")
+	fs.StringVar(&cfg.tlsCert, "tls-cert", "", "PEM file of the server certificate")
+	fs.StringVar(&cfg.tlsKey, "tls-key", "", "PEM file of the server key")
+	fs.StringVar(&cfg.clientCA, "client-ca", "", "PEM file of the authorities client certificates must be signed by")
+	fs.Int64Var(&cfg.maxBodySize, "max-body-size", 256<<20, "largest value accepted, in bytes")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.dsn == "":
+		return nil, errors.New("missing -dsn")
+	case (cfg.tlsCert == "") != (cfg.tlsKey == ""):
+		return nil, errors.New("-tls-cert and -tls-key must be given together")
+	case cfg.clientCA != "" && cfg.tlsCert == "":
+		return nil, errors.New("-client-ca requires -tls-cert")
+	case cfg.maxBodySize <= 0:
+		return nil, errors.New("-max-body-size must be positive")
+	}
+	return cfg, nil
+}
+
+// token returns the bearer token clients must send, or "" for none.
+func (cfg *config) token() (string, error) {
+	if cfg.tokenFile == "" {
+		return os.Getenv(tokenEnv), nil
+	}
+	b, err := os.ReadFile(cfg.tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %q is empty", cfg.tokenFile)
+	}
+	return token, nil
+}
+
+// tlsConfig returns the server's TLS configuration, or nil to serve plain
+// HTTP.
+func (cfg *config) tlsConfig() (*tls.Config, error) {
+	if cfg.tlsCert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.tlsCert, cfg.tlsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if cfg.clientCA != "" {
+		pem, err := os.ReadFile(cfg.clientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		tc.ClientCAs = x509.NewCertPool()
+		if !tc.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA file %q holds no certificates", cfg.clientCA)
+		}
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tc, nil
+}
+
+// newServer opens the cache backend and returns a server for it, along with
+// a function that closes the backend.
+func newServer(cfg *config) (*http.Server, func() error, error) {
+	token, err := cfg.token()
+	if err != nil {
+		return nil, nil, err
+	}
+	tc, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := store.Open(cfg.dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open cache: %w", err)
+	}
+	closeConn := func() error {
+		if c, ok := conn.(io.Closer); ok {
+			return c.Close()
+		}
+		return nil
+	}
+	opts := []remote.HandlerOption{remote.WithMaxBodySize(cfg.maxBodySize)}
+	if token != "" {
+		opts = append(opts, remote.RequireToken(token))
+	}
+	srv := &http.Server{
+		Addr:              cfg.addr,
+		Handler:           remote.NewHandler(conn, opts...),
+		TLSConfig:         tc,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return srv, closeConn, nil
+}
+
+func run(ctx context.Context, args []string, stderr io.Writer) error {
+	cfg, err := parseFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+	srv, closeConn, err := newServer(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	errc := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errc <- srv.ListenAndServeTLS("", "")
+		} else {
+			errc <- srv.ListenAndServe()
+		}
+	}()
+	logger := log.New(stderr, "", log.LstdFlags)
+	logger.Printf("serving %s on %s", strings.SplitN(cfg.dsn, ":", 2)[0], cfg.addr)
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	logger.Print("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stderr); err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "httpcache-server:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/remote"
+)
+
+func Test_parseFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"minimal", []string{"-dsn", "memcache://"}, false},
+		{"mutual TLS", []string{"-dsn", "memcache://", "-tls-cert", "c.pem", "-tls-key", "k.pem", "-client-ca", "ca.pem"}, false},
+		{"missing dsn", nil, true},
+		{"cert without key", []string{"-dsn", "memcache://", "-tls-cert", "c.pem"}, true},
+		{"client CA without TLS", []string{"-dsn", "memcache://", "-client-ca", "ca.pem"}, true},
+		{"invalid max body size", []string{"-dsn", "memcache://", "-max-body-size", "0"}, true},
+		{"unknown flag", []string{"-dsn", "memcache://", "-verbose"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFlags(tt.args, io.Discard)
+			testutil.AssertTrue(t, tt.wantErr == (err != nil), "unexpected error result")
+		})
+	}
+}
+
+func Test_config_token(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	testutil.RequireNoError(t, os.WriteFile(file, []byte("from-file\n"), 0o600))
+	t.Setenv(tokenEnv, "from-env")
+
+	got, err := (&config{tokenFile: file}).token()
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "from-file", got)
+	got, err = (&config{}).token()
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "from-env", got)
+
+	empty := filepath.Join(dir, "empty")
+	testutil.RequireNoError(t, os.WriteFile(empty, nil, 0o600))
+	_, err = (&config{tokenFile: empty}).token()
+	testutil.RequireError(t, err)
+}
+
+// writePKI writes a certificate authority, and a server and a client
+// certificate it signed, to dir as PEM files.
+func writePKI(t *testing.T, dir string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutil.RequireNoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	testutil.RequireNoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	testutil.RequireNoError(t, err)
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", caDER)
+
+	for i, name := range []string{"server", "client"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		testutil.RequireNoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: name},
+			IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+		testutil.RequireNoError(t, err)
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		testutil.RequireNoError(t, err)
+		writePEM(t, filepath.Join(dir, name+".pem"), "CERTIFICATE", der)
+		writePEM(t, filepath.Join(dir, name+"-key.pem"), "PRIVATE KEY", keyDER)
+	}
+}
+
+func writePEM(t *testing.T, name, typ string, der []byte) {
+	t.Helper()
+	b := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	testutil.RequireNoError(t, os.WriteFile(name, b, 0o600))
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	writePKI(t, dir)
+	t.Setenv(tokenEnv, "secret")
+	cfg, err := parseFlags([]string{
+		"-dsn", "memcache://",
+		"-tls-cert", filepath.Join(dir, "server.pem"),
+		"-tls-key", filepath.Join(dir, "server-key.pem"),
+		"-client-ca", filepath.Join(dir, "ca.pem"),
+	}, io.Discard)
+	testutil.RequireNoError(t, err)
+	srv, closeConn, err := newServer(cfg)
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { closeConn() })
+	ts := httptest.NewUnstartedServer(srv.Handler)
+	ts.TLS = srv.TLSConfig
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	q := url.Values{
+		"ca_file":   {filepath.Join(dir, "ca.pem")},
+		"cert_file": {filepath.Join(dir, "client.pem")},
+		"key_file":  {filepath.Join(dir, "client-key.pem")},
+		"token":     {"secret"},
+	}
+	dsn := "remotes://" + strings.TrimPrefix(ts.URL, "https://") + "?"
+	conn, err := store.Open(dsn + q.Encode())
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { conn.(io.Closer).Close() })
+	testutil.RequireNoError(t, conn.Set("k", []byte("v")))
+	got, err := conn.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "v", string(got))
+
+	// Without a client certificate, or the token, the server refuses.
+	noCert := url.Values{"ca_file": q["ca_file"], "token": q["token"]}
+	_, err = store.Open(dsn + noCert.Encode())
+	testutil.RequireError(t, err)
+	q.Del("token")
+	_, err = store.Open(dsn + q.Encode())
+	testutil.RequireErrorIs(t, err, remote.ErrUnauthorized)
+}
+
+func Test_run(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	errc := make(chan error, 1)
+	go func() { errc <- run(ctx, []string{"-addr", "127.0.0.1:0", "-dsn", "memcache://"}, io.Discard) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errc:
+		testutil.RequireNoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+
+	err := run(t.Context(), []string{"-dsn", "nosuchscheme://"}, io.Discard)
+	testutil.RequireError(t, err)
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance checks the behaviour of the httpcache transport against
+// the HTTP caching specifications (RFC 9111, RFC 5861 and RFC 8246), in the
+// spirit of the cache-tests.fyi suite.
+//
+// A [Scenario] is a sequence of [Step]s. Each step sends a request through
+// the transport, scripts the response of the origin server (an
+// [httptest.Server]) should the request reach it, and declares the expected
+// status, cache status, headers, body and number of requests made to the
+// origin. The transport's clock is fake: it starts at [Epoch], and only moves
+// when a step advances it, so that freshness can be tested without waiting.
+//
+// # Usage
+//
+// Run the scenario catalogue against a cache backend, and optionally a set of
+// transport options:
+//
+//	func TestConformance(t *testing.T) {
+//	    conformance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+//	        cache := NewMyCache() // Your implementation
+//	        return cache, func() { /* cleanup logic */ }
+//	    }), httpcache.WithRetentionGrace(time.Hour))
+//	}
+//
+// Each scenario runs against a connection of its own. Use [RunScenarios] to
+// run a subset of [Scenarios], or scenarios of your own.
+package conformance
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache"
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+// Epoch is the time at which the clock of each scenario starts.
+var Epoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Date returns the HTTP-date offset from [Epoch], for scripting header values
+// such as Expires and Last-Modified.
+func Date(offset time.Duration) string {
+	return Epoch.Add(offset).Format(http.TimeFormat)
+}
+
+// Scenario is a sequence of requests checking one aspect of the specification.
+type Scenario struct {
+	Name  string // unique name, used as the name of the subtest
+	Spec  string // section of the specification covered, e.g. "RFC 9111 §4.2.1"
+	Steps []Step
+}
+
+// Step is a single exchange with the transport.
+type Step struct {
+	// Advance is how far the clock moves before the request is sent.
+	Advance time.Duration
+	// Request is the request sent to the transport.
+	Request Request
+	// Response is the response of the origin server, to every request it
+	// receives during the step; nil if the origin must not be contacted.
+	Response *Response
+	// Want is the expected outcome.
+	Want Want
+}
+
+// Request describes a request sent to the transport.
+type Request struct {
+	Method string            // default: GET
+	Path   string            // path and query, relative to the origin; default: "/"
+	Header map[string]string // request header fields
+}
+
+// Response describes a response of the origin server.
+type Response struct {
+	Status int               // default: 200
+	Header map[string]string // Date defaults to the current time of the clock
+	Body   string
+}
+
+// Want describes the expected outcome of a step.
+type Want struct {
+	// Status is the status code of the response; default: 200.
+	Status int
+	// CacheStatus is the value of the [httpcache.CacheStatusHeader] field,
+	// e.g. "HIT"; not checked if empty.
+	CacheStatus string
+	// Header holds expected response header values; an empty value means the
+	// field must be absent.
+	Header map[string]string
+	// Body is the response body; not checked if empty.
+	Body string
+	// Upstream is the number of requests the origin receives during the step,
+	// including those made in the background, which the step waits for.
+	Upstream int
+	// OriginHeader holds the expected header values of the last request the
+	// origin received; an empty value means the field must be absent.
+	OriginHeader map[string]string
+}
+
+// Scenarios returns the scenario catalogue, which covers freshness,
+// validation, invalidation, Vary and the extension directives.
+func Scenarios() []Scenario {
+	return slices.Concat(
+		freshnessScenarios,
+		requestDirectiveScenarios,
+		validationScenarios,
+		invalidationScenarios,
+		varyScenarios,
+		extensionScenarios,
+	)
+}
+
+// Run runs the scenario catalogue against the transport, configured with
+// options, for cache connections made by factory.
+func Run(t *testing.T, factory acceptance.Factory, options ...httpcache.Option) {
+	t.Helper()
+	RunScenarios(t, factory, Scenarios(), options...)
+}
+
+// RunScenarios runs scenarios against the transport, configured with options,
+// for cache connections made by factory. The options must not set the
+// upstream, registry or clock of the transport, which the harness provides.
+func RunScenarios(
+	t *testing.T,
+	factory acceptance.Factory,
+	scenarios []Scenario,
+	options ...httpcache.Option,
+) {
+	t.Helper()
+	for _, s := range scenarios {
+		t.Run(s.Name, func(t *testing.T) {
+			conn, cleanup := factory.Make()
+			t.Cleanup(cleanup)
+			newHarness(t, conn, options).run(s)
+		})
+	}
+}
+
+// harness drives the transport for a single scenario.
+type harness struct {
+	t      *testing.T
+	rt     http.RoundTripper
+	origin *httptest.Server
+	up     *upstream
+
+	mu   sync.Mutex
+	now  time.Time
+	step *Step // step in progress
+}
+
+func newHarness(t *testing.T, conn driver.Conn, options []httpcache.Option) *harness {
+	h := &harness{t: t, now: Epoch}
+	h.origin = httptest.NewServer(http.HandlerFunc(h.serve))
+	t.Cleanup(h.origin.Close)
+	h.up = &upstream{next: h.origin.Client().Transport}
+
+	reg := store.NewRegistry()
+	reg.Register("conformance", driver.DriverFunc(func(*url.URL) (driver.Conn, error) {
+		return conn, nil
+	}))
+	h.rt = httpcache.NewTransport("conformance://", append(slices.Clone(options),
+		httpcache.WithRegistry(reg),
+		httpcache.WithUpstream(h.up),
+		httpcache.WithClock(h.clock),
+	)...)
+	return h
+}
+
+func (h *harness) clock() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.now
+}
+
+func (h *harness) run(s Scenario) {
+	h.t.Helper()
+	if s.Spec != "" {
+		h.t.Log(s.Spec)
+	}
+	for i := range s.Steps {
+		h.runStep(i+1, &s.Steps[i])
+	}
+}
+
+func (h *harness) runStep(n int, step *Step) {
+	t := h.t
+	t.Helper()
+	h.mu.Lock()
+	h.now = h.now.Add(step.Advance)
+	h.step = step
+	h.mu.Unlock()
+
+	ctx := t.Context()
+	req, err := http.NewRequestWithContext(
+		ctx,
+		cmp.Or(step.Request.Method, http.MethodGet),
+		h.origin.URL+cmp.Or(step.Request.Path, "/"),
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("step %d: %v", n, err)
+	}
+	for k, v := range step.Request.Header {
+		req.Header.Set(k, v)
+	}
+	h.up.begin(ctx)
+	resp, err := h.rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("step %d: RoundTrip: %v", n, err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatalf("step %d: reading body: %v", n, err)
+	}
+	calls, last := h.up.wait(step.Want.Upstream)
+
+	want := step.Want
+	if got, want := resp.StatusCode, cmp.Or(want.Status, http.StatusOK); got != want {
+		t.Errorf("step %d: status = %d, want %d", n, got, want)
+	}
+	if got := resp.Header.Get(httpcache.CacheStatusHeader); want.CacheStatus != "" && got != want.CacheStatus {
+		t.Errorf("step %d: cache status = %q, want %q", n, got, want.CacheStatus)
+	}
+	checkHeader(t, n, "response", resp.Header, want.Header)
+	if want.Body != "" && string(body) != want.Body {
+		t.Errorf("step %d: body = %q, want %q", n, body, want.Body)
+	}
+	if calls != want.Upstream {
+		t.Errorf("step %d: origin received %d requests, want %d", n, calls, want.Upstream)
+	}
+	if len(want.OriginHeader) > 0 {
+		if last == nil {
+			t.Errorf("step %d: origin received no request", n)
+		} else {
+			checkHeader(t, n, "origin request", last, want.OriginHeader)
+		}
+	}
+}
+
+func checkHeader(t *testing.T, n int, what string, got http.Header, want map[string]string) {
+	t.Helper()
+	for k, v := range want {
+		if v == "" {
+			if vs, ok := got[http.CanonicalHeaderKey(k)]; ok {
+				t.Errorf("step %d: %s header %s = %q, want absent", n, what, k, vs)
+			}
+		} else if g := strings.Join(got.Values(k), ", "); g != v {
+			t.Errorf("step %d: %s header %s = %q, want %q", n, what, k, g, v)
+		}
+	}
+}
+
+// serve responds to origin requests as scripted by the step in progress.
+func (h *harness) serve(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	now, step := h.now, h.step
+	h.mu.Unlock()
+	resp := step.Response
+	if resp == nil {
+		h.t.Errorf("unexpected origin request: %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Date", now.Format(http.TimeFormat))
+	for k, v := range resp.Header {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(cmp.Or(resp.Status, http.StatusOK))
+	_, _ = io.WriteString(w, resp.Body)
+}
+
+// upstream forwards requests to the origin, counting those of each step.
+// Requests made in the background, on a context other than that of the step,
+// are in flight until their context is done.
+type upstream struct {
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	ctx      context.Context // context of the step in progress
+	calls    int
+	last     http.Header
+	inflight []context.Context
+}
+
+func (u *upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	u.mu.Lock()
+	u.calls++
+	u.last = req.Header.Clone()
+	if ctx := req.Context(); ctx != u.ctx {
+		u.inflight = append(u.inflight, ctx)
+	}
+	u.mu.Unlock()
+	return u.next.RoundTrip(req)
+}
+
+func (u *upstream) begin(ctx context.Context) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.ctx, u.calls, u.last, u.inflight = ctx, 0, nil, nil
+}
+
+// waitTimeout bounds how long a step waits for background requests.
+const waitTimeout = 5 * time.Second
+
+// wait waits until at least want requests were made, and those made in the
+// background are done, or until waitTimeout elapses. It returns the number of
+// requests made, and the header of the last one.
+func (u *upstream) wait(want int) (int, http.Header) {
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		u.mu.Lock()
+		calls, last := u.calls, u.last
+		u.inflight = slices.DeleteFunc(u.inflight, func(ctx context.Context) bool {
+			return ctx.Err() != nil
+		})
+		done := calls >= want && len(u.inflight) == 0
+		u.mu.Unlock()
+		if done || time.Now().After(deadline) {
+			return calls, last
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/fscache"
+	"github.com/bartventer/httpcache/store/memcache"
+)
+
+func TestConformance_MemCache(t *testing.T) {
+	Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		return memcache.Open(), func() {}
+	}))
+}
+
+func TestConformance_FSCache(t *testing.T) {
+	Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		cache, err := fscache.Open("conformance", fscache.WithBaseDir(t.TempDir()))
+		testutil.RequireNoError(t, err)
+		return cache, func() { cache.Close() }
+	}))
+}
+
+func TestScenarios(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, s := range Scenarios() {
+		testutil.AssertTrue(t, !seen[s.Name], "duplicate scenario %q", s.Name)
+		seen[s.Name] = true
+		testutil.AssertTrue(t, s.Spec != "", "scenario %q has no spec reference", s.Name)
+		for i, step := range s.Steps {
+			testutil.AssertTrue(t, (step.Response == nil) == (step.Want.Upstream == 0),
+				"scenario %q, step %d: a response must be scripted iff the origin is contacted", s.Name, i+1)
+		}
+	}
+}
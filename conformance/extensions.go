@@ -0,0 +1,174 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"net/http"
+	"time"
+)
+
+// serverError is a step whose request reaches the origin, which fails, after
+// the clock advances by d; the stored response is served stale.
+func serverError(d time.Duration, header map[string]string) Step {
+	return Step{
+		Advance:  d,
+		Request:  Request{Header: header},
+		Response: &Response{Status: http.StatusInternalServerError, Body: "error"},
+		Want:     Want{CacheStatus: "STALE", Body: "v1", Upstream: 1},
+	}
+}
+
+var extensionScenarios = []Scenario{
+	{
+		Name: "stale-while-revalidate",
+		Spec: "RFC 5861 §3",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, stale-while-revalidate=60"}),
+			{
+				Advance:  90 * time.Second,
+				Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60"}, Body: "v2"},
+				Want:     Want{CacheStatus: "STALE", Body: "v1", Upstream: 1},
+			},
+			hit(0, "v2"),
+		},
+	},
+	{
+		Name: "stale-while-revalidate/not-modified",
+		Spec: "RFC 5861 §3",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, stale-while-revalidate=60", "ETag": `"v1"`}),
+			{
+				Advance:  90 * time.Second,
+				Response: &Response{Status: http.StatusNotModified},
+				Want: Want{
+					CacheStatus:  "STALE",
+					Body:         "v1",
+					Upstream:     1,
+					OriginHeader: map[string]string{"If-None-Match": `"v1"`},
+				},
+			},
+			hit(30*time.Second, "v1"),
+		},
+	},
+	{
+		Name: "stale-while-revalidate/variants",
+		Spec: "RFC 5861 §3",
+		Steps: []Step{
+			{
+				Request:  Request{Header: lang("en")},
+				Response: &Response{Header: map[string]string{"Cache-Control": "max-age=300", "Vary": "Accept-Language"}, Body: "en"},
+				Want:     Want{CacheStatus: "MISS", Body: "en", Upstream: 1},
+			},
+			{
+				Request:  Request{Header: lang("fr")},
+				Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60, stale-while-revalidate=60", "Vary": "Accept-Language"}, Body: "fr"},
+				Want:     Want{CacheStatus: "MISS", Body: "fr", Upstream: 1},
+			},
+			{
+				Advance:  90 * time.Second,
+				Request:  Request{Header: lang("fr")},
+				Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60", "Vary": "Accept-Language"}, Body: "fr2"},
+				Want:     Want{CacheStatus: "STALE", Body: "fr", Upstream: 1},
+			},
+			{Request: Request{Header: lang("fr")}, Want: Want{CacheStatus: "HIT", Body: "fr2"}},
+			{Request: Request{Header: lang("en")}, Want: Want{CacheStatus: "HIT", Body: "en"}},
+		},
+	},
+	{
+		Name: "stale-while-revalidate/expired",
+		Spec: "RFC 5861 §3",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, stale-while-revalidate=60"}),
+			refetched(2*time.Minute, nil),
+		},
+	},
+	{
+		Name: "stale-if-error",
+		Spec: "RFC 5861 §4",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, stale-if-error=60"}),
+			serverError(90*time.Second, nil),
+			refetched(0, nil),
+		},
+	},
+	{
+		Name: "stale-if-error/expired",
+		Spec: "RFC 5861 §4",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, stale-if-error=60"}),
+			{
+				Advance:  2*time.Minute + time.Second,
+				Response: &Response{Status: http.StatusInternalServerError, Body: "error"},
+				Want:     Want{Status: http.StatusInternalServerError, Body: "error", Upstream: 1},
+			},
+		},
+	},
+	{
+		Name: "stale-if-error/client-error",
+		Spec: "RFC 5861 §4",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, stale-if-error=60"}),
+			{
+				Advance:  90 * time.Second,
+				Response: &Response{Status: http.StatusNotFound, Body: "gone"},
+				Want:     Want{Status: http.StatusNotFound, Body: "gone", Upstream: 1},
+			},
+		},
+	},
+	{
+		Name: "stale-if-error/request",
+		Spec: "RFC 5861 §4",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60"}),
+			serverError(90*time.Second, map[string]string{"Cache-Control": "stale-if-error=60"}),
+		},
+	},
+	{
+		Name: "immutable",
+		Spec: "RFC 8246 §2",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, immutable"}),
+			hit(30*time.Second, "v1"),
+			refetched(30*time.Second, nil),
+		},
+	},
+	{
+		Name: "immutable/request-no-cache",
+		Spec: "RFC 8246 §2",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, immutable"}),
+			{
+				Request:  Request{Header: map[string]string{"Cache-Control": "no-cache"}},
+				Response: &Response{Body: "v2"},
+				Want:     Want{CacheStatus: "MISS", Body: "v2", Upstream: 1},
+			},
+		},
+	},
+	{
+		Name: "must-understand",
+		Spec: "RFC 9111 §5.2.2.3",
+		Steps: []Step{
+			{
+				Response: &Response{Status: 299, Header: map[string]string{"Cache-Control": "max-age=60, must-understand"}},
+				Want:     Want{Status: 299, CacheStatus: "MISS", Upstream: 1},
+			},
+			{
+				Response: &Response{Status: 299, Header: map[string]string{"Cache-Control": "max-age=60"}},
+				Want:     Want{Status: 299, CacheStatus: "MISS", Upstream: 1},
+			},
+			{Want: Want{Status: 299, CacheStatus: "HIT"}},
+		},
+	},
+}
@@ -0,0 +1,404 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"net/http"
+	"time"
+)
+
+// stored is the first step of most scenarios: a miss, whose response is
+// stored.
+func stored(header map[string]string) Step {
+	return Step{
+		Response: &Response{Header: header, Body: "v1"},
+		Want:     Want{CacheStatus: "MISS", Body: "v1", Upstream: 1},
+	}
+}
+
+// hit is a step served from the cache, after the clock advances by d.
+func hit(d time.Duration, body string) Step {
+	return Step{
+		Advance: d,
+		Want:    Want{CacheStatus: "HIT", Body: body},
+	}
+}
+
+// refetched is a step that reaches the origin, which responds with a new
+// value, after the clock advances by d.
+func refetched(d time.Duration, header map[string]string) Step {
+	return Step{
+		Advance:  d,
+		Response: &Response{Header: header, Body: "v2"},
+		Want:     Want{CacheStatus: "MISS", Body: "v2", Upstream: 1},
+	}
+}
+
+var freshnessScenarios = []Scenario{
+	{
+		Name: "freshness/max-age",
+		Spec: "RFC 9111 §5.2.2.1",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60"}),
+			{
+				Advance: 30 * time.Second,
+				Want:    Want{CacheStatus: "HIT", Body: "v1", Header: map[string]string{"Age": "30"}},
+			},
+			refetched(30*time.Second, map[string]string{"Cache-Control": "max-age=60"}),
+			hit(59*time.Second, "v2"),
+		},
+	},
+	{
+		Name: "freshness/max-age-zero",
+		Spec: "RFC 9111 §5.2.2.1",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=0"}),
+			refetched(0, nil),
+		},
+	},
+	{
+		Name: "freshness/age-header",
+		Spec: "RFC 9111 §4.2.3",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60", "Age": "50"}),
+			{
+				Advance: 5 * time.Second,
+				Want:    Want{CacheStatus: "HIT", Body: "v1", Header: map[string]string{"Age": "55"}},
+			},
+			refetched(5*time.Second, nil),
+		},
+	},
+	{
+		Name: "freshness/apparent-age",
+		Spec: "RFC 9111 §4.2.3",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60", "Date": Date(-30 * time.Second)}),
+			{
+				Advance: 20 * time.Second,
+				Want:    Want{CacheStatus: "HIT", Body: "v1", Header: map[string]string{"Age": "50"}},
+			},
+			refetched(10*time.Second, nil),
+		},
+	},
+	{
+		Name: "freshness/expires",
+		Spec: "RFC 9111 §5.3",
+		Steps: []Step{
+			stored(map[string]string{"Expires": Date(time.Minute)}),
+			hit(59*time.Second, "v1"),
+			refetched(time.Second, nil),
+		},
+	},
+	{
+		Name: "freshness/expires-relative-to-date",
+		Spec: "RFC 9111 §4.2.1",
+		Steps: []Step{
+			// The origin's clock is an hour ahead.
+			stored(map[string]string{"Date": Date(time.Hour), "Expires": Date(time.Hour + time.Minute)}),
+			hit(59*time.Second, "v1"),
+			refetched(time.Second, nil),
+		},
+	},
+	{
+		Name: "freshness/expires-invalid",
+		Spec: "RFC 9111 §5.3",
+		Steps: []Step{
+			stored(map[string]string{"Expires": "0"}),
+			refetched(time.Second, nil),
+		},
+	},
+	{
+		Name: "freshness/max-age-overrides-expires",
+		Spec: "RFC 9111 §5.3",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60", "Expires": Date(-time.Hour)}),
+			hit(30*time.Second, "v1"),
+		},
+	},
+	{
+		Name: "freshness/s-maxage-ignored",
+		Spec: "RFC 9111 §5.2.2.10",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "s-maxage=60"}),
+			refetched(time.Second, nil),
+		},
+	},
+	{
+		Name: "freshness/heuristic",
+		Spec: "RFC 9111 §4.2.2",
+		Steps: []Step{
+			stored(map[string]string{"Last-Modified": Date(-1000 * time.Second)}),
+			hit(99*time.Second, "v1"),
+			{
+				Advance:  time.Second,
+				Response: &Response{Status: http.StatusNotModified},
+				Want:     Want{CacheStatus: "REVALIDATED", Body: "v1", Upstream: 1},
+			},
+		},
+	},
+	{
+		Name: "freshness/heuristic-without-last-modified",
+		Spec: "RFC 9111 §4.2.2",
+		Steps: []Step{
+			stored(nil),
+			refetched(time.Second, nil),
+		},
+	},
+	{
+		Name: "freshness/heuristic-status-code",
+		Spec: "RFC 9111 §4.2.2",
+		Steps: []Step{
+			{
+				Response: &Response{Status: http.StatusNotFound, Header: map[string]string{"Last-Modified": Date(-1000 * time.Second)}},
+				Want:     Want{Status: http.StatusNotFound, CacheStatus: "MISS", Upstream: 1},
+			},
+			{
+				Advance: 10 * time.Second,
+				Want:    Want{Status: http.StatusNotFound, CacheStatus: "HIT"},
+			},
+		},
+	},
+	{
+		Name: "freshness/status-code-not-heuristically-cacheable",
+		Spec: "RFC 9111 §3",
+		Steps: []Step{
+			{
+				Response: &Response{Status: http.StatusCreated, Header: map[string]string{"Last-Modified": Date(-1000 * time.Second)}},
+				Want:     Want{Status: http.StatusCreated, CacheStatus: "MISS", Upstream: 1},
+			},
+			refetched(time.Second, nil),
+		},
+	},
+	{
+		Name: "freshness/explicit-status-code",
+		Spec: "RFC 9111 §3",
+		Steps: []Step{
+			{
+				Response: &Response{Status: http.StatusCreated, Header: map[string]string{"Cache-Control": "max-age=60"}},
+				Want:     Want{Status: http.StatusCreated, CacheStatus: "MISS", Upstream: 1},
+			},
+			{
+				Advance: 10 * time.Second,
+				Want:    Want{Status: http.StatusCreated, CacheStatus: "HIT"},
+			},
+		},
+	},
+	{
+		Name: "freshness/no-store",
+		Spec: "RFC 9111 §5.2.2.5",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, no-store"}),
+			refetched(0, nil),
+		},
+	},
+	{
+		Name: "freshness/private",
+		Spec: "RFC 9111 §5.2.2.7",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, private"}),
+			hit(30*time.Second, "v1"),
+		},
+	},
+	{
+		Name: "freshness/authorization",
+		Spec: "RFC 9111 §3.5",
+		Steps: []Step{
+			{
+				Request:  Request{Header: map[string]string{"Authorization": "Bearer token"}},
+				Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60"}, Body: "v1"},
+				Want:     Want{CacheStatus: "MISS", Body: "v1", Upstream: 1},
+			},
+			{
+				Request: Request{Header: map[string]string{"Authorization": "Bearer token"}},
+				Want:    Want{CacheStatus: "HIT", Body: "v1"},
+			},
+		},
+	},
+	{
+		Name: "freshness/query",
+		Spec: "RFC 9111 §2",
+		Steps: []Step{
+			{
+				Request:  Request{Path: "/?q=1"},
+				Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60"}, Body: "v1"},
+				Want:     Want{CacheStatus: "MISS", Body: "v1", Upstream: 1},
+			},
+			{
+				Request:  Request{Path: "/?q=2"},
+				Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60"}, Body: "v2"},
+				Want:     Want{CacheStatus: "MISS", Body: "v2", Upstream: 1},
+			},
+			{
+				Request: Request{Path: "/?q=1"},
+				Want:    Want{CacheStatus: "HIT", Body: "v1"},
+			},
+		},
+	},
+	{
+		Name: "freshness/head-bypasses-cache",
+		Spec: "RFC 9111 §4",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60"}),
+			{
+				Request:  Request{Method: http.MethodHead},
+				Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60"}},
+				Want:     Want{CacheStatus: "BYPASS", Upstream: 1},
+			},
+			hit(0, "v1"),
+		},
+	},
+	{
+		Name: "freshness/range-bypasses-cache",
+		Spec: "RFC 9111 §3.3",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60"}),
+			{
+				Request:  Request{Header: map[string]string{"Range": "bytes=0-0"}},
+				Response: &Response{Status: http.StatusPartialContent, Header: map[string]string{"Content-Range": "bytes 0-0/2"}, Body: "v"},
+				Want:     Want{Status: http.StatusPartialContent, CacheStatus: "BYPASS", Body: "v", Upstream: 1},
+			},
+			hit(0, "v1"),
+		},
+	},
+}
+
+var requestDirectiveScenarios = []Scenario{
+	{
+		Name: "request/no-cache",
+		Spec: "RFC 9111 §5.2.1.4",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60"}),
+			{
+				Request:  Request{Header: map[string]string{"Cache-Control": "no-cache"}},
+				Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60"}, Body: "v2"},
+				Want:     Want{CacheStatus: "MISS", Body: "v2", Upstream: 1},
+			},
+			hit(0, "v2"),
+		},
+	},
+	{
+		Name: "request/pragma-no-cache",
+		Spec: "RFC 9111 §5.4",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60"}),
+			{
+				Request: Request{Header: map[string]string{"Pragma": "no-cache"}},
+				Want:    Want{CacheStatus: "HIT", Body: "v1"},
+			},
+		},
+	},
+	{
+		Name: "request/max-age-zero",
+		Spec: "RFC 9111 §5.2.1.1",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60"}),
+			{
+				Request:  Request{Header: map[string]string{"Cache-Control": "max-age=0"}},
+				Response: &Response{Body: "v2"},
+				Want:     Want{CacheStatus: "MISS", Body: "v2", Upstream: 1},
+			},
+		},
+	},
+	{
+		Name: "request/max-age",
+		Spec: "RFC 9111 §5.2.1.1",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=300"}),
+			{
+				Advance: 30 * time.Second,
+				Request: Request{Header: map[string]string{"Cache-Control": "max-age=60"}},
+				Want:    Want{CacheStatus: "HIT", Body: "v1"},
+			},
+			{
+				Advance:  60 * time.Second,
+				Request:  Request{Header: map[string]string{"Cache-Control": "max-age=60"}},
+				Response: &Response{Body: "v2"},
+				Want:     Want{CacheStatus: "MISS", Body: "v2", Upstream: 1},
+			},
+		},
+	},
+	{
+		Name: "request/min-fresh",
+		Spec: "RFC 9111 §5.2.1.3",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=100"}),
+			{
+				Advance: 50 * time.Second,
+				Request: Request{Header: map[string]string{"Cache-Control": "min-fresh=30"}},
+				Want:    Want{CacheStatus: "HIT", Body: "v1"},
+			},
+			{
+				Request:  Request{Header: map[string]string{"Cache-Control": "min-fresh=60"}},
+				Response: &Response{Body: "v2"},
+				Want:     Want{CacheStatus: "MISS", Body: "v2", Upstream: 1},
+			},
+		},
+	},
+	{
+		Name: "request/max-stale",
+		Spec: "RFC 9111 §5.2.1.2",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60"}),
+			{
+				Advance: 90 * time.Second,
+				Request: Request{Header: map[string]string{"Cache-Control": "max-stale=60"}},
+				Want:    Want{CacheStatus: "HIT", Body: "v1", Header: map[string]string{"Age": "90"}},
+			},
+			{
+				Advance: time.Hour,
+				Request: Request{Header: map[string]string{"Cache-Control": "max-stale"}},
+				Want:    Want{CacheStatus: "HIT", Body: "v1"},
+			},
+			{
+				Request:  Request{Header: map[string]string{"Cache-Control": "max-stale=60"}},
+				Response: &Response{Body: "v2"},
+				Want:     Want{CacheStatus: "MISS", Body: "v2", Upstream: 1},
+			},
+		},
+	},
+	{
+		Name: "request/no-store",
+		Spec: "RFC 9111 §5.2.1.5",
+		Steps: []Step{
+			{
+				Request:  Request{Header: map[string]string{"Cache-Control": "no-store"}},
+				Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60"}, Body: "v1"},
+				Want:     Want{CacheStatus: "MISS", Body: "v1", Upstream: 1},
+			},
+			refetched(0, nil),
+		},
+	},
+	{
+		Name: "request/only-if-cached-miss",
+		Spec: "RFC 9111 §5.2.1.7",
+		Steps: []Step{
+			{
+				Request: Request{Header: map[string]string{"Cache-Control": "only-if-cached"}},
+				Want:    Want{Status: http.StatusGatewayTimeout},
+			},
+		},
+	},
+	{
+		Name: "request/only-if-cached-hit",
+		Spec: "RFC 9111 §5.2.1.7",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60"}),
+			{
+				Request: Request{Header: map[string]string{"Cache-Control": "only-if-cached"}},
+				Want:    Want{CacheStatus: "HIT", Body: "v1"},
+			},
+		},
+	},
+}
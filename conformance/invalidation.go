@@ -0,0 +1,144 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"net/http"
+)
+
+// storedAt is [stored], for the resource at path.
+func storedAt(path string) Step {
+	s := stored(map[string]string{"Cache-Control": "max-age=60"})
+	s.Request.Path = path
+	return s
+}
+
+// hitAt is [hit], for the resource at path.
+func hitAt(path string) Step {
+	s := hit(0, "v1")
+	s.Request.Path = path
+	return s
+}
+
+// refetchedAt is [refetched], for the resource at path.
+func refetchedAt(path string) Step {
+	s := refetched(0, nil)
+	s.Request.Path = path
+	return s
+}
+
+// unsafe is a step sending an unsafe request to path, to which the origin
+// responds with status and header.
+func unsafe(method, path string, status int, header map[string]string) Step {
+	return Step{
+		Request:  Request{Method: method, Path: path},
+		Response: &Response{Status: status, Header: header},
+		Want:     Want{Status: status, CacheStatus: "BYPASS", Upstream: 1},
+	}
+}
+
+// invalidationScenarios checks that each unsafe method invalidates the
+// target URI, along with those of the Location and Content-Location fields
+// on the same origin.
+var invalidationScenarios = func() []Scenario {
+	var scenarios []Scenario
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch} {
+		scenarios = append(scenarios, Scenario{
+			Name: "invalidation/" + method,
+			Spec: "RFC 9111 §4.4",
+			Steps: []Step{
+				storedAt("/a"),
+				unsafe(method, "/a", http.StatusOK, nil),
+				refetchedAt("/a"),
+			},
+		})
+	}
+	return append(scenarios, []Scenario{
+		{
+			Name: "invalidation/location",
+			Spec: "RFC 9111 §4.4",
+			Steps: []Step{
+				storedAt("/a"),
+				storedAt("/b"),
+				unsafe(http.MethodPost, "/b", http.StatusCreated, map[string]string{"Location": "/a"}),
+				refetchedAt("/a"),
+				refetchedAt("/b"),
+			},
+		},
+		{
+			Name: "invalidation/content-location",
+			Spec: "RFC 9111 §4.4",
+			Steps: []Step{
+				storedAt("/a"),
+				unsafe(http.MethodPut, "/b", http.StatusOK, map[string]string{"Content-Location": "/a"}),
+				refetchedAt("/a"),
+			},
+		},
+		{
+			Name: "invalidation/location-other-origin",
+			Spec: "RFC 9111 §4.4",
+			Steps: []Step{
+				storedAt("/a"),
+				unsafe(http.MethodPost, "/b", http.StatusCreated, map[string]string{"Location": "http://example.com/a"}),
+				hitAt("/a"),
+			},
+		},
+		{
+			Name: "invalidation/error-status",
+			Spec: "RFC 9111 §4.4",
+			Steps: []Step{
+				storedAt("/a"),
+				unsafe(http.MethodPost, "/a", http.StatusInternalServerError, nil),
+				hitAt("/a"),
+			},
+		},
+		{
+			Name: "invalidation/other-resource",
+			Spec: "RFC 9111 §4.4",
+			Steps: []Step{
+				storedAt("/a"),
+				unsafe(http.MethodPost, "/b", http.StatusOK, nil),
+				hitAt("/a"),
+			},
+		},
+		{
+			Name: "invalidation/all-variants",
+			Spec: "RFC 9111 §4.4",
+			Steps: []Step{
+				{
+					Request:  Request{Header: map[string]string{"Accept-Language": "en"}},
+					Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60", "Vary": "Accept-Language"}, Body: "en"},
+					Want:     Want{CacheStatus: "MISS", Body: "en", Upstream: 1},
+				},
+				{
+					Request:  Request{Header: map[string]string{"Accept-Language": "fr"}},
+					Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60", "Vary": "Accept-Language"}, Body: "fr"},
+					Want:     Want{CacheStatus: "MISS", Body: "fr", Upstream: 1},
+				},
+				unsafe(http.MethodPost, "/", http.StatusOK, nil),
+				{
+					Request:  Request{Header: map[string]string{"Accept-Language": "en"}},
+					Response: &Response{Header: map[string]string{"Vary": "Accept-Language"}, Body: "en2"},
+					Want:     Want{CacheStatus: "MISS", Body: "en2", Upstream: 1},
+				},
+				{
+					Request:  Request{Header: map[string]string{"Accept-Language": "fr"}},
+					Response: &Response{Header: map[string]string{"Vary": "Accept-Language"}, Body: "fr2"},
+					Want:     Want{CacheStatus: "MISS", Body: "fr2", Upstream: 1},
+				},
+			},
+		},
+	}...)
+}()
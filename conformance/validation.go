@@ -0,0 +1,249 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"net/http"
+	"time"
+)
+
+// notModified is a step that revalidates the stored response, after the clock
+// advances by d; the origin responds with 304 Not Modified and header.
+func notModified(d time.Duration, header map[string]string) Step {
+	return Step{
+		Advance:  d,
+		Response: &Response{Status: http.StatusNotModified, Header: header},
+		Want:     Want{CacheStatus: "REVALIDATED", Body: "v1", Upstream: 1},
+	}
+}
+
+var validationScenarios = []Scenario{
+	{
+		Name: "validation/etag",
+		Spec: "RFC 9111 §4.3.1",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60", "ETag": `"v1"`}),
+			{
+				Advance:  time.Minute,
+				Response: &Response{Status: http.StatusNotModified, Header: map[string]string{"ETag": `"v1"`}},
+				Want: Want{
+					CacheStatus:  "REVALIDATED",
+					Body:         "v1",
+					Upstream:     1,
+					OriginHeader: map[string]string{"If-None-Match": `"v1"`, "If-Modified-Since": ""},
+				},
+			},
+			hit(30*time.Second, "v1"),
+		},
+	},
+	{
+		Name: "validation/weak-etag",
+		Spec: "RFC 9111 §4.3.1",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60", "ETag": `W/"v1"`}),
+			{
+				Advance:  time.Minute,
+				Response: &Response{Status: http.StatusNotModified},
+				Want: Want{
+					CacheStatus:  "REVALIDATED",
+					Body:         "v1",
+					Upstream:     1,
+					OriginHeader: map[string]string{"If-None-Match": `W/"v1"`},
+				},
+			},
+		},
+	},
+	{
+		Name: "validation/last-modified",
+		Spec: "RFC 9111 §4.3.1",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60", "Last-Modified": Date(-time.Hour)}),
+			{
+				Advance:  time.Minute,
+				Response: &Response{Status: http.StatusNotModified},
+				Want: Want{
+					CacheStatus:  "REVALIDATED",
+					Body:         "v1",
+					Upstream:     1,
+					OriginHeader: map[string]string{"If-Modified-Since": Date(-time.Hour), "If-None-Match": ""},
+				},
+			},
+		},
+	},
+	{
+		Name: "validation/etag-and-last-modified",
+		Spec: "RFC 9111 §4.3.1",
+		Steps: []Step{
+			stored(map[string]string{
+				"Cache-Control": "max-age=60",
+				"ETag":          `"v1"`,
+				"Last-Modified": Date(-time.Hour),
+			}),
+			{
+				Advance:  time.Minute,
+				Response: &Response{Status: http.StatusNotModified},
+				Want: Want{
+					CacheStatus:  "REVALIDATED",
+					Body:         "v1",
+					Upstream:     1,
+					OriginHeader: map[string]string{"If-None-Match": `"v1"`, "If-Modified-Since": Date(-time.Hour)},
+				},
+			},
+		},
+	},
+	{
+		Name: "validation/no-validator",
+		Spec: "RFC 9111 §4.3.1",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60"}),
+			{
+				Advance:  time.Minute,
+				Response: &Response{Body: "v2"},
+				Want: Want{
+					CacheStatus:  "MISS",
+					Body:         "v2",
+					Upstream:     1,
+					OriginHeader: map[string]string{"If-None-Match": "", "If-Modified-Since": ""},
+				},
+			},
+		},
+	},
+	{
+		Name: "validation/full-response",
+		Spec: "RFC 9111 §4.3.3",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60", "ETag": `"v1"`}),
+			refetched(time.Minute, map[string]string{"Cache-Control": "max-age=60", "ETag": `"v2"`}),
+			hit(30*time.Second, "v2"),
+			{
+				Advance:  30 * time.Second,
+				Response: &Response{Status: http.StatusNotModified},
+				Want: Want{
+					CacheStatus:  "REVALIDATED",
+					Body:         "v2",
+					Upstream:     1,
+					OriginHeader: map[string]string{"If-None-Match": `"v2"`},
+				},
+			},
+		},
+	},
+	{
+		Name: "validation/freshening",
+		Spec: "RFC 9111 §4.3.4",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60", "ETag": `"v1"`, "X-Version": "1"}),
+			{
+				Advance: time.Minute,
+				Response: &Response{Status: http.StatusNotModified, Header: map[string]string{
+					"Cache-Control": "max-age=120",
+					"ETag":          `"v1"`,
+					"X-Version":     "2",
+				}},
+				Want: Want{
+					CacheStatus: "REVALIDATED",
+					Body:        "v1",
+					Upstream:    1,
+					Header:      map[string]string{"X-Version": "2"},
+				},
+			},
+			{
+				Advance: 90 * time.Second,
+				Want: Want{
+					CacheStatus: "HIT",
+					Body:        "v1",
+					Header:      map[string]string{"X-Version": "2", "Cache-Control": "max-age=120"},
+				},
+			},
+		},
+	},
+	{
+		Name: "validation/freshening-keeps-content-length",
+		Spec: "RFC 9111 §3.2",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60", "ETag": `"v1"`}),
+			{
+				Advance:  time.Minute,
+				Response: &Response{Status: http.StatusNotModified, Header: map[string]string{"Content-Length": "0"}},
+				Want: Want{
+					CacheStatus: "REVALIDATED",
+					Body:        "v1",
+					Upstream:    1,
+					Header:      map[string]string{"Content-Length": "2"},
+				},
+			},
+		},
+	},
+	{
+		Name: "validation/no-cache",
+		Spec: "RFC 9111 §5.2.2.4",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, no-cache", "ETag": `"v1"`}),
+			notModified(0, nil),
+			notModified(time.Second, nil),
+		},
+	},
+	{
+		Name: "validation/no-cache-qualified",
+		Spec: "RFC 9111 §5.2.2.4",
+		Steps: []Step{
+			stored(map[string]string{
+				"Cache-Control": `max-age=60, no-cache="X-Secret"`,
+				"X-Secret":      "s3cr3t",
+				"X-Public":      "p",
+			}),
+			{
+				Advance: time.Second,
+				Want: Want{
+					CacheStatus: "HIT",
+					Body:        "v1",
+					Header:      map[string]string{"X-Secret": "", "X-Public": "p"},
+				},
+			},
+		},
+	},
+	{
+		Name: "validation/must-revalidate",
+		Spec: "RFC 9111 §5.2.2.2",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, must-revalidate", "ETag": `"v1"`}),
+			hit(30*time.Second, "v1"),
+			notModified(30*time.Second, nil),
+		},
+	},
+	{
+		Name: "validation/must-revalidate-error",
+		Spec: "RFC 9111 §5.2.2.2",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60, must-revalidate, stale-if-error=60"}),
+			{
+				Advance:  90 * time.Second,
+				Response: &Response{Status: http.StatusInternalServerError, Body: "error"},
+				Want:     Want{Status: http.StatusInternalServerError, Body: "error", Upstream: 1},
+			},
+		},
+	},
+	{
+		Name: "validation/server-error",
+		Spec: "RFC 9111 §4.3.3",
+		Steps: []Step{
+			stored(map[string]string{"Cache-Control": "max-age=60", "ETag": `"v1"`}),
+			{
+				Advance:  time.Minute,
+				Response: &Response{Status: http.StatusServiceUnavailable, Body: "error"},
+				Want:     Want{Status: http.StatusServiceUnavailable, Body: "error", Upstream: 1},
+			},
+		},
+	},
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+// variant is a step requesting the variant selected by header. If body is
+// empty, the variant is expected to be served from the cache; otherwise, it
+// is fetched from the origin, which responds with body, varying on vary.
+func variant(header map[string]string, vary, body string) Step {
+	if body == "" {
+		return Step{
+			Request: Request{Header: header},
+			Want:    Want{CacheStatus: "HIT"},
+		}
+	}
+	return Step{
+		Request:  Request{Header: header},
+		Response: &Response{Header: map[string]string{"Cache-Control": "max-age=60", "Vary": vary}, Body: body},
+		Want:     Want{CacheStatus: "MISS", Body: body, Upstream: 1},
+	}
+}
+
+func lang(v string) map[string]string { return map[string]string{"Accept-Language": v} }
+
+var varyScenarios = []Scenario{
+	{
+		Name: "vary/match",
+		Spec: "RFC 9111 §4.1",
+		Steps: []Step{
+			variant(lang("en"), "Accept-Language", "en"),
+			variant(lang("en"), "", ""),
+		},
+	},
+	{
+		Name: "vary/variants",
+		Spec: "RFC 9111 §4.1",
+		Steps: []Step{
+			variant(lang("en"), "Accept-Language", "en"),
+			variant(lang("fr"), "Accept-Language", "fr"),
+			{Request: Request{Header: lang("en")}, Want: Want{CacheStatus: "HIT", Body: "en"}},
+			{Request: Request{Header: lang("fr")}, Want: Want{CacheStatus: "HIT", Body: "fr"}},
+		},
+	},
+	{
+		Name: "vary/absent-field",
+		Spec: "RFC 9111 §4.1",
+		Steps: []Step{
+			variant(nil, "Accept-Language", "none"),
+			variant(lang("en"), "Accept-Language", "en"),
+			{Want: Want{CacheStatus: "HIT", Body: "none"}},
+		},
+	},
+	{
+		Name: "vary/field-name-case",
+		Spec: "RFC 9111 §4.1",
+		Steps: []Step{
+			variant(lang("en"), "accept-language", "en"),
+			variant(lang("en"), "", ""),
+			variant(lang("fr"), "accept-language", "fr"),
+		},
+	},
+	{
+		Name: "vary/multiple-fields",
+		Spec: "RFC 9111 §4.1",
+		Steps: []Step{
+			variant(map[string]string{"Accept-Language": "en", "X-Device": "mobile"}, "Accept-Language, X-Device", "en-mobile"),
+			variant(map[string]string{"Accept-Language": "en", "X-Device": "mobile"}, "", ""),
+			variant(map[string]string{"Accept-Language": "en", "X-Device": "desktop"}, "Accept-Language, X-Device", "en-desktop"),
+			variant(map[string]string{"Accept-Language": "fr", "X-Device": "mobile"}, "Accept-Language, X-Device", "fr-mobile"),
+		},
+	},
+	{
+		Name: "vary/normalized-values",
+		Spec: "RFC 9111 §4.1",
+		Steps: []Step{
+			variant(lang("en, fr;q=0.8"), "Accept-Language", "en"),
+			variant(lang("en,fr;q=0.8"), "", ""),
+			variant(lang("fr;q=0.8, en"), "", ""),
+		},
+	},
+	{
+		Name: "vary/star",
+		Spec: "RFC 9111 §4.1",
+		Steps: []Step{
+			variant(nil, "*", "v1"),
+			variant(nil, "*", "v2"),
+		},
+	},
+	{
+		Name: "vary/unrelated-field",
+		Spec: "RFC 9111 §4.1",
+		Steps: []Step{
+			variant(lang("en"), "Accept-Language", "en"),
+			variant(map[string]string{"Accept-Language": "en", "X-Other": "1"}, "", ""),
+		},
+	},
+}
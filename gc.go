@@ -0,0 +1,93 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bartventer/httpcache/internal"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+// ErrKeyListingUnsupported is returned by [GC] when the cache backend does not
+// implement [expapi.KeyLister].
+//
+// [expapi.KeyLister]: https://pkg.go.dev/github.com/bartventer/httpcache/store/expapi#KeyLister
+var ErrKeyListingUnsupported = internal.ErrKeyListingUnsupported
+
+// ErrInvalidGCInterval is passed to the callback of [RunGC] when the interval
+// is not positive.
+var ErrInvalidGCInterval = errors.New("httpcache: GC interval must be positive")
+
+// GCReport describes the repairs made by a single [GC] pass.
+type GCReport = internal.GCReport
+
+// GC finds and repairs stored entries that have drifted out of sync, and
+// returns a report of what it did. It removes:
+//
+//   - response entries not referenced by any refs document;
+//   - refs documents that cannot be decoded, or have no variants left;
+//   - references to response entries that are missing or cannot be decoded,
+//     rewriting the refs document that holds them.
+//
+// Keys not generated by httpcache are left untouched, and listed in the
+// report as unrecognized.
+//
+// The cache backend must implement [expapi.KeyLister]; otherwise GC returns
+// [ErrKeyListingUnsupported]. Backend errors abort the pass, and are returned
+// along with the partial report.
+//
+// [expapi.KeyLister]: https://pkg.go.dev/github.com/bartventer/httpcache/store/expapi#KeyLister
+func GC(ctx context.Context, conn driver.Conn) (*GCReport, error) {
+	return internal.NewGarbageCollector(conn).Collect(ctx)
+}
+
+// RunGC calls [GC] every interval until ctx is done, passing the outcome of
+// each pass to fn, which may be nil. If interval is not positive, it passes
+// [ErrInvalidGCInterval] to fn and returns immediately. It blocks, so it is
+// typically started in its own goroutine:
+//
+//	go httpcache.RunGC(ctx, conn, time.Hour, func(r *httpcache.GCReport, err error) {
+//		logger.Info("Cache GC complete.", slog.Any("report", r), slog.Any("error", err))
+//	})
+func RunGC(
+	ctx context.Context,
+	conn driver.Conn,
+	interval time.Duration,
+	fn func(*GCReport, error),
+) {
+	if interval <= 0 {
+		if fn != nil {
+			fn(nil, ErrInvalidGCInterval)
+		}
+		return
+	}
+	gc := internal.NewGarbageCollector(conn)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := gc.Collect(ctx)
+			if fn != nil {
+				fn(report, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+// listingCache is an in-memory [driver.Conn] that also lists its keys.
+type listingCache struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+func newListingCache() *listingCache {
+	return &listingCache{m: make(map[string][]byte)}
+}
+
+func (c *listingCache) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.m[key]
+	if !ok {
+		return nil, driver.ErrNotExist
+	}
+	return v, nil
+}
+
+func (c *listingCache) Set(key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[key] = value
+	return nil
+}
+
+func (c *listingCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.m[key]; !ok {
+		return driver.ErrNotExist
+	}
+	delete(c.m, key)
+	return nil
+}
+
+func (c *listingCache) Keys(prefix string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for k := range c.m {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func TestGC(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	conn := newListingCache()
+	client := &http.Client{Transport: newTransport(conn)}
+	for _, path := range []string{"/a", "/b"} {
+		resp, err := client.Get(server.URL + path)
+		testutil.RequireNoError(t, err)
+		resp.Body.Close()
+	}
+
+	report, err := GC(context.Background(), conn)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 4, report.Scanned)
+	testutil.AssertEqual(t, 0, len(report.OrphanedResponses))
+
+	// Simulate a failed SetRefs by dropping the refs document for /b.
+	testutil.RequireNoError(t, conn.Delete(server.URL+"/b"))
+	report, err = GC(context.Background(), conn)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 1, len(report.OrphanedResponses))
+	keys, _ := conn.Keys("")
+	testutil.AssertEqual(t, 2, len(keys))
+}
+
+func TestRunGC(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunGC(ctx, newListingCache(), time.Millisecond, func(r *GCReport, err error) {
+			testutil.AssertNil(t, err)
+			cancel()
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunGC did not return after context was canceled")
+	}
+}
+
+func TestRunGC_InvalidInterval(t *testing.T) {
+	var got error
+	RunGC(context.Background(), newListingCache(), 0, func(r *GCReport, err error) {
+		got = err
+	})
+	testutil.RequireErrorIs(t, got, ErrInvalidGCInterval)
+}
@@ -15,6 +15,7 @@
 package internal
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 )
@@ -25,7 +26,7 @@ import (
 // entries for URIs in Location or Content-Location headers, but only if they
 // share the same origin as the target URI.
 type CacheInvalidator interface {
-	InvalidateCache(reqURL *url.URL, respHeader http.Header, refs ResponseRefs, key string)
+	InvalidateCache(ctx context.Context, reqURL *url.URL, respHeader http.Header, refs ResponseRefs, key string)
 }
 
 type cacheInvalidator struct {
@@ -38,31 +39,35 @@ func NewCacheInvalidator(cache ResponseCache, cke URLKeyer) *cacheInvalidator {
 }
 
 func (r *cacheInvalidator) InvalidateCache(
+	ctx context.Context,
 	reqURL *url.URL,
 	respHeader http.Header,
 	refs ResponseRefs,
 	key string,
 ) {
-	deleted := map[string]struct{}{}
-	del := func(k string) {
-		if _, ok := deleted[k]; !ok {
-			_ = r.cache.Delete(k)
-			deleted[k] = struct{}{}
+	var keys []string
+	seen := map[string]struct{}{}
+	add := func(k string) {
+		if _, ok := seen[k]; !ok {
+			keys = append(keys, k)
+			seen[k] = struct{}{}
 		}
 	}
 	for h := range refs.ResponseIDs() {
-		del(h)
+		add(h)
 	}
-	r.invalidateLocationHeaders(reqURL, respHeader, del)
-	del(key)
+	r.invalidateLocationHeaders(ctx, reqURL, respHeader, add)
+	add(key)
+	_ = r.cache.DeleteMulti(ctx, keys)
 }
 
 var locationHeaders = [...]string{"Location", "Content-Location"}
 
 func (r *cacheInvalidator) invalidateLocationHeaders(
+	ctx context.Context,
 	reqURL *url.URL,
 	respHeader http.Header,
-	deleteFn func(string),
+	addFn func(string),
 ) {
 	for _, hdr := range locationHeaders {
 		loc := respHeader.Get(hdr)
@@ -76,11 +81,11 @@ func (r *cacheInvalidator) invalidateLocationHeaders(
 		locURL = reqURL.ResolveReference(locURL)
 		if sameOrigin(reqURL, locURL) {
 			urlKey := r.cke.URLKey(locURL)
-			refs, _ := r.cache.GetRefs(urlKey)
+			refs, _ := r.cache.GetRefs(ctx, urlKey)
 			for h := range refs.ResponseIDs() {
-				deleteFn(h)
+				addFn(h)
 			}
-			deleteFn(urlKey)
+			addFn(urlKey)
 		}
 	}
 }
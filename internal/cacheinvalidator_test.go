@@ -96,13 +96,15 @@ func Test_cacheInvalidator_InvalidateCache(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			deleted := []string{}
+			batches := 0
 			refs := tt.refs
 			if refs == nil {
 				refs = map[string]ResponseRefs{}
 			}
 			mrc := &MockResponseCache{
-				DeleteFunc: func(key string) error {
-					deleted = append(deleted, key)
+				DeleteMultiFunc: func(keys []string) error {
+					deleted = append(deleted, keys...)
+					batches++
 					return nil
 				},
 				GetRefsFunc: func(key string) (ResponseRefs, error) {
@@ -116,12 +118,15 @@ func Test_cacheInvalidator_InvalidateCache(t *testing.T) {
 			ci := &cacheInvalidator{cache: mrc, cke: URLKeyerFunc(func(u *url.URL) string {
 				return tt.keyerKey
 			})}
-			ci.InvalidateCache(tt.reqURL, respHeader, tt.headers, "main")
+			ci.InvalidateCache(t.Context(), tt.reqURL, respHeader, tt.headers, "main")
 			slices.Sort(deleted)
 			slices.Sort(tt.expectDelete)
 			if !slices.Equal(deleted, tt.expectDelete) {
 				t.Errorf("expected deleted keys %v, got %v", tt.expectDelete, deleted)
 			}
+			if batches != 1 {
+				t.Errorf("expected keys deleted in 1 batch, got %d", batches)
+			}
 		})
 	}
 }
@@ -31,6 +31,12 @@ func NewClock() *clock { return &clock{} }
 func (c clock) Since(t time.Time) time.Duration { return time.Since(t) }
 func (c clock) Now() time.Time                  { return time.Now() }
 
+// ClockFunc adapts a function that reports the current time to a [Clock].
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Since(t time.Time) time.Duration { return f().Sub(t) }
+func (f ClockFunc) Now() time.Time                  { return f() }
+
 // FixDateHeader sets the "Date" header to the current time in UTC if it is
 // missing or empty, as per RFC 9110 §6.6.1, and reports whether it was changed.
 //
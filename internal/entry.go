@@ -120,7 +120,12 @@ var (
 // ParseResponse parses a cached HTTP response entry from binary data and reconstructs
 // a [Response] using the provided request for context. Returns an error if parsing fails.
 func ParseResponse(data []byte, req *http.Request) (resp *Response, err error) {
-	reader := bufio.NewReader(bytes.NewReader(data))
+	return ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+}
+
+// ReadResponse is like [ParseResponse], but reads the entry from reader. The
+// body of the returned response reads from reader as it is consumed.
+func ReadResponse(reader *bufio.Reader, req *http.Request) (resp *Response, err error) {
 	metaLine, err := reader.ReadBytes('\n')
 	if err != nil {
 		return nil, errors.Join(errReadBytes, fmt.Errorf("failed to read metadata line: %w", err))
@@ -145,10 +150,11 @@ func ParseResponse(data []byte, req *http.Request) (resp *Response, err error) {
 
 // ResponseRef represents a reference to a cached HTTP response.
 type ResponseRef struct {
-	ResponseID   string            `json:"id"`                   // unique identifier for the response entry.
-	Vary         string            `json:"vary"`                 // value of the Vary response header.
-	VaryResolved map[string]string `json:"vary_resolved"`        // resolved varying request headers, keys are canonicalized.
-	ReceivedAt   time.Time         `json:"received_at,omitzero"` // when the response was generated.
+	ResponseID   string            `json:"id"`                    // unique identifier for the response entry.
+	Vary         string            `json:"vary"`                  // value of the Vary response header.
+	VaryResolved map[string]string `json:"vary_resolved"`         // resolved varying request headers, keys are canonicalized.
+	ReceivedAt   time.Time         `json:"received_at,omitzero"`  // when the response was generated.
+	RetainUntil  time.Time         `json:"retain_until,omitzero"` // when the response may be dropped; zero if never.
 }
 
 var _ slog.LogValuer = (*ResponseRef)(nil)
@@ -159,11 +165,33 @@ func (r ResponseRef) LogValue() slog.Value {
 		slog.String("vary", r.Vary),
 		slog.Any("vary_resolved", r.VaryResolved),
 		slog.Time("received_at", r.ReceivedAt),
+		slog.Time("retain_until", r.RetainUntil),
 	)
 }
 
 type ResponseRefs []*ResponseRef
 
+// TTL returns how long, from now, the refs must be retained to outlive every
+// response they reference, or zero if one of them is retained indefinitely.
+func (he ResponseRefs) TTL(now time.Time) time.Duration {
+	var latest time.Time
+	for _, ref := range he {
+		if ref == nil {
+			continue
+		}
+		if ref.RetainUntil.IsZero() {
+			return 0
+		}
+		if ref.RetainUntil.After(latest) {
+			latest = ref.RetainUntil
+		}
+	}
+	if latest.IsZero() {
+		return 0
+	}
+	return max(latest.Sub(now), 1)
+}
+
 func (he ResponseRefs) ResponseIDs() iter.Seq[string] {
 	return func(yield func(string) bool) {
 		for _, entry := range he {
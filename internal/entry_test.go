@@ -188,3 +188,29 @@ func TestParseHTTPDateCompat(t *testing.T) {
 		testutil.AssertTrue(t, found && valid)
 	})
 }
+
+func TestResponseRefs_TTL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		refs ResponseRefs
+		want time.Duration
+	}{
+		{"no refs", nil, 0},
+		{"longest lived variant", ResponseRefs{
+			{RetainUntil: now.Add(time.Minute)},
+			nil,
+			{RetainUntil: now.Add(time.Hour)},
+		}, time.Hour},
+		{"variant retained indefinitely", ResponseRefs{
+			{RetainUntil: now.Add(time.Minute)},
+			{},
+		}, 0},
+		{"all expired", ResponseRefs{{RetainUntil: now.Add(-time.Minute)}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.AssertEqual(t, tt.want, tt.refs.TTL(now))
+		})
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+)
+
+// ErrKeyListingUnsupported is returned by [GarbageCollector.Collect] when the
+// underlying cache does not implement [expapi.KeyLister].
+var ErrKeyListingUnsupported = errors.New("httpcache: cache does not support listing keys")
+
+// GCReport describes the repairs made by a single garbage collection pass.
+//
+// All key slices, except Unrecognized, hold the keys of entries that were
+// deleted or rewritten.
+type GCReport struct {
+	Scanned           int      // number of keys inspected
+	OrphanedResponses []string // response entries not referenced by any refs document
+	CorruptResponses  []string // response entries that could not be decoded
+	CorruptRefs       []string // refs documents that could not be decoded
+	EmptyRefs         []string // refs documents without any variants
+	RepairedRefs      []string // refs documents rewritten to drop dangling references
+	DanglingRefs      int      // number of references dropped from repaired refs documents
+	Unrecognized      []string // keys not in a format generated by httpcache; left untouched
+}
+
+var _ slog.LogValuer = (*GCReport)(nil)
+
+func (r GCReport) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("scanned", r.Scanned),
+		slog.Int("orphaned_responses", len(r.OrphanedResponses)),
+		slog.Int("corrupt_responses", len(r.CorruptResponses)),
+		slog.Int("corrupt_refs", len(r.CorruptRefs)),
+		slog.Int("empty_refs", len(r.EmptyRefs)),
+		slog.Int("repaired_refs", len(r.RepairedRefs)),
+		slog.Int("dangling_refs", r.DanglingRefs),
+		slog.Int("unrecognized", len(r.Unrecognized)),
+	)
+}
+
+// GarbageCollector describes the interface implemented by types that can find
+// and repair stored entries that have drifted out of sync, such as response
+// entries no longer referenced by a refs document, or refs documents pointing
+// at missing or undecodable response entries.
+type GarbageCollector interface {
+	Collect(ctx context.Context) (*GCReport, error)
+}
+
+type garbageCollector struct {
+	cache Cache
+	rc    ResponseCache
+}
+
+func NewGarbageCollector(cache Cache) *garbageCollector {
+	return &garbageCollector{cache, NewResponseCache(cache)}
+}
+
+var _ GarbageCollector = (*garbageCollector)(nil)
+
+// IsResponseKey reports whether key identifies a response entry, as generated
+// by [VaryKeyer], rather than a refs document.
+func IsResponseKey(key string) bool {
+	i := strings.LastIndexByte(key, '#')
+	if i < 0 || i == len(key)-1 {
+		return false
+	}
+	for _, c := range key[i+1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsURLKey reports whether key is in the format of a refs document key, as
+// generated by [URLKeyer]: an absolute http or https URL without a fragment.
+func IsURLKey(key string) bool {
+	u, err := url.Parse(key)
+	return err == nil &&
+		(u.Scheme == "http" || u.Scheme == "https") &&
+		u.Host != "" &&
+		u.Fragment == "" && !strings.Contains(key, "#")
+}
+
+// isCacheKey reports whether key was provably generated by httpcache, and
+// classifies it as a response entry key or a refs document key.
+func isCacheKey(key string) (response, ok bool) {
+	if IsResponseKey(key) {
+		return true, IsURLKey(key[:strings.LastIndexByte(key, '#')])
+	}
+	return false, IsURLKey(key)
+}
+
+// Collect performs a single garbage collection pass.
+//
+// Keys that were not generated by httpcache, such as those written to a
+// shared backend by another application, are reported as unrecognized and
+// never read or deleted.
+//
+// Only entries that were read successfully but could not be decoded are
+// treated as corrupt; any other backend error aborts the pass and is returned
+// along with the partial report.
+//
+// A reference is only dropped once a lookup confirms that its response entry
+// does not exist, so responses stored after the keys were listed are kept.
+// Response entries are written before the refs document that points at them,
+// so a pass running concurrently with a store may delete a response entry that
+// is about to be referenced. The next request for that URL is then a miss,
+// which is harmless.
+func (gc *garbageCollector) Collect(ctx context.Context) (*GCReport, error) {
+	kl, ok := gc.cache.(expapi.KeyLister)
+	if !ok {
+		return nil, ErrKeyListingUnsupported
+	}
+	keys, err := kl.Keys("")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GCReport{Scanned: len(keys)}
+	responses := make(map[string]struct{})
+	refKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		switch response, ok := isCacheKey(key); {
+		case !ok:
+			report.Unrecognized = append(report.Unrecognized, key)
+		case response:
+			responses[key] = struct{}{}
+		default:
+			refKeys = append(refKeys, key)
+		}
+	}
+
+	referenced := make(map[string]struct{}, len(responses))
+	for _, urlKey := range refKeys {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if err := gc.collectRefs(ctx, urlKey, responses, referenced, report); err != nil {
+			return report, err
+		}
+	}
+
+	for key := range responses {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if _, ok := referenced[key]; ok {
+			continue
+		}
+		if gc.delete(ctx, key) {
+			report.OrphanedResponses = append(report.OrphanedResponses, key)
+		}
+	}
+	return report, nil
+}
+
+func (gc *garbageCollector) collectRefs(
+	ctx context.Context,
+	urlKey string,
+	responses, referenced map[string]struct{},
+	report *GCReport,
+) error {
+	// A concurrent store may be updating the refs document; if the backend
+	// supports locking, hold the lock for the read-modify-write.
+	if l, ok := gc.rc.(RefsLocker); ok {
+		switch unlock, err := l.LockRefs(urlKey); {
+		case err == nil:
+			defer unlock()
+		case !errors.Is(err, ErrLockingUnsupported):
+			return err
+		}
+	}
+
+	refs, err := gc.rc.GetRefs(ctx, urlKey)
+	switch {
+	case err == nil:
+	case errors.Is(err, driver.ErrNotExist):
+		return nil
+	case isDecodeError(err):
+		if gc.delete(ctx, urlKey) {
+			report.CorruptRefs = append(report.CorruptRefs, urlKey)
+		}
+		return nil
+	default:
+		return err
+	}
+
+	kept := make(ResponseRefs, 0, len(refs))
+	for _, ref := range refs {
+		if ref == nil {
+			continue
+		}
+		if _, ok := referenced[ref.ResponseID]; ok {
+			kept = append(kept, ref)
+			continue
+		}
+		// Look the response up even if it was not listed: it may have been
+		// stored after the keys were listed.
+		entry, err := gc.rc.Get(ctx, ref.ResponseID, nil)
+		if err != nil {
+			switch {
+			case errors.Is(err, driver.ErrNotExist):
+			case isDecodeError(err):
+				if gc.delete(ctx, ref.ResponseID) {
+					report.CorruptResponses = append(report.CorruptResponses, ref.ResponseID)
+				}
+			default:
+				return err
+			}
+			delete(responses, ref.ResponseID)
+			continue
+		}
+		_ = entry.Data.Body.Close()
+		referenced[ref.ResponseID] = struct{}{}
+		kept = append(kept, ref)
+	}
+
+	switch {
+	case len(kept) == 0:
+		if gc.delete(ctx, urlKey) {
+			report.EmptyRefs = append(report.EmptyRefs, urlKey)
+		}
+	case len(kept) < len(refs):
+		if gc.rc.SetRefs(ctx, urlKey, kept, kept.TTL(time.Now())) == nil {
+			report.RepairedRefs = append(report.RepairedRefs, urlKey)
+			report.DanglingRefs += len(refs) - len(kept)
+		}
+	}
+	return nil
+}
+
+// isDecodeError reports whether err was caused by an entry that was read from
+// the cache but could not be decoded, either by httpcache or by a driver that
+// transforms stored values, as opposed to a backend failure.
+func isDecodeError(err error) bool {
+	var cacheErr *CacheError
+	return errors.As(err, &cacheErr) || errors.Is(err, driver.ErrCorrupt)
+}
+
+// delete removes key from the cache, and reports whether it was removed by
+// this call.
+func (gc *garbageCollector) delete(ctx context.Context, key string) bool {
+	return gc.rc.Delete(ctx, key) == nil
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+// mapCache is a minimal [Cache] that also implements key listing.
+type mapCache map[string][]byte
+
+func (m mapCache) Get(key string) ([]byte, error) {
+	v, ok := m[key]
+	if !ok {
+		return nil, driver.ErrNotExist
+	}
+	return v, nil
+}
+
+func (m mapCache) Set(key string, value []byte) error { m[key] = value; return nil }
+
+func (m mapCache) Delete(key string) error {
+	if _, ok := m[key]; !ok {
+		return driver.ErrNotExist
+	}
+	delete(m, key)
+	return nil
+}
+
+func (m mapCache) Keys(prefix string) ([]string, error) {
+	var keys []string
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func mustMarshalResponse(t *testing.T, id string) []byte {
+	t.Helper()
+	data, err := (&Response{
+		ID:          id,
+		Data:        httptest.NewRecorder().Result(),
+		RequestedAt: time.Now(),
+		ReceivedAt:  time.Now(),
+	}).MarshalBinary()
+	testutil.RequireNoError(t, err)
+	return data
+}
+
+func mustMarshalRefs(t *testing.T, ids ...string) []byte {
+	t.Helper()
+	refs := make(ResponseRefs, 0, len(ids))
+	for _, id := range ids {
+		refs = append(refs, &ResponseRef{ResponseID: id})
+	}
+	data, err := json.Marshal(refs)
+	testutil.RequireNoError(t, err)
+	return data
+}
+
+func Test_isResponseKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"https://example.com/", false},
+		{"https://example.com/#0", true},
+		{"https://example.com/#582009573798973898", true},
+		{"https://example.com/#", false},
+		{"https://example.com/#abc", false},
+		{"#1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			testutil.AssertTrue(t, IsResponseKey(tt.key) == tt.want)
+		})
+	}
+}
+
+func Test_isURLKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"https://example.com/", true},
+		{"http://example.com:8080/a?b=c", true},
+		{"https://example.com/#0", false},
+		{"ftp://example.com/", false},
+		{"https:opaque", false},
+		{"session:abc", false},
+		{"/relative", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			testutil.AssertTrue(t, IsURLKey(tt.key) == tt.want)
+		})
+	}
+}
+
+// lockingMapCache is a [mapCache] that records the refs documents it locks.
+type lockingMapCache struct {
+	mapCache
+	locked []string
+}
+
+func (l *lockingMapCache) Lock(key string) (func(), error) {
+	l.locked = append(l.locked, key)
+	return func() {}, nil
+}
+
+func Test_garbageCollector_Collect_LocksRefs(t *testing.T) {
+	const u = "https://example.com/a"
+	cache := &lockingMapCache{mapCache: mapCache{
+		u:        mustMarshalRefs(t, u+"#0", u+"#1"),
+		u + "#0": mustMarshalResponse(t, u+"#0"),
+	}}
+	report, err := NewGarbageCollector(cache).Collect(t.Context())
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, slices.Equal(report.RepairedRefs, []string{u}))
+	testutil.AssertTrue(t, slices.Equal(cache.locked, []string{u}))
+}
+
+// storingMapCache is a [mapCache] that runs store right after listing its
+// keys, as a request stored concurrently with a garbage collection pass would.
+type storingMapCache struct {
+	mapCache
+	store func()
+}
+
+func (s *storingMapCache) Keys(prefix string) ([]string, error) {
+	keys, err := s.mapCache.Keys(prefix)
+	s.store()
+	return keys, err
+}
+
+func Test_garbageCollector_Collect_ConcurrentStore(t *testing.T) {
+	const u = "https://example.com/a"
+	cache := &storingMapCache{mapCache: mapCache{
+		u:        mustMarshalRefs(t, u+"#0"),
+		u + "#0": mustMarshalResponse(t, u+"#0"),
+	}}
+	cache.store = func() {
+		cache.mapCache[u+"#1"] = mustMarshalResponse(t, u+"#1")
+		cache.mapCache[u] = mustMarshalRefs(t, u+"#0", u+"#1")
+	}
+
+	report, err := NewGarbageCollector(cache).Collect(t.Context())
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 0, len(report.RepairedRefs)+len(report.EmptyRefs))
+	testutil.AssertEqual(t, 0, report.DanglingRefs)
+
+	refs, err := NewResponseCache(cache).GetRefs(t.Context(), u)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, slices.Equal(slices.Collect(refs.ResponseIDs()), []string{u + "#0", u + "#1"}))
+	_, err = cache.Get(u + "#1")
+	testutil.RequireNoError(t, err)
+}
+
+// corruptingMapCache is a [mapCache] that fails to decode the values of the
+// keys in corrupt, as a driver that transforms stored values would.
+type corruptingMapCache struct {
+	mapCache
+	corrupt []string
+}
+
+func (c *corruptingMapCache) Get(key string) ([]byte, error) {
+	if slices.Contains(c.corrupt, key) {
+		return nil, fmt.Errorf("decode %q: %w", key, driver.ErrCorrupt)
+	}
+	return c.mapCache.Get(key)
+}
+
+func Test_garbageCollector_Collect_DriverCorrupt(t *testing.T) {
+	const (
+		u1 = "https://example.com/a"
+		u2 = "https://example.com/b"
+	)
+	cache := &corruptingMapCache{
+		mapCache: mapCache{
+			u1:        mustMarshalRefs(t, u1+"#0", u1+"#1"),
+			u1 + "#0": mustMarshalResponse(t, u1+"#0"),
+			u1 + "#1": mustMarshalResponse(t, u1+"#1"),
+			u2:        mustMarshalRefs(t),
+		},
+		corrupt: []string{u1 + "#1", u2},
+	}
+	report, err := NewGarbageCollector(cache).Collect(t.Context())
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, slices.Equal(report.CorruptResponses, []string{u1 + "#1"}))
+	testutil.AssertTrue(t, slices.Equal(report.CorruptRefs, []string{u2}))
+	testutil.AssertTrue(t, slices.Equal(report.RepairedRefs, []string{u1}))
+
+	got := slices.Sorted(maps.Keys(cache.mapCache))
+	testutil.AssertTrue(t, slices.Equal(got, []string{u1, u1 + "#0"}), "unexpected keys left: %v", got)
+}
+
+func Test_garbageCollector_Collect(t *testing.T) {
+	const (
+		u1 = "https://example.com/a"
+		u2 = "https://example.com/b"
+		u3 = "https://example.com/c"
+		u4 = "https://example.com/d"
+	)
+	cache := mapCache{
+		// healthy
+		u1:        mustMarshalRefs(t, u1+"#0"),
+		u1 + "#0": mustMarshalResponse(t, u1+"#0"),
+		// one dangling, one corrupt, one healthy variant
+		u2:        mustMarshalRefs(t, u2+"#1", u2+"#2", u2+"#3"),
+		u2 + "#2": []byte("not a response"),
+		u2 + "#3": mustMarshalResponse(t, u2+"#3"),
+		// only dangling references
+		u3: mustMarshalRefs(t, u3+"#0"),
+		// undecodable refs document
+		u4: []byte("{"),
+		// orphan
+		u4 + "#0": mustMarshalResponse(t, u4+"#0"),
+		// not generated by httpcache
+		"session:abc": []byte("{"),
+		"jobs#1":      []byte("not a response"),
+	}
+
+	report, err := NewGarbageCollector(cache).Collect(context.Background())
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 10, report.Scanned)
+	testutil.AssertTrue(t, slices.Equal(report.OrphanedResponses, []string{u4 + "#0"}))
+	testutil.AssertTrue(t, slices.Equal(report.CorruptResponses, []string{u2 + "#2"}))
+	testutil.AssertTrue(t, slices.Equal(report.CorruptRefs, []string{u4}))
+	testutil.AssertTrue(t, slices.Equal(report.EmptyRefs, []string{u3}))
+	testutil.AssertTrue(t, slices.Equal(report.RepairedRefs, []string{u2}))
+	testutil.AssertEqual(t, 2, report.DanglingRefs)
+	slices.Sort(report.Unrecognized)
+	testutil.AssertTrue(t, slices.Equal(report.Unrecognized, []string{"jobs#1", "session:abc"}))
+
+	got := slices.Sorted(maps.Keys(cache))
+	want := []string{u1, u1 + "#0", u2, u2 + "#3", "jobs#1", "session:abc"}
+	slices.Sort(want)
+	testutil.AssertTrue(t, slices.Equal(got, want), "unexpected keys left: %v", got)
+
+	refs, err := NewResponseCache(cache).GetRefs(t.Context(), u2)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, slices.Equal(slices.Collect(refs.ResponseIDs()), []string{u2 + "#3"}))
+
+	// A second pass finds nothing to do.
+	report, err = NewGarbageCollector(cache).Collect(context.Background())
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 0, len(report.OrphanedResponses)+len(report.CorruptResponses)+
+		len(report.CorruptRefs)+len(report.EmptyRefs)+len(report.RepairedRefs))
+}
+
+func Test_garbageCollector_Collect_Errors(t *testing.T) {
+	t.Run("key listing unsupported", func(t *testing.T) {
+		_, err := NewGarbageCollector(&MockCache{}).Collect(context.Background())
+		testutil.RequireErrorIs(t, err, ErrKeyListingUnsupported)
+	})
+	t.Run("context canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		cache := mapCache{"https://example.com/": mustMarshalRefs(t)}
+		_, err := NewGarbageCollector(cache).Collect(ctx)
+		testutil.RequireErrorIs(t, err, context.Canceled)
+		testutil.AssertEqual(t, 1, len(cache), "expected no deletions")
+	})
+}
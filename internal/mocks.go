@@ -15,9 +15,12 @@
 package internal
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/bartventer/httpcache/store/driver"
 )
 
 var _ Cache = (*MockCache)(nil)
@@ -40,6 +43,27 @@ func (m *MockCache) Delete(key string) error {
 	return m.DeleteFunc(key)
 }
 
+var _ driver.ConnContext = (*MockCacheContext)(nil)
+
+type MockCacheContext struct {
+	*MockCache
+	GetContextFunc    func(ctx context.Context, key string) ([]byte, error)
+	SetContextFunc    func(ctx context.Context, key string, entry []byte) error
+	DeleteContextFunc func(ctx context.Context, key string) error
+}
+
+func (m *MockCacheContext) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return m.GetContextFunc(ctx, key)
+}
+
+func (m *MockCacheContext) SetContext(ctx context.Context, key string, entry []byte) error {
+	return m.SetContextFunc(ctx, key, entry)
+}
+
+func (m *MockCacheContext) DeleteContext(ctx context.Context, key string) error {
+	return m.DeleteContextFunc(ctx, key)
+}
+
 var _ ResponseCache = (*MockResponseCache)(nil)
 
 type MockResponseCache struct {
@@ -48,26 +72,60 @@ type MockResponseCache struct {
 	DeleteFunc  func(key string) error
 	GetRefsFunc func(key string) (ResponseRefs, error)
 	SetRefsFunc func(key string, headers ResponseRefs) error
+
+	// Optional; default to GetRefsFunc without a prefetched response, and
+	// DeleteFunc for each key.
+	GetRefsPrefetchFunc func(key string, req *http.Request) (ResponseRefs, *Response, error)
+	DeleteMultiFunc     func(keys []string) error
 }
 
-func (m *MockResponseCache) GetRefs(key string) (ResponseRefs, error) {
+func (m *MockResponseCache) GetRefs(_ context.Context, key string) (ResponseRefs, error) {
 	return m.GetRefsFunc(key)
 }
 
-func (m *MockResponseCache) SetRefs(key string, headers ResponseRefs) error {
+func (m *MockResponseCache) SetRefs(
+	_ context.Context,
+	key string,
+	headers ResponseRefs,
+	_ time.Duration,
+) error {
 	return m.SetRefsFunc(key, headers)
 }
 
-func (m *MockResponseCache) Get(key string, req *http.Request) (*Response, error) {
+func (m *MockResponseCache) Get(_ context.Context, key string, req *http.Request) (*Response, error) {
 	return m.GetFunc(key, req)
 }
-func (m *MockResponseCache) Set(key string, entry *Response) error {
+func (m *MockResponseCache) Set(_ context.Context, key string, entry *Response, _ time.Duration) error {
 	return m.SetFunc(key, entry)
 }
-func (m *MockResponseCache) Delete(key string) error {
+func (m *MockResponseCache) Delete(_ context.Context, key string) error {
 	return m.DeleteFunc(key)
 }
 
+func (m *MockResponseCache) GetRefsPrefetch(
+	_ context.Context,
+	key string,
+	req *http.Request,
+) (ResponseRefs, *Response, error) {
+	if m.GetRefsPrefetchFunc != nil {
+		return m.GetRefsPrefetchFunc(key, req)
+	}
+	refs, err := m.GetRefsFunc(key)
+	return refs, nil, err
+}
+
+func (m *MockResponseCache) DeleteMulti(_ context.Context, keys []string) error {
+	if m.DeleteMultiFunc != nil {
+		return m.DeleteMultiFunc(keys)
+	}
+	for _, key := range keys {
+		if err := m.DeleteFunc(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var _ RequestMethodChecker = (*MockRequestMethodChecker)(nil)
 
 type MockRequestMethodChecker struct {
@@ -128,6 +186,16 @@ func (m *MockFreshnessCalculator) CalculateFreshness(
 	return m.CalculateFreshnessFunc(resp.Data, reqCC, resCC)
 }
 
+var _ RetentionPolicy = (*MockRetentionPolicy)(nil)
+
+type MockRetentionPolicy struct {
+	RetentionFunc func(entry *Response) time.Duration
+}
+
+func (m *MockRetentionPolicy) Retention(entry *Response) time.Duration {
+	return m.RetentionFunc(entry)
+}
+
 var _ Clock = (*MockClock)(nil)
 
 type MockClock struct {
@@ -172,6 +240,7 @@ type MockCacheInvalidator struct {
 }
 
 func (m *MockCacheInvalidator) InvalidateCache(
+	_ context.Context,
 	reqURL *url.URL,
 	respHeader http.Header,
 	headers ResponseRefs,
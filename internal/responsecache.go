@@ -15,10 +15,15 @@
 package internal
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/bartventer/httpcache/store/driver"
 )
@@ -28,24 +33,65 @@ type Cache = driver.Conn
 // ResponseCache is an interface for caching HTTP responses.
 // It provides methods to delete any cached item by its key,
 // retrieve and set full cached responses, and manage references associated
-// with a given URL key.
+// with a given URL key. The context cancels the backend operation if the
+// backend implements [driver.ConnContext]. A positive ttl passed to Set or
+// SetRefs lets a backend implementing [driver.TTLSetter] drop the entry once
+// it has elapsed.
+//
+// If the backend implements [driver.Streamer], the body of a response
+// returned by Get is read from the backend as it is consumed, and the caller
+// must close it, as for a response from a [http.RoundTripper].
 type ResponseCache interface {
-	Get(key string, req *http.Request) (*Response, error)
-	Set(key string, entry *Response) error
-	Delete(key string) error
-	GetRefs(key string) (ResponseRefs, error)
-	SetRefs(key string, refs ResponseRefs) error
+	Get(ctx context.Context, key string, req *http.Request) (*Response, error)
+	Set(ctx context.Context, key string, entry *Response, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	GetRefs(ctx context.Context, key string) (ResponseRefs, error)
+	SetRefs(ctx context.Context, key string, refs ResponseRefs, ttl time.Duration) error
+
+	// GetRefsPrefetch is like GetRefs, but if the backend implements
+	// [driver.Batcher], and not [driver.Streamer], it also fetches, in the
+	// same batch, the response stored for urlKey without Vary headers, which
+	// is the only variant of most URLs. The prefetched response is nil if it
+	// was not fetched or could not be read; its ID tells whether the refs
+	// select it.
+	GetRefsPrefetch(ctx context.Context, urlKey string, req *http.Request) (ResponseRefs, *Response, error)
+
+	// DeleteMulti deletes the given keys, in a single batch if the backend
+	// implements [driver.Batcher]. Keys that do not exist are ignored.
+	DeleteMulti(ctx context.Context, keys []string) error
+}
+
+// RefsLocker is implemented by a [ResponseCache] whose backend can serialize
+// updates of a refs document, across processes if they share the backend.
+type RefsLocker interface {
+	// LockRefs acquires the lock for the refs document of urlKey, and returns
+	// a function that releases it. It returns [ErrLockingUnsupported] if the
+	// backend does not implement [driver.Locker].
+	LockRefs(urlKey string) (unlock func(), err error)
 }
 
+// ErrLockingUnsupported is returned by [RefsLocker.LockRefs] if the backend
+// does not support locking.
+var ErrLockingUnsupported = errors.New("httpcache: cache backend does not support locking")
+
 type responseCache struct {
 	cache Cache
+	cc    driver.ConnContext // cache, if it supports cancellation; else nil
+	b     driver.Batcher     // cache, if it supports batching; else nil
+	ts    driver.TTLSetter   // cache, if it supports expiry; else nil
+	s     driver.Streamer    // cache, if it supports streaming; else nil
 }
 
 func NewResponseCache(cache Cache) *responseCache {
-	return &responseCache{cache}
+	cc, _ := cache.(driver.ConnContext)
+	b, _ := cache.(driver.Batcher)
+	ts, _ := cache.(driver.TTLSetter)
+	s, _ := cache.(driver.Streamer)
+	return &responseCache{cache, cc, b, ts, s}
 }
 
 var _ ResponseCache = (*responseCache)(nil)
+var _ RefsLocker = (*responseCache)(nil)
 
 type CacheError struct {
 	Op      string
@@ -70,8 +116,32 @@ func newCacheError(err error, op, message string) *CacheError {
 
 var _ slog.LogValuer = (*CacheError)(nil)
 
-func (r *responseCache) Get(responseKey string, req *http.Request) (*Response, error) {
-	data, err := r.cache.Get(responseKey)
+func (r *responseCache) get(ctx context.Context, key string) ([]byte, error) {
+	if r.cc != nil {
+		return r.cc.GetContext(ctx, key)
+	}
+	return r.cache.Get(key)
+}
+
+func (r *responseCache) set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl > 0 && r.ts != nil {
+		return r.ts.SetWithTTL(ctx, key, value, ttl)
+	}
+	if r.cc != nil {
+		return r.cc.SetContext(ctx, key, value)
+	}
+	return r.cache.Set(key, value)
+}
+
+func (r *responseCache) Get(
+	ctx context.Context,
+	responseKey string,
+	req *http.Request,
+) (*Response, error) {
+	if r.s != nil {
+		return r.openResponse(ctx, responseKey, req)
+	}
+	data, err := r.get(ctx, responseKey)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +156,52 @@ func (r *responseCache) Get(responseKey string, req *http.Request) (*Response, e
 	return entry, nil
 }
 
-func (r *responseCache) Set(responseKey string, entry *Response) error {
+// openResponse reads the response for responseKey through the streamer,
+// leaving its body to be read as it is consumed.
+func (r *responseCache) openResponse(
+	ctx context.Context,
+	responseKey string,
+	req *http.Request,
+) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rc, err := r.s.OpenReader(responseKey)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := ReadResponse(bufio.NewReader(rc), req)
+	if err != nil {
+		_ = rc.Close()
+		return nil, newCacheError(
+			err,
+			"Get",
+			fmt.Sprintf("failed to unmarshal cached entry for key %q", responseKey),
+		)
+	}
+	// Closing the body closes the reader, without draining the rest of it.
+	entry.Data.Body = streamedBody{entry.Data.Body, rc, responseKey}
+	return entry, nil
+}
+
+// streamedBody is the body of a response read through a [driver.Streamer].
+type streamedBody struct {
+	io.Reader
+	io.Closer
+	key string // key of the response it is read from
+}
+
+func (r *responseCache) Set(
+	ctx context.Context,
+	responseKey string,
+	entry *Response,
+	ttl time.Duration,
+) error {
+	if body, ok := entry.Data.Body.(streamedBody); ok && r.s != nil && (ttl <= 0 || r.ts == nil) {
+		// The body is still stored, as when a response is freshened upon
+		// validation: copy it within the cache rather than buffer it.
+		return r.copyResponse(ctx, responseKey, entry, body.key)
+	}
 	data, err := entry.MarshalBinary()
 	if err != nil {
 		return newCacheError(
@@ -95,18 +210,98 @@ func (r *responseCache) Set(responseKey string, entry *Response) error {
 			fmt.Sprintf("failed to marshal entry for key %q", responseKey),
 		)
 	}
-	return r.cache.Set(responseKey, data)
+	return r.set(ctx, responseKey, data, ttl)
+}
+
+// copyResponse stores entry under responseKey, streaming its body from the
+// response stored under srcKey. The value is deleted if the copy fails, so
+// that it is not left truncated.
+func (r *responseCache) copyResponse(
+	ctx context.Context,
+	responseKey string,
+	entry *Response,
+	srcKey string,
+) error {
+	src, err := r.openResponse(ctx, srcKey, nil)
+	if err != nil {
+		return err
+	}
+	data := *entry.Data
+	data.Body = src.Data.Body
+	w, err := r.s.OpenWriter(responseKey)
+	if err != nil {
+		_ = data.Body.Close()
+		return err
+	}
+	_, err = entry.WriteTo(w)
+	if err == nil {
+		err = data.Write(w) // closes the body
+	} else {
+		_ = data.Body.Close()
+	}
+	if err = errors.Join(err, w.Close()); err != nil {
+		_ = r.Delete(ctx, responseKey)
+		return newCacheError(
+			err,
+			"Set",
+			fmt.Sprintf("failed to copy entry for key %q", responseKey),
+		)
+	}
+	return nil
 }
 
-func (r *responseCache) Delete(key string) error {
+func (r *responseCache) Delete(ctx context.Context, key string) error {
+	if r.cc != nil {
+		return r.cc.DeleteContext(ctx, key)
+	}
 	return r.cache.Delete(key)
 }
 
-func (r *responseCache) GetRefs(urlKey string) (ResponseRefs, error) {
-	data, err := r.cache.Get(urlKey)
+func (r *responseCache) DeleteMulti(ctx context.Context, keys []string) error {
+	return driver.AsBatcher(r.cache).DeleteMulti(ctx, keys)
+}
+
+func (r *responseCache) GetRefs(ctx context.Context, urlKey string) (ResponseRefs, error) {
+	data, err := r.get(ctx, urlKey)
 	if err != nil {
 		return nil, err
 	}
+	return decodeRefs(urlKey, data)
+}
+
+func (r *responseCache) GetRefsPrefetch(
+	ctx context.Context,
+	urlKey string,
+	req *http.Request,
+) (ResponseRefs, *Response, error) {
+	if r.b == nil || r.s != nil {
+		refs, err := r.GetRefs(ctx, urlKey)
+		return refs, nil, err
+	}
+	responseKey := makeVaryKey(urlKey, nil)
+	values, err := r.b.GetMulti(ctx, []string{urlKey, responseKey})
+	if err != nil {
+		return nil, nil, err
+	}
+	data, ok := values[urlKey]
+	if !ok {
+		return nil, nil, errors.Join(
+			driver.ErrNotExist,
+			fmt.Errorf("httpcache: refs for key %q do not exist", urlKey),
+		)
+	}
+	refs, err := decodeRefs(urlKey, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var prefetched *Response
+	if data, ok := values[responseKey]; ok {
+		prefetched, _ = ParseResponse(data, req)
+	}
+	return refs, prefetched, nil
+}
+
+func decodeRefs(urlKey string, data []byte) (ResponseRefs, error) {
 	var refs ResponseRefs
 	if unmarshalErr := json.Unmarshal(data, &refs); unmarshalErr != nil {
 		return nil, newCacheError(
@@ -118,7 +313,12 @@ func (r *responseCache) GetRefs(urlKey string) (ResponseRefs, error) {
 	return refs, nil
 }
 
-func (r *responseCache) SetRefs(urlKey string, refs ResponseRefs) error {
+func (r *responseCache) SetRefs(
+	ctx context.Context,
+	urlKey string,
+	refs ResponseRefs,
+	ttl time.Duration,
+) error {
 	data, err := json.Marshal(refs)
 	if err != nil {
 		return newCacheError(
@@ -127,5 +327,13 @@ func (r *responseCache) SetRefs(urlKey string, refs ResponseRefs) error {
 			fmt.Sprintf("failed to marshal refs for key %q", urlKey),
 		)
 	}
-	return r.cache.Set(urlKey, data)
+	return r.set(ctx, urlKey, data, ttl)
+}
+
+func (r *responseCache) LockRefs(urlKey string) (func(), error) {
+	l, ok := r.cache.(driver.Locker)
+	if !ok {
+		return nil, ErrLockingUnsupported
+	}
+	return l.Lock(urlKey)
 }
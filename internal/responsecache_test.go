@@ -15,7 +15,10 @@
 package internal
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -24,6 +27,7 @@ import (
 	"time"
 
 	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/driver"
 )
 
 func Test_responseCache_Get(t *testing.T) {
@@ -123,7 +127,7 @@ func Test_responseCache_Get(t *testing.T) {
 			r := &responseCache{
 				cache: tt.fields.cache,
 			}
-			got, err := r.Get(tt.args.key, tt.args.req)
+			got, err := r.Get(t.Context(), tt.args.key, tt.args.req)
 			tt.assertion(t, err)
 			if tt.want != nil && got != nil {
 				testutil.AssertEqual(t, tt.want.Data.StatusCode, got.Data.StatusCode)
@@ -190,7 +194,7 @@ func Test_responseCache_Set(t *testing.T) {
 			r := &responseCache{
 				cache: tt.fields.cache,
 			}
-			tt.assertion(t, r.Set(tt.args.key, tt.args.entry))
+			tt.assertion(t, r.Set(t.Context(), tt.args.key, tt.args.entry, 0))
 		})
 	}
 }
@@ -231,7 +235,7 @@ func Test_responseCache_Delete(t *testing.T) {
 			r := &responseCache{
 				cache: tt.fields.cache,
 			}
-			tt.assertion(t, r.Delete(tt.args.key))
+			tt.assertion(t, r.Delete(t.Context(), tt.args.key))
 		})
 	}
 }
@@ -320,7 +324,7 @@ func Test_responseCache_GetHeaders(t *testing.T) {
 			r := &responseCache{
 				cache: tt.fields.cache,
 			}
-			got, err := r.GetRefs(tt.args.key)
+			got, err := r.GetRefs(t.Context(), tt.args.key)
 			tt.assertion(t, got, err)
 		})
 	}
@@ -371,7 +375,7 @@ func Test_responseCache_SetHeaders(t *testing.T) {
 			r := &responseCache{
 				cache: tt.fields.cache,
 			}
-			err := r.SetRefs(tt.args.key, tt.args.headers)
+			err := r.SetRefs(t.Context(), tt.args.key, tt.args.headers, 0)
 			tt.assertion(t, err)
 		})
 	}
@@ -394,3 +398,199 @@ func TestNewResponseCache(t *testing.T) {
 		})
 	}
 }
+
+func Test_responseCache_Context(t *testing.T) {
+	type ctxKey struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(t.Context(), ctxKey{}, "req"))
+	var calls []string
+	record := func(op string, ctx context.Context) {
+		if ctx.Value(ctxKey{}) == "req" {
+			calls = append(calls, op)
+		}
+	}
+	refs, err := json.Marshal(ResponseRefs{})
+	testutil.RequireNoError(t, err)
+	mock := &MockCacheContext{
+		MockCache: &MockCache{
+			GetFunc:    func(string) ([]byte, error) { panic("Get called") },
+			SetFunc:    func(string, []byte) error { panic("Set called") },
+			DeleteFunc: func(string) error { panic("Delete called") },
+		},
+		GetContextFunc: func(ctx context.Context, key string) ([]byte, error) {
+			record("get", ctx)
+			return refs, ctx.Err()
+		},
+		SetContextFunc: func(ctx context.Context, key string, entry []byte) error {
+			record("set", ctx)
+			return ctx.Err()
+		},
+		DeleteContextFunc: func(ctx context.Context, key string) error {
+			record("delete", ctx)
+			return ctx.Err()
+		},
+	}
+	r := NewResponseCache(mock)
+
+	_, err = r.GetRefs(ctx, "url")
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, r.SetRefs(ctx, "url", ResponseRefs{}, 0))
+	testutil.RequireNoError(t, r.Delete(ctx, "url"))
+	testutil.AssertEqual(t, "get,set,delete", strings.Join(calls, ","))
+
+	cancel()
+	_, err = r.Get(ctx, "url#1", nil)
+	testutil.RequireErrorIs(t, err, context.Canceled)
+	testutil.RequireErrorIs(t, r.Set(ctx, "url#1", &Response{Data: httptest.NewRecorder().Result()}, 0), context.Canceled)
+}
+
+// batchCache is a mapCache that implements [driver.Batcher], counting the
+// batches.
+type batchCache struct {
+	mapCache
+	batches int
+}
+
+func (c *batchCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	c.batches++
+	return driver.AsBatcher(c.mapCache).GetMulti(ctx, keys)
+}
+
+func (c *batchCache) SetMulti(ctx context.Context, values map[string][]byte) error {
+	c.batches++
+	return driver.AsBatcher(c.mapCache).SetMulti(ctx, values)
+}
+
+func (c *batchCache) DeleteMulti(ctx context.Context, keys []string) error {
+	c.batches++
+	return driver.AsBatcher(c.mapCache).DeleteMulti(ctx, keys)
+}
+
+func Test_responseCache_GetRefsPrefetch(t *testing.T) {
+	const urlKey = "https://example.com/"
+	noVaryKey := makeVaryKey(urlKey, nil)
+	req := httptest.NewRequest(http.MethodGet, urlKey, nil)
+
+	cache := &batchCache{mapCache: mapCache{
+		urlKey:    mustMarshalRefs(t, noVaryKey),
+		noVaryKey: mustMarshalResponse(t, noVaryKey),
+	}}
+	r := NewResponseCache(cache)
+	refs, prefetched, err := r.GetRefsPrefetch(t.Context(), urlKey, req)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 1, len(refs))
+	testutil.RequireNotNil(t, prefetched)
+	testutil.AssertEqual(t, noVaryKey, prefetched.ID)
+	testutil.AssertEqual(t, 1, cache.batches)
+
+	// A variant that was not prefetched.
+	delete(cache.mapCache, noVaryKey)
+	_, prefetched, err = r.GetRefsPrefetch(t.Context(), urlKey, req)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, prefetched == nil, "prefetched a missing response")
+
+	// Missing and corrupt refs.
+	_, _, err = r.GetRefsPrefetch(t.Context(), "https://example.com/missing", req)
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	cache.mapCache[urlKey] = []byte("{")
+	_, _, err = r.GetRefsPrefetch(t.Context(), urlKey, req)
+	var cacheErr *CacheError
+	testutil.RequireErrorAs(t, err, &cacheErr)
+
+	// Without batching, nothing is prefetched.
+	plain := mapCache{
+		urlKey:    mustMarshalRefs(t, noVaryKey),
+		noVaryKey: mustMarshalResponse(t, noVaryKey),
+	}
+	refs, prefetched, err = NewResponseCache(plain).GetRefsPrefetch(t.Context(), urlKey, req)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 1, len(refs))
+	testutil.AssertTrue(t, prefetched == nil, "prefetched without batching")
+}
+
+func Test_responseCache_DeleteMulti(t *testing.T) {
+	for _, batching := range []bool{true, false} {
+		m := mapCache{"a": nil, "b": nil, "c": nil}
+		var cache Cache = m
+		if batching {
+			cache = &batchCache{mapCache: m}
+		}
+		r := NewResponseCache(cache)
+		testutil.RequireNoError(t, r.DeleteMulti(t.Context(), []string{"a", "c", "missing"}))
+		testutil.AssertEqual(t, 1, len(m))
+		_, ok := m["b"]
+		testutil.AssertTrue(t, ok, "deleted a key that was not given")
+	}
+}
+
+// streamCache is a mapCache that implements [driver.Streamer], counting the
+// readers left open.
+type streamCache struct {
+	*batchCache
+	open int
+}
+
+func (c *streamCache) OpenReader(key string) (io.ReadCloser, error) {
+	data, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	c.open++
+	return &countedReader{bytes.NewReader(data), c}, nil
+}
+
+func (c *streamCache) OpenWriter(key string) (io.WriteCloser, error) {
+	panic("OpenWriter called")
+}
+
+type countedReader struct {
+	io.Reader
+	c *streamCache
+}
+
+func (r *countedReader) Close() error { r.c.open--; return nil }
+
+func Test_responseCache_Stream(t *testing.T) {
+	const urlKey = "https://example.com/"
+	noVaryKey := makeVaryKey(urlKey, nil)
+	req := httptest.NewRequest(http.MethodGet, urlKey, nil)
+	rec := httptest.NewRecorder()
+	rec.WriteString("hello")
+	data, err := (&Response{ID: noVaryKey, Data: rec.Result()}).MarshalBinary()
+	testutil.RequireNoError(t, err)
+
+	cache := &streamCache{batchCache: &batchCache{mapCache: mapCache{
+		urlKey:    mustMarshalRefs(t, noVaryKey),
+		noVaryKey: data,
+		"corrupt": []byte("corrupt"),
+	}}}
+	r := NewResponseCache(cache)
+
+	// Streamed responses are not prefetched in a batch.
+	_, prefetched, err := r.GetRefsPrefetch(t.Context(), urlKey, req)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, prefetched == nil, "prefetched a streamed response")
+	testutil.AssertEqual(t, 0, cache.batches)
+
+	entry, err := r.Get(t.Context(), noVaryKey, req)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, noVaryKey, entry.ID)
+	testutil.AssertEqual(t, 1, cache.open, "reader not open while the body is unread")
+	body, err := io.ReadAll(entry.Data.Body)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "hello", string(body))
+	testutil.RequireNoError(t, entry.Data.Body.Close())
+	testutil.AssertEqual(t, 0, cache.open, "reader not closed with the body")
+
+	_, err = r.Get(t.Context(), "corrupt", req)
+	var cacheErr *CacheError
+	testutil.RequireErrorAs(t, err, &cacheErr)
+	testutil.AssertEqual(t, 0, cache.open, "reader of a corrupt entry left open")
+	_, err = r.Get(t.Context(), "missing", req)
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = r.Get(ctx, noVaryKey, req)
+	testutil.RequireErrorIs(t, err, context.Canceled)
+	testutil.AssertEqual(t, 0, cache.open)
+}
@@ -15,11 +15,15 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"maps"
 	"net/http"
 	"slices"
 	"strings"
 	"time"
+
+	"github.com/bartventer/httpcache/store/driver"
 )
 
 // ResponseStorer describes the interface implemented by types that can store HTTP responses
@@ -28,6 +32,11 @@ import (
 // If refs is nil, a new slice should be  created. If refIndex is valid, the
 // reference at that index should be updated; otherwise, a new reference should
 // be appended.
+//
+// The response is stored with the time to live given by the
+// [RetentionPolicy], and the refs with one long enough to outlive every
+// response they reference. References to responses past their retention
+// horizon are dropped.
 type ResponseStorer interface {
 	StoreResponse(
 		req *http.Request,
@@ -43,10 +52,18 @@ type responseStorer struct {
 	cache ResponseCache
 	vhn   VaryHeaderNormalizer
 	vk    VaryKeyer
+	rp    RetentionPolicy
+	clock Clock
 }
 
-func NewResponseStorer(cache ResponseCache, vhn VaryHeaderNormalizer, vk VaryKeyer) ResponseStorer {
-	return &responseStorer{cache, vhn, vk}
+func NewResponseStorer(
+	cache ResponseCache,
+	vhn VaryHeaderNormalizer,
+	vk VaryKeyer,
+	rp RetentionPolicy,
+	clock Clock,
+) ResponseStorer {
+	return &responseStorer{cache, vhn, vk, rp, clock}
 }
 
 func (r *responseStorer) StoreResponse(
@@ -73,7 +90,25 @@ func (r *responseStorer) StoreResponse(
 		ReceivedAt:  respTime,
 		ID:          responseID,
 	}
-	_ = r.cache.Set(responseID, respEntry)
+	now := r.clock.Now()
+	var retainUntil time.Time
+	ttl := r.rp.Retention(respEntry)
+	if ttl > 0 {
+		retainUntil = now.Add(ttl)
+	}
+	ctx := req.Context()
+	// Stored before, and apart from, the refs: they expire at different
+	// times, and the refs must never reference a response not yet stored.
+	_ = r.cache.Set(ctx, responseID, respEntry, ttl)
+
+	// Another process may have updated the refs since they were read; if the
+	// backend supports locking, reload them under the lock and merge.
+	if l, ok := r.cache.(RefsLocker); ok {
+		if unlock, err := l.LockRefs(urlKey); err == nil {
+			defer unlock()
+			refs, refIndex = r.reloadRefs(ctx, urlKey, refs, refIndex, responseID)
+		}
+	}
 
 	switch {
 	case refs == nil:
@@ -87,6 +122,7 @@ func (r *responseStorer) StoreResponse(
 		VaryResolved: varyResolved,
 		ReceivedAt:   respEntry.DateHeader(),
 		ResponseID:   responseID,
+		RetainUntil:  retainUntil,
 	}
 
 	if refIndex < 0 || refIndex >= len(refs) {
@@ -95,5 +131,53 @@ func (r *responseStorer) StoreResponse(
 		refs[refIndex] = refEntry // Update existing response reference
 	}
 
-	return r.cache.SetRefs(urlKey, refs)
+	refs = retainedRefs(refs, now)
+	return r.cache.SetRefs(ctx, urlKey, refs, refs.TTL(now))
+}
+
+// retainedRefs returns refs without the references to responses past their
+// retention horizon, which the backend may already have dropped.
+func retainedRefs(refs ResponseRefs, now time.Time) ResponseRefs {
+	expired := func(ref *ResponseRef) bool {
+		return ref != nil && !ref.RetainUntil.IsZero() && !now.Before(ref.RetainUntil)
+	}
+	if !slices.ContainsFunc(refs, expired) {
+		return refs
+	}
+	retained := make(ResponseRefs, 0, len(refs))
+	for _, ref := range refs {
+		if !expired(ref) {
+			retained = append(retained, ref)
+		}
+	}
+	return retained
+}
+
+// reloadRefs returns the current refs for urlKey, with refIndex translated to
+// the position of the same reference in them. If the refs cannot be read, the
+// given refs are returned unchanged.
+func (r *responseStorer) reloadRefs(
+	ctx context.Context,
+	urlKey string,
+	refs ResponseRefs,
+	refIndex int,
+	responseID string,
+) (ResponseRefs, int) {
+	current, err := r.cache.GetRefs(ctx, urlKey)
+	if err != nil && !errors.Is(err, driver.ErrNotExist) {
+		return refs, refIndex
+	}
+	// Match the reference being replaced, or else one for the same variant.
+	target := responseID
+	if refIndex >= 0 && refIndex < len(refs) && refs[refIndex] != nil {
+		target = refs[refIndex].ResponseID
+	}
+	for _, id := range []string{target, responseID} {
+		if i := slices.IndexFunc(current, func(ref *ResponseRef) bool {
+			return ref != nil && ref.ResponseID == id
+		}); i >= 0 {
+			return current, i
+		}
+	}
+	return current, -1
 }
@@ -15,15 +15,22 @@
 package internal
 
 import (
+	"context"
 	"iter"
 	"maps"
 	"net/http"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/bartventer/httpcache/internal/testutil"
 )
 
+// indefinitely is a retention policy that never expires responses.
+var indefinitely = &MockRetentionPolicy{
+	RetentionFunc: func(*Response) time.Duration { return 0 },
+}
+
 func Test_responseStorer_StoreResponse(t *testing.T) {
 	base := time.Unix(0, 0).UTC()
 	type fields struct {
@@ -231,6 +238,8 @@ func Test_responseStorer_StoreResponse(t *testing.T) {
 				cache: tt.fields.cache,
 				vhn:   tt.fields.vhn,
 				vk:    tt.fields.vk,
+				rp:    indefinitely,
+				clock: NewClock(),
 			}
 			FixDateHeader(tt.args.resp.Header, tt.args.respTime)
 			err := r.StoreResponse(
@@ -250,3 +259,142 @@ func Test_responseStorer_StoreResponse(t *testing.T) {
 		})
 	}
 }
+
+// lockingCache is a [mapCache] that implements [driver.Locker].
+type lockingCache struct {
+	mapCache
+	locked int
+}
+
+func (c *lockingCache) Lock(key string) (func(), error) {
+	c.locked++
+	return func() { c.locked-- }, nil
+}
+
+func Test_responseStorer_StoreResponse_ReloadsRefsUnderLock(t *testing.T) {
+	const urlKey = "https://example.com/"
+	tests := []struct {
+		name     string
+		stored   []string // response IDs stored by another process; "" for null
+		refs     []string // response IDs read by this process
+		refIndex int
+		newID    string
+		want     []string
+	}{
+		{"append to concurrent update", []string{"#a"}, nil, -1, "#b", []string{"#a", "#b"}},
+		{"replace moved reference", []string{"#x", "#a"}, []string{"#a"}, 0, "#c", []string{"#x", "#c"}},
+		{"update same variant", []string{"#a", "#b"}, []string{"#b"}, -1, "#b", []string{"#a", "#b"}},
+		{"skip null reference", []string{"", "#a"}, []string{"#a"}, 0, "#c", []string{"", "#c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &lockingCache{mapCache: mapCache{}}
+			rc := NewResponseCache(cache)
+			toRefs := func(ids []string) ResponseRefs {
+				refs := make(ResponseRefs, 0, len(ids))
+				for _, id := range ids {
+					if id == "" {
+						refs = append(refs, nil)
+						continue
+					}
+					refs = append(refs, &ResponseRef{ResponseID: urlKey + id})
+				}
+				return refs
+			}
+			ids := func(refs ResponseRefs) []string {
+				ids := make([]string, len(refs))
+				for i, ref := range refs {
+					if ref != nil {
+						ids[i] = ref.ResponseID
+					}
+				}
+				return ids
+			}
+			if tt.stored != nil {
+				testutil.RequireNoError(t, rc.SetRefs(t.Context(), urlKey, toRefs(tt.stored), 0))
+			}
+			storer := NewResponseStorer(rc,
+				VaryHeaderNormalizerFunc(func(string, http.Header) iter.Seq2[string, string] {
+					return maps.All(map[string]string{})
+				}),
+				VaryKeyerFunc(func(string, map[string]string) string { return urlKey + tt.newID }),
+				indefinitely,
+				NewClock(),
+			)
+			err := storer.StoreResponse(
+				&http.Request{Header: http.Header{}},
+				&http.Response{Header: http.Header{}},
+				urlKey, toRefs(tt.refs), time.Now(), time.Now(), tt.refIndex,
+			)
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, 0, cache.locked, "lock not released")
+
+			got, err := rc.GetRefs(t.Context(), urlKey)
+			testutil.RequireNoError(t, err)
+			testutil.AssertTrue(t, slices.Equal(ids(got), ids(toRefs(tt.want))), "got %v", ids(got))
+		})
+	}
+}
+
+// ttlCache is a mapCache that records the time to live of each entry.
+type ttlCache struct {
+	mapCache
+	ttls map[string]time.Duration
+}
+
+func (c *ttlCache) SetWithTTL(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.ttls[key] = ttl
+	return c.Set(key, value)
+}
+
+func Test_responseStorer_Retention(t *testing.T) {
+	const urlKey = "https://example.com/"
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := &ttlCache{mapCache: mapCache{}, ttls: make(map[string]time.Duration)}
+	rc := NewResponseCache(cache)
+	retention := map[string]time.Duration{"text/html": time.Hour, "text/plain": time.Minute}
+	storer := NewResponseStorer(rc,
+		VaryHeaderNormalizerFunc(func(_ string, reqHeader http.Header) iter.Seq2[string, string] {
+			return maps.All(map[string]string{"Accept": reqHeader.Get("Accept")})
+		}),
+		VaryKeyerFunc(func(urlKey string, vary map[string]string) string { return urlKey + "#" + vary["Accept"] }),
+		&MockRetentionPolicy{RetentionFunc: func(entry *Response) time.Duration {
+			return retention[entry.Data.Header.Get("Content-Type")]
+		}},
+		&MockClock{NowResult: now},
+	)
+	store := func(accept string, refs ResponseRefs) {
+		t.Helper()
+		req := &http.Request{Header: http.Header{"Accept": {accept}}}
+		resp := &http.Response{Header: http.Header{"Vary": {"Accept"}, "Content-Type": {accept}}}
+		testutil.RequireNoError(t, storer.StoreResponse(req, resp, urlKey, refs, now, now, -1))
+	}
+
+	store("text/html", nil)
+	store("text/plain", ResponseRefs{{ResponseID: urlKey + "#text/gone", RetainUntil: now.Add(-time.Second)}})
+	testutil.AssertEqual(t, time.Hour, cache.ttls[urlKey+"#text/html"])
+	testutil.AssertEqual(t, time.Minute, cache.ttls[urlKey+"#text/plain"])
+
+	// Refs outlive their longest-lived variant, and drop expired ones.
+	refs, err := rc.GetRefs(t.Context(), urlKey)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, slices.Equal(
+		slices.Collect(refs.ResponseIDs()),
+		[]string{urlKey + "#text/plain"},
+	), "got %v", slices.Collect(refs.ResponseIDs()))
+	testutil.AssertTrue(t, refs[0].RetainUntil.Equal(now.Add(time.Minute)))
+	testutil.AssertEqual(t, time.Minute, cache.ttls[urlKey])
+
+	store("text/html", refs)
+	refs, err = rc.GetRefs(t.Context(), urlKey)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 2, len(refs))
+	testutil.AssertEqual(t, time.Hour, cache.ttls[urlKey])
+
+	// Responses retained indefinitely are stored without expiry.
+	retention["text/html"] = 0
+	delete(cache.ttls, urlKey)
+	store("text/html", refs)
+	_, ok := cache.ttls[urlKey]
+	testutil.AssertTrue(t, !ok, "refs stored with a TTL")
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import "time"
+
+// RetentionPolicy describes the interface implemented by types that can
+// determine how long a stored response remains useful to the cache, so that
+// backends implementing [driver.TTLSetter] can drop it once it is not.
+type RetentionPolicy interface {
+	// Retention returns how long, from now, the response should be retained;
+	// zero means indefinitely.
+	Retention(entry *Response) time.Duration
+}
+
+var _ RetentionPolicy = (*retentionPolicy)(nil)
+
+type retentionPolicy struct {
+	fc    FreshnessCalculator
+	grace time.Duration
+}
+
+// NewRetentionPolicy returns a policy that retains a response while it is
+// fresh, then for the longer of its stale-while-revalidate and stale-if-error
+// windows (RFC 5861), then for the grace period, during which it can still be
+// revalidated or served stale when a request allows it. A grace period of
+// zero or less retains responses indefinitely.
+func NewRetentionPolicy(fc FreshnessCalculator, grace time.Duration) *retentionPolicy {
+	return &retentionPolicy{fc, grace}
+}
+
+func (p *retentionPolicy) Retention(entry *Response) time.Duration {
+	if p.grace <= 0 {
+		return 0
+	}
+	resCC := ParseCCResponseDirectives(entry.Data.Header)
+	freshness := p.fc.CalculateFreshness(entry, nil, resCC)
+	swr, _ := resCC.StaleWhileRevalidate()
+	sie, _ := resCC.StaleIfError()
+	retention := p.grace
+	for _, d := range []time.Duration{
+		max(freshness.UsefulLife-freshness.Age.Value, 0),
+		max(swr, sie, 0),
+	} {
+		if d > maxDuration-retention {
+			return 0 // too far ahead to be worth expiring
+		}
+		retention += d
+	}
+	return retention
+}
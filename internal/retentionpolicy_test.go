@@ -0,0 +1,84 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+func Test_retentionPolicy_Retention(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	const grace = time.Hour
+	tests := []struct {
+		name   string
+		header http.Header
+		grace  time.Duration
+		want   time.Duration
+	}{
+		{
+			name:   "fresh",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			grace:  grace,
+			want:   time.Minute + grace,
+		},
+		{
+			name:   "fresh with age",
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Age": {"20"}},
+			grace:  grace,
+			want:   40*time.Second + grace,
+		},
+		{
+			name:   "longest stale window",
+			header: http.Header{"Cache-Control": {"max-age=60, stale-while-revalidate=30, stale-if-error=120"}},
+			grace:  grace,
+			want:   3*time.Minute + grace,
+		},
+		{
+			name:   "stale on arrival",
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Age": {"90"}},
+			grace:  grace,
+			want:   grace,
+		},
+		{
+			name:   "must revalidate",
+			header: http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}},
+			grace:  grace,
+			want:   grace,
+		},
+		{
+			name:   "no grace period",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			grace:  -1,
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.header.Set("Date", base.Format(http.TimeFormat))
+			entry := &Response{
+				Data:        &http.Response{StatusCode: http.StatusOK, Header: tt.header},
+				RequestedAt: base,
+				ReceivedAt:  base,
+			}
+			fc := NewFreshnessCalculator(&MockClock{NowResult: base})
+			got := NewRetentionPolicy(fc, tt.grace).Retention(entry)
+			testutil.AssertEqual(t, tt.want, got)
+		})
+	}
+}
@@ -100,7 +100,7 @@ func (r *validationResponseHandler) HandleValidationResponse(
 	if isStaleErrorAllowed(resp.StatusCode) && req.Method == http.MethodGet {
 		ccResp = ParseCCResponseDirectives(resp.Header)
 		ccRespOnce = true
-		if r.siep.CanStaleOnError(ctx.Freshness, ccResp) {
+		if r.canStaleOnError(ctx, ccResp) {
 			// RFC 9111 §4.2.4 Serving Stale Responses
 			// RFC 9111 §4.3.3 Handling Validation Responses (5xx errors)
 			SetAgeHeader(ctx.Stored.Data, r.clock, ctx.Freshness.Age)
@@ -122,7 +122,7 @@ func (r *validationResponseHandler) HandleValidationResponse(
 		r.l.LogCacheMiss(req, ctx.URLKey, ctx.ToMisc(ccResp))
 	case IsUnsafeMethod(req.Method) && IsNonErrorStatus(resp.StatusCode):
 		// RFC 9111 §4.4 Invalidation of Cache Entries
-		r.ci.InvalidateCache(req.URL, resp.Header, ctx.Refs, ctx.URLKey)
+		r.ci.InvalidateCache(req.Context(), req.URL, resp.Header, ctx.Refs, ctx.URLKey)
 		fallthrough
 	default:
 		CacheStatusBypass.ApplyTo(resp.Header)
@@ -130,3 +130,23 @@ func (r *validationResponseHandler) HandleValidationResponse(
 	}
 	return resp, nil
 }
+
+// canStaleOnError reports whether the stored response may be served in place
+// of an error response, as allowed by the stale-if-error directive of the
+// request, the stored response or the error response (RFC 5861 §4), unless
+// the stored response must not be served stale (RFC 9111 §4.2.4).
+func (r *validationResponseHandler) canStaleOnError(
+	ctx RevalidationContext,
+	ccResp CCResponseDirectives,
+) bool {
+	ccStored := ParseCCResponseDirectives(ctx.Stored.Data.Header)
+	if ccStored.MustRevalidate() {
+		return false
+	}
+	if fields, noCache := ccStored.NoCache(); noCache {
+		if _, qualified := fields.Value(); !qualified {
+			return false
+		}
+	}
+	return r.siep.CanStaleOnError(ctx.Freshness, ctx.CCReq, ccStored, ccResp)
+}
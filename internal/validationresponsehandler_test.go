@@ -191,3 +191,42 @@ func Test_validationResponseHandler_HandleValidationResponse(t *testing.T) {
 		})
 	}
 }
+
+func Test_validationResponseHandler_canStaleOnError(t *testing.T) {
+	base := time.Unix(0, 0).UTC()
+	tests := []struct {
+		name   string
+		stored string // Cache-Control of the stored response
+		req    string // Cache-Control of the request
+		resp   string // Cache-Control of the error response
+		want   bool
+	}{
+		{"none", "max-age=60", "", "", false},
+		{"stored response", "max-age=60, stale-if-error=60", "", "", true},
+		{"request", "max-age=60", "stale-if-error=60", "", true},
+		{"error response", "max-age=60", "", "stale-if-error=60", true},
+		{"window elapsed", "max-age=60, stale-if-error=10", "", "", false},
+		{"must-revalidate", "max-age=60, must-revalidate, stale-if-error=60", "", "", false},
+		{"no-cache", "max-age=60, no-cache, stale-if-error=60", "", "", false},
+		{"qualified no-cache", `max-age=60, no-cache="Set-Cookie", stale-if-error=60`, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &MockClock{NowResult: base}
+			handler := &validationResponseHandler{siep: NewStaleIfErrorPolicy(clock)}
+			ctx := RevalidationContext{
+				CCReq: ParseCCRequestDirectives(http.Header{"Cache-Control": {tt.req}}),
+				Stored: &Response{Data: &http.Response{
+					Header: http.Header{"Cache-Control": {tt.stored}},
+				}},
+				Freshness: &Freshness{
+					IsStale:    true,
+					Age:        &Age{Value: 90 * time.Second, Timestamp: base},
+					UsefulLife: time.Minute,
+				},
+			}
+			ccResp := ParseCCResponseDirectives(http.Header{"Cache-Control": {tt.resp}})
+			testutil.AssertTrue(t, handler.canStaleOnError(ctx, ccResp) == tt.want)
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/bartventer/httpcache/internal"
+	"github.com/bartventer/httpcache/store"
 )
 
 type Option interface {
@@ -45,6 +46,15 @@ func WithUpstream(upstream http.RoundTripper) Option {
 	})
 }
 
+// WithRegistry sets the driver registry in which [NewTransport] and
+// [NewClient] open the cache backend DSN; default: the registry used by
+// [store.Open].
+func WithRegistry(reg *store.Registry) Option {
+	return optionFunc(func(r *transport) {
+		r.registry = reg
+	})
+}
+
 // WithSWRTimeout sets the timeout for Stale-While-Revalidate requests;
 // default: [DefaultSWRTimeout].
 func WithSWRTimeout(timeout time.Duration) Option {
@@ -53,6 +63,30 @@ func WithSWRTimeout(timeout time.Duration) Option {
 	})
 }
 
+// WithRetentionGrace sets how long a stored response is retained once it is
+// stale and past its stale-while-revalidate and stale-if-error windows, during
+// which it can still be revalidated; default: [DefaultRetentionGrace]. Cache
+// backends that support expiry (see
+// [github.com/bartventer/httpcache/store/driver.TTLSetter]) drop responses,
+// and the references to them, at the end of this period. A negative grace
+// period retains responses indefinitely.
+func WithRetentionGrace(grace time.Duration) Option {
+	return optionFunc(func(r *transport) {
+		r.grace = grace
+	})
+}
+
+// WithClock sets the function the transport uses to tell the current time,
+// when computing the age and freshness of stored responses; default:
+// [time.Now]. It is mainly useful for testing.
+func WithClock(now func() time.Time) Option {
+	return optionFunc(func(r *transport) {
+		if now != nil {
+			r.clock = internal.ClockFunc(now)
+		}
+	})
+}
+
 // WithLogger sets the logger for debug output; default:
 // [slog.New]([slog.DiscardHandler]).
 func WithLogger(logger *slog.Logger) Option {
@@ -61,7 +61,8 @@ import (
 )
 
 const (
-	DefaultSWRTimeout = 5 * time.Second
+	DefaultSWRTimeout     = 5 * time.Second
+	DefaultRetentionGrace = 24 * time.Hour
 
 	CacheStatusHeader = internal.CacheStatusHeader
 )
@@ -74,7 +75,9 @@ type transport struct {
 	cache      internal.ResponseCache // Cache for storing and retrieving responses
 	upstream   http.RoundTripper      // Underlying round tripper for upstream/origin requests
 	swrTimeout time.Duration          // Timeout for Stale-While-Revalidate requests
+	grace      time.Duration          // How long responses are retained once no longer usable without validation
 	logger     *internal.Logger       // Logger for debug output, if needed
+	registry   *store.Registry        // Registry in which the cache DSN is opened, if not the default
 
 	// Internal details
 
@@ -116,19 +119,32 @@ var ErrOpenCache = errors.New("httpcache: failed to open cache")
 // NewTransport returns an [http.RoundTripper] that caches HTTP responses using
 // the specified cache backend.
 //
-// The dsn parameter follows the format documented in [store.Open].
+// The dsn parameter follows the format documented in [store.Open], and is
+// opened in the registry set by [WithRegistry], if any.
 // Configuration is done via functional options like [WithUpstream] and
 // [WithSWRTimeout].
 //
 // Panics with [ErrOpenCache] if the cache backend cannot be opened.
 func NewTransport(dsn string, options ...Option) http.RoundTripper {
-	cache, err := store.Open(dsn)
+	cache, err := openCache(dsn, options)
 	if err != nil {
 		panic(ErrOpenCache)
 	}
 	return newTransport(cache, options...)
 }
 
+// openCache opens dsn in the registry selected by options.
+func openCache(dsn string, options []Option) (driver.Conn, error) {
+	var rt transport
+	for _, opt := range options {
+		opt.apply(&rt)
+	}
+	if rt.registry != nil {
+		return rt.registry.Open(dsn)
+	}
+	return store.Open(dsn)
+}
+
 func newTransport(conn driver.Conn, options ...Option) http.RoundTripper {
 	rt := &transport{
 		cache: internal.NewResponseCache(conn),
@@ -144,6 +160,7 @@ func newTransport(conn driver.Conn, options ...Option) http.RoundTripper {
 	}
 	rt.upstream = cmp.Or(rt.upstream, http.DefaultTransport)
 	rt.swrTimeout = cmp.Or(max(rt.swrTimeout, 0), DefaultSWRTimeout)
+	rt.grace = cmp.Or(rt.grace, DefaultRetentionGrace)
 	if rt.logger == nil {
 		rt.logger = internal.NewLogger(slog.DiscardHandler)
 	}
@@ -155,6 +172,8 @@ func newTransport(conn driver.Conn, options ...Option) http.RoundTripper {
 		rt.cache,
 		internal.NewVaryHeaderNormalizer(),
 		internal.NewVaryKeyer(),
+		internal.NewRetentionPolicy(rt.fc, rt.grace),
+		rt.clock,
 	)
 	rt.vrh = internal.NewValidationResponseHandler(
 		rt.logger,
@@ -184,7 +203,7 @@ func (r *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		return r.handleUnrecognizedMethod(req, urlKey)
 	}
 
-	refs, err := r.cache.GetRefs(urlKey)
+	refs, entry, err := r.cache.GetRefsPrefetch(req.Context(), urlKey, req)
 	if err != nil || len(refs) == 0 {
 		return r.handleCacheMiss(req, urlKey, nil, -1)
 	}
@@ -194,7 +213,9 @@ func (r *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		return r.handleCacheMiss(req, urlKey, refs, -1)
 	}
 
-	entry, err := r.cache.Get(refs[refIndex].ResponseID, req)
+	if responseID := refs[refIndex].ResponseID; entry == nil || entry.ID != responseID {
+		entry, err = r.cache.Get(req.Context(), responseID, req)
+	}
 	if err != nil {
 		r.logger.LogCacheError(
 			"Error retrieving cache entry; possible corruption.",
@@ -234,8 +255,8 @@ func (r *transport) handleUnrecognizedMethod(
 		return nil, err
 	}
 	if internal.IsNonErrorStatus(resp.StatusCode) {
-		refs, _ := r.cache.GetRefs(urlKey)
-		r.ci.InvalidateCache(req.URL, resp.Header, refs, urlKey)
+		refs, _ := r.cache.GetRefs(req.Context(), urlKey)
+		r.ci.InvalidateCache(req.Context(), req.URL, resp.Header, refs, urlKey)
 	}
 	internal.CacheStatusBypass.ApplyTo(resp.Header)
 	r.logger.LogCacheBypass(
@@ -334,7 +355,7 @@ func (r *transport) handleCacheHit(
 		age := freshness.Age.Value + r.clock.Since(freshness.Age.Timestamp)
 		staleFor := age - freshness.UsefulLife
 		if staleFor >= 0 && staleFor < swr {
-			return r.handleStaleWhileRevalidate(req, stored, urlKey, freshness, ccReq)
+			return r.handleStaleWhileRevalidate(req, stored, urlKey, refs, refIndex, freshness, ccReq)
 		}
 	}
 
@@ -342,6 +363,7 @@ revalidate:
 	req = withConditionalHeaders(req, stored.Data.Header)
 	resp, start, end, err := r.roundTripTimed(req)
 	if err != nil {
+		_ = stored.Data.Body.Close()
 		return nil, err
 	}
 	ctx := internal.RevalidationContext{
@@ -354,7 +376,13 @@ revalidate:
 		RefIndex:  refIndex,
 		Freshness: freshness,
 	}
-	return r.vrh.HandleValidationResponse(ctx, req, resp)
+	resp, err = r.vrh.HandleValidationResponse(ctx, req, resp)
+	if err != nil || resp != stored.Data {
+		// The stored response was not served; release its body, which may
+		// be streamed from the backend.
+		_ = stored.Data.Body.Close()
+	}
+	return resp, err
 }
 
 func (r *transport) serveFromCache(
@@ -388,6 +416,8 @@ func (r *transport) handleStaleWhileRevalidate(
 	req *http.Request,
 	stored *internal.Response,
 	urlKey string,
+	refs internal.ResponseRefs,
+	refIndex int,
 	freshness *internal.Freshness,
 	ccReq internal.CCRequestDirectives,
 ) (*http.Response, error) {
@@ -399,7 +429,7 @@ func (r *transport) handleStaleWhileRevalidate(
 	//
 	// Open a discussion at github.com/bartventer/httpcache/issues if your use case requires
 	// guaranteed completion.
-	go r.backgroundRevalidate(req2, stored, urlKey, freshness, ccReq)
+	go r.backgroundRevalidate(req2, stored.ID, urlKey, refs, refIndex, freshness, ccReq)
 	internal.CacheStatusStale.ApplyTo(stored.Data.Header)
 	r.logger.LogCacheStaleRevalidate(req, urlKey, internal.MiscFunc(func() internal.Misc {
 		return internal.Misc{
@@ -413,17 +443,26 @@ func (r *transport) handleStaleWhileRevalidate(
 
 func (r *transport) backgroundRevalidate(
 	req *http.Request,
-	stored *internal.Response,
+	responseID string,
 	urlKey string,
+	refs internal.ResponseRefs,
+	refIndex int,
 	freshness *internal.Freshness,
 	ccReq internal.CCRequestDirectives,
 ) {
 	ctx, cancel := context.WithTimeout(req.Context(), r.swrTimeout)
 	defer cancel()
 	req = req.WithContext(ctx)
+	// The stored response was served to the client, which owns its header and
+	// body; revalidate a copy of it.
+	stored, err := r.cache.Get(ctx, responseID, req)
+	if err != nil {
+		return
+	}
 	errc := make(chan error, 1)
 	go func() {
 		defer close(errc)
+		defer stored.Data.Body.Close()
 		//nolint:bodyclose // The response is not used, so we don't need to close it.
 		resp, start, end, err := r.roundTripTimed(req)
 		if err != nil {
@@ -442,6 +481,8 @@ func (r *transport) backgroundRevalidate(
 			End:       end,
 			CCReq:     ccReq,
 			Stored:    stored,
+			Refs:      refs,
+			RefIndex:  refIndex,
 			Freshness: freshness,
 		}
 		//nolint:bodyclose // The response is not used, so we don't need to close it.
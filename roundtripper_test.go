@@ -15,6 +15,8 @@
 package httpcache
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -23,6 +25,8 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -30,6 +34,7 @@ import (
 	"github.com/bartventer/httpcache/internal"
 	"github.com/bartventer/httpcache/internal/testutil"
 	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/driver"
 	"github.com/bartventer/httpcache/store/memcache"
 )
 
@@ -793,6 +798,63 @@ func TestNewTransport_Panic(t *testing.T) {
 	})
 }
 
+func TestNewTransport_WithRegistry(t *testing.T) {
+	backend := memcache.Open()
+	reg := store.NewRegistry()
+	reg.Register("memcache", driver.DriverFunc(func(*url.URL) (driver.Conn, error) {
+		return backend, nil
+	}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	tr := NewTransport("memcache://", WithRegistry(reg))
+	resp, err := tr.RoundTrip(httptest.NewRequest(http.MethodGet, server.URL, nil))
+	testutil.RequireNoError(t, err)
+	_ = resp.Body.Close()
+	keys, err := backend.Keys("")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, len(keys) > 0, "response not stored in the registry's backend")
+
+	// Drivers of the default registry are not found in another registry.
+	testutil.RequirePanics(t, func() {
+		NewTransport("fscache://?appname=test", WithRegistry(reg))
+	})
+}
+
+func TestNewTransport_WithClock(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Date", now.Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	tr := newTransport(memcache.Open(), WithClock(func() time.Time { return now }))
+	get := func() *http.Response {
+		t.Helper()
+		resp, err := tr.RoundTrip(httptest.NewRequest(http.MethodGet, server.URL, nil))
+		testutil.RequireNoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	get()
+	now = now.Add(30 * time.Second)
+	resp := get()
+	assertCacheStatus(t, resp, internal.CacheStatusHit)
+	testutil.AssertEqual(t, "30", resp.Header.Get("Age"))
+	now = now.Add(time.Minute)
+	get()
+	testutil.AssertEqual(t, 2, calls, "stale response served without revalidation")
+}
+
 //nolint:cyclop // Acceptable complexity for a test function
 func Test_transport_Vary(t *testing.T) {
 	etag := `W/"1234567890"`
@@ -1045,3 +1107,318 @@ func Test_transport_Vary_MultipleHeaders(t *testing.T) {
 	_ = resp.Body.Close()
 	testutil.AssertEqual(t, "lang=en-us date=2026-01-01", string(body))
 }
+
+// ctxRecorder is a cache backend that records the contexts of its operations.
+type ctxRecorder struct {
+	driver.Conn
+	mu   sync.Mutex
+	ctxs []context.Context
+}
+
+func (c *ctxRecorder) record(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ctxs = append(c.ctxs, ctx)
+	return ctx.Err()
+}
+
+func (c *ctxRecorder) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := c.record(ctx); err != nil {
+		return nil, err
+	}
+	return c.Get(key)
+}
+
+func (c *ctxRecorder) SetContext(ctx context.Context, key string, value []byte) error {
+	if err := c.record(ctx); err != nil {
+		return err
+	}
+	return c.Set(key, value)
+}
+
+func (c *ctxRecorder) DeleteContext(ctx context.Context, key string) error {
+	if err := c.record(ctx); err != nil {
+		return err
+	}
+	return c.Delete(key)
+}
+
+func Test_transport_RequestContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	type ctxKey struct{}
+	backend := &ctxRecorder{Conn: memcache.Open()}
+	tr := newTransport(backend)
+	ctx := context.WithValue(t.Context(), ctxKey{}, "value")
+	for _, want := range []internal.CacheStatus{internal.CacheStatusMiss, internal.CacheStatusHit} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		testutil.RequireNoError(t, err)
+		resp, err := tr.RoundTrip(req)
+		testutil.RequireNoError(t, err)
+		_ = resp.Body.Close()
+		assertCacheStatus(t, resp, want)
+	}
+	// GetRefs and two Sets on the miss; GetRefs and Get on the hit.
+	testutil.AssertEqual(t, 5, len(backend.ctxs))
+	for _, c := range backend.ctxs {
+		testutil.AssertTrue(t, c.Value(ctxKey{}) == "value", "backend called without the request context")
+	}
+
+	// A cancelled request is a miss that fails upstream.
+	cancelled, cancel := context.WithCancel(t.Context())
+	cancel()
+	req, err := http.NewRequestWithContext(cancelled, http.MethodGet, server.URL, nil)
+	testutil.RequireNoError(t, err)
+	_, err = tr.RoundTrip(req)
+	testutil.RequireErrorIs(t, err, context.Canceled)
+}
+
+// batchRecorder is a batching cache backend that counts its lookups.
+type batchRecorder struct {
+	driver.Conn
+	driver.Batcher
+	gets, getMultis atomic.Int32
+}
+
+func (c *batchRecorder) Get(key string) ([]byte, error) {
+	c.gets.Add(1)
+	return c.Conn.Get(key)
+}
+
+func (c *batchRecorder) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	c.getMultis.Add(1)
+	return c.Batcher.GetMulti(ctx, keys)
+}
+
+func Test_transport_BatchLookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/vary" {
+			w.Header().Set("Vary", "Accept")
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path      string
+		wantGets  int32
+		wantMulti int32
+	}{
+		{"/", 0, 1},
+		{"/vary", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			conn := memcache.Open()
+			backend := &batchRecorder{Conn: conn, Batcher: conn}
+			tr := newTransport(backend)
+			for _, want := range []internal.CacheStatus{internal.CacheStatusMiss, internal.CacheStatusHit} {
+				backend.gets.Store(0)
+				backend.getMultis.Store(0)
+				req := httptest.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+				req.Header.Set("Accept", "text/plain")
+				resp, err := tr.RoundTrip(req)
+				testutil.RequireNoError(t, err)
+				_ = resp.Body.Close()
+				assertCacheStatus(t, resp, want)
+			}
+			testutil.AssertEqual(t, tt.wantMulti, backend.getMultis.Load())
+			testutil.AssertEqual(t, tt.wantGets, backend.gets.Load())
+		})
+	}
+}
+
+// ttlRecorder is a cache backend that records the time to live of each entry.
+type ttlRecorder struct {
+	driver.Conn
+	mu   sync.Mutex
+	ttls map[string]time.Duration
+}
+
+func (c *ttlRecorder) SetWithTTL(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	c.ttls[key] = ttl
+	c.mu.Unlock()
+	return c.Set(key, value)
+}
+
+func Test_transport_Retention(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60, stale-if-error=300")
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		options []Option
+		want    time.Duration // lower bound of the time to live
+	}{
+		{"default grace", nil, 6*time.Minute + DefaultRetentionGrace},
+		{"custom grace", []Option{WithRetentionGrace(time.Minute)}, 7 * time.Minute},
+		{"retained indefinitely", []Option{WithRetentionGrace(-1)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &ttlRecorder{Conn: memcache.Open(), ttls: make(map[string]time.Duration)}
+			tr := newTransport(backend, tt.options...)
+			resp, err := tr.RoundTrip(httptest.NewRequest(http.MethodGet, server.URL, nil))
+			testutil.RequireNoError(t, err)
+			_ = resp.Body.Close()
+
+			if tt.want == 0 {
+				testutil.AssertEqual(t, 0, len(backend.ttls), "entries stored with a TTL")
+				return
+			}
+			testutil.AssertEqual(t, 2, len(backend.ttls))
+			for key, ttl := range backend.ttls {
+				// Allow for the response's age when stored.
+				testutil.AssertTrue(t, ttl > tt.want-2*time.Second && ttl <= tt.want,
+					"unexpected TTL %v for %q", ttl, key)
+			}
+		})
+	}
+}
+
+// streamRecorder is a streaming cache backend that counts its open readers,
+// and records the values stored through writers and the largest write.
+type streamRecorder struct {
+	driver.Conn
+	open     atomic.Int32
+	written  atomic.Int32
+	maxWrite atomic.Int64
+}
+
+func (c *streamRecorder) OpenReader(key string) (io.ReadCloser, error) {
+	data, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	c.open.Add(1)
+	return &streamedReader{strings.NewReader(string(data)), c}, nil
+}
+
+func (c *streamRecorder) OpenWriter(key string) (io.WriteCloser, error) {
+	return &streamedWriter{c: c, key: key}, nil
+}
+
+type streamedWriter struct {
+	c   *streamRecorder
+	key string
+	buf bytes.Buffer
+}
+
+func (w *streamedWriter) Write(p []byte) (int, error) {
+	if n := int64(len(p)); n > w.c.maxWrite.Load() {
+		w.c.maxWrite.Store(n)
+	}
+	return w.buf.Write(p)
+}
+
+func (w *streamedWriter) Close() error {
+	w.c.written.Add(1)
+	return w.c.Set(w.key, w.buf.Bytes())
+}
+
+type streamedReader struct {
+	io.Reader
+	c *streamRecorder
+}
+
+func (r *streamedReader) Close() error {
+	r.c.open.Add(-1)
+	return nil
+}
+
+func Test_transport_StreamedBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/no-cache" {
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	backend := &streamRecorder{Conn: memcache.Open()}
+	tr := newTransport(backend)
+	for _, path := range []string{"/", "/no-cache"} {
+		resp, err := tr.RoundTrip(httptest.NewRequest(http.MethodGet, server.URL+path, nil))
+		testutil.RequireNoError(t, err)
+		_ = resp.Body.Close()
+		assertCacheStatus(t, resp, internal.CacheStatusMiss)
+	}
+
+	// A hit reads the body from the backend as it is consumed.
+	resp, err := tr.RoundTrip(httptest.NewRequest(http.MethodGet, server.URL, nil))
+	testutil.RequireNoError(t, err)
+	assertCacheStatus(t, resp, internal.CacheStatusHit)
+	testutil.AssertEqual(t, int32(1), backend.open.Load(), "body not streamed")
+	body, err := io.ReadAll(resp.Body)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "hello", string(body))
+	testutil.RequireNoError(t, resp.Body.Close())
+	testutil.AssertEqual(t, int32(0), backend.open.Load(), "reader not closed with the body")
+
+	// A stored response replaced on revalidation is closed.
+	resp, err = tr.RoundTrip(httptest.NewRequest(http.MethodGet, server.URL+"/no-cache", nil))
+	testutil.RequireNoError(t, err)
+	assertCacheStatus(t, resp, internal.CacheStatusMiss)
+	testutil.AssertEqual(t, int32(0), backend.open.Load(), "replaced response left open")
+	_ = resp.Body.Close()
+}
+
+func Test_transport_StreamedBody_Revalidated(t *testing.T) {
+	const etag = `"v1"`
+	body := strings.Repeat("0123456789abcdef", 16<<10) // larger than any read buffer
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.Header().Set("X-Revalidated", "true")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	backend := &streamRecorder{Conn: memcache.Open()}
+	tr := newTransport(backend)
+	resp, err := tr.RoundTrip(httptest.NewRequest(http.MethodGet, server.URL, nil))
+	testutil.RequireNoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	testutil.AssertEqual(t, int32(0), backend.written.Load())
+
+	// Freshening the stored response copies its body within the backend,
+	// while the client reads it from the stored response.
+	for range 2 {
+		resp, err = tr.RoundTrip(httptest.NewRequest(http.MethodGet, server.URL, nil))
+		testutil.RequireNoError(t, err)
+		assertCacheStatus(t, resp, internal.CacheStatusRevalidated)
+		got, err := io.ReadAll(resp.Body)
+		testutil.RequireNoError(t, err)
+		testutil.AssertTrue(t, string(got) == body, "body changed on revalidation")
+		testutil.RequireNoError(t, resp.Body.Close())
+	}
+	testutil.AssertEqual(t, int32(2), backend.written.Load(), "stored response not copied")
+	testutil.AssertTrue(t, backend.maxWrite.Load() < int64(len(body)), "body buffered when copied")
+	testutil.AssertEqual(t, int32(0), backend.open.Load(), "reader left open")
+
+	values, err := backend.Conn.(interface{ Keys(string) ([]string, error) }).Keys("")
+	testutil.RequireNoError(t, err)
+	for _, key := range values {
+		if internal.IsResponseKey(key) {
+			data, err := backend.Get(key)
+			testutil.RequireNoError(t, err)
+			testutil.AssertTrue(t, strings.Contains(string(data), "X-Revalidated: true"), "headers not freshened")
+		}
+	}
+}
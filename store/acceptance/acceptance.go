@@ -32,10 +32,35 @@
 //	    acceptance.Run(t, factory)
 //	}
 //
+// Backends with documented limits, or whose clock can be advanced to check
+// expiry, declare them with [Option]s:
+//
+//	acceptance.Run(t, factory,
+//	    acceptance.WithMaxKeyLen(1024),
+//	    acceptance.WithExpiry(func(_ driver.Conn, d time.Duration) { clock.Advance(d) }),
+//	)
+//
 // # Tests
 //
 // Verifies byte-identical storage/retrieval, overwrite behavior, deletion semantics,
 // error handling for non-existent keys, and optional key listing functionality.
+// Values range from empty to several megabytes of binary data, and keys include
+// URL delimiters ('#', '/', '?'), Unicode and lengths of 2 KiB. Stored values
+// must not alias the slices passed to Set or returned by Get, and concurrent
+// Set, Get and Delete calls must neither fail nor return torn values; run the
+// suite with -race.
+//
+// Backends that implement [expapi.KeyLister] must list keys by plain string
+// prefix, including a prefix equal to a key, and with no pattern syntax.
+//
+// Batch operations, native ([driver.Batcher]) or emulated by
+// [driver.AsBatcher], must give the same results as single-key ones. The
+// sub-suites for other optional interfaces are skipped unless the backend
+// implements them: values streamed through a [driver.Streamer] must be
+// interchangeable with those stored by Set, a [driver.TTLSetter] must keep
+// entries until their ttl elapses (checked when [WithExpiry] is given), and
+// a [driver.ConnContext] must fail without side effects once its context is
+// done.
 package acceptance
 
 import (
@@ -43,6 +68,7 @@ import (
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/bartventer/httpcache/internal/testutil"
 	"github.com/bartventer/httpcache/store/driver"
@@ -57,16 +83,81 @@ type FactoryFunc func() (driver.Conn, func())
 
 func (f FactoryFunc) Make() (driver.Conn, func()) { return f() }
 
+const (
+	defaultMaxKeyLen    = 2 << 10 // longest key tested by default
+	defaultMaxValueSize = 4 << 20 // largest value tested by default
+)
+
+type config struct {
+	maxKeyLen    int
+	maxValueSize int
+	advance      func(cache driver.Conn, d time.Duration)
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{maxKeyLen: defaultMaxKeyLen, maxValueSize: defaultMaxValueSize}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	return cfg
+}
+
+// Option configures the suite for the limits and capabilities of a backend.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(cfg *config) {
+	f(cfg)
+}
+
+// WithMaxKeyLen sets the length in bytes of the longest key the backend
+// accepts, if shorter than the longest key tested; default: 2 KiB.
+func WithMaxKeyLen(n int) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.maxKeyLen = min(n, defaultMaxKeyLen)
+	})
+}
+
+// WithMaxValueSize sets the size in bytes of the largest value the backend
+// accepts, if smaller than the largest value tested; default: 4 MiB.
+func WithMaxValueSize(n int) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.maxValueSize = min(n, defaultMaxValueSize)
+	})
+}
+
+// WithExpiry enables the checks that entries stored by a [driver.TTLSetter]
+// expire. The advance function must make d elapse for cache, which is the
+// backend under test, by advancing a fake clock, or else by sleeping.
+func WithExpiry(advance func(cache driver.Conn, d time.Duration)) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.advance = advance
+	})
+}
+
 // Run runs a standard suite of tests against the provided Cache implementation.
 // The factory function must return a new, empty Cache for each test.
-func Run(t *testing.T, factory Factory) {
+func Run(t *testing.T, factory Factory, opts ...Option) {
 	t.Helper()
+	cfg := newConfig(opts)
 	t.Run("SetAndGet", func(t *testing.T) { testSetAndGet(t, factory.Make) })
 	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, factory.Make) })
 	t.Run("Delete", func(t *testing.T) { testDelete(t, factory.Make) })
 	t.Run("GetNonexistent", func(t *testing.T) { testGetNonexistent(t, factory.Make) })
 	t.Run("DeleteNonexistent", func(t *testing.T) { testDeleteNonexistent(t, factory.Make) })
+	t.Run("Values", func(t *testing.T) { testValues(t, factory.Make, cfg) })
+	t.Run("Isolation", func(t *testing.T) { testIsolation(t, factory.Make) })
+	t.Run("OddKeys", func(t *testing.T) { testOddKeys(t, factory.Make, cfg) })
 	t.Run("Keys", func(t *testing.T) { testKeys(t, factory.Make) })
+	t.Run("ListPrefix", func(t *testing.T) { testListPrefix(t, factory.Make) })
+	t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, factory.Make) })
+	t.Run("Batch", func(t *testing.T) { testBatch(t, factory.Make) })
+	t.Run("Stream", func(t *testing.T) { testStream(t, factory.Make) })
+	t.Run("TTL", func(t *testing.T) { testTTL(t, factory.Make, cfg) })
+	t.Run("Context", func(t *testing.T) { testContext(t, factory.Make) })
 }
 
 func testSetAndGet(t *testing.T, factory FactoryFunc) {
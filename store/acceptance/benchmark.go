@@ -5,6 +5,7 @@ package acceptance
 
 import (
 	"errors"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -12,14 +13,28 @@ import (
 )
 
 // RunB runs the acceptance tests for a Cache implementation in a benchmark context.
-func RunB(b *testing.B, factory Factory) {
+// The options are the same as for [Run]; value sizes above the configured
+// maximum are skipped.
+func RunB(b *testing.B, factory Factory, opts ...Option) {
 	b.Helper()
+	cfg := newConfig(opts)
 
 	key := "benchmark_key" + strings.Repeat("x", 100)
 	value := []byte("benchmark_value")
 	b.Run("Get", func(b *testing.B) { benchmarkGet(b, factory.Make, key) })
 	b.Run("Set", func(b *testing.B) { benchmarkSet(b, factory.Make, key, value) })
 	b.Run("Delete", func(b *testing.B) { benchmarkDelete(b, factory.Make, key) })
+	for _, size := range []int{1 << 10, 64 << 10, 1 << 20} {
+		if size > cfg.maxValueSize {
+			continue
+		}
+		name := strconv.Itoa(size>>10) + "KiB"
+		b.Run("Get/"+name, func(b *testing.B) { benchmarkGetSize(b, factory.Make, size) })
+		b.Run("Set/"+name, func(b *testing.B) { benchmarkSetSize(b, factory.Make, size) })
+	}
+	b.Run("ParallelGet", func(b *testing.B) { benchmarkParallelGet(b, factory.Make) })
+	b.Run("ParallelSet", func(b *testing.B) { benchmarkParallelSet(b, factory.Make) })
+	b.Run("ParallelMixed", func(b *testing.B) { benchmarkParallelMixed(b, factory.Make) })
 }
 
 func benchmarkGet(b *testing.B, factory FactoryFunc, key string) {
@@ -63,3 +78,97 @@ func benchmarkDelete(b *testing.B, factory FactoryFunc, key string) {
 		}
 	}
 }
+
+func benchmarkGetSize(b *testing.B, factory FactoryFunc, size int) {
+	cache, cleanup := factory.Make()
+	defer cleanup()
+
+	if err := cache.Set("benchmark_key", pattern(size)); err != nil {
+		b.Fatalf("Set failed: %v", err)
+	}
+
+	b.SetBytes(int64(size))
+	for b.Loop() {
+		if _, err := cache.Get("benchmark_key"); err != nil {
+			b.Errorf("Get failed: %v", err)
+		}
+	}
+}
+
+func benchmarkSetSize(b *testing.B, factory FactoryFunc, size int) {
+	cache, cleanup := factory.Make()
+	defer cleanup()
+
+	value := pattern(size)
+	b.SetBytes(int64(size))
+	for b.Loop() {
+		if err := cache.Set("benchmark_key", value); err != nil {
+			b.Errorf("Set failed: %v", err)
+		}
+	}
+}
+
+// benchmarkKeys is the number of distinct keys used by the parallel
+// benchmarks.
+const benchmarkKeys = 64
+
+func benchmarkKey(i int) string { return "benchmark_key_" + strconv.Itoa(i%benchmarkKeys) }
+
+func benchmarkParallelGet(b *testing.B, factory FactoryFunc) {
+	cache, cleanup := factory.Make()
+	defer cleanup()
+
+	for i := range benchmarkKeys {
+		if err := cache.Set(benchmarkKey(i), []byte("value")); err != nil {
+			b.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, err := cache.Get(benchmarkKey(i)); err != nil {
+				b.Errorf("Get failed: %v", err)
+			}
+		}
+	})
+}
+
+func benchmarkParallelSet(b *testing.B, factory FactoryFunc) {
+	cache, cleanup := factory.Make()
+	defer cleanup()
+
+	value := []byte("benchmark_value")
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if err := cache.Set(benchmarkKey(i), value); err != nil {
+				b.Errorf("Set failed: %v", err)
+			}
+		}
+	})
+}
+
+// benchmarkParallelMixed runs a read-heavy workload: of every ten operations,
+// eight are gets, one is a set and one is a delete.
+func benchmarkParallelMixed(b *testing.B, factory FactoryFunc) {
+	cache, cleanup := factory.Make()
+	defer cleanup()
+
+	value := []byte("benchmark_value")
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			key := benchmarkKey(i)
+			var err error
+			switch i % 10 {
+			case 0:
+				err = cache.Set(key, value)
+			case 1:
+				err = cache.Delete(key)
+			default:
+				_, err = cache.Get(key)
+			}
+			if err != nil && !errors.Is(err, driver.ErrNotExist) {
+				b.Errorf("operation failed: %v", err)
+			}
+		}
+	})
+}
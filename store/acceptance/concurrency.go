@@ -0,0 +1,89 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+func testConcurrent(t *testing.T, factory FactoryFunc) {
+	cache, cleanup := factory.Make()
+	t.Cleanup(cleanup)
+
+	const (
+		workers = 8
+		rounds  = 20
+		shared  = "concurrent/shared"
+	)
+	// Values of different lengths, so that torn writes are detected.
+	values := make([][]byte, workers)
+	for i := range values {
+		values[i] = bytes.Repeat([]byte{byte('a' + i)}, 1000+100*i)
+	}
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Go(func() {
+			own := "concurrent/" + strconv.Itoa(i)
+			for j := range rounds {
+				if err := cache.Set(shared, values[i]); err != nil {
+					t.Errorf("Set failed for key %s: %v", shared, err)
+					return
+				}
+				got, err := cache.Get(shared)
+				switch {
+				case errors.Is(err, driver.ErrNotExist): // deleted by another worker
+				case err != nil:
+					t.Errorf("Get failed for key %s: %v", shared, err)
+					return
+				case !slices.ContainsFunc(values, func(v []byte) bool { return bytes.Equal(got, v) }):
+					t.Errorf("Get returned a torn value of %d bytes for key %s", len(got), shared)
+					return
+				}
+				if j%5 == 4 {
+					if err := cache.Delete(shared); err != nil && !errors.Is(err, driver.ErrNotExist) {
+						t.Errorf("Delete failed for key %s: %v", shared, err)
+						return
+					}
+				}
+
+				// A key written by a single worker reads back what it wrote.
+				want := []byte(own + "/" + strconv.Itoa(j))
+				if err := cache.Set(own, want); err != nil {
+					t.Errorf("Set failed for key %s: %v", own, err)
+					return
+				}
+				if got, err := cache.Get(own); err != nil || !bytes.Equal(got, want) {
+					t.Errorf("Get(%q) = %q, %v; want %q", own, got, err, want)
+					return
+				}
+			}
+			if err := cache.Delete(own); err != nil {
+				t.Errorf("Delete failed for key %s: %v", own, err)
+				return
+			}
+			if _, err := cache.Get(own); !errors.Is(err, driver.ErrNotExist) {
+				t.Errorf("Get after delete of key %s returned %v, want ErrNotExist", own, err)
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+)
+
+// oddKeys returns keys that are likely to trip up backends that map keys to
+// file names, URLs, or protocol tokens, none longer than maxLen bytes.
+func oddKeys(maxLen int) []string {
+	long := "https://example.com/" + strings.Repeat("k", maxLen)
+	return []string{
+		"https://example.com/path#0",
+		"https://example.com/path#-1234567890",
+		"https://example.com/a/b/c/",
+		"https://example.com/?q=1&r=%2F#frag",
+		"/leading/slash",
+		"a//b",
+		"..",
+		"../escape",
+		`back\slash`,
+		"with space",
+		"percent%2Fencoded",
+		"*?[glob]",
+		"ключ/日本語/🙂",
+		long[:maxLen],
+	}
+}
+
+func testOddKeys(t *testing.T, factory FactoryFunc, cfg *config) {
+	cache, cleanup := factory.Make()
+	t.Cleanup(cleanup)
+
+	keys := oddKeys(cfg.maxKeyLen)
+	for _, key := range keys {
+		testutil.RequireNoError(t, cache.Set(key, []byte("value for "+key)), "Set failed for key "+key)
+	}
+	for _, key := range keys {
+		got, err := cache.Get(key)
+		testutil.RequireNoError(t, err, "Get failed for key "+key)
+		testutil.AssertEqual(t, "value for "+key, string(got), "Get returned unexpected value for key "+key)
+	}
+	if kl, ok := cache.(expapi.KeyLister); ok {
+		got, err := kl.Keys("")
+		testutil.RequireNoError(t, err, "Keys failed")
+		slices.Sort(got)
+		testutil.AssertTrue(t, slices.Equal(got, slices.Sorted(slices.Values(keys))), "Keys returned %q", got)
+	}
+
+	// Deleting one key leaves the others alone.
+	testutil.RequireNoError(t, cache.Delete(keys[0]), "Delete failed for key "+keys[0])
+	_, err := cache.Get(keys[0])
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "Get after delete did not return ErrNotExist for key "+keys[0])
+	for _, key := range keys[1:] {
+		_, err := cache.Get(key)
+		testutil.RequireNoError(t, err, "Get failed after deleting another key for key "+key)
+	}
+}
+
+func testListPrefix(t *testing.T, factory FactoryFunc) {
+	cache, cleanup := factory.Make()
+	t.Cleanup(cleanup)
+
+	kl, ok := cache.(expapi.KeyLister)
+	if !ok {
+		t.Skip("Cache implementation does not support key listing")
+	}
+	keys := []string{"pre", "pre/a", "pre/b", "prefix", "pr", "other", "pre*", "pre?", "pre%"}
+	for _, key := range keys {
+		testutil.RequireNoError(t, cache.Set(key, []byte("v")), "Set failed for key "+key)
+	}
+	list := func(prefix string) []string {
+		t.Helper()
+		got, err := kl.Keys(prefix)
+		testutil.RequireNoError(t, err, "Keys failed for prefix "+prefix)
+		slices.Sort(got)
+		return got
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", slices.Sorted(slices.Values(keys))},
+		{"pre", []string{"pre", "pre%", "pre*", "pre/a", "pre/b", "pre?", "prefix"}}, // prefix equal to a key
+		{"pre/", []string{"pre/a", "pre/b"}},
+		{"pre/a", []string{"pre/a"}},
+		{"pre*", []string{"pre*"}}, // no glob syntax
+		{"pre?", []string{"pre?"}},
+		{"pre%", []string{"pre%"}}, // no LIKE syntax
+		{"nomatch", nil},
+		{"pre/a/", nil}, // longer than every matching key
+	}
+	for _, tt := range tests {
+		got := list(tt.prefix)
+		testutil.AssertTrue(t, slices.Equal(got, tt.want), "Keys(%q) = %q, want %q", tt.prefix, got, tt.want)
+	}
+
+	// Deleted keys are not listed.
+	testutil.RequireNoError(t, cache.Delete("pre/a"), "Delete failed")
+	got := list("pre/")
+	testutil.AssertTrue(t, slices.Equal(got, []string{"pre/b"}), "Keys after delete = %q", got)
+
+	// The returned slice belongs to the caller.
+	got = list("")
+	clear(got)
+	got = list("")
+	testutil.AssertTrue(t, slices.Equal(got, slices.DeleteFunc(slices.Sorted(slices.Values(keys)), func(k string) bool {
+		return k == "pre/a"
+	})), "Keys changed with a returned slice: %q", got)
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+func testBatch(t *testing.T, factory FactoryFunc) {
+	cache, cleanup := factory.Make()
+	t.Cleanup(cleanup)
+
+	ctx := t.Context()
+	b := driver.AsBatcher(cache)
+	values := map[string][]byte{
+		"batch1": []byte("one"),
+		"batch2": []byte("two"),
+		"batch3": bytes.Repeat([]byte{0, 1, 2, 255}, 1024), // binary data
+	}
+	testutil.RequireNoError(t, b.SetMulti(ctx, values), "SetMulti failed")
+	for key, want := range values {
+		got, err := cache.Get(key)
+		testutil.RequireNoError(t, err, "Get after SetMulti failed for key "+key)
+		testutil.AssertTrue(t, bytes.Equal(got, want), "Get after SetMulti returned unexpected value for key "+key)
+	}
+
+	testutil.RequireNoError(t, cache.Set("single", []byte("single")), "Set failed")
+	keys := []string{"batch1", "batch2", "batch3", "single", "doesnotexist"}
+	got, err := b.GetMulti(ctx, keys)
+	testutil.RequireNoError(t, err, "GetMulti failed")
+	testutil.AssertTrue(t, len(got) == 4, "GetMulti returned unexpected number of values")
+	for key, value := range got {
+		want, err := cache.Get(key)
+		testutil.RequireNoError(t, err, "Get failed for key "+key)
+		testutil.AssertTrue(t, bytes.Equal(value, want), "GetMulti and Get differ for key "+key)
+	}
+	_, ok := got["doesnotexist"]
+	testutil.AssertTrue(t, !ok, "GetMulti returned a value for a non-existent key")
+
+	// Overwrite through the batch.
+	testutil.RequireNoError(t, b.SetMulti(ctx, map[string][]byte{"single": []byte("batch")}), "SetMulti overwrite failed")
+	value, err := cache.Get("single")
+	testutil.RequireNoError(t, err, "Get after SetMulti overwrite failed")
+	testutil.AssertTrue(t, bytes.Equal(value, []byte("batch")), "SetMulti did not overwrite")
+
+	testutil.RequireNoError(t, b.DeleteMulti(ctx, []string{"batch1", "single", "doesnotexist"}), "DeleteMulti failed")
+	for _, key := range []string{"batch1", "single"} {
+		_, err := cache.Get(key)
+		testutil.RequireErrorIs(t, err, driver.ErrNotExist, "Get after DeleteMulti did not return ErrNotExist for key "+key)
+	}
+	got, err = b.GetMulti(ctx, slices.Sorted(maps.Keys(values)))
+	testutil.RequireNoError(t, err, "GetMulti after DeleteMulti failed")
+	testutil.AssertTrue(t, len(got) == 2, "DeleteMulti removed unexpected keys")
+
+	// Empty batches are no-ops.
+	got, err = b.GetMulti(ctx, nil)
+	testutil.RequireNoError(t, err, "GetMulti of no keys failed")
+	testutil.AssertTrue(t, len(got) == 0, "GetMulti of no keys returned values")
+	testutil.RequireNoError(t, b.SetMulti(ctx, nil), "SetMulti of no values failed")
+	testutil.RequireNoError(t, b.DeleteMulti(ctx, nil), "DeleteMulti of no keys failed")
+}
+
+func testStream(t *testing.T, factory FactoryFunc) {
+	cache, cleanup := factory.Make()
+	t.Cleanup(cleanup)
+
+	s, ok := cache.(driver.Streamer)
+	if !ok {
+		t.Skip("Cache implementation does not support streaming")
+	}
+	value := bytes.Repeat([]byte{0, 1, 2, 255}, 64<<10) // larger than typical buffers
+	w, err := s.OpenWriter("stream")
+	testutil.RequireNoError(t, err, "OpenWriter failed")
+	for chunk := range slices.Chunk(value, 3000) {
+		_, err = w.Write(chunk)
+		testutil.RequireNoError(t, err, "Write failed")
+	}
+	_, err = cache.Get("stream")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "value visible before the writer was closed")
+	testutil.RequireNoError(t, w.Close(), "Close of writer failed")
+
+	got, err := cache.Get("stream")
+	testutil.RequireNoError(t, err, "Get of streamed value failed")
+	testutil.AssertTrue(t, bytes.Equal(got, value), "Get returned unexpected value for streamed key")
+
+	testutil.RequireNoError(t, cache.Set("set", value[:100]), "Set failed")
+	for key, want := range map[string][]byte{"stream": value, "set": value[:100]} {
+		r, err := s.OpenReader(key)
+		testutil.RequireNoError(t, err, "OpenReader failed for key "+key)
+		got, err := io.ReadAll(r)
+		testutil.RequireNoError(t, err, "Read failed for key "+key)
+		testutil.RequireNoError(t, r.Close(), "Close of reader failed for key "+key)
+		testutil.AssertTrue(t, bytes.Equal(got, want), "OpenReader returned unexpected value for key "+key)
+	}
+
+	_, err = s.OpenReader("doesnotexist")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "OpenReader of non-existent key did not return ErrNotExist")
+}
+
+func testTTL(t *testing.T, factory FactoryFunc, cfg *config) {
+	cache, cleanup := factory.Make()
+	t.Cleanup(cleanup)
+
+	ts, ok := cache.(driver.TTLSetter)
+	if !ok {
+		t.Skip("Cache implementation does not support expiry")
+	}
+	ctx := t.Context()
+	for key, ttl := range map[string]time.Duration{"ttl/long": time.Hour, "ttl/none": 0, "ttl/negative": -time.Second} {
+		testutil.RequireNoError(t, ts.SetWithTTL(ctx, key, []byte(key), ttl), "SetWithTTL failed for key "+key)
+		got, err := cache.Get(key)
+		testutil.RequireNoError(t, err, "Get after SetWithTTL failed for key "+key)
+		testutil.AssertEqual(t, key, string(got), "Get after SetWithTTL returned unexpected value for key "+key)
+	}
+	if cfg.advance == nil {
+		return
+	}
+	testutil.RequireNoError(t, ts.SetWithTTL(ctx, "ttl/short", []byte("v"), 2*time.Second), "SetWithTTL failed")
+	cfg.advance(cache, time.Second)
+	_, err := cache.Get("ttl/short")
+	testutil.RequireNoError(t, err, "entry expired before its ttl")
+	cfg.advance(cache, 2*time.Second)
+	_, err = cache.Get("ttl/short")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "entry did not expire after its ttl")
+	for _, key := range []string{"ttl/long", "ttl/none"} {
+		_, err := cache.Get(key)
+		testutil.RequireNoError(t, err, "entry expired early for key "+key)
+	}
+}
+
+func testContext(t *testing.T, factory FactoryFunc) {
+	cache, cleanup := factory.Make()
+	t.Cleanup(cleanup)
+
+	cc, ok := cache.(driver.ConnContext)
+	if !ok {
+		t.Skip("Cache implementation does not support contexts")
+	}
+	ctx := t.Context()
+	testutil.RequireNoError(t, cc.SetContext(ctx, "ctx", []byte("v")), "SetContext failed")
+	got, err := cc.GetContext(ctx, "ctx")
+	testutil.RequireNoError(t, err, "GetContext failed")
+	testutil.AssertEqual(t, "v", string(got), "GetContext returned unexpected value")
+	_, err = cc.GetContext(ctx, "doesnotexist")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "GetContext of non-existent key did not return ErrNotExist")
+	err = cc.DeleteContext(ctx, "doesnotexist")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "DeleteContext of non-existent key did not return ErrNotExist")
+
+	// Operations fail without side effects once the context is done.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = cc.GetContext(canceled, "ctx")
+	testutil.RequireErrorIs(t, err, context.Canceled, "GetContext with a canceled context")
+	err = cc.SetContext(canceled, "ctx", []byte("new"))
+	testutil.RequireErrorIs(t, err, context.Canceled, "SetContext with a canceled context")
+	err = cc.DeleteContext(canceled, "ctx")
+	testutil.RequireErrorIs(t, err, context.Canceled, "DeleteContext with a canceled context")
+	got, err = cache.Get("ctx")
+	testutil.RequireNoError(t, err, "entry removed by a canceled operation")
+	testutil.AssertEqual(t, "v", string(got), "entry changed by a canceled operation")
+
+	testutil.RequireNoError(t, cc.DeleteContext(ctx, "ctx"), "DeleteContext failed")
+	_, err = cache.Get("ctx")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "Get after DeleteContext did not return ErrNotExist")
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+// pattern returns n bytes of deterministic binary data, covering every byte
+// value.
+func pattern(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i*31 + i>>8)
+	}
+	return b
+}
+
+func testValues(t *testing.T, factory FactoryFunc, cfg *config) {
+	cache, cleanup := factory.Make()
+	t.Cleanup(cleanup)
+
+	values := map[string][]byte{
+		"empty":  {},
+		"binary": pattern(256 * 4),
+		"large":  pattern(cfg.maxValueSize),
+	}
+	for key, value := range values {
+		testutil.RequireNoError(t, cache.Set(key, value), "Set failed for key "+key)
+	}
+	for key, want := range values {
+		got, err := cache.Get(key)
+		testutil.RequireNoError(t, err, "Get failed for key "+key)
+		testutil.AssertTrue(t, bytes.Equal(got, want),
+			"Get returned %d bytes for key %s, want %d identical bytes", len(got), key, len(want))
+	}
+
+	// An empty value is stored, rather than treated as a deletion.
+	testutil.RequireNoError(t, cache.Delete("empty"), "Delete of empty value failed")
+	_, err := cache.Get("empty")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "Get after delete of empty value did not return ErrNotExist")
+
+	// Replacing a large value with a small one leaves nothing of the former.
+	testutil.RequireNoError(t, cache.Set("large", []byte("small")), "Set overwrite of large value failed")
+	got, err := cache.Get("large")
+	testutil.RequireNoError(t, err, "Get after overwrite of large value failed")
+	testutil.AssertEqual(t, "small", string(got), "Get after overwrite of large value returned unexpected value")
+}
+
+func testIsolation(t *testing.T, factory FactoryFunc) {
+	cache, cleanup := factory.Make()
+	t.Cleanup(cleanup)
+
+	value := []byte("original")
+	testutil.RequireNoError(t, cache.Set("key", value), "Set failed")
+	value[0] = 'X' // the caller may reuse the slice passed to Set
+
+	got, err := cache.Get("key")
+	testutil.RequireNoError(t, err, "Get failed")
+	testutil.AssertEqual(t, "original", string(got), "stored value changed with the slice passed to Set")
+	got[0] = 'Y' // the caller may modify the slice returned by Get
+
+	got, err = cache.Get("key")
+	testutil.RequireNoError(t, err, "Get failed")
+	testutil.AssertEqual(t, "original", string(got), "stored value changed with the slice returned by Get")
+}
@@ -15,8 +15,9 @@
 // Package store provides a registry and entry point for cache backends.
 //
 // This package allows you to register cache drivers and open cache connections
-// using a DSN string. It acts as a facade over the internal registry and the
-// driver interfaces defined in the [driver] subpackage.
+// using a DSN string, in the default registry or in a [Registry] of your own.
+// It acts as a facade over the internal registry and the driver interfaces
+// defined in the [driver] subpackage.
 //
 // Most users won't interact with this package directly, but will instead use it
 // indirectly through higher-level caching abstractions.
@@ -25,7 +26,10 @@
 package store
 
 import (
+	"io"
+
 	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/internal/keyspace"
 	"github.com/bartventer/httpcache/store/internal/registry"
 )
 
@@ -34,8 +38,17 @@ import (
 // a DSN string used in [Open] to identify the driver to use.
 // If Register is called twice with the same name or if driver is nil, it
 // panics.
+//
+// Register adds the driver to the default registry, which the built-in
+// drivers register with when imported. See [Registry] to keep drivers apart.
 func Register(name string, driver driver.Driver) {
-	registry.Default().RegisterDriver(name, driver)
+	defaultRegistry.Register(name, driver)
+}
+
+// Unregister removes the driver registered by the provided name from the
+// default registry, if any.
+func Unregister(name string) {
+	defaultRegistry.Unregister(name)
 }
 
 // Open establishes a connection to a registered driver, as specified in the
@@ -53,12 +66,103 @@ func Register(name string, driver driver.Driver) {
 //   - query_parameters: Optional key-value pairs for additional driver-
 //     specific settings.
 //
-// See [Register] for registering drivers.
-func Open(dsn string) (driver.Conn, error) {
-	return registry.Default().OpenConn(dsn)
+// See [Register] for registering drivers, and [WithNamespace] for sharing a
+// backend between several users.
+func Open(dsn string, opts ...OpenOption) (driver.Conn, error) {
+	return defaultRegistry.Open(dsn, opts...)
+}
+
+type openConfig struct {
+	namespace string
+}
+
+type OpenOption interface {
+	apply(*openConfig)
+}
+
+type openOptionFunc func(*openConfig)
+
+func (f openOptionFunc) apply(cfg *openConfig) {
+	f(cfg)
+}
+
+// WithNamespace confines the keys of the opened connection to the given
+// namespace of the backend, as the namespace backend does; default: none.
+// Opening fails if the namespace is empty, contains ':', or is "http" or
+// "https", whose keys would overlap those of URLs stored without a namespace.
+// Closing the connection closes the backend.
+func WithNamespace(ns string) OpenOption {
+	return openOptionFunc(func(cfg *openConfig) {
+		cfg.namespace = ns
+	})
 }
 
 // Drivers returns a sorted list of the names of the registered drivers.
 func Drivers() []string {
-	return registry.Default().Drivers()
+	return defaultRegistry.Drivers()
+}
+
+// Registry is a set of drivers, keyed by name, independent of the default
+// registry used by [Register] and [Open]. Tests can register fake drivers in
+// a registry of their own, and libraries can register a driver under a name
+// that is already taken in the default registry.
+//
+// Wrapper drivers, such as encrypted and tiered, open the DSNs they wrap in
+// the registry that opens them; see [driver.WrapperDriver].
+//
+// A Registry is safe for concurrent use.
+type Registry struct {
+	dr *registry.Registry
+}
+
+// defaultRegistry is the registry used by the package-level functions.
+var defaultRegistry = &Registry{registry.Default()}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{registry.New()}
+}
+
+// Register makes a driver available in the registry by the provided name; see
+// the package-level [Register].
+func (r *Registry) Register(name string, driver driver.Driver) {
+	r.dr.RegisterDriver(name, driver)
+}
+
+// Unregister removes the driver registered by the provided name, if any.
+func (r *Registry) Unregister(name string) {
+	r.dr.UnregisterDriver(name)
+}
+
+// Open establishes a connection to a driver in the registry; see the
+// package-level [Open].
+func (r *Registry) Open(dsn string, opts ...OpenOption) (driver.Conn, error) {
+	cfg := &openConfig{}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	conn, err := r.dr.OpenConn(dsn)
+	if err != nil || cfg.namespace == "" {
+		return conn, err
+	}
+	nsConn, err := keyspace.Adopt(conn, cfg.namespace)
+	if err != nil {
+		if c, ok := conn.(io.Closer); ok {
+			_ = c.Close()
+		}
+		return nil, err
+	}
+	return nsConn, nil
+}
+
+// OpenConn is like Open without options. It lets a registry serve the
+// maintenance API; see
+// [github.com/bartventer/httpcache/store/expapi.NewService].
+func (r *Registry) OpenConn(dsn string) (driver.Conn, error) {
+	return r.Open(dsn)
+}
+
+// Drivers returns a sorted list of the names of the drivers in the registry.
+func (r *Registry) Drivers() []string {
+	return r.dr.Drivers()
 }
@@ -0,0 +1,115 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+	"github.com/bartventer/httpcache/store/internal/keyspace"
+	"github.com/bartventer/httpcache/store/internal/registry"
+)
+
+var _ expapi.Opener = (*Registry)(nil)
+
+type mapConn map[string][]byte
+
+func (m mapConn) Get(key string) ([]byte, error) {
+	v, ok := m[key]
+	if !ok {
+		return nil, driver.ErrNotExist
+	}
+	return v, nil
+}
+
+func (m mapConn) Set(key string, value []byte) error {
+	m[key] = value
+	return nil
+}
+
+func (m mapConn) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestOpen_WithNamespace(t *testing.T) {
+	shared := mapConn{}
+	Register("storetest", driver.DriverFunc(func(*url.URL) (driver.Conn, error) {
+		return shared, nil
+	}))
+	t.Cleanup(func() { Unregister("storetest") })
+
+	conn, err := Open("storetest://", WithNamespace("a"))
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, conn.Set("k", []byte("v")))
+	_, ok := shared["a:k"]
+	testutil.AssertTrue(t, ok, "key not stored in the namespace")
+
+	_, err = Open("storetest://", WithNamespace("a:b"))
+	testutil.RequireErrorIs(t, err, keyspace.ErrInvalidNamespace)
+	_, err = Open("storetest://", WithNamespace("https"))
+	testutil.RequireErrorIs(t, err, keyspace.ErrInvalidNamespace)
+
+	conn, err = Open("storetest://")
+	testutil.RequireNoError(t, err)
+	_, err = conn.Get("k")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+}
+
+func TestRegistry(t *testing.T) {
+	a, b := mapConn{}, mapConn{}
+	ra, rb := NewRegistry(), NewRegistry()
+	ra.Register("storetest", driver.DriverFunc(func(*url.URL) (driver.Conn, error) { return a, nil }))
+	rb.Register("storetest", driver.DriverFunc(func(*url.URL) (driver.Conn, error) { return b, nil }))
+
+	conn, err := ra.Open("storetest://", WithNamespace("ns"))
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, conn.Set("k", []byte("v")))
+	_, ok := a["ns:k"]
+	testutil.AssertTrue(t, ok, "key not stored through the first registry")
+	testutil.AssertEqual(t, 0, len(b), "registries share a driver")
+
+	// Registries are independent of each other and of the default registry.
+	_, err = Open("storetest://")
+	testutil.RequireErrorIs(t, err, registry.ErrUnknownDriver)
+	testutil.AssertTrue(t, !slices.Contains(Drivers(), "storetest"))
+	testutil.AssertTrue(t, slices.Equal(rb.Drivers(), []string{"storetest"}))
+
+	ra.Unregister("storetest")
+	_, err = ra.OpenConn("storetest://")
+	testutil.RequireErrorIs(t, err, registry.ErrUnknownDriver)
+	_, err = rb.OpenConn("storetest://")
+	testutil.RequireNoError(t, err)
+}
+
+func TestRegistry_ExpAPI(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register("storetest", driver.DriverFunc(func(*url.URL) (driver.Conn, error) {
+		return mapConn{"k": []byte("v")}, nil
+	}))
+	mux := http.NewServeMux()
+	expapi.NewService(reg).Register(expapi.WithServeMux(mux))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/httpcache/k?dsn=storetest://", nil))
+	testutil.AssertEqual(t, http.StatusOK, rr.Code)
+	testutil.AssertEqual(t, "v", rr.Body.String())
+}
@@ -0,0 +1,530 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compressed implements a cache backend that compresses the values
+// stored in any other backend.
+//
+// Cached responses are stored as their wire representation, which is
+// usually uncompressed, as the HTTP client decompresses response bodies
+// transparently. Compressing them saves space, and bandwidth to network
+// backends, at the cost of CPU time.
+//
+// Values smaller than the minimum size, or that do not shrink, are stored as
+// is. Compressed values start with a header naming their codec, so values
+// written before the wrapper was introduced, or by another codec, remain
+// readable.
+//
+// The cache does not implement [driver.Streamer], even if the wrapped backend
+// does: whether a value is compressed depends on its size, which is only
+// known once it has been written in full. Values are held in memory instead.
+//
+// # Configuration Parameters
+//
+// The driver wraps the DSN of another backend: compressed+<scheme>://...,
+// such as compressed+fscache://?appname=myapp. The following query
+// parameters are consumed by the wrapper; the others are passed on to the
+// wrapped backend:
+//
+//   - compress_codec (optional): "gzip" or "flate" (default: "gzip")
+//   - compress_level (optional): Compression level, from -2 (Huffman only) to 9 (best compression) (default: -1, the codec's default)
+//   - compress_min_size (optional): Values smaller than this, e.g. "4KiB", are stored uncompressed (default: 1KiB)
+//
+// # Usage Examples
+//
+//	The DSN and equivalent programmatic usage are shown below.
+//
+//	 Compressed file system cache:
+//
+//		compressed+fscache://?appname=myapp
+//		compressed.New(fscacheConn)
+//
+//	 Faster compression of larger values:
+//
+//		compressed+redis://localhost:6379?compress_codec=flate&compress_level=1&compress_min_size=4KiB
+//		compressed.New(redisConn, compressed.WithCodec(compressed.Flate(1)), compressed.WithMinSize(4<<10))
+package compressed
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+	"github.com/bartventer/httpcache/store/internal/bytesize"
+	"github.com/bartventer/httpcache/store/internal/keylock"
+	"github.com/bartventer/httpcache/store/internal/registry"
+)
+
+const Scheme = "compressed"
+
+// Driver opens DSNs of the form compressed+<scheme>://.... It is registered in
+// the default registry under [Scheme]; register it in a [store.Registry] to
+// open the DSNs it names with the drivers of that registry.
+var Driver driver.WrapperDriver = registry.WrapperFunc(fromURL)
+
+//nolint:gochecknoinits // We use init to register the driver.
+func init() {
+	store.Register(Scheme, Driver)
+}
+
+var (
+	ErrInvalidParam = errors.New("compressed: invalid DSN parameter")
+	// ErrDecompress is returned by Get for compressed values that fail to
+	// decompress, because they are corrupt or their codec is unknown. It
+	// satisfies errors.Is(err, driver.ErrCorrupt).
+	ErrDecompress = fmt.Errorf("compressed: failed to decompress value: %w", driver.ErrCorrupt)
+)
+
+const defaultMinSize = 1 << 10
+
+// Codec compresses values. Its name is stored with each value it compresses,
+// and selects the codec that decompresses it.
+type Codec interface {
+	// Name identifies the codec; it must be unique and at most 255 bytes.
+	Name() string
+	// NewWriter returns a writer that compresses to w. Closing it flushes
+	// the compressed data, but does not close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader that decompresses from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Gzip returns a [Codec] that compresses with gzip (RFC 1952) at the given
+// level; see [gzip.NewWriterLevel].
+func Gzip(level int) Codec {
+	return &gzipCodec{level: level}
+}
+
+type gzipCodec struct {
+	level int
+	pool  sync.Pool // of *gzip.Writer
+}
+
+func (*gzipCodec) Name() string { return "gzip" }
+
+func (c *gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if zw, ok := c.pool.Get().(*gzip.Writer); ok {
+		zw.Reset(w)
+		return &pooledWriter{zw, &c.pool}, nil
+	}
+	zw, err := gzip.NewWriterLevel(w, c.level)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledWriter{zw, &c.pool}, nil
+}
+
+func (*gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// Flate returns a [Codec] that compresses with DEFLATE (RFC 1951) at the
+// given level; see [flate.NewWriter].
+func Flate(level int) Codec {
+	return &flateCodec{level: level}
+}
+
+type flateCodec struct {
+	level int
+	pool  sync.Pool // of *flate.Writer
+}
+
+func (*flateCodec) Name() string { return "flate" }
+
+func (c *flateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if zw, ok := c.pool.Get().(*flate.Writer); ok {
+		zw.Reset(w)
+		return &pooledWriter{zw, &c.pool}, nil
+	}
+	zw, err := flate.NewWriter(w, c.level)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledWriter{zw, &c.pool}, nil
+}
+
+func (*flateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+// resetWriter is a compressing writer of the standard library.
+type resetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// pooledWriter returns its writer to the pool once closed, as the writers of
+// the standard library allocate large buffers.
+type pooledWriter struct {
+	resetWriter
+	pool *sync.Pool
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.resetWriter.Close()
+	if err == nil {
+		w.resetWriter.Reset(io.Discard) // release the destination
+		w.pool.Put(w.resetWriter)
+	}
+	return err
+}
+
+type compressedCache struct {
+	// configurable options
+
+	codec   Codec // compresses new values
+	minSize int64 // values smaller than this are stored as is
+
+	// internal state
+
+	conn   driver.Conn
+	cc     driver.ConnContext // conn, or an adapter if it does not support cancellation
+	b      driver.Batcher     // conn, or an adapter if it does not support batching
+	codecs map[string]Codec   // decompress values, by name
+	klocks keylock.Stripes    // back Lock when conn is not a driver.Locker
+}
+
+type Option interface {
+	apply(*compressedCache)
+}
+
+type optionFunc func(*compressedCache)
+
+func (f optionFunc) apply(c *compressedCache) {
+	f(c)
+}
+
+// WithCodec sets the codec that compresses new values; default: [Gzip] at
+// the default level. Values compressed by the codec, and by the built-in
+// gzip and flate codecs, can be read back regardless of the codec in use, so
+// passing several codecs keeps the values of earlier ones readable; the last
+// one compresses new values.
+func WithCodec(codec Codec) Option {
+	return optionFunc(func(c *compressedCache) {
+		c.codec = codec
+		c.codecs[codec.Name()] = codec
+	})
+}
+
+// WithMinSize stores values smaller than n bytes uncompressed, as they gain
+// little from compression; default: 1KiB.
+func WithMinSize(n int64) Option {
+	return optionFunc(func(c *compressedCache) {
+		c.minSize = max(n, 0)
+	})
+}
+
+func fromURL(u *url.URL, o driver.Opener) (driver.Conn, error) {
+	q := u.Query()
+	level := gzip.DefaultCompression
+	if v := q.Get("compress_level"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < gzip.HuffmanOnly || n > gzip.BestCompression {
+			return nil, fmt.Errorf("%w: compress_level %q", ErrInvalidParam, v)
+		}
+		level = n
+	}
+	opts := make([]Option, 0, 2)
+	switch v := q.Get("compress_codec"); v {
+	case "", "gzip":
+		opts = append(opts, WithCodec(Gzip(level)))
+	case "flate":
+		opts = append(opts, WithCodec(Flate(level)))
+	default:
+		return nil, fmt.Errorf("%w: compress_codec %q", ErrInvalidParam, v)
+	}
+	if v := q.Get("compress_min_size"); v != "" {
+		n, err := bytesize.Parse(v)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidParam, err)
+		}
+		opts = append(opts, WithMinSize(n))
+	}
+	dsn, err := registry.Unwrap(u, "compress_codec", "compress_level", "compress_min_size")
+	if err != nil {
+		return nil, errors.Join(ErrInvalidParam, err)
+	}
+	conn, err := o.OpenConn(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("compressed: failed to open backend: %w", err)
+	}
+	return New(conn, opts...), nil
+}
+
+// New returns a cache that compresses the values it stores in conn. The
+// returned Conn implements [driver.ConnContext], [driver.Batcher],
+// [driver.Locker], [expapi.KeyLister] and [driver.TTLSetter] if conn does,
+// and [io.Closer], which closes conn if it is a Closer. It does not implement
+// [driver.Streamer]; see the package documentation.
+//
+// See the package documentation for supported options.
+func New(conn driver.Conn, opts ...Option) driver.Conn {
+	gz, fl := Gzip(gzip.DefaultCompression), Flate(flate.DefaultCompression)
+	c := &compressedCache{
+		codec:   gz,
+		minSize: defaultMinSize,
+		conn:    conn,
+		cc:      driver.AsConnContext(conn),
+		b:       driver.AsBatcher(conn),
+		codecs:  map[string]Codec{gz.Name(): gz, fl.Name(): fl},
+	}
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+	return wrap(c, conn)
+}
+
+var (
+	_ driver.Conn        = (*compressedCache)(nil)
+	_ driver.ConnContext = (*compressedCache)(nil)
+	_ driver.Batcher     = (*compressedCache)(nil)
+	_ driver.Locker      = (*compressedCache)(nil)
+	_ io.Closer          = (*compressedCache)(nil)
+	_ expapi.KeyLister   = (*listingCache)(nil)
+	_ driver.TTLSetter   = (*expiringCache)(nil)
+	_ expapi.KeyLister   = (*listingExpiringCache)(nil)
+	_ driver.TTLSetter   = (*listingExpiringCache)(nil)
+)
+
+// magic starts every value stored with a header. It is followed by one byte
+// holding the length of the codec name, the codec name, and the compressed
+// value; an empty name marks a value stored as is. Values without it, such
+// as those written before compression was enabled, are returned as is.
+var magic = []byte("\x00hcz")
+
+// header returns the header of a value compressed by the named codec.
+func header(name string) []byte {
+	h := make([]byte, 0, len(magic)+1+len(name))
+	h = append(h, magic...)
+	h = append(h, byte(len(name)))
+	return append(h, name...)
+}
+
+func (c *compressedCache) compress(value []byte) ([]byte, error) {
+	if int64(len(value)) < c.minSize {
+		return c.raw(value), nil
+	}
+	name := c.codec.Name()
+	if len(name) == 0 || len(name) > 255 {
+		return nil, fmt.Errorf("compressed: invalid codec name %q", name)
+	}
+	buf := bytes.NewBuffer(header(name))
+	zw, err := c.codec.NewWriter(buf)
+	if err != nil {
+		return nil, fmt.Errorf("compressed: %s: %w", name, err)
+	}
+	if _, err = zw.Write(value); err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("compressed: %s: %w", name, err)
+	}
+	if buf.Len() >= len(value) {
+		return c.raw(value), nil
+	}
+	return buf.Bytes(), nil
+}
+
+// raw returns value to be stored uncompressed. Only values that could be
+// mistaken for a header need one.
+func (c *compressedCache) raw(value []byte) []byte {
+	if !bytes.HasPrefix(value, magic) {
+		return value
+	}
+	return append(header(""), value...)
+}
+
+func (c *compressedCache) decompress(key string, data []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(data, magic)
+	if !ok {
+		return data, nil
+	}
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, fmt.Errorf("%w for key %q: truncated header", ErrDecompress, key)
+	}
+	name, payload := string(rest[1:1+int(rest[0])]), rest[1+int(rest[0]):]
+	if name == "" {
+		return payload, nil
+	}
+	codec, ok := c.codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w for key %q: unknown codec %q", ErrDecompress, key, name)
+	}
+	zr, err := codec.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("%w for key %q: %w", ErrDecompress, key, err)
+	}
+	defer zr.Close()
+	value, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("%w for key %q: %w", ErrDecompress, key, err)
+	}
+	return value, nil
+}
+
+func (c *compressedCache) Get(key string) ([]byte, error) {
+	data, err := c.conn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.decompress(key, data)
+}
+
+func (c *compressedCache) Set(key string, value []byte) error {
+	data, err := c.compress(value)
+	if err != nil {
+		return err
+	}
+	return c.conn.Set(key, data)
+}
+
+func (c *compressedCache) Delete(key string) error { return c.conn.Delete(key) }
+
+func (c *compressedCache) GetContext(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.cc.GetContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.decompress(key, data)
+}
+
+func (c *compressedCache) SetContext(ctx context.Context, key string, value []byte) error {
+	data, err := c.compress(value)
+	if err != nil {
+		return err
+	}
+	return c.cc.SetContext(ctx, key, data)
+}
+
+func (c *compressedCache) DeleteContext(ctx context.Context, key string) error {
+	return c.cc.DeleteContext(ctx, key)
+}
+
+// GetMulti is like Get for each key, except that values that fail to
+// decompress are omitted as misses, so that one of them does not fail the
+// whole batch.
+func (c *compressedCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values, err := c.b.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	for key, data := range values {
+		value, err := c.decompress(key, data)
+		if err != nil {
+			delete(values, key)
+			continue
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (c *compressedCache) SetMulti(ctx context.Context, values map[string][]byte) error {
+	compressed := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := c.compress(value)
+		if err != nil {
+			return err
+		}
+		compressed[key] = data
+	}
+	return c.b.SetMulti(ctx, compressed)
+}
+
+func (c *compressedCache) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.b.DeleteMulti(ctx, keys)
+}
+
+// Lock acquires the lock for key from the wrapped backend if it is a
+// [driver.Locker], so that it is shared with other users of the backend;
+// otherwise it only serializes callers within this process.
+func (c *compressedCache) Lock(key string) (func(), error) {
+	if l, ok := c.conn.(driver.Locker); ok {
+		return l.Lock(key)
+	}
+	return c.klocks.Lock(key), nil
+}
+
+// Close closes the wrapped backend if it is an [io.Closer].
+func (c *compressedCache) Close() error {
+	if closer, ok := c.conn.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// wrap returns c along with the optional interfaces of conn that it
+// forwards.
+func wrap(c *compressedCache, conn driver.Conn) driver.Conn {
+	kl, _ := conn.(expapi.KeyLister)
+	ts, _ := conn.(driver.TTLSetter)
+	switch {
+	case kl != nil && ts != nil:
+		return &listingExpiringCache{c, lister{kl}, expirer{c, ts}}
+	case kl != nil:
+		return &listingCache{c, lister{kl}}
+	case ts != nil:
+		return &expiringCache{c, expirer{c, ts}}
+	}
+	return c
+}
+
+// lister lists the keys of a backend that can list them.
+type lister struct{ kl expapi.KeyLister }
+
+func (l lister) Keys(prefix string) ([]string, error) { return l.kl.Keys(prefix) }
+
+// expirer stores compressed values in a backend that can expire them.
+type expirer struct {
+	c  *compressedCache
+	ts driver.TTLSetter
+}
+
+func (e expirer) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	data, err := e.c.compress(value)
+	if err != nil {
+		return err
+	}
+	return e.ts.SetWithTTL(ctx, key, data, ttl)
+}
+
+// listingCache is a compressedCache whose backend can list its keys.
+type listingCache struct {
+	*compressedCache
+	lister
+}
+
+// expiringCache is a compressedCache whose backend can expire its entries.
+type expiringCache struct {
+	*compressedCache
+	expirer
+}
+
+// listingExpiringCache is a compressedCache whose backend can list its keys and
+// expire its entries.
+type listingExpiringCache struct {
+	*compressedCache
+	lister
+	expirer
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compressed
+
+import (
+	"bytes"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+	"github.com/bartventer/httpcache/store/internal/conntest"
+	"github.com/bartventer/httpcache/store/internal/registry"
+	"github.com/bartventer/httpcache/store/memcache"
+)
+
+// response is a value that compresses well.
+var response = []byte("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n" +
+	strings.Repeat(`{"id":1,"name":"widget","tags":["a","b","c"]},`, 100))
+
+func TestCompressed_Acceptance(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		return New(memcache.Open(), WithMinSize(0)), func() {}
+	}))
+}
+
+func TestCompressed_Acceptance_DSN(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		u, err := url.Parse("compressed+memcache://?max_entries=100&compress_codec=flate&compress_min_size=0")
+		testutil.RequireNoError(t, err)
+		conn, err := fromURL(u, registry.Default())
+		testutil.RequireNoError(t, err)
+		return conn, func() {}
+	}))
+}
+
+func Test_fromURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		dsn       string
+		assertion func(tt *testing.T, got driver.Conn, err error)
+	}{
+		{
+			name: "defaults",
+			dsn:  "compressed+memcache://",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireNoError(tt, err)
+				c := got.(*listingExpiringCache)
+				testutil.AssertEqual(tt, "gzip", c.codec.Name())
+				testutil.AssertEqual(tt, int64(defaultMinSize), c.minSize)
+			},
+		},
+		{
+			name: "all parameters",
+			dsn:  "compressed+memcache://?max_entries=10&compress_codec=flate&compress_level=9&compress_min_size=4KiB",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireNoError(tt, err)
+				c := got.(*listingExpiringCache)
+				testutil.AssertEqual(tt, "flate", c.codec.Name())
+				testutil.AssertEqual(tt, 9, c.codec.(*flateCodec).level)
+				testutil.AssertEqual(tt, int64(4<<10), c.minSize)
+			},
+		},
+		{
+			name: "invalid codec",
+			dsn:  "compressed+memcache://?compress_codec=zstd",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid level",
+			dsn:  "compressed+memcache://?compress_level=10",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid min size",
+			dsn:  "compressed+memcache://?compress_min_size=big",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "no wrapped scheme",
+			dsn:  "compressed://",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid wrapped parameter",
+			dsn:  "compressed+memcache://?max_entries=many",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireError(tt, err)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.dsn)
+			testutil.RequireNoError(t, err)
+			got, err := fromURL(u, registry.Default())
+			tt.assertion(t, got, err)
+		})
+	}
+}
+
+func TestCompressed_Format(t *testing.T) {
+	for _, codec := range []Codec{Gzip(1), Flate(9)} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			backend := memcache.Open()
+			c := New(backend, WithCodec(codec))
+			testutil.RequireNoError(t, c.Set("large", response))
+			stored, err := backend.Get("large")
+			testutil.RequireNoError(t, err)
+			testutil.AssertTrue(t, len(stored) < len(response)/4, "value not compressed")
+			testutil.AssertTrue(t, bytes.HasPrefix(stored, header(codec.Name())), "missing header")
+
+			got, err := c.Get("large")
+			testutil.RequireNoError(t, err)
+			testutil.AssertTrue(t, bytes.Equal(response, got), "value changed")
+		})
+	}
+}
+
+func TestCompressed_Uncompressed(t *testing.T) {
+	backend := memcache.Open()
+	c := New(backend, WithMinSize(64))
+	tests := []struct {
+		name   string
+		value  []byte
+		stored []byte
+	}{
+		{"below minimum size", []byte("HTTP/1.1 204 No Content\r\n\r\n"), []byte("HTTP/1.1 204 No Content\r\n\r\n")},
+		{"looks like a header", append(bytes.Clone(magic), "\x04gzip"...), append(header(""), append(bytes.Clone(magic), "\x04gzip"...)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.RequireNoError(t, c.Set("k", tt.value))
+			stored, err := backend.Get("k")
+			testutil.RequireNoError(t, err)
+			testutil.AssertTrue(t, bytes.Equal(tt.stored, stored), "stored "+strconv.Quote(string(stored)))
+			got, err := c.Get("k")
+			testutil.RequireNoError(t, err)
+			testutil.AssertTrue(t, bytes.Equal(tt.value, got), "got "+strconv.Quote(string(got)))
+		})
+	}
+
+	// Incompressible values above the minimum size are stored as is.
+	random := make([]byte, 70)
+	for i := range random {
+		random[i] = byte(i*131 + i*i*17)
+	}
+	c = New(backend, WithMinSize(0), WithCodec(Gzip(9)))
+	testutil.RequireNoError(t, c.Set("random", random))
+	stored, err := backend.Get("random")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, !bytes.HasPrefix(stored, magic), "incompressible value stored compressed")
+}
+
+func TestCompressed_Legacy(t *testing.T) {
+	backend := memcache.Open()
+	testutil.RequireNoError(t, backend.Set("old", response))
+	c := New(backend)
+	got, err := c.Get("old")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, bytes.Equal(response, got), "legacy value changed")
+}
+
+func TestCompressed_CodecChange(t *testing.T) {
+	backend := memcache.Open()
+	testutil.RequireNoError(t, New(backend, WithCodec(Flate(1))).Set("flate", response))
+	testutil.RequireNoError(t, New(backend, WithCodec(renamed{Flate(1), "custom"})).Set("custom", response))
+
+	// The built-in codecs are always readable; custom ones once passed.
+	c := New(backend)
+	got, err := c.Get("flate")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, bytes.Equal(response, got), "flate value changed")
+	_, err = c.Get("custom")
+	testutil.RequireErrorIs(t, err, ErrDecompress)
+
+	c = New(backend, WithCodec(renamed{Flate(1), "custom"}), WithCodec(Gzip(-1)))
+	got, err = c.Get("custom")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, bytes.Equal(response, got), "custom value changed")
+	testutil.AssertEqual(t, "gzip", c.(*listingExpiringCache).codec.Name())
+}
+
+func TestCompressed_Corrupt(t *testing.T) {
+	backend := memcache.Open()
+	c := New(backend)
+	testutil.RequireNoError(t, c.Set("k", response))
+	stored, err := backend.Get("k")
+	testutil.RequireNoError(t, err)
+	for _, data := range [][]byte{
+		stored[:len(stored)/2],
+		magic,
+		append(bytes.Clone(magic), 10, 'g'),
+		append(header("gzip"), "not gzip"...),
+	} {
+		testutil.RequireNoError(t, backend.Set("k", data))
+		_, err = c.Get("k")
+		testutil.RequireErrorIs(t, err, ErrDecompress)
+		testutil.RequireErrorIs(t, err, driver.ErrCorrupt)
+	}
+}
+
+func TestCompressed_GetMulti(t *testing.T) {
+	backend := memcache.Open()
+	c := New(backend)
+	testutil.RequireNoError(t, c.Set("a", response))
+	testutil.RequireNoError(t, backend.Set("b", append(header("gzip"), "not gzip"...)))
+
+	got, err := c.(driver.Batcher).GetMulti(t.Context(), []string{"a", "b", "c"})
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 1, len(got))
+	testutil.AssertTrue(t, bytes.Equal(response, got["a"]), "value changed")
+}
+
+func TestCompressed_InvalidCodec(t *testing.T) {
+	c := New(memcache.Open(), WithCodec(Gzip(42)), WithMinSize(0))
+	testutil.RequireError(t, c.Set("k", response))
+}
+
+func TestCompressed_KeyLister(t *testing.T) {
+	c := New(conntest.Plain(memcache.Open()))
+	_, ok := c.(expapi.KeyLister)
+	testutil.AssertTrue(t, !ok, "keys listed without a listing backend")
+}
+
+func TestCompressed_Locker(t *testing.T) {
+	var locked []string
+	c := New(conntest.Locking(memcache.Open(), &locked))
+	unlock, err := c.(driver.Locker).Lock("k")
+	testutil.RequireNoError(t, err)
+	unlock()
+	testutil.AssertTrue(t, slices.Equal(locked, []string{"k"}), "lock not taken from the backend")
+
+	// Without a locking backend, the lock only serializes this process.
+	unlock, err = New(memcache.Open()).(driver.Locker).Lock("k")
+	testutil.RequireNoError(t, err)
+	unlock()
+}
+
+func TestCompressed_Streamer(t *testing.T) {
+	// Whether a value is compressed depends on its full size, so streaming
+	// is not forwarded.
+	c := New(conntest.Streaming(memcache.Open()))
+	_, ok := c.(driver.Streamer)
+	testutil.AssertTrue(t, !ok, "streams values past compression")
+}
+
+// renamed is a custom codec: flate under another name.
+type renamed struct {
+	Codec
+	name string
+}
+
+func (c renamed) Name() string { return c.name }
@@ -29,8 +29,11 @@
 package driver
 
 import (
+	"context"
 	"errors"
+	"io"
 	"net/url"
+	"time"
 )
 
 // ErrNotExist is returned when a cache entry does not exist.
@@ -39,6 +42,14 @@ import (
 // that satisfies errors.Is(err, store.ErrNotExist) if the entry is not found.
 var ErrNotExist = errors.New("driver: entry does not exist")
 
+// ErrCorrupt is returned when a cache entry exists but its value cannot be
+// decoded, for example because it was modified or written with another key.
+//
+// Drivers that transform stored values should return an error that satisfies
+// errors.Is(err, driver.ErrCorrupt) for such entries, so callers can tell
+// them apart from backend failures.
+var ErrCorrupt = errors.New("driver: entry is corrupt")
+
 // Driver is the interface implemented by cache backends that can create a [Conn]
 // from a URL. The URL scheme determines which driver is used.
 type Driver interface {
@@ -51,6 +62,21 @@ func (f DriverFunc) Open(u *url.URL) (Conn, error) {
 	return f(u)
 }
 
+// Opener opens connections to cache backends given a DSN. A registry of
+// drivers is an Opener.
+type Opener interface {
+	OpenConn(dsn string) (Conn, error)
+}
+
+// WrapperDriver is an optional interface implemented by a [Driver] whose DSNs
+// name other DSNs, such as those of the backends it wraps. A registry opens
+// its DSNs with OpenWith, passing itself, so that the other DSNs are opened
+// in the same registry.
+type WrapperDriver interface {
+	Driver
+	OpenWith(u *url.URL, o Opener) (Conn, error)
+}
+
 // Conn describes the interface implemented by types that provide
 // a connection to a cache backend. It allows for basic operations such as
 // getting, setting, and deleting cache entries by key.
@@ -69,3 +95,181 @@ type Conn interface {
 	// errors.Is(err, store.ErrNotExist).
 	Delete(key string) error
 }
+
+// ConnContext is an optional interface implemented by a [Conn] whose
+// operations can be cancelled. The methods behave as their [Conn]
+// counterparts, but return early, with an error wrapping ctx.Err(), once ctx
+// is done. An operation that returns early may still complete in the
+// background.
+type ConnContext interface {
+	GetContext(ctx context.Context, key string) ([]byte, error)
+	SetContext(ctx context.Context, key string, value []byte) error
+	DeleteContext(ctx context.Context, key string) error
+}
+
+// AsConnContext returns conn as a [ConnContext]. If conn does not implement
+// it, the returned ConnContext fails once ctx is done, and otherwise performs
+// the operation through conn, which is not interrupted.
+func AsConnContext(conn Conn) ConnContext {
+	if cc, ok := conn.(ConnContext); ok {
+		return cc
+	}
+	return connContext{conn}
+}
+
+type connContext struct{ conn Conn }
+
+func (c connContext) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.conn.Get(key)
+}
+
+func (c connContext) SetContext(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.conn.Set(key, value)
+}
+
+func (c connContext) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.conn.Delete(key)
+}
+
+// Batcher is an optional interface implemented by a [Conn] that can operate
+// on several keys at once, typically in a single round trip to a network
+// backend. The operations need not be atomic; on error, some keys may have
+// been processed.
+type Batcher interface {
+	// GetMulti retrieves the values of the given keys. Keys that do not exist
+	// are omitted from the result.
+	GetMulti(ctx context.Context, keys []string) (map[string][]byte, error)
+
+	// SetMulti stores the given values by key, overwriting existing ones.
+	SetMulti(ctx context.Context, values map[string][]byte) error
+
+	// DeleteMulti removes the values of the given keys. Keys that do not exist
+	// are ignored.
+	DeleteMulti(ctx context.Context, keys []string) error
+}
+
+// TTLSetter is an optional interface implemented by a [Conn] that can expire
+// entries on its own, so that entries no longer useful are dropped without
+// being deleted explicitly.
+type TTLSetter interface {
+	// SetWithTTL is like Set, but the entry expires once ttl has elapsed, and
+	// is then treated as if it did not exist. A ttl of zero or less stores
+	// the entry without expiry. If the backend is configured with a time to
+	// live of its own, the shorter of the two applies.
+	SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// AsBatcher returns conn as a [Batcher]. If conn does not implement it, the
+// returned Batcher performs the operations one key at a time, through
+// [ConnContext] if conn implements it, stopping at the first error.
+func AsBatcher(conn Conn) Batcher {
+	if b, ok := conn.(Batcher); ok {
+		return b
+	}
+	cc, _ := conn.(ConnContext)
+	return &connBatcher{conn, cc}
+}
+
+type connBatcher struct {
+	conn Conn
+	cc   ConnContext // conn, if it supports cancellation; else nil
+}
+
+func (b *connBatcher) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var (
+			value []byte
+			err   error
+		)
+		if b.cc != nil {
+			value, err = b.cc.GetContext(ctx, key)
+		} else {
+			value, err = b.conn.Get(key)
+		}
+		switch {
+		case err == nil:
+			values[key] = value
+		case !errors.Is(err, ErrNotExist):
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (b *connBatcher) SetMulti(ctx context.Context, values map[string][]byte) error {
+	for key, value := range values {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		if b.cc != nil {
+			err = b.cc.SetContext(ctx, key, value)
+		} else {
+			err = b.conn.Set(key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *connBatcher) DeleteMulti(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		if b.cc != nil {
+			err = b.cc.DeleteContext(ctx, key)
+		} else {
+			err = b.conn.Delete(key)
+		}
+		if err != nil && !errors.Is(err, ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Streamer is an optional interface implemented by a [Conn] that can read
+// and write values incrementally, so that large values need not be held in
+// memory. Values written through it are read by Get, and vice versa.
+type Streamer interface {
+	// OpenReader opens the value for key for reading. If the key does not
+	// exist, it should return an error satisfying errors.Is(err,
+	// ErrNotExist). The caller must close the reader.
+	OpenReader(key string) (io.ReadCloser, error)
+
+	// OpenWriter opens a writer for the value of key. The value is stored
+	// when the writer is closed, replacing any existing value; until then,
+	// readers see the previous value. If a write fails, Close discards the
+	// value and returns the error.
+	OpenWriter(key string) (io.WriteCloser, error)
+}
+
+// Locker is an optional interface implemented by a [Conn] that can serialize
+// read-modify-write sequences on a key, across processes if they share the
+// backend. Callers that read a value, modify it, and write it back should
+// hold the lock for the duration, so that concurrent updates are not lost.
+//
+// The lock is advisory: [Conn] methods do not acquire it, so they may be
+// called while it is held.
+type Locker interface {
+	// Lock blocks until the lock for key is acquired, and returns a function
+	// that releases it.
+	Lock(key string) (unlock func(), err error)
+}
@@ -0,0 +1,417 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encrypted implements a cache backend that encrypts the values
+// stored in any other backend with AES-GCM.
+//
+// The cache key is authenticated along with each value, so a value cannot
+// be moved to another key without failing to decrypt. Keys themselves are
+// stored in the clear, as the backend needs them to find values.
+//
+// Each value records the ID of the encryption key that sealed it. New values
+// are encrypted with the first key of the ring, and the other keys are only
+// used to decrypt values written before the key was rotated. To rotate, put
+// the new key first, and drop the old key once the entries it encrypted have
+// expired or been rewritten.
+//
+// The cache does not implement [driver.Streamer], even if the wrapped backend
+// does: AES-GCM authenticates a value as a whole, so it can be neither
+// sealed nor opened incrementally. Values are held in memory instead.
+//
+// # Configuration Parameters
+//
+// The driver wraps the DSN of another backend: encrypted+<scheme>://...,
+// such as encrypted+memcache:// or encrypted+redis://localhost:6379. The
+// following query parameters are consumed by the wrapper; the others are
+// passed on to the wrapped backend:
+//
+//   - wrap_key (optional): Comma-separated AES keys in the form [id:]base64 (URL-safe, RFC 4648 §5), active key first
+//   - wrap_key_file (optional): Path to a file with one key per line, active key first
+//
+// Without either, the keys are read from the HTTPCACHE_WRAP_KEY
+// environment variable. The names differ from those of backends that encrypt
+// on their own, such as fscache's encrypt_key, so both can be configured in
+// one DSN.
+//
+// Generate a 256-bit key with:
+//
+//	openssl rand 32 | base64 | tr '+/' '-_' | tr -d '\n'
+//
+// # Usage Examples
+//
+//	The DSN and equivalent programmatic usage are shown below.
+//
+//	 Encrypted in-memory cache:
+//
+//		encrypted+memcache://?max_bytes=64MiB&wrap_key=6S-Ks2YYOW0xMvTzKSv6QD30gZeOi1c6Ydr-As5csWk=
+//		encrypted.New(memcache.Open(memcache.WithMaxBytes(64<<20)), key)
+//
+//	 Rotated keys:
+//
+//		encrypted+redis://localhost:6379?wrap_key=2026-07:NEW,2026-01:OLD
+//		encrypted.New(redisConn, newKey, oldKey)
+package encrypted
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+	"github.com/bartventer/httpcache/store/internal/keylock"
+	"github.com/bartventer/httpcache/store/internal/keyring"
+	"github.com/bartventer/httpcache/store/internal/registry"
+)
+
+const (
+	Scheme = "encrypted"
+	KeyEnv = "HTTPCACHE_WRAP_KEY"
+)
+
+// Driver opens DSNs of the form encrypted+<scheme>://.... It is registered in
+// the default registry under [Scheme]; register it in a [store.Registry] to
+// open the DSNs it names with the drivers of that registry.
+var Driver driver.WrapperDriver = registry.WrapperFunc(fromURL)
+
+//nolint:gochecknoinits // We use init to register the driver.
+func init() {
+	store.Register(Scheme, Driver)
+}
+
+var (
+	ErrInvalidParam = errors.New("encrypted: invalid DSN parameter")
+	ErrNoKeys       = errors.New("encrypted: key ring is empty")
+	// ErrDecrypt is returned by Get for values that fail to decrypt, because
+	// they were encrypted with an unknown key, were modified, or belong to
+	// another cache key. It satisfies errors.Is(err, driver.ErrCorrupt).
+	ErrDecrypt = fmt.Errorf("encrypted: failed to decrypt value: %w", driver.ErrCorrupt)
+)
+
+// Key is an AES key used to encrypt values.
+type Key = keyring.Key
+
+// ParseKey parses a key of the form "[id:]secret", where secret is base64
+// encoded (RFC 4648 §5, URL-safe variant).
+func ParseKey(s string) (Key, error) {
+	k, err := keyring.Parse(s)
+	if err != nil {
+		return Key{}, fmt.Errorf("encrypted: %w", err)
+	}
+	return k, nil
+}
+
+func fromURL(u *url.URL, o driver.Opener) (driver.Conn, error) {
+	q := u.Query()
+	var (
+		keys []Key
+		err  error
+	)
+	switch {
+	case q.Get("wrap_key") != "":
+		keys, err = keyring.ParseList(q.Get("wrap_key"))
+	case q.Get("wrap_key_file") != "":
+		keys, err = keyring.ReadFile(q.Get("wrap_key_file"))
+	default:
+		keys, err = keyring.ParseList(os.Getenv(KeyEnv))
+	}
+	if err != nil {
+		return nil, errors.Join(ErrInvalidParam, err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no wrap_key, wrap_key_file or $%s", ErrInvalidParam, KeyEnv)
+	}
+	dsn, err := registry.Unwrap(u, "wrap_key", "wrap_key_file")
+	if err != nil {
+		return nil, errors.Join(ErrInvalidParam, err)
+	}
+	conn, err := o.OpenConn(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted: failed to open backend: %w", err)
+	}
+	c, err := New(conn, keys...)
+	if err != nil {
+		if closer, ok := conn.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return nil, errors.Join(ErrInvalidParam, err)
+	}
+	return c, nil
+}
+
+// New returns a cache that encrypts the values it stores in conn with the
+// given keys, the first of which encrypts new values. The returned Conn
+// implements [driver.ConnContext], [driver.Batcher], [driver.Locker],
+// [expapi.KeyLister] and [driver.TTLSetter] if conn does, and [io.Closer],
+// which closes conn if it is a Closer. It does not implement
+// [driver.Streamer]; see the package documentation.
+func New(conn driver.Conn, keys ...Key) (driver.Conn, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	c := &encryptedCache{
+		conn: conn,
+		cc:   driver.AsConnContext(conn),
+		b:    driver.AsBatcher(conn),
+		byID: make(map[string]cipher.AEAD, len(keys)),
+		rand: rand.Reader,
+	}
+	for i, k := range keys {
+		id := keyring.ID(k)
+		if len(id) > 255 {
+			return nil, fmt.Errorf("encrypted: key ID %q is longer than 255 bytes", id)
+		}
+		if _, dup := c.byID[id]; dup {
+			return nil, fmt.Errorf("encrypted: duplicate key ID %q", id)
+		}
+		block, err := aes.NewCipher(k.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("encrypted: key %q: %w", id, err)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("encrypted: key %q: %w", id, err)
+		}
+		c.byID[id] = gcm
+		if i == 0 {
+			c.activeID, c.active = id, gcm
+		}
+	}
+	return wrap(c, conn), nil
+}
+
+var (
+	_ driver.Conn        = (*encryptedCache)(nil)
+	_ driver.ConnContext = (*encryptedCache)(nil)
+	_ driver.Batcher     = (*encryptedCache)(nil)
+	_ driver.Locker      = (*encryptedCache)(nil)
+	_ io.Closer          = (*encryptedCache)(nil)
+	_ expapi.KeyLister   = (*listingCache)(nil)
+	_ driver.TTLSetter   = (*expiringCache)(nil)
+	_ expapi.KeyLister   = (*listingExpiringCache)(nil)
+	_ driver.TTLSetter   = (*listingExpiringCache)(nil)
+)
+
+// formatVersion starts every stored value. It is followed by one byte
+// holding the length of the key ID, the key ID, the nonce, and the
+// ciphertext. The header, up to the nonce, and the cache key are
+// authenticated as associated data.
+const formatVersion = 1
+
+type encryptedCache struct {
+	conn     driver.Conn
+	cc       driver.ConnContext // conn, or an adapter if it does not support cancellation
+	b        driver.Batcher     // conn, or an adapter if it does not support batching
+	activeID string
+	active   cipher.AEAD
+	byID     map[string]cipher.AEAD
+	rand     io.Reader
+	klocks   keylock.Stripes // back Lock when conn is not a driver.Locker
+}
+
+// additionalData returns the data authenticated along with the value of key.
+func additionalData(header []byte, key string) []byte {
+	ad := make([]byte, 0, len(header)+len(key))
+	ad = append(ad, header...)
+	return append(ad, key...)
+}
+
+func (c *encryptedCache) seal(key string, value []byte) ([]byte, error) {
+	header := make([]byte, 0, 2+len(c.activeID))
+	header = append(header, formatVersion, byte(len(c.activeID)))
+	header = append(header, c.activeID...)
+	nonceSize := c.active.NonceSize()
+	out := make([]byte, len(header)+nonceSize, len(header)+nonceSize+len(value)+c.active.Overhead())
+	copy(out, header)
+	nonce := out[len(header):]
+	if _, err := io.ReadFull(c.rand, nonce); err != nil {
+		return nil, fmt.Errorf("encrypted: failed to generate nonce: %w", err)
+	}
+	return c.active.Seal(out, nonce, value, additionalData(header, key)), nil
+}
+
+func (c *encryptedCache) open(key string, data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != formatVersion || len(data) < 2+int(data[1]) {
+		return nil, fmt.Errorf("%w for key %q: unknown format", ErrDecrypt, key)
+	}
+	headerLen := 2 + int(data[1])
+	header, rest := data[:headerLen], data[headerLen:]
+	id := string(header[2:])
+	aead, ok := c.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("%w for key %q: unknown key ID %q", ErrDecrypt, key, id)
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w for key %q: ciphertext too short", ErrDecrypt, key)
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData(header, key))
+	if err != nil {
+		return nil, fmt.Errorf("%w for key %q: %w", ErrDecrypt, key, err)
+	}
+	return plaintext, nil
+}
+
+func (c *encryptedCache) Get(key string) ([]byte, error) {
+	data, err := c.conn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.open(key, data)
+}
+
+func (c *encryptedCache) Set(key string, value []byte) error {
+	data, err := c.seal(key, value)
+	if err != nil {
+		return err
+	}
+	return c.conn.Set(key, data)
+}
+
+func (c *encryptedCache) Delete(key string) error { return c.conn.Delete(key) }
+
+func (c *encryptedCache) GetContext(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.cc.GetContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.open(key, data)
+}
+
+func (c *encryptedCache) SetContext(ctx context.Context, key string, value []byte) error {
+	data, err := c.seal(key, value)
+	if err != nil {
+		return err
+	}
+	return c.cc.SetContext(ctx, key, data)
+}
+
+func (c *encryptedCache) DeleteContext(ctx context.Context, key string) error {
+	return c.cc.DeleteContext(ctx, key)
+}
+
+// GetMulti is like Get for each key, except that values that fail to decrypt
+// are omitted as misses, so that one of them does not fail the whole batch.
+func (c *encryptedCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values, err := c.b.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	for key, data := range values {
+		value, err := c.open(key, data)
+		if err != nil {
+			delete(values, key)
+			continue
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (c *encryptedCache) SetMulti(ctx context.Context, values map[string][]byte) error {
+	sealed := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := c.seal(key, value)
+		if err != nil {
+			return err
+		}
+		sealed[key] = data
+	}
+	return c.b.SetMulti(ctx, sealed)
+}
+
+func (c *encryptedCache) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.b.DeleteMulti(ctx, keys)
+}
+
+// Lock acquires the lock for key from the wrapped backend if it is a
+// [driver.Locker], so that it is shared with other users of the backend;
+// otherwise it only serializes callers within this process.
+func (c *encryptedCache) Lock(key string) (func(), error) {
+	if l, ok := c.conn.(driver.Locker); ok {
+		return l.Lock(key)
+	}
+	return c.klocks.Lock(key), nil
+}
+
+// Close closes the wrapped backend if it is an [io.Closer].
+func (c *encryptedCache) Close() error {
+	if closer, ok := c.conn.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// wrap returns c along with the optional interfaces of conn that it
+// forwards.
+func wrap(c *encryptedCache, conn driver.Conn) driver.Conn {
+	kl, _ := conn.(expapi.KeyLister)
+	ts, _ := conn.(driver.TTLSetter)
+	switch {
+	case kl != nil && ts != nil:
+		return &listingExpiringCache{c, lister{kl}, expirer{c, ts}}
+	case kl != nil:
+		return &listingCache{c, lister{kl}}
+	case ts != nil:
+		return &expiringCache{c, expirer{c, ts}}
+	}
+	return c
+}
+
+// lister lists the keys of a backend that can list them.
+type lister struct{ kl expapi.KeyLister }
+
+func (l lister) Keys(prefix string) ([]string, error) { return l.kl.Keys(prefix) }
+
+// expirer stores encrypted values in a backend that can expire them.
+type expirer struct {
+	c  *encryptedCache
+	ts driver.TTLSetter
+}
+
+func (e expirer) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	data, err := e.c.seal(key, value)
+	if err != nil {
+		return err
+	}
+	return e.ts.SetWithTTL(ctx, key, data, ttl)
+}
+
+// listingCache is an encryptedCache whose backend can list its keys.
+type listingCache struct {
+	*encryptedCache
+	lister
+}
+
+// expiringCache is an encryptedCache whose backend can expire its entries.
+type expiringCache struct {
+	*encryptedCache
+	expirer
+}
+
+// listingExpiringCache is an encryptedCache whose backend can list its keys and
+// expire its entries.
+type listingExpiringCache struct {
+	*encryptedCache
+	lister
+	expirer
+}
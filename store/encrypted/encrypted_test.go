@@ -0,0 +1,331 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encrypted
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+	_ "github.com/bartventer/httpcache/store/fscache"
+	"github.com/bartventer/httpcache/store/internal/conntest"
+	"github.com/bartventer/httpcache/store/internal/keyring"
+	"github.com/bartventer/httpcache/store/internal/registry"
+	"github.com/bartventer/httpcache/store/memcache"
+)
+
+var (
+	key1 = Key{ID: "k1", Secret: bytes.Repeat([]byte{1}, 32)}
+	key2 = Key{ID: "k2", Secret: bytes.Repeat([]byte{2}, 16)}
+)
+
+func encodeKey(k Key) string {
+	return k.ID + ":" + base64.URLEncoding.EncodeToString(k.Secret)
+}
+
+func newCache(t *testing.T, conn driver.Conn, keys ...Key) driver.Conn {
+	t.Helper()
+	c, err := New(conn, keys...)
+	testutil.RequireNoError(t, err)
+	return c
+}
+
+func TestEncrypted_Acceptance(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		return newCache(t, memcache.Open(), key1, key2), func() {}
+	}))
+}
+
+func TestEncrypted_Acceptance_DSN(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		u, err := url.Parse("encrypted+memcache://?max_entries=100&wrap_key=" + url.QueryEscape(encodeKey(key1)))
+		testutil.RequireNoError(t, err)
+		conn, err := fromURL(u, registry.Default())
+		testutil.RequireNoError(t, err)
+		return conn, func() {}
+	}))
+}
+
+func Test_fromURL_WrappedEncryption(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv(KeyEnv, "")
+	fsKey := base64.URLEncoding.EncodeToString(bytes.Repeat([]byte{3}, 32))
+	fsDSN := "fscache://?appname=wrapped&encrypt=on&encrypt_key=" + url.QueryEscape(fsKey)
+	u, err := url.Parse("encrypted+" + fsDSN + "&wrap_key=" + url.QueryEscape(encodeKey(key1)))
+	testutil.RequireNoError(t, err)
+	conn, err := fromURL(u, registry.Default())
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, conn.Set("k", []byte("value")))
+	testutil.RequireNoError(t, conn.(io.Closer).Close())
+
+	// The backend decrypts with its own key, and holds the wrapper's
+	// ciphertext, which only the wrapper's key opens.
+	backend, err := registry.Default().OpenConn(fsDSN)
+	testutil.RequireNoError(t, err)
+	defer backend.(io.Closer).Close()
+	stored, err := backend.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, stored[0] == formatVersion)
+	got, err := newCache(t, backend, key1).Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "value", string(got))
+	_, err = newCache(t, backend, key2).Get("k")
+	testutil.RequireErrorIs(t, err, ErrDecrypt)
+}
+
+func Test_fromURL(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys")
+	testutil.RequireNoError(t, os.WriteFile(keyFile, []byte("# active first\n"+encodeKey(key2)+"\n\n"+encodeKey(key1)+"\n"), 0o600))
+	t.Setenv(KeyEnv, "")
+	tests := []struct {
+		name      string
+		dsn       string
+		env       string
+		assertion func(tt *testing.T, got driver.Conn, err error)
+	}{
+		{
+			name: "key ring",
+			dsn:  "encrypted+memcache://?wrap_key=" + url.QueryEscape(encodeKey(key2)+","+encodeKey(key1)),
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireNoError(tt, err)
+				c := got.(*listingExpiringCache)
+				testutil.AssertEqual(tt, "k2", c.activeID)
+				testutil.AssertEqual(tt, 2, len(c.byID))
+			},
+		},
+		{
+			name: "key file",
+			dsn:  "encrypted+memcache://?wrap_key_file=" + url.QueryEscape(keyFile),
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, "k2", got.(*listingExpiringCache).activeID)
+			},
+		},
+		{
+			name: "environment",
+			dsn:  "encrypted+memcache://",
+			env:  encodeKey(key1),
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, "k1", got.(*listingExpiringCache).activeID)
+			},
+		},
+		{
+			name: "missing key",
+			dsn:  "encrypted+memcache://",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid key encoding",
+			dsn:  "encrypted+memcache://?wrap_key=not*base64",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid key size",
+			dsn:  "encrypted+memcache://?wrap_key=" + base64.URLEncoding.EncodeToString([]byte("short")),
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "missing key file",
+			dsn:  "encrypted+memcache://?wrap_key_file=" + url.QueryEscape(filepath.Join(dir, "missing")),
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "no wrapped scheme",
+			dsn:  "encrypted://?wrap_key=" + url.QueryEscape(encodeKey(key1)),
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid wrapped parameter",
+			dsn:  "encrypted+memcache://?max_entries=many&wrap_key=" + url.QueryEscape(encodeKey(key1)),
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireError(tt, err)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(KeyEnv, tt.env)
+			u, err := url.Parse(tt.dsn)
+			testutil.RequireNoError(t, err)
+			got, err := fromURL(u, registry.Default())
+			tt.assertion(t, got, err)
+		})
+	}
+}
+
+func TestEncrypted_Ciphertext(t *testing.T) {
+	backend := memcache.Open()
+	c := newCache(t, backend, key1)
+	plaintext := []byte("HTTP/1.1 200 OK\r\n\r\npersonal data")
+	testutil.RequireNoError(t, c.Set("a", plaintext))
+	testutil.RequireNoError(t, c.Set("b", []byte("other")))
+
+	stored, err := backend.Get("a")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, !bytes.Contains(stored, []byte("personal data")), "value stored in the clear")
+
+	// The same value encrypts differently each time.
+	testutil.RequireNoError(t, c.Set("a2", plaintext))
+	stored2, err := backend.Get("a2")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, !bytes.Equal(stored, stored2), "nonce reused")
+
+	// A ciphertext moved to another key does not decrypt.
+	testutil.RequireNoError(t, backend.Set("b", stored))
+	_, err = c.Get("b")
+	testutil.RequireErrorIs(t, err, ErrDecrypt)
+
+	// Nor does a modified one.
+	tampered := bytes.Clone(stored)
+	tampered[len(tampered)-1] ^= 1
+	testutil.RequireNoError(t, backend.Set("a", tampered))
+	_, err = c.Get("a")
+	testutil.RequireErrorIs(t, err, ErrDecrypt)
+	testutil.RequireErrorIs(t, err, driver.ErrCorrupt)
+
+	for _, data := range [][]byte{nil, []byte("plaintext"), {formatVersion, 200}} {
+		testutil.RequireNoError(t, backend.Set("a", data))
+		_, err = c.Get("a")
+		testutil.RequireErrorIs(t, err, ErrDecrypt)
+	}
+}
+
+func TestEncrypted_GetMulti(t *testing.T) {
+	backend := memcache.Open()
+	c := newCache(t, backend, key1)
+	testutil.RequireNoError(t, c.Set("a", []byte("v1")))
+	testutil.RequireNoError(t, c.Set("b", []byte("v2")))
+	testutil.RequireNoError(t, backend.Set("c", []byte("plaintext")))
+
+	got, err := c.(driver.Batcher).GetMulti(t.Context(), []string{"a", "b", "c", "d"})
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 2, len(got))
+	testutil.AssertEqual(t, "v1", string(got["a"]))
+	testutil.AssertEqual(t, "v2", string(got["b"]))
+}
+
+func TestEncrypted_Rotation(t *testing.T) {
+	backend := memcache.Open()
+	old := newCache(t, backend, key1)
+	testutil.RequireNoError(t, old.Set("old", []byte("v1")))
+
+	rotated := newCache(t, backend, key2, key1)
+	got, err := rotated.Get("old")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "v1", string(got))
+
+	testutil.RequireNoError(t, rotated.Set("new", []byte("v2")))
+	stored, err := backend.Get("new")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "k2", string(stored[2:2+int(stored[1])]))
+
+	// The old ring cannot read values encrypted with the new key.
+	_, err = old.Get("new")
+	testutil.RequireErrorIs(t, err, ErrDecrypt)
+}
+
+func TestNew_Errors(t *testing.T) {
+	_, err := New(memcache.Open())
+	testutil.RequireErrorIs(t, err, ErrNoKeys)
+	_, err = New(memcache.Open(), key1, Key{ID: "k1", Secret: key2.Secret})
+	testutil.RequireError(t, err)
+	_, err = New(memcache.Open(), Key{Secret: []byte("short")})
+	testutil.RequireError(t, err)
+	_, err = New(memcache.Open(), Key{ID: strings.Repeat("x", 256), Secret: key1.Secret})
+	testutil.RequireError(t, err)
+}
+
+func TestDriver_Registry(t *testing.T) {
+	backend := memcache.Open()
+	r := store.NewRegistry()
+	r.Register("private", driver.DriverFunc(func(*url.URL) (driver.Conn, error) {
+		return backend, nil
+	}))
+	r.Register(Scheme, Driver)
+	dsn := "encrypted+private://?wrap_key=" + url.QueryEscape(encodeKey(key1))
+
+	// The wrapped DSN is opened in the registry that opens the wrapper.
+	c, err := r.Open(dsn)
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, c.Set("k", []byte("v")))
+	stored, err := backend.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, !bytes.Equal(stored, []byte("v")), "value stored in the clear")
+	_, err = store.Open(dsn)
+	testutil.RequireErrorIs(t, err, registry.ErrUnknownDriver)
+}
+
+func TestParseKey(t *testing.T) {
+	k, err := ParseKey(" " + encodeKey(key1) + " ")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "k1", k.ID)
+	testutil.AssertTrue(t, bytes.Equal(key1.Secret, k.Secret))
+
+	k, err = ParseKey(base64.URLEncoding.EncodeToString(key1.Secret))
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "", k.ID)
+	testutil.AssertEqual(t, 8, len(keyring.ID(k)), "derived key ID")
+}
+
+func TestEncrypted_KeyLister(t *testing.T) {
+	c := newCache(t, conntest.Plain(memcache.Open()), key1)
+	_, ok := c.(expapi.KeyLister)
+	testutil.AssertTrue(t, !ok, "keys listed without a listing backend")
+}
+
+func TestEncrypted_Locker(t *testing.T) {
+	var locked []string
+	c := newCache(t, conntest.Locking(memcache.Open(), &locked), key1)
+	unlock, err := c.(driver.Locker).Lock("k")
+	testutil.RequireNoError(t, err)
+	unlock()
+	testutil.AssertTrue(t, slices.Equal(locked, []string{"k"}), "lock not taken from the backend")
+
+	// Without a locking backend, the lock only serializes this process.
+	c = newCache(t, memcache.Open(), key1)
+	unlock, err = c.(driver.Locker).Lock("k")
+	testutil.RequireNoError(t, err)
+	unlock()
+}
+
+func TestEncrypted_Streamer(t *testing.T) {
+	// Values are sealed as a whole, so streaming is not forwarded.
+	c := newCache(t, conntest.Streaming(memcache.Open()), key1)
+	_, ok := c.(driver.Streamer)
+	testutil.AssertTrue(t, !ok, "streams values past encryption")
+}
@@ -23,27 +23,50 @@
 //	GET    /debug/httpcache           -- List cache keys (if supported)
 //	GET    /debug/httpcache/{key}     -- Retrieve a cache entry
 //	DELETE /debug/httpcache/{key}     -- Delete a cache entry
+//	POST   /debug/httpcache/check     -- Check cache integrity; repair with "repair=true" (if supported)
 //
-// Backends that implement the [KeyLister] interface will support key listing.
-// All handlers expect a "dsn" query parameter to select the cache backend.
+// Backends that implement the [KeyLister] interface will support key listing,
+// and backends that implement the [Checker] interface integrity checks.
+// All handlers expect a "dsn" query parameter to select the cache backend,
+// which is opened in the default driver registry, or by the [Opener] given
+// to [NewService], for each request. Backends that implement [io.Closer] are
+// closed once the request has been served.
+//
+// [Register] installs the handlers at the paths above. The functions and
+// methods returning a single handler, such as [ListHandler], are only needed
+// to install it in a non-standard location.
 package expapi
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/bartventer/httpcache/store/driver"
 	"github.com/bartventer/httpcache/store/internal/registry"
 )
 
-type connOpener interface {
+// Opener opens cache connections given a DSN. A
+// [github.com/bartventer/httpcache/store.Registry] is an Opener.
+type Opener interface {
 	OpenConn(dsn string) (driver.Conn, error)
 }
 
-type storeService struct {
-	co connOpener
+// Service serves the HTTP cache API for the backends opened by an [Opener].
+// The package-level functions use a Service that opens backends in the
+// default registry.
+type Service struct {
+	co Opener
+}
+
+// NewService returns a Service that opens backends with o, such as a registry
+// returned by [github.com/bartventer/httpcache/store.NewRegistry].
+func NewService(o Opener) *Service {
+	return &Service{co: o}
 }
 
 // KeyLister is an optional interface implemented by cache backends that
@@ -53,9 +76,31 @@ type KeyLister interface {
 	Keys(prefix string) ([]string, error)
 }
 
+// Checker is an optional interface implemented by cache backends that can
+// check the integrity of their storage, and optionally repair the problems
+// they find.
+type Checker interface {
+	Check(ctx context.Context, repair bool) (*CheckReport, error)
+}
+
+// CheckReport describes the problems found by [Checker.Check].
+type CheckReport struct {
+	Scanned  int            `json:"scanned"`  // number of items inspected
+	Problems []CheckProblem `json:"problems"` // problems found
+}
+
+// CheckProblem describes a single problem found by [Checker.Check].
+type CheckProblem struct {
+	Kind     string `json:"kind"`             // kind of problem, defined by the backend
+	Path     string `json:"path"`             // location of the problem in the backend
+	Key      string `json:"key,omitempty"`    // affected key, if known
+	Detail   string `json:"detail,omitempty"` // description of the problem
+	Repaired bool   `json:"repaired"`         // whether the problem was repaired
+}
+
 func keyFromRequest(r *http.Request) string { return r.PathValue("key") }
 
-func connHandler(co connOpener, handler func(driver.Conn) http.Handler) http.Handler {
+func connHandler(co Opener, handler func(driver.Conn) http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		dsn := r.URL.Query().Get("dsn")
 		conn, err := co.OpenConn(dsn)
@@ -63,6 +108,9 @@ func connHandler(co connOpener, handler func(driver.Conn) http.Handler) http.Han
 			http.Error(w, "failed to open cache: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if c, ok := conn.(io.Closer); ok {
+			defer c.Close()
+		}
 		handler(conn).ServeHTTP(w, r)
 	})
 }
@@ -138,6 +186,29 @@ func destroy(conn driver.Conn) http.Handler {
 	})
 }
 
+func check(conn driver.Conn) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := conn.(Checker)
+		if !ok {
+			http.Error(w, "cache does not support integrity checks", http.StatusNotImplemented)
+			return
+		}
+		repair, _ := strconv.ParseBool(r.URL.Query().Get("repair"))
+		report, err := c.Check(r.Context(), repair)
+		if err != nil {
+			http.Error(
+				w,
+				fmt.Sprintf("failed to check cache: %v", err),
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
 type registerConfig struct {
 	Mux *http.ServeMux
 }
@@ -160,7 +231,8 @@ func WithServeMux(mux *http.ServeMux) RegisterOption {
 	})
 }
 
-func (m *storeService) Register(opts ...RegisterOption) {
+// Register registers the HTTP cache API handlers with the provided options.
+func (m *Service) Register(opts ...RegisterOption) {
 	cfg := &registerConfig{
 		Mux: http.DefaultServeMux,
 	}
@@ -171,24 +243,34 @@ func (m *storeService) Register(opts ...RegisterOption) {
 	mux.Handle("GET /debug/httpcache", connHandler(m.co, list))
 	mux.Handle("GET /debug/httpcache/{key}", connHandler(m.co, retrieve))
 	mux.Handle("DELETE /debug/httpcache/{key}", connHandler(m.co, destroy))
+	mux.Handle("POST /debug/httpcache/check", connHandler(m.co, check))
 }
 
-var defaultService = &storeService{co: registry.Default()}
+// ListHandler returns the list handler for the HTTP cache API.
+func (m *Service) ListHandler() http.Handler { return connHandler(m.co, list) }
+
+// RetrieveHandler returns the retrieve handler for the HTTP cache API.
+func (m *Service) RetrieveHandler() http.Handler { return connHandler(m.co, retrieve) }
+
+// DestroyHandler returns the destroy handler for the HTTP cache API.
+func (m *Service) DestroyHandler() http.Handler { return connHandler(m.co, destroy) }
+
+// CheckHandler returns the integrity check handler for the HTTP cache API.
+func (m *Service) CheckHandler() http.Handler { return connHandler(m.co, check) }
+
+var defaultService = NewService(registry.Default())
 
 // Register registers the HTTP cache API handlers with the provided options.
 func Register(opts ...RegisterOption) { defaultService.Register(opts...) }
 
 // ListHandler returns the list handler for the HTTP cache API.
-//
-// This is only needed to install the handler in a non-standard location.
-func ListHandler() http.Handler { return connHandler(defaultService.co, list) }
+func ListHandler() http.Handler { return defaultService.ListHandler() }
 
 // RetrieveHandler returns the retrieve handler for the HTTP cache API.
-//
-// This is only needed to install the handler in a non-standard location.
-func RetrieveHandler() http.Handler { return connHandler(defaultService.co, retrieve) }
+func RetrieveHandler() http.Handler { return defaultService.RetrieveHandler() }
 
 // DestroyHandler returns the destroy handler for the HTTP cache API.
-//
-// This is only needed to install the handler in a non-standard location.
-func DestroyHandler() http.Handler { return connHandler(defaultService.co, destroy) }
+func DestroyHandler() http.Handler { return defaultService.DestroyHandler() }
+
+// CheckHandler returns the integrity check handler for the HTTP cache API.
+func CheckHandler() http.Handler { return defaultService.CheckHandler() }
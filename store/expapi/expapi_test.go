@@ -15,6 +15,7 @@
 package expapi
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -26,7 +27,7 @@ import (
 	"github.com/bartventer/httpcache/store/driver"
 )
 
-var _ connOpener = (*mockConnOpener)(nil)
+var _ Opener = (*mockConnOpener)(nil)
 
 type mockConnOpener struct {
 	OpenConnFunc func(dsn string) (driver.Conn, error)
@@ -36,6 +37,17 @@ func (m *mockConnOpener) OpenConn(dsn string) (driver.Conn, error) {
 	return m.OpenConnFunc(dsn)
 }
 
+type mockCheckingCache struct {
+	driver.Conn
+	CheckFunc func(ctx context.Context, repair bool) (*CheckReport, error)
+}
+
+var _ Checker = (*mockCheckingCache)(nil)
+
+func (m *mockCheckingCache) Check(ctx context.Context, repair bool) (*CheckReport, error) {
+	return m.CheckFunc(ctx, repair)
+}
+
 type mockHTTPCache struct {
 	GetFunc    func(key string) ([]byte, error)
 	SetFunc    func(key string, value []byte) error
@@ -51,11 +63,11 @@ func (m *mockHTTPCache) Set(key string, value []byte) error   { return m.SetFunc
 func (m *mockHTTPCache) Delete(key string) error              { return m.DeleteFunc(key) }
 func (m *mockHTTPCache) Keys(prefix string) ([]string, error) { return m.KeysFunc(prefix) }
 
-func Test_storeService_OpenError(t *testing.T) {
+func TestService_OpenError(t *testing.T) {
 	co := &mockConnOpener{
 		OpenConnFunc: func(dsn string) (driver.Conn, error) { return nil, testutil.ErrSample },
 	}
-	m := &storeService{co: co}
+	m := NewService(co)
 	mux := http.NewServeMux()
 	m.Register(WithServeMux(mux))
 
@@ -67,6 +79,7 @@ func Test_storeService_OpenError(t *testing.T) {
 		{"List", http.MethodGet, "/debug/httpcache?dsn=foo"},
 		{"Get", http.MethodGet, "/debug/httpcache/key?dsn=foo"},
 		{"Delete", http.MethodDelete, "/debug/httpcache/key?dsn=foo"},
+		{"Check", http.MethodPost, "/debug/httpcache/check?dsn=foo"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -78,7 +91,31 @@ func Test_storeService_OpenError(t *testing.T) {
 	}
 }
 
-func Test_storeService_handlers(t *testing.T) {
+type mockClosingCache struct {
+	*mockHTTPCache
+	closed int
+}
+
+func (m *mockClosingCache) Close() error { m.closed++; return nil }
+
+func TestService_ClosesConn(t *testing.T) {
+	cache := &mockClosingCache{mockHTTPCache: &mockHTTPCache{
+		KeysFunc: func(prefix string) ([]string, error) { return nil, nil },
+		GetFunc:  func(key string) ([]byte, error) { return nil, driver.ErrNotExist },
+	}}
+	co := &mockConnOpener{
+		OpenConnFunc: func(dsn string) (driver.Conn, error) { return cache, nil },
+	}
+	mux := http.NewServeMux()
+	NewService(co).Register(WithServeMux(mux))
+
+	for i, url := range []string{"/debug/httpcache?dsn=foo", "/debug/httpcache/key?dsn=foo"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+		testutil.AssertEqual(t, i+1, cache.closed, "conn not closed after %s", url)
+	}
+}
+
+func TestService_handlers(t *testing.T) {
 	type args struct {
 		method string
 		url    string
@@ -229,6 +266,56 @@ func Test_storeService_handlers(t *testing.T) {
 				testutil.AssertTrue(t, strings.Contains(rr.Body.String(), "failed to delete value"))
 			},
 		},
+		{
+			name: "Check",
+			args: args{
+				method: http.MethodPost,
+				url:    "/debug/httpcache/check?dsn=foo&repair=true",
+				cache: &mockCheckingCache{
+					CheckFunc: func(ctx context.Context, repair bool) (*CheckReport, error) {
+						return &CheckReport{
+							Scanned:  2,
+							Problems: []CheckProblem{{Kind: "corrupt", Path: "a", Repaired: repair}},
+						}, nil
+					},
+				},
+			},
+			assertion: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				testutil.AssertEqual(t, http.StatusOK, rr.Code)
+				var report CheckReport
+				testutil.RequireNoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+				testutil.AssertEqual(t, 2, report.Scanned)
+				testutil.RequireTrue(t, len(report.Problems) == 1)
+				testutil.AssertTrue(t, report.Problems[0].Repaired, "repair not requested")
+			},
+		},
+		{
+			name: "Check with Error",
+			args: args{
+				method: http.MethodPost,
+				url:    "/debug/httpcache/check?dsn=foo",
+				cache: &mockCheckingCache{
+					CheckFunc: func(ctx context.Context, repair bool) (*CheckReport, error) {
+						return nil, testutil.ErrSample
+					},
+				},
+			},
+			assertion: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				testutil.AssertEqual(t, http.StatusInternalServerError, rr.Code)
+				testutil.AssertTrue(t, strings.Contains(rr.Body.String(), "failed to check cache"))
+			},
+		},
+		{
+			name: "Check Not Supported",
+			args: args{
+				method: http.MethodPost,
+				url:    "/debug/httpcache/check?dsn=foo",
+				cache:  &struct{ driver.Conn }{},
+			},
+			assertion: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				testutil.AssertEqual(t, http.StatusNotImplemented, rr.Code)
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -237,7 +324,7 @@ func Test_storeService_handlers(t *testing.T) {
 					return tt.args.cache, nil
 				},
 			}
-			m := &storeService{co: co}
+			m := NewService(co)
 			mux := http.NewServeMux()
 			m.Register(WithServeMux(mux))
 
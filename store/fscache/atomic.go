@@ -0,0 +1,208 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Durability controls which data is flushed to stable storage before a write
+// is reported as complete.
+type Durability int
+
+const (
+	// DurabilityFile flushes the file contents before it is renamed into
+	// place. A crash may lose the rename, but never exposes a partial file.
+	// This is the default.
+	DurabilityFile Durability = iota
+	// DurabilityNone does not flush. Writes are still atomic with respect to
+	// concurrent readers, but a crash may leave an empty or partial file.
+	DurabilityNone
+	// DurabilityFull flushes the file contents and, after the rename, the
+	// containing directory, so that a completed write survives a crash.
+	DurabilityFull
+)
+
+func (d Durability) String() string {
+	switch d {
+	case DurabilityNone:
+		return "none"
+	case DurabilityFile:
+		return "file"
+	case DurabilityFull:
+		return "full"
+	default:
+		return fmt.Sprintf("Durability(%d)", int(d))
+	}
+}
+
+func parseDurability(v string) (Durability, error) {
+	switch strings.ToLower(v) {
+	case "none":
+		return DurabilityNone, nil
+	case "file":
+		return DurabilityFile, nil
+	case "full":
+		return DurabilityFull, nil
+	default:
+		return 0, fmt.Errorf("%w: durability %q", ErrInvalidParam, v)
+	}
+}
+
+// tempPrefix marks files that are still being written. It cannot occur in a
+// file name produced by [fragmentFileName], as '.' is not part of the
+// URL-safe base64 alphabet.
+const tempPrefix = ".tmp-"
+
+func isTempFile(name string) bool {
+	return strings.HasPrefix(filepath.Base(name), tempPrefix)
+}
+
+// tempFileName returns a unique temporary file name in the directory of name.
+func tempFileName(name string) (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(name), tempPrefix+hex.EncodeToString(b[:])), nil
+}
+
+// writeFile atomically replaces the named file with data. The data is written
+// to a temporary file in the same directory, flushed according to c.durability,
+// and then renamed into place, so that readers never observe a partial file.
+func (c *fsCache) writeFile(name string, data []byte) error {
+	return c.writeFileIf(name, data, nil)
+}
+
+// writeFileIf is like writeFile, but calls check, if not nil, with the name of
+// the temporary file just before it is renamed into place. If check returns an
+// error, the write is abandoned and the error returned.
+func (c *fsCache) writeFileIf(name string, data []byte, check func(tmp string) error) error {
+	t, err := c.createTemp(name)
+	if err != nil {
+		return err
+	}
+	if _, err := t.f.Write(data); err != nil {
+		t.abort()
+		return err
+	}
+	return t.commit(check)
+}
+
+// tempFile is a temporary file being written, which replaces the named file
+// once committed.
+type tempFile struct {
+	c    *fsCache
+	f    *os.File
+	name string // file replaced on commit
+	tmp  string // name of the temporary file
+}
+
+// createTemp creates a temporary file that replaces name once committed.
+func (c *fsCache) createTemp(name string) (*tempFile, error) {
+	tmp, err := tempFileName(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := c.createExcl(tmp)
+	if err != nil {
+		return nil, err
+	}
+	return &tempFile{c, f, name, tmp}, nil
+}
+
+// commit flushes the file according to the cache's durability, and renames
+// it into place, calling check first as described for writeFileIf. The
+// temporary file is removed if commit fails.
+func (t *tempFile) commit(check func(tmp string) error) (err error) {
+	defer func() {
+		if err != nil {
+			_ = t.c.root.Remove(t.tmp)
+		}
+	}()
+	if t.c.durability != DurabilityNone {
+		if err = t.f.Sync(); err != nil {
+			_ = t.f.Close()
+			return err
+		}
+	}
+	if err = t.f.Close(); err != nil {
+		return err
+	}
+	if check != nil {
+		if err = check(t.tmp); err != nil {
+			return err
+		}
+	}
+	if err = t.c.root.Rename(t.tmp, t.name); err != nil {
+		return err
+	}
+	if t.c.durability == DurabilityFull {
+		return t.c.syncDir(filepath.Dir(t.name))
+	}
+	return nil
+}
+
+// abort closes and removes the temporary file.
+func (t *tempFile) abort() {
+	_ = t.f.Close()
+	_ = t.c.root.Remove(t.tmp)
+}
+
+// createAttempts bounds how often createExcl recreates parent directories
+// removed concurrently.
+const createAttempts = 8
+
+// createExcl creates a new file along with its parent directories. Empty
+// fragment directories may be removed by a Delete or an eviction pass, in
+// this or another process, between creating them and creating the file, in
+// which case it tries again.
+func (c *fsCache) createExcl(name string) (*os.File, error) {
+	dir := filepath.Dir(name)
+	for attempt := 1; ; attempt++ {
+		if err := c.root.MkdirAll(dir, 0o755); err != nil {
+			if errors.Is(err, os.ErrNotExist) && attempt < createAttempts {
+				continue
+			}
+			return nil, err
+		}
+		f, err := c.root.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil && errors.Is(err, os.ErrNotExist) && attempt < createAttempts {
+			continue
+		}
+		return f, err
+	}
+}
+
+// syncDir flushes the directory entry changes of dir. Windows does not support
+// syncing directories, so it is a no-op there.
+func (c *fsCache) syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := c.root.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+func Test_fromURL_Durability(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Durability
+		wantErr bool
+	}{
+		{"default", "", DurabilityFile, false},
+		{"none", "none", DurabilityNone, false},
+		{"file", "file", DurabilityFile, false},
+		{"full", "FULL", DurabilityFull, false},
+		{"invalid", "always", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := makeRootURL(t)
+			if tt.value != "" {
+				q := u.Query()
+				q.Set("durability", tt.value)
+				u.RawQuery = q.Encode()
+			}
+			cache, err := fromURL(u)
+			if tt.wantErr {
+				testutil.RequireErrorIs(t, err, ErrInvalidParam)
+				return
+			}
+			testutil.RequireNoError(t, err)
+			t.Cleanup(func() { cache.Close() })
+			testutil.AssertEqual(t, tt.want, cache.durability)
+		})
+	}
+}
+
+func Test_fsCache_Set_Durability(t *testing.T) {
+	for _, d := range []Durability{DurabilityNone, DurabilityFile, DurabilityFull} {
+		t.Run(d.String(), func(t *testing.T) {
+			cache, err := Open("testapp", WithBaseDir(t.TempDir()), WithDurability(d))
+			testutil.RequireNoError(t, err)
+			t.Cleanup(func() { cache.Close() })
+
+			key := "https://example.com/" + string(bytes.Repeat([]byte("a"), 300))
+			testutil.RequireNoError(t, cache.Set(key, []byte("first")))
+			testutil.RequireNoError(t, cache.Set(key, []byte("second")))
+			got, err := cache.Get(key)
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, "second", string(got))
+			testutil.AssertEqual(t, 0, len(tempFiles(t, cache)), "temporary files left behind")
+		})
+	}
+}
+
+// tempFiles returns the temporary files in the cache directory.
+func tempFiles(t *testing.T, c *fsCache) []string {
+	t.Helper()
+	var names []string
+	err := fs.WalkDir(c.root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && isTempFile(path) {
+			names = append(names, path)
+		}
+		return err
+	})
+	testutil.RequireNoError(t, err)
+	return names
+}
+
+func Test_fsCache_Set_ConcurrentReadersSeeWholeEntries(t *testing.T) {
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()), WithDurability(DurabilityNone))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+
+	values := [][]byte{
+		bytes.Repeat([]byte("a"), 64<<10),
+		bytes.Repeat([]byte("b"), 128<<10),
+	}
+	testutil.RequireNoError(t, cache.Set("key", values[0]))
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 50 {
+			_ = cache.Set("key", values[i%2])
+		}
+	})
+	for range 200 {
+		got, err := cache.Get("key")
+		testutil.RequireNoError(t, err)
+		testutil.AssertTrue(t, slices.ContainsFunc(values, func(v []byte) bool {
+			return bytes.Equal(got, v)
+		}), "read a partial entry of %d bytes", len(got))
+	}
+	wg.Wait()
+}
+
+func Test_fsCache_IgnoresStaleTempFiles(t *testing.T) {
+	cache := openManualEvict(t, 0, 1)
+	testutil.RequireNoError(t, cache.Set("a", []byte("1")))
+
+	// Simulate a write interrupted by a crash.
+	tmp, err := tempFileName(filepath.Join("x", "y"))
+	testutil.RequireNoError(t, err)
+	f, err := cache.createExcl(tmp)
+	testutil.RequireNoError(t, err)
+	_, err = f.Write([]byte("partial"))
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, f.Close())
+
+	testutil.AssertTrue(t, slices.Equal(sortedKeys(t, cache), []string{"a"}))
+	testutil.RequireNoError(t, cache.evict())
+	testutil.AssertTrue(t, slices.Equal(sortedKeys(t, cache), []string{"a"}),
+		"temporary file counted against the quota")
+}
+
+func Test_tempFileName(t *testing.T) {
+	name := fragmentingFileNamer().FileName("https://example.com/")
+	tmp, err := tempFileName(name)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, isTempFile(tmp))
+	testutil.AssertTrue(t, !isTempFile(name))
+	testutil.AssertEqual(t, filepath.Dir(name), filepath.Dir(tmp))
+}
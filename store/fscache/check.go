@@ -0,0 +1,323 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/bartventer/httpcache/internal"
+	"github.com/bartventer/httpcache/store/expapi"
+)
+
+// Kinds of problems reported by Check.
+const (
+	ProblemBadName       = "bad_name"      // file name does not decode to a key, or is not the name of its key
+	ProblemUndecryptable = "undecryptable" // file fails AES-GCM authentication with every key
+	ProblemBadPayload    = "bad_payload"   // file has no valid key header, with hashed names
+	ProblemBadResponse   = "bad_response"  // response entry is truncated or cannot be decoded
+	ProblemBadRefs       = "bad_refs"      // refs document cannot be decoded
+	ProblemDanglingRefs  = "dangling_refs" // refs document points at missing response entries
+	ProblemTempFile      = "temp_file"     // temporary file left behind by an interrupted write
+	ProblemEmptyDir      = "empty_dir"     // fragment or shard directory without files
+)
+
+// staleTempAge is the age after which a temporary file is considered left
+// behind by an interrupted write rather than part of one in progress.
+const staleTempAge = 2 * defaultTimeout
+
+var errMigrationInProgress = errors.New("fscache: layout migration in progress")
+
+var _ expapi.Checker = (*fsCache)(nil)
+
+// Check inspects every file in the cache directory and reports the problems
+// it finds; see the Problem constants. If repair is set, it also removes the
+// files and directories at fault, and rewrites refs documents without their
+// dangling references, or removes them if none are left.
+//
+// Check assumes the cache holds entries written by httpcache: response
+// entries, whose keys end in '#' and a number, and refs documents listing the
+// responses stored for a URL under other keys. It fails while a layout
+// migration is in progress, as entries not migrated yet have unexpected names.
+//
+// Entries replaced by a concurrent Set, in this or another process, are not
+// removed.
+func (c *fsCache) Check(ctx context.Context, repair bool) (*expapi.CheckReport, error) {
+	if !c.migrated.Load() {
+		return nil, errMigrationInProgress
+	}
+	chk := &checker{
+		c:         c,
+		repair:    repair,
+		report:    &expapi.CheckReport{Problems: []expapi.CheckProblem{}},
+		responses: make(map[string]bool),
+		corrupt:   make(map[string]bool),
+		refs:      make(map[string]internal.ResponseRefs),
+	}
+	err := fs.WalkDir(c.root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := filepath.FromSlash(path)
+		switch {
+		case d.IsDir():
+			if path != "." {
+				chk.dirs = append(chk.dirs, name)
+			}
+			return nil
+		case isTempFile(name):
+			return chk.checkTempFile(name, d)
+		case isMetaFile(name):
+			return nil
+		}
+		chk.report.Scanned++
+		return chk.checkFile(name)
+	})
+	if err != nil {
+		return chk.report, err
+	}
+	for _, urlKey := range slices.Sorted(maps.Keys(chk.refs)) {
+		if err := ctx.Err(); err != nil {
+			return chk.report, err
+		}
+		if err := chk.checkRefs(urlKey); err != nil {
+			return chk.report, err
+		}
+	}
+	// Directories are listed before their contents; check the deepest first,
+	// so that parents emptied by repairs are removed too.
+	for _, dir := range slices.Backward(chk.dirs) {
+		chk.checkDir(dir)
+	}
+	return chk.report, nil
+}
+
+// checker holds the state of a single Check pass.
+type checker struct {
+	c      *fsCache
+	repair bool
+	report *expapi.CheckReport
+
+	responses map[string]bool                  // valid response entries
+	corrupt   map[string]bool                  // corrupt entries
+	refs      map[string]internal.ResponseRefs // valid refs documents
+	dirs      []string                         // directories, in walk order
+}
+
+func (chk *checker) add(p expapi.CheckProblem) {
+	p.Path = filepath.ToSlash(p.Path)
+	chk.report.Problems = append(chk.report.Problems, p)
+}
+
+func (chk *checker) checkTempFile(name string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	if time.Since(info.ModTime()) < staleTempAge {
+		return nil // probably a write in progress
+	}
+	p := expapi.CheckProblem{Kind: ProblemTempFile, Path: name}
+	if chk.repair {
+		p.Repaired = chk.c.root.Remove(name) == nil
+	}
+	chk.add(p)
+	return nil
+}
+
+func (chk *checker) checkFile(name string) error {
+	info, err := chk.c.root.Stat(name)
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	data, err := chk.c.root.ReadFile(name)
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	key, value, kind, err := chk.c.decodeEntry(name, data)
+	if kind == "" {
+		switch {
+		case internal.IsResponseKey(key):
+			if err = checkResponse(value); err != nil {
+				kind = ProblemBadResponse
+			}
+		default:
+			var refs internal.ResponseRefs
+			if err = json.Unmarshal(value, &refs); err != nil {
+				kind = ProblemBadRefs
+			} else {
+				chk.refs[key] = refs
+			}
+		}
+	}
+	if kind == "" {
+		if internal.IsResponseKey(key) {
+			chk.responses[key] = true
+		}
+		return nil
+	}
+	chk.corrupt[key] = true
+	p := expapi.CheckProblem{Kind: kind, Path: name, Key: key, Detail: err.Error()}
+	if chk.repair {
+		p.Repaired = chk.c.removeFile(name, info) == nil
+	}
+	chk.add(p)
+	return nil
+}
+
+// decodeEntry returns the key and value stored in the named entry file, or
+// the kind of problem that prevents it.
+func (c *fsCache) decodeEntry(name string, data []byte) (key string, value []byte, kind string, err error) {
+	if !c.hashNames {
+		if key, err = c.fnk.KeyFromFileName(name); err != nil {
+			return "", nil, ProblemBadName, err
+		}
+	}
+	value = data
+	if c.enc != nil {
+		if value, err = c.enc.Decrypt(data); err != nil {
+			return key, nil, ProblemUndecryptable, err
+		}
+	}
+	if c.hashNames {
+		if key, value, err = decodePayload(value); err != nil {
+			return "", nil, ProblemBadPayload, err
+		}
+	}
+	if want := c.fn.FileName(key); want != name {
+		return key, nil, ProblemBadName, fmt.Errorf("expected file name %q", filepath.ToSlash(want))
+	}
+	return key, value, "", nil
+}
+
+// checkResponse reports whether value is a complete response entry.
+func checkResponse(value []byte) error {
+	resp, err := internal.ParseResponse(value, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Data.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Data.Body)
+	return err
+}
+
+// checkRefs reports, and optionally removes, the references of the refs
+// document stored under urlKey to response entries that are missing or
+// corrupt.
+func (chk *checker) checkRefs(urlKey string) error {
+	refs := chk.refs[urlKey]
+	dangling := chk.dangling(refs)
+	if dangling == 0 {
+		return nil
+	}
+	p := expapi.CheckProblem{
+		Kind:   ProblemDanglingRefs,
+		Path:   chk.c.fn.FileName(urlKey),
+		Key:    urlKey,
+		Detail: fmt.Sprintf("%d of %d references point at missing responses", dangling, len(refs)),
+	}
+	if chk.repair {
+		repaired, err := chk.repairRefs(urlKey)
+		if err != nil {
+			return err
+		}
+		p.Repaired = repaired
+	}
+	chk.add(p)
+	return nil
+}
+
+func (chk *checker) dangling(refs internal.ResponseRefs) int {
+	var n int
+	for _, ref := range refs {
+		if ref == nil || !chk.responseExists(ref.ResponseID) {
+			n++
+		}
+	}
+	return n
+}
+
+// responseExists reports whether the response entry for key is valid, or was
+// stored after the walk passed its location.
+func (chk *checker) responseExists(key string) bool {
+	if chk.responses[key] {
+		return true
+	}
+	if chk.corrupt[key] {
+		return false
+	}
+	_, err := chk.c.root.Stat(chk.c.fn.FileName(key))
+	return err == nil
+}
+
+// repairRefs rewrites the refs document stored under urlKey without its
+// dangling references, holding the lock that writers of refs documents
+// acquire via Lock. It reports whether the document was changed.
+func (chk *checker) repairRefs(urlKey string) (bool, error) {
+	c := chk.c
+	unlock, err := c.klocks.lock(c, c.fn.FileName(urlKey))
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	data, err := c.get(urlKey)
+	if err != nil {
+		return false, ignoreNotExist(err)
+	}
+	var refs internal.ResponseRefs
+	if err := json.Unmarshal(data, &refs); err != nil {
+		return false, nil //nolint:nilerr // Replaced by a concurrent write.
+	}
+	kept := slices.DeleteFunc(slices.Clone(refs), func(ref *internal.ResponseRef) bool {
+		return ref == nil || !chk.responseExists(ref.ResponseID)
+	})
+	switch {
+	case len(kept) == len(refs):
+		return false, nil
+	case len(kept) == 0:
+		return true, ignoreNotExist(c.delete(urlKey))
+	}
+	data, err = json.Marshal(kept)
+	if err != nil {
+		return false, err
+	}
+	return true, c.set(context.Background(), urlKey, data)
+}
+
+func (chk *checker) checkDir(dir string) {
+	entries, err := fs.ReadDir(chk.c.root.FS(), filepath.ToSlash(dir))
+	if err != nil || len(entries) > 0 {
+		return
+	}
+	p := expapi.CheckProblem{Kind: ProblemEmptyDir, Path: dir}
+	if chk.repair {
+		p.Repaired = chk.c.root.Remove(dir) == nil
+	}
+	chk.add(p)
+}
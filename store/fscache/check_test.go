@@ -0,0 +1,211 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal"
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/expapi"
+)
+
+// responseEntry returns a response entry as stored by httpcache.
+func responseEntry(t *testing.T, id, body string) []byte {
+	t.Helper()
+	data, err := internal.Response{
+		ID: id,
+		Data: &http.Response{
+			StatusCode:    http.StatusOK,
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+		},
+		RequestedAt: time.Now(),
+		ReceivedAt:  time.Now(),
+	}.MarshalBinary()
+	testutil.RequireNoError(t, err)
+	return data
+}
+
+func refsEntry(t *testing.T, ids ...string) []byte {
+	t.Helper()
+	refs := make(internal.ResponseRefs, 0, len(ids))
+	for _, id := range ids {
+		refs = append(refs, &internal.ResponseRef{ResponseID: id})
+	}
+	data, err := json.Marshal(refs)
+	testutil.RequireNoError(t, err)
+	return data
+}
+
+// setURL stores a refs document for url with a response entry for each of
+// the given ids.
+func setURL(t *testing.T, c *fsCache, url string, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		testutil.RequireNoError(t, c.Set(id, responseEntry(t, id, "hello")))
+	}
+	testutil.RequireNoError(t, c.Set(url, refsEntry(t, ids...)))
+}
+
+func problemKinds(report *expapi.CheckReport) []string {
+	var kinds []string
+	for _, p := range report.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+	slices.Sort(kinds)
+	return slices.Compact(kinds)
+}
+
+func Test_fsCache_Check(t *testing.T) {
+	const url = "https://example.com/"
+	encrypted := []Option{WithKeyProvider(StaticKeys(mustKey(t, "k")))}
+	tests := []struct {
+		name  string
+		opts  []Option
+		setup func(t *testing.T, c *fsCache)
+		want  []string
+	}{
+		{"Healthy", encrypted, func(t *testing.T, c *fsCache) {}, nil},
+		{"BadName", nil, func(t *testing.T, c *fsCache) {
+			testutil.RequireNoError(t, c.root.WriteFile("not*base64", []byte("v"), 0o644))
+		}, []string{ProblemBadName}},
+		{"MisplacedName", nil, func(t *testing.T, c *fsCache) {
+			// A valid encoding, but fragmented although the key is short.
+			testutil.RequireNoError(t, c.root.MkdirAll("YQ", 0o755))
+			testutil.RequireNoError(t, c.root.WriteFile(filepath.Join("YQ", "YQ"), []byte("v"), 0o644))
+		}, []string{ProblemBadName}},
+		{"Undecryptable", encrypted, func(t *testing.T, c *fsCache) {
+			testutil.RequireNoError(t, c.root.WriteFile(c.fn.FileName(url+"#2"), []byte("garbage"), 0o644))
+		}, []string{ProblemUndecryptable}},
+		{"BadPayload", encrypted, func(t *testing.T, c *fsCache) {
+			testutil.RequireNoError(t, c.root.WriteFile(
+				c.fn.FileName(url+"#2"), mustEncrypt(t, c, []byte("no header")), 0o644))
+		}, []string{ProblemBadPayload}},
+		{"TruncatedResponse", nil, func(t *testing.T, c *fsCache) {
+			data := responseEntry(t, url+"#2", "hello")
+			testutil.RequireNoError(t, c.Set(url+"#2", data[:len(data)-2]))
+		}, []string{ProblemBadResponse}},
+		{"UndecodableResponse", encrypted, func(t *testing.T, c *fsCache) {
+			testutil.RequireNoError(t, c.Set(url+"#2", []byte("garbage")))
+		}, []string{ProblemBadResponse}},
+		{"BadRefs", nil, func(t *testing.T, c *fsCache) {
+			testutil.RequireNoError(t, c.Set("https://example.com/other", []byte("{")))
+		}, []string{ProblemBadRefs}},
+		{"DanglingRefs", nil, func(t *testing.T, c *fsCache) {
+			testutil.RequireNoError(t, c.Set("https://example.com/gone", refsEntry(t, "https://example.com/gone#1")))
+		}, []string{ProblemDanglingRefs}},
+		{"StaleTempFile", nil, func(t *testing.T, c *fsCache) {
+			for _, age := range []time.Duration{0, 2 * staleTempAge} {
+				tmp, err := tempFileName(c.fn.FileName(url))
+				testutil.RequireNoError(t, err)
+				testutil.RequireNoError(t, c.root.WriteFile(tmp, []byte("partial"), 0o644))
+				mtime := time.Now().Add(-age)
+				testutil.RequireNoError(t, c.root.Chtimes(tmp, mtime, mtime))
+			}
+		}, []string{ProblemTempFile}},
+		{"EmptyDir", nil, func(t *testing.T, c *fsCache) {
+			testutil.RequireNoError(t, c.root.MkdirAll(filepath.Join("a", "b"), 0o755))
+		}, []string{ProblemEmptyDir}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := Open("testapp", append([]Option{WithBaseDir(t.TempDir())}, tt.opts...)...)
+			testutil.RequireNoError(t, err)
+			t.Cleanup(func() { cache.Close() })
+			cache.bg.Wait()
+			setURL(t, cache, url, url+"#1")
+			tt.setup(t, cache)
+
+			for range 2 { // checking does not change anything
+				report, err := cache.Check(t.Context(), false)
+				testutil.RequireNoError(t, err)
+				testutil.AssertTrue(t, slices.Equal(problemKinds(report), tt.want), "got %v", report.Problems)
+				for _, p := range report.Problems {
+					testutil.AssertTrue(t, !p.Repaired, "repaired %q without being asked to", p.Path)
+				}
+			}
+
+			report, err := cache.Check(t.Context(), true)
+			testutil.RequireNoError(t, err)
+			testutil.AssertTrue(t, slices.Equal(problemKinds(report), tt.want), "got %v", report.Problems)
+			for _, p := range report.Problems {
+				testutil.AssertTrue(t, p.Repaired, "did not repair %q", p.Path)
+			}
+			report, err = cache.Check(t.Context(), false)
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, 0, len(report.Problems), "problems left after repair")
+			testutil.AssertTrue(t, report.Scanned >= 2)
+
+			// The healthy entries survive the repair.
+			_, err = cache.Get(url)
+			testutil.RequireNoError(t, err)
+			_, err = cache.Get(url + "#1")
+			testutil.RequireNoError(t, err)
+		})
+	}
+}
+
+func Test_fsCache_Check_RepairsDanglingRefs(t *testing.T) {
+	const url = "https://example.com/"
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	setURL(t, cache, url, url+"#1", url+"#2")
+	testutil.RequireNoError(t, cache.Delete(url+"#2"))
+
+	report, err := cache.Check(t.Context(), true)
+	testutil.RequireNoError(t, err)
+	testutil.RequireTrue(t, len(report.Problems) == 1)
+	testutil.AssertEqual(t, url, report.Problems[0].Key)
+
+	data, err := cache.Get(url)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, string(refsEntry(t, url+"#1")), string(data))
+}
+
+func Test_fsCache_Check_MigrationInProgress(t *testing.T) {
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	cache.migrated.Store(false)
+	_, err = cache.Check(t.Context(), false)
+	testutil.RequireErrorIs(t, err, errMigrationInProgress)
+}
+
+func Test_fsCache_removeFile_Changed(t *testing.T) {
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	testutil.RequireNoError(t, cache.Set("a", []byte("old")))
+	name := cache.fn.FileName("a")
+	info, err := cache.root.Stat(name)
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, cache.Set("a", []byte("newer")))
+
+	testutil.RequireErrorIs(t, cache.removeFile(name, info), errEntryChanged)
+	_, err = cache.Get("a")
+	testutil.RequireNoError(t, err)
+}
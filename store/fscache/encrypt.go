@@ -15,14 +15,21 @@
 package fscache
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
+
+	"github.com/bartventer/httpcache/store/internal/keyring"
 )
 
-var errCiphertextTooShort = errors.New("ciphertext too short")
+var (
+	errCiphertextTooShort = errors.New("ciphertext too short")
+	errNoKeys             = errors.New("fscache: key ring is empty")
+)
 
 type encryptor interface {
 	Encrypt(data []byte) ([]byte, error)
@@ -44,6 +51,10 @@ func newAESGCMEncryptor(r io.Reader, keyB64 string) (*aesgcmEncryptor, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newAESGCMEncryptorKey(r, key)
+}
+
+func newAESGCMEncryptorKey(r io.Reader, key []byte) (*aesgcmEncryptor, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -71,3 +82,103 @@ func (e *aesgcmEncryptor) Decrypt(data []byte) ([]byte, error) {
 	nonce, ciphertext := data[:e.gcm.NonceSize()], data[e.gcm.NonceSize():]
 	return e.gcm.Open(ciphertext[:0], nonce, ciphertext, nil)
 }
+
+// keyRingMagic prefixes ciphertexts that carry a key ID. It is followed by
+// one byte holding the length of the key ID, the key ID, and the output of
+// [aesgcmEncryptor.Encrypt]. Ciphertexts without it were written before key
+// rotation was supported and are decrypted by trying every key in the ring.
+var keyRingMagic = []byte("fsk1")
+
+// keyRing implements the encryptor interface with one active key used for
+// encryption and any number of older keys that are only used for decryption.
+type keyRing struct {
+	active *ringKey
+	keys   []*ringKey // all keys, active first
+	byID   map[string]*ringKey
+}
+
+type ringKey struct {
+	id string
+	*aesgcmEncryptor
+}
+
+func newKeyRing(r io.Reader, keys []Key) (*keyRing, error) {
+	if len(keys) == 0 {
+		return nil, errNoKeys
+	}
+	kr := &keyRing{byID: make(map[string]*ringKey, len(keys))}
+	for _, k := range keys {
+		id := keyring.ID(k)
+		if len(id) > 255 {
+			return nil, fmt.Errorf("fscache: key ID %q is longer than 255 bytes", id)
+		}
+		if _, ok := kr.byID[id]; ok {
+			return nil, fmt.Errorf("fscache: duplicate key ID %q", id)
+		}
+		enc, err := newAESGCMEncryptorKey(r, k.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("fscache: key %q: %w", id, err)
+		}
+		rk := &ringKey{id: id, aesgcmEncryptor: enc}
+		kr.keys = append(kr.keys, rk)
+		kr.byID[id] = rk
+	}
+	kr.active = kr.keys[0]
+	return kr, nil
+}
+
+var _ encryptor = (*keyRing)(nil)
+
+func (kr *keyRing) Encrypt(data []byte) ([]byte, error) {
+	ciphertext, err := kr.active.Encrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, len(keyRingMagic)+1+len(kr.active.id))
+	header = append(header, keyRingMagic...)
+	header = append(header, byte(len(kr.active.id)))
+	header = append(header, kr.active.id...)
+	return append(header, ciphertext...), nil
+}
+
+func (kr *keyRing) Decrypt(data []byte) ([]byte, error) {
+	plaintext, _, err := kr.open(data)
+	return plaintext, err
+}
+
+// open decrypts data and reports whether it was encrypted with the active key.
+func (kr *keyRing) open(data []byte) (plaintext []byte, active bool, err error) {
+	if rk, ciphertext, ok := kr.split(data); ok {
+		plaintext, err = rk.Decrypt(ciphertext)
+		if err == nil {
+			return plaintext, rk == kr.active, nil
+		}
+	}
+	// Fall back to the format without a key ID; a legacy nonce may also start
+	// with the magic bytes by chance.
+	for _, rk := range kr.keys {
+		plaintext, err = rk.Decrypt(bytes.Clone(data))
+		if err == nil {
+			return plaintext, false, nil
+		}
+	}
+	return nil, false, err
+}
+
+// split parses the key ID header of data, returning the matching key and the
+// remaining ciphertext.
+func (kr *keyRing) split(data []byte) (*ringKey, []byte, bool) {
+	rest, ok := bytes.CutPrefix(data, keyRingMagic)
+	if !ok || len(rest) == 0 {
+		return nil, nil, false
+	}
+	n := int(rest[0])
+	if len(rest) < 1+n {
+		return nil, nil, false
+	}
+	rk, ok := kr.byID[string(rest[1:1+n])]
+	if !ok {
+		return nil, nil, false
+	}
+	return rk, bytes.Clone(rest[1+n:]), true
+}
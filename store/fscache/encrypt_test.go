@@ -20,9 +20,11 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/internal/keyring"
 )
 
 func mustBase64Key(t *testing.T, size int) string {
@@ -138,3 +140,86 @@ type errorReader struct{}
 func (errorReader) Read(p []byte) (int, error) {
 	return 0, testutil.ErrSample
 }
+
+func mustKey(t *testing.T, id string) Key {
+	t.Helper()
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	testutil.RequireNoError(t, err)
+	return Key{ID: id, Secret: secret}
+}
+
+func Test_newKeyRing(t *testing.T) {
+	k := mustKey(t, "a")
+	tests := []struct {
+		name    string
+		keys    []Key
+		wantErr bool
+	}{
+		{"Valid", []Key{k, mustKey(t, "b")}, false},
+		{"Empty", nil, true},
+		{"DuplicateID", []Key{k, mustKey(t, "a")}, true},
+		{"DerivedIDs", []Key{{Secret: k.Secret}, {Secret: mustKey(t, "").Secret}}, false},
+		{"InvalidKeySize", []Key{{ID: "short", Secret: []byte("short")}}, true},
+		{"LongID", []Key{{ID: strings.Repeat("x", 256), Secret: k.Secret}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr, err := newKeyRing(rand.Reader, tt.keys)
+			if tt.wantErr {
+				testutil.RequireError(t, err)
+				return
+			}
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, keyring.ID(tt.keys[0]), kr.active.id)
+		})
+	}
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	oldKey, newKey := mustKey(t, "old"), mustKey(t, "new")
+	oldRing, err := newKeyRing(rand.Reader, []Key{oldKey})
+	testutil.RequireNoError(t, err)
+	newRing, err := newKeyRing(rand.Reader, []Key{newKey, oldKey})
+	testutil.RequireNoError(t, err)
+
+	plaintext := []byte("hello world")
+	ciphertext, err := oldRing.Encrypt(plaintext)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, bytes.HasPrefix(ciphertext, append(keyRingMagic, 3, 'o', 'l', 'd')))
+
+	got, active, err := newRing.open(ciphertext)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, bytes.Equal(got, plaintext))
+	testutil.AssertTrue(t, !active, "expected ciphertext to need re-encryption")
+
+	ciphertext, err = newRing.Encrypt(plaintext)
+	testutil.RequireNoError(t, err)
+	_, active, err = newRing.open(ciphertext)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, active)
+
+	_, err = oldRing.Decrypt(ciphertext)
+	testutil.RequireError(t, err, "old ring must not decrypt entries written with the new key")
+}
+
+func TestKeyRing_DecryptLegacy(t *testing.T) {
+	key := mustBase64Key(t, 32)
+	legacy, err := newAESGCMEncryptor(rand.Reader, key)
+	testutil.RequireNoError(t, err)
+	plaintext := []byte("written before key rotation")
+	ciphertext, err := legacy.Encrypt(plaintext)
+	testutil.RequireNoError(t, err)
+
+	k, err := ParseKey(key)
+	testutil.RequireNoError(t, err)
+	kr, err := newKeyRing(rand.Reader, []Key{mustKey(t, "new"), k})
+	testutil.RequireNoError(t, err)
+	got, active, err := kr.open(ciphertext)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, bytes.Equal(got, plaintext))
+	testutil.AssertTrue(t, !active)
+
+	_, err = kr.Decrypt([]byte("garbage that no key can open"))
+	testutil.RequireError(t, err)
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultEvictInterval = time.Minute
+
+// evictor tracks file accesses and sizes so that the cache can be kept within
+// its configured quota. Access times recorded in process take precedence over
+// file modification times, which are used for files not accessed since the
+// cache was opened.
+type evictor struct {
+	maxSize  int64 // maximum total file size; 0 means unbounded
+	maxFiles int   // maximum number of files; 0 means unbounded
+
+	mu      sync.Mutex
+	atimes  map[string]time.Time // file name -> last access in this process
+	reading map[string]int       // file name -> number of in-flight reads
+	size    int64                // estimated total size since the last pass
+	files   int                  // estimated file count since the last pass
+
+	kick chan struct{} // requests an eviction pass
+	stop chan struct{} // closed to stop the eviction loop
+	done chan struct{} // closed when the eviction loop has returned
+}
+
+func newEvictor(maxSize int64, maxFiles int) *evictor {
+	return &evictor{
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		atimes:   make(map[string]time.Time),
+		reading:  make(map[string]int),
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (e *evictor) overQuota(size int64, files int) bool {
+	return (e.maxSize > 0 && size > e.maxSize) || (e.maxFiles > 0 && files > e.maxFiles)
+}
+
+// overLowWater reports whether the totals exceed the low-water mark, 90% of
+// the quota, down to which an eviction pass removes files so that a cache
+// kept full by steady writes is not trimmed by one file at a time.
+func (e *evictor) overLowWater(size int64, files int) bool {
+	return (e.maxSize > 0 && size > e.maxSize-e.maxSize/10) ||
+		(e.maxFiles > 0 && files > e.maxFiles-e.maxFiles/10)
+}
+
+// beginRead marks name as being read, so that it is not evicted until the
+// matching call to endRead.
+func (e *evictor) beginRead(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.reading[name]++
+}
+
+func (e *evictor) endRead(name string, hit bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.reading[name]--; e.reading[name] <= 0 {
+		delete(e.reading, name)
+	}
+	if hit {
+		e.atimes[name] = time.Now()
+	}
+}
+
+// written records that name was written with the given size, and requests an
+// eviction pass if the estimated totals exceed the quota.
+func (e *evictor) written(name string, size int64) {
+	e.mu.Lock()
+	if _, ok := e.atimes[name]; !ok {
+		e.files++
+	}
+	e.atimes[name] = time.Now()
+	e.size += size
+	over := e.overQuota(e.size, e.files)
+	e.mu.Unlock()
+	if over {
+		select {
+		case e.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *evictor) removed(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.atimes, name)
+}
+
+// evictCandidate describes a file considered for eviction.
+type evictCandidate struct {
+	name  string
+	info  fs.FileInfo // as scanned; the file is skipped if it has changed since
+	size  int64
+	atime time.Time
+}
+
+// lastAccess returns the time name was last accessed, falling back to mtime.
+func (e *evictor) lastAccess(name string, mtime time.Time) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if t, ok := e.atimes[name]; ok && t.After(mtime) {
+		return t
+	}
+	return mtime
+}
+
+func (e *evictor) isReading(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.reading[name] > 0
+}
+
+// run calls pass every interval, or sooner when requested via e.kick, until
+// e.stop is closed.
+func (e *evictor) run(interval time.Duration, pass func()) {
+	defer close(e.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		case <-e.kick:
+		}
+		pass()
+	}
+}
+
+func (e *evictor) close() {
+	close(e.stop)
+	<-e.done
+}
+
+// scan walks the cache directory and returns every cache file with its size
+// and last access time. Files removed concurrently are skipped.
+func (c *fsCache) scan() ([]evictCandidate, error) {
+	var files []evictCandidate
+	err := fs.WalkDir(c.root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || isMetaFile(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		name := filepath.FromSlash(path)
+		files = append(files, evictCandidate{
+			name:  name,
+			info:  info,
+			size:  info.Size(),
+			atime: c.ev.lastAccess(name, info.ModTime()),
+		})
+		return nil
+	})
+	return files, err
+}
+
+// evict removes the least recently used files, once the cache is over its
+// quota, until it is within the low-water mark. Files being read by this
+// process, or rewritten since the scan, are skipped; on POSIX systems,
+// readers in other processes keep their open file descriptor, so removing a
+// file does not disturb them.
+func (c *fsCache) evict() error {
+	files, err := c.scan()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	count := len(files)
+	if c.ev.overQuota(size, count) {
+		slices.SortFunc(files, func(a, b evictCandidate) int {
+			return cmp.Or(a.atime.Compare(b.atime), cmp.Compare(a.name, b.name))
+		})
+		for _, f := range files {
+			if !c.ev.overLowWater(size, count) {
+				break
+			}
+			if c.ev.isReading(f.name) {
+				continue
+			}
+			if err := c.removeFile(f.name, f.info); err != nil {
+				continue
+			}
+			size -= f.size
+			count--
+		}
+	}
+	c.ev.mu.Lock()
+	c.ev.size, c.ev.files = size, count
+	c.ev.mu.Unlock()
+	return nil
+}
+
+// removeFile removes the named entry file, along with the fragment
+// directories left empty, holding its write lock so that the index is not
+// updated out of order with a concurrent Set. If want is not nil, the file is
+// only removed if its size and modification time still match, and
+// errEntryChanged is returned otherwise.
+func (c *fsCache) removeFile(name string, want fs.FileInfo) error {
+	unlock, err := c.wlocks.lock(c, name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if want != nil {
+		cur, err := c.root.Stat(name)
+		if err != nil {
+			return err
+		}
+		if !cur.ModTime().Equal(want.ModTime()) || cur.Size() != want.Size() {
+			return errEntryChanged
+		}
+	}
+	if err := c.root.Remove(name); err != nil {
+		return err
+	}
+	if c.idx != nil {
+		// The file is gone either way; the entry is dropped when the index
+		// is next rebuilt if this fails.
+		_ = c.idx.removeName(c, name)
+	}
+	if c.ev != nil {
+		c.ev.removed(name)
+	}
+	c.removeEmptyDirs(filepath.Dir(name))
+	return nil
+}
+
+// removeEmptyDirsStep is the number of path components resolved per
+// intermediate root by removeEmptyDirs, which keeps the cost of removing the
+// directories of very long keys linear in their depth.
+const removeEmptyDirsStep = 64
+
+// removeEmptyDirs removes dir and its parents, up to but excluding the cache
+// root, for as long as they are empty. These are the fragment directories
+// created for long keys by [fragmentFileName].
+func (c *fsCache) removeEmptyDirs(dir string) {
+	if dir == "." || dir == "" {
+		return
+	}
+	parts := strings.Split(dir, string(filepath.Separator))
+	roots := []*os.Root{c.root} // roots[i] is opened at parts[:i*step]
+	defer func() {
+		for _, r := range roots[1:] {
+			_ = r.Close()
+		}
+	}()
+	for i := removeEmptyDirsStep; i < len(parts); i += removeEmptyDirsStep {
+		r, err := roots[len(roots)-1].OpenRoot(
+			filepath.Join(parts[i-removeEmptyDirsStep : i]...),
+		)
+		if err != nil {
+			return
+		}
+		roots = append(roots, r)
+	}
+	for n := len(parts); n > 0; n-- {
+		k := (n - 1) / removeEmptyDirsStep
+		name := filepath.Join(parts[k*removeEmptyDirsStep : n]...)
+		// Remove fails on non-empty directories, which ends the walk.
+		if err := roots[k].Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return
+		}
+	}
+}
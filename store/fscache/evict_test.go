@@ -0,0 +1,216 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+func TestFSCache_Acceptance_Bounded(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		cache, err := Open("testapp", WithBaseDir(t.TempDir()), WithMaxFiles(100))
+		testutil.RequireNoError(t, err, "Failed to create fscache")
+		return cache, func() { cache.Close() }
+	}))
+}
+
+func Test_quotaOptions(t *testing.T) {
+	tests := []struct {
+		dsn     string
+		wantErr bool
+	}{
+		{"fscache://?appname=a&max_size=1GiB&max_files=10&evict_interval=10s", false},
+		{"fscache://?appname=a&max_size=big", true},
+		{"fscache://?appname=a&max_files=-1", true},
+		{"fscache://?appname=a&max_files=ten", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			u, err := url.Parse(tt.dsn)
+			testutil.RequireNoError(t, err)
+			_, err = quotaOptions(u.Query())
+			if tt.wantErr {
+				testutil.RequireErrorIs(t, err, ErrInvalidParam)
+			} else {
+				testutil.RequireNoError(t, err)
+			}
+		})
+	}
+}
+
+func openBounded(t *testing.T, opts ...Option) *fsCache {
+	t.Helper()
+	opts = append([]Option{WithBaseDir(t.TempDir()), WithEvictInterval(time.Hour)}, opts...)
+	cache, err := Open("testapp", opts...)
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+// openManualEvict returns a cache with a quota that is only enforced by
+// explicit calls to evict, so tests are not racing the eviction loop.
+func openManualEvict(t *testing.T, maxSize int64, maxFiles int) *fsCache {
+	t.Helper()
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()))
+	testutil.RequireNoError(t, err)
+	cache.ev = newEvictor(maxSize, maxFiles)
+	t.Cleanup(func() {
+		cache.ev = nil
+		cache.Close()
+	})
+	return cache
+}
+
+func sortedKeys(t *testing.T, c *fsCache) []string {
+	t.Helper()
+	keys, err := c.Keys("")
+	testutil.RequireNoError(t, err)
+	slices.Sort(keys)
+	return keys
+}
+
+func Test_fsCache_evict_MaxFiles(t *testing.T) {
+	cache := openManualEvict(t, 0, 2)
+	testutil.RequireNoError(t, cache.Set("a", []byte("1")))
+	testutil.RequireNoError(t, cache.Set("b", []byte("2")))
+	_, err := cache.Get("a")
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, cache.Set("c", []byte("3")))
+
+	testutil.RequireNoError(t, cache.evict())
+	got := sortedKeys(t, cache)
+	testutil.AssertTrue(t, slices.Equal(got, []string{"a", "c"}), "got %v", got)
+}
+
+func Test_fsCache_evict_MaxSize(t *testing.T) {
+	cache := openManualEvict(t, 10, 0)
+	for _, k := range []string{"a", "b", "c"} {
+		testutil.RequireNoError(t, cache.Set(k, []byte("1234")))
+	}
+	testutil.RequireNoError(t, cache.evict())
+	got := sortedKeys(t, cache)
+	testutil.AssertTrue(t, slices.Equal(got, []string{"b", "c"}), "got %v", got)
+}
+
+func Test_fsCache_evict_LowWaterMark(t *testing.T) {
+	cache := openManualEvict(t, 0, 10)
+	for i := range 11 {
+		testutil.RequireNoError(t, cache.Set(strings.Repeat("k", i+1), []byte("v")))
+	}
+	testutil.RequireNoError(t, cache.evict())
+	testutil.AssertEqual(t, 9, len(sortedKeys(t, cache)))
+
+	// Within the quota, but over the low-water mark, nothing is removed.
+	testutil.RequireNoError(t, cache.Set("a", []byte("v")))
+	testutil.RequireNoError(t, cache.evict())
+	testutil.AssertEqual(t, 10, len(sortedKeys(t, cache)))
+}
+
+func Test_fsCache_evict_SkipsChangedFiles(t *testing.T) {
+	cache := openManualEvict(t, 0, 1)
+	testutil.RequireNoError(t, cache.Set("a", []byte("1")))
+	files, err := cache.scan()
+	testutil.RequireNoError(t, err)
+	testutil.RequireTrue(t, len(files) == 1)
+
+	// "a" is rewritten after the scan; its new value must not be evicted on
+	// the strength of the old one's access time.
+	testutil.RequireNoError(t, cache.Set("a", []byte("12")))
+	err = cache.removeFile(files[0].name, files[0].info)
+	testutil.RequireErrorIs(t, err, errEntryChanged)
+	got := sortedKeys(t, cache)
+	testutil.AssertTrue(t, slices.Equal(got, []string{"a"}), "got %v", got)
+}
+
+func Test_fsCache_evict_SkipsInFlightReads(t *testing.T) {
+	cache := openManualEvict(t, 0, 1)
+	testutil.RequireNoError(t, cache.Set("a", []byte("1")))
+	name := cache.fn.FileName("a")
+	cache.ev.beginRead(name)
+	// "a" is least recently used, but is being read.
+	testutil.RequireNoError(t, cache.Set("b", []byte("2")))
+	testutil.RequireNoError(t, cache.evict())
+	cache.ev.endRead(name, true)
+	got := sortedKeys(t, cache)
+	testutil.AssertTrue(t, slices.Equal(got, []string{"a"}), "got %v", got)
+}
+
+func Test_fsCache_evict_Background(t *testing.T) {
+	cache := openBounded(t, WithMaxFiles(3))
+	for i := range 10 {
+		testutil.RequireNoError(t, cache.Set(strings.Repeat("k", i+1), []byte("v")))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sortedKeys(t, cache)) > 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("cache not trimmed to quota, have %d keys", len(sortedKeys(t, cache)))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_fsCache_evict_ExistingDirectory(t *testing.T) {
+	base := t.TempDir()
+	cache, err := Open("testapp", WithBaseDir(base))
+	testutil.RequireNoError(t, err)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		testutil.RequireNoError(t, cache.Set(k, []byte("v")))
+	}
+	testutil.RequireNoError(t, cache.Close())
+
+	cache = openBounded(t, WithBaseDir(base), WithMaxFiles(2))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sortedKeys(t, cache)) > 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("existing directory not trimmed on open")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_fsCache_RemovesEmptyFragmentDirs(t *testing.T) {
+	tests := []struct {
+		name   string
+		keyLen int
+	}{
+		{"shallow", 1024},
+		{"deep", 20 * 1024}, // deeper than removeEmptyDirsStep
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := openManualEvict(t, 0, 1)
+			long := strings.Repeat("x", tt.keyLen)
+			testutil.RequireNoError(t, cache.Set(long+"1", []byte("v")))
+			testutil.RequireNoError(t, cache.Set(long+"2", []byte("v")))
+			testutil.RequireTrue(t, strings.ContainsRune(cache.fn.FileName(long), os.PathSeparator))
+
+			testutil.RequireNoError(t, cache.evict())
+			testutil.RequireNoError(t, cache.Delete(long+"2"))
+			entries, err := os.ReadDir(cache.root.Name())
+			testutil.RequireNoError(t, err)
+			entries = slices.DeleteFunc(entries, func(e os.DirEntry) bool { return isMetaFile(e.Name()) })
+			testutil.AssertEqual(t, 0, len(entries), "expected empty cache directory")
+		})
+	}
+}
@@ -31,8 +31,18 @@
 //   - timeout (optional): Operation timeout duration (default: 5m)
 //   - connect_timeout (optional): Cache initialization timeout (default: 5m)
 //   - encrypt (optional): Enable AES-GCM encryption ("on" or "aesgcm")
-//   - encrypt_key (optional): Base64-encoded AES key (URL-safe, RFC 4648 §5)
+//   - encrypt_key (optional): Comma-separated base64-encoded AES keys (URL-safe, RFC 4648 §5), active key first
+//   - encrypt_key_file (optional): Path to a file with one key per line, active key first
+//   - encrypt_passphrase (optional): Passphrase to derive the AES key from, for development use
+//   - reencrypt (optional): Re-encrypt entries written with older keys in the background ("on" to enable)
+//   - hashed_names (optional): Name files after a keyed hash of the key ("on" or "off"; default: on if encrypted)
+//   - layout (optional): Place files in hash-prefixed shard directories ("sharded" or "flat"; default: "flat")
+//   - index (optional): Maintain a persistent key index for Keys and Stats ("on" to enable)
 //   - update_mtime (optional): Update file mtime on cache hits ("on" to enable)
+//   - max_size (optional): Maximum total size of cache files, e.g. "512MiB" (default: unbounded)
+//   - max_files (optional): Maximum number of cache files (default: unbounded)
+//   - evict_interval (optional): How often the quota is enforced (default: 1m)
+//   - durability (optional): What is flushed to disk on writes, "none", "file" or "full" (default: "file")
 //
 // # Usage Examples
 //
@@ -58,6 +68,11 @@
 //		fscache://?appname=myapp&update_mtime=on
 //		fscache.Open("myapp", fscache.WithUpdateMTime(true))
 //
+//	 Bounded cache:
+//
+//		fscache://?appname=myapp&max_size=512MiB&max_files=100000
+//		fscache.Open("myapp", fscache.WithMaxSize(512<<20), fscache.WithMaxFiles(100000))
+//
 // # Encryption Key Management
 //
 // Encryption keys can be provided via DSN parameter or environment variable:
@@ -68,16 +83,117 @@
 //
 //	openssl rand 32 | base64 | tr '+/' '-_' | tr -d '\n'
 //
-// # LRU Cleanup Integration
+// Each key may be prefixed with an ID, as in "2026-01:6S-Ks2Y...". Every
+// entry records the ID of the key that encrypted it; keys without an explicit
+// ID are identified by a hash of the key.
+//
+// To rotate keys, prepend the new key to the list. New entries are encrypted
+// with the first key, and the remaining keys are only used for decryption.
+// Once every entry has been rewritten with the new key, by reencrypt=on or
+// by calling Reencrypt, the old keys can be dropped:
+//
+//	fscache://?appname=myapp&encrypt=on&encrypt_key=2026-07:NEW,2026-01:OLD&reencrypt=on
+//
+// Keys can also be read from a file (encrypt_key_file), or supplied by a
+// [KeyProvider], such as a secrets manager client, with [WithKeyProvider].
+// The keys are loaded when the cache is opened.
+//
+// On development machines, encrypt_passphrase or FSCACHE_ENCRYPT_PASSPHRASE
+// derives the key from a passphrase with PBKDF2, salted with the appname.
+//
+// # File Names
+//
+// By default a file is named after the base64 encoding of its key, so the
+// cached URLs can be read from the directory listing. With hashed_names=on,
+// which is the default when encryption is enabled, files are instead named
+// after an HMAC-SHA-256 of the key, and the key is stored in the file, where
+// it is encrypted along with the value. The HMAC key is generated when the
+// directory is first opened, and kept in the ".namekey" file, encrypted with
+// the active key. Open fails with [ErrNameKeyMismatch] if no configured key
+// can decrypt it, as when a key is replaced instead of rotated. If the old key
+// is lost, clear the directory to start over.
+//
+// Existing entries are renamed in the background when hashed names are first
+// enabled; until then they are reported as missing. Turning hashed names off
+// again does not rename entries back, so clear the directory instead.
+//
+// Files are kept in the cache directory itself, which slows down lookups and
+// directory walks on most file systems once it holds many entries. With
+// layout=sharded they are spread into two levels of shard directories, such
+// as "3f/a0/", named after a hash prefix of the file name. Existing entries
+// are moved in the background when the layout changes, or by calling
+// MigrateLayout; until then, lookups fall back to their old location. The
+// layout is recorded in the ".layout" file.
+//
+// # Key Index
+//
+// Keys walks the cache directory, and in hashed mode reads every entry, which
+// becomes slow on large caches. With index=on the cache maintains an index of
+// its keys, file names, sizes and access times in the ".index" file, so that
+// Keys and Stats are answered from memory. Changes are appended to the file,
+// which is compacted once most of its records are superseded, and processes
+// sharing the directory replay each other's changes. The index is rebuilt
+// from a walk of the directory when it is missing or corrupt, including when
+// it is first enabled. Its records are encrypted when encryption is enabled.
+//
+// # Size Limits
+//
+// When max_size or max_files is set, the cache enforces the quota itself: a
+// background loop removes the least recently used files whenever a write
+// takes the cache over quota, and at every evict_interval. Once over quota,
+// it removes files until the cache is within 90% of it. Empty fragment
+// directories left behind by long keys are removed along with the files.
+// Call Close to stop the loop.
+//
+// Access times are tracked in process and fall back to file modification
+// times. Enable update_mtime so that the access order survives restarts and
+// is shared between processes using the same directory.
 //
-// When update_mtime is enabled, cache hits update file modification times,
-// enabling efficient LRU cleanup using standard Unix tools:
+// Files being read by this process are never evicted. On POSIX systems a
+// reader in another process keeps its open file descriptor, so removing the
+// file does not disturb it; on Windows, files open in another process cannot
+// be removed and are skipped until the next pass.
 //
-//	# Remove files older than 7 days
-//	find /cache/dir -type f -mtime +7 -delete
+// # Durability
 //
-//	# Keep only the 1000 most recently used files
-//	find /cache/dir -type f -printf '%T@ %p\n' | sort -rn | tail -n +1001 | cut -d' ' -f2- | xargs rm
+// Writes go to a temporary file in the destination directory, which is then
+// renamed over the entry, so readers and crashes never observe a partially
+// written entry. The durability parameter controls what is flushed to disk
+// before a write returns:
+//
+//   - none: nothing; a crash may leave an empty entry behind
+//   - file: the file contents (the default); a crash may lose the write
+//   - full: the file contents and its directory; the write survives a crash
+//
+// Temporary files left behind by a crash are named with a ".tmp-" prefix and
+// are ignored by Keys and by quota enforcement.
+//
+// # Streaming
+//
+// The cache implements [driver.Streamer]. OpenReader reads an entry from disk
+// as it is consumed, so that httpcache serves large cached bodies without
+// loading them into memory, and the entry is not evicted until the reader is
+// closed. OpenWriter writes to the temporary file as the value is written,
+// and renames it over the entry on Close. When encryption is enabled, values
+// are encrypted as a whole, so both buffer the value in memory.
+//
+// # Concurrency
+//
+// Several processes may share a cache directory. Writes and deletes of an
+// entry are serialized through lock files (".lock-*") in the directory, and
+// Lock (see [driver.Locker]) holds an advisory lock on a key, so that a
+// read-modify-write such as updating the list of variants of a URL is not
+// lost to a concurrent writer. Locks use flock(2) on Unix; on other
+// platforms they only coordinate goroutines within one process.
+//
+// # Integrity Check
+//
+// Check walks the cache directory and reports files that do not decode to an
+// entry, fail decryption, hold truncated or undecodable responses, or are
+// stale temporary files, along with empty fragment directories and refs
+// documents pointing at missing responses. With repair set it removes them
+// and rewrites the refs documents. It implements [expapi.Checker], so it is
+// also reachable as POST /debug/httpcache/check?repair=true.
 package fscache
 
 import (
@@ -92,12 +208,17 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bartventer/httpcache/store"
 	"github.com/bartventer/httpcache/store/driver"
 	"github.com/bartventer/httpcache/store/expapi"
+	"github.com/bartventer/httpcache/store/internal/bytesize"
+	"github.com/bartventer/httpcache/store/internal/keyring"
 )
 
 const Scheme = "fscache" // url scheme for the file system cache
@@ -113,6 +234,7 @@ var (
 	ErrUserCacheDir   = errors.New("fscache: could not determine user cache dir")
 	ErrMissingAppName = errors.New("fscache: appname query parameter is required")
 	ErrCreateCacheDir = errors.New("fscache: could not create cache dir")
+	ErrInvalidParam   = errors.New("fscache: invalid DSN parameter")
 )
 
 type Error struct {
@@ -135,18 +257,39 @@ func (e *Error) Unwrap() error {
 type fsCache struct {
 	// configurable options
 
-	root        *os.Root
-	base        string        // base directory for the cache (parent of root)
-	connTimeout time.Duration // optional timeout for establishing the connection
-	timeout     time.Duration // optional timeout for operations
-	enc         encryptor     // optional encryptor for data
-	updateMTime bool          // whether to update file mtime on cache hits
+	root         *os.Root
+	base         string        // base directory for the cache (parent of root)
+	connTimeout  time.Duration // optional timeout for establishing the connection
+	timeout      time.Duration // optional timeout for operations
+	enc          encryptor     // optional encryptor for data
+	updateMTime  bool          // whether to update file mtime on cache hits
+	maxSize      int64         // optional maximum total file size
+	maxFiles     int           // optional maximum number of files
+	evictEvery   time.Duration // how often the quota is enforced
+	durability   Durability    // what is flushed to disk on writes
+	keyProvider  KeyProvider   // optional key ring source, loaded on open
+	reencrypt    bool          // whether to re-encrypt old entries on open
+	hashNames    bool          // whether file names are keyed hashes of the keys
+	hashNamesSet bool          // whether hashNames was set explicitly
+	shard        bool          // whether files are placed in shard directories
+	index        bool          // whether to maintain the key index
 
 	// internal dependencies
 
-	fn  fileNamer     // generates file names from keys
-	fnk fileNameKeyer // recovers keys from file names
-	dw  dirWalker     // used for directory walking
+	fn       fileNamer     // generates file names from keys
+	fnk      fileNameKeyer // recovers keys from file names
+	fallback fileNamer     // names files in the layout being migrated from; nil if none
+	migrated atomic.Bool   // whether the layout migration has completed
+	dw       dirWalker     // used for directory walking
+	ev       *evictor      // enforces the quota; nil if unbounded
+
+	idx *index // lists and counts keys without walking; nil if disabled
+
+	wlocks *lockSet // serializes replacing and removing entry files
+	klocks *lockSet // backs Lock
+
+	stopBG context.CancelFunc // stops background tasks; nil if none
+	bg     sync.WaitGroup     // tracks background tasks
 }
 
 const defaultTimeout = 5 * time.Minute
@@ -191,16 +334,71 @@ func WithTimeout(timeout time.Duration) Option {
 }
 
 // WithEncryption enables encryption for cache entries using AES-GCM.
+//
+// The key may be a comma-separated list of keys in the format accepted by
+// [ParseKey]. The first key encrypts new entries; the others only decrypt
+// entries written before the key was rotated.
 func WithEncryption(key string) Option {
 	return optionFunc(func(c *fsCache) (err error) {
-		if key == "" {
+		keys, err := keyring.ParseList(key)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
 			return errEncryptionEnabledWithoutKey
 		}
-		c.enc, err = newAESGCMEncryptor(rand.Reader, key)
+		c.enc, err = newKeyRing(rand.Reader, keys)
 		return err
 	})
 }
 
+// WithKeyProvider enables encryption for cache entries using AES-GCM, with
+// the key ring supplied by p when the cache is opened.
+func WithKeyProvider(p KeyProvider) Option {
+	return optionFunc(func(c *fsCache) error {
+		c.keyProvider = p
+		return nil
+	})
+}
+
+// WithHashedNames sets whether files are named after a keyed hash of their key
+// rather than its encoding; default: enabled if encryption is enabled.
+func WithHashedNames(enabled bool) Option {
+	return optionFunc(func(c *fsCache) error {
+		c.hashNames, c.hashNamesSet = enabled, true
+		return nil
+	})
+}
+
+// WithShardedLayout sets whether files are spread into two levels of shard
+// directories named after a hash prefix, rather than kept in the cache
+// directory itself; default: disabled. Existing entries are moved in the
+// background; see MigrateLayout.
+func WithShardedLayout(enabled bool) Option {
+	return optionFunc(func(c *fsCache) error {
+		c.shard = enabled
+		return nil
+	})
+}
+
+// WithIndex enables a persistent index of the keys in the cache, so that
+// Keys and Stats do not walk the cache directory; default: disabled.
+func WithIndex(enabled bool) Option {
+	return optionFunc(func(c *fsCache) error {
+		c.index = enabled
+		return nil
+	})
+}
+
+// WithReencrypt starts a background pass on open that rewrites entries not
+// encrypted with the active key; default: disabled. See Reencrypt.
+func WithReencrypt(enabled bool) Option {
+	return optionFunc(func(c *fsCache) error {
+		c.reencrypt = enabled
+		return nil
+	})
+}
+
 // WithBaseDir sets the base directory for the cache; default: user's OS cache directory.
 func WithBaseDir(base string) Option {
 	return optionFunc(func(c *fsCache) error {
@@ -219,12 +417,88 @@ func WithUpdateMTime(enabled bool) Option {
 	})
 }
 
+// WithMaxSize bounds the total size of cache files; default: unbounded.
+func WithMaxSize(n int64) Option {
+	return optionFunc(func(c *fsCache) error {
+		c.maxSize = max(n, 0)
+		return nil
+	})
+}
+
+// WithMaxFiles bounds the number of cache files; default: unbounded.
+func WithMaxFiles(n int) Option {
+	return optionFunc(func(c *fsCache) error {
+		c.maxFiles = max(n, 0)
+		return nil
+	})
+}
+
+// WithEvictInterval sets how often the quota set by [WithMaxSize] and
+// [WithMaxFiles] is enforced, in addition to after writes that exceed it;
+// default: 1 minute.
+func WithEvictInterval(d time.Duration) Option {
+	return optionFunc(func(c *fsCache) error {
+		c.evictEvery = max(d, 0)
+		return nil
+	})
+}
+
+// WithDurability sets what is flushed to disk before a write returns;
+// default: [DurabilityFile].
+func WithDurability(d Durability) Option {
+	return optionFunc(func(c *fsCache) error {
+		c.durability = d
+		return nil
+	})
+}
+
+func quotaOptions(q url.Values) ([]Option, error) {
+	var opts []Option
+	if v := q.Get("max_size"); v != "" {
+		n, err := bytesize.Parse(v)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidParam, err)
+		}
+		opts = append(opts, WithMaxSize(n))
+	}
+	if v := q.Get("max_files"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: max_files %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithMaxFiles(n))
+	}
+	if v := q.Get("evict_interval"); v != "" {
+		opts = append(opts, WithEvictInterval(parseTimeout(v)))
+	}
+	return opts, nil
+}
+
+// encryptionOption selects the key source from the DSN parameters and
+// environment, in order: key file, keys, passphrase.
+func encryptionOption(q url.Values, appname string) Option {
+	if path := q.Get("encrypt_key_file"); path != "" {
+		return WithKeyProvider(FileKeys(path))
+	}
+	if key := cmp.Or(q.Get("encrypt_key"), os.Getenv("FSCACHE_ENCRYPT_KEY")); key != "" {
+		return WithEncryption(key)
+	}
+	passphrase := cmp.Or(q.Get("encrypt_passphrase"), os.Getenv("FSCACHE_ENCRYPT_PASSPHRASE"))
+	if passphrase != "" {
+		return WithKeyProvider(KeyProviderFunc(func(context.Context) ([]Key, error) {
+			k, err := PassphraseKey(passphrase, []byte("fscache/"+appname))
+			return []Key{k}, err
+		}))
+	}
+	return WithEncryption("")
+}
+
 func fromURL(u *url.URL) (*fsCache, error) {
 	appname := u.Query().Get("appname")
 	if appname == "" {
 		return nil, ErrMissingAppName
 	}
-	opts := make([]Option, 0, 5)
+	opts := make([]Option, 0, 8)
 	if u.Path != "" && u.Path != "/" {
 		opts = append(opts, WithBaseDir(u.Path))
 	}
@@ -235,12 +509,42 @@ func fromURL(u *url.URL) (*fsCache, error) {
 		opts = append(opts, WithTimeout(parseTimeout(v)))
 	}
 	if encrypt := u.Query().Get("encrypt"); encrypt == "on" || encrypt == "aesgcm" {
-		key := cmp.Or(u.Query().Get("encrypt_key"), os.Getenv("FSCACHE_ENCRYPT_KEY"))
-		opts = append(opts, WithEncryption(key))
+		opts = append(opts, encryptionOption(u.Query(), appname))
+	}
+	if reencrypt := u.Query().Get("reencrypt"); reencrypt == "on" {
+		opts = append(opts, WithReencrypt(true))
+	}
+	switch u.Query().Get("hashed_names") {
+	case "on":
+		opts = append(opts, WithHashedNames(true))
+	case "off":
+		opts = append(opts, WithHashedNames(false))
+	}
+	switch v := u.Query().Get("layout"); v {
+	case "", "flat":
+	case "sharded":
+		opts = append(opts, WithShardedLayout(true))
+	default:
+		return nil, fmt.Errorf("%w: layout %q", ErrInvalidParam, v)
+	}
+	if index := u.Query().Get("index"); index == "on" {
+		opts = append(opts, WithIndex(true))
 	}
 	if updateMTime := u.Query().Get("update_mtime"); updateMTime == "on" {
 		opts = append(opts, WithUpdateMTime(true))
 	}
+	quotaOpts, err := quotaOptions(u.Query())
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, quotaOpts...)
+	if v := u.Query().Get("durability"); v != "" {
+		d, err := parseDurability(v)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithDurability(d))
+	}
 	if cap(opts) > len(opts) {
 		opts = slices.Clip(opts)
 	}
@@ -262,7 +566,7 @@ func Open(appname string, opts ...Option) (*fsCache, error) {
 	errc := make(chan error, 1)
 	go func() {
 		defer close(errc)
-		if err := c.initialize(appname); err != nil {
+		if err := c.initialize(ctx, appname); err != nil {
 			errc <- err
 			return
 		}
@@ -279,7 +583,7 @@ func Open(appname string, opts ...Option) (*fsCache, error) {
 	}
 }
 
-func (c *fsCache) initialize(appname string) error {
+func (c *fsCache) initialize(ctx context.Context, appname string) error {
 	if c.base == "" {
 		userCacheDir, err := os.UserCacheDir()
 		if err != nil {
@@ -290,6 +594,16 @@ func (c *fsCache) initialize(appname string) error {
 	if appname == "" {
 		return ErrMissingAppName
 	}
+	if c.keyProvider != nil {
+		keys, err := c.keyProvider.Keys(ctx)
+		if err != nil {
+			return fmt.Errorf("fscache: could not load encryption keys: %w", err)
+		}
+		c.enc, err = newKeyRing(rand.Reader, keys)
+		if err != nil {
+			return err
+		}
+	}
 	c.base = filepath.Join(c.base, appname)
 	if err := os.MkdirAll(c.base, 0o755); err != nil {
 		return errors.Join(ErrCreateCacheDir, err)
@@ -299,14 +613,107 @@ func (c *fsCache) initialize(appname string) error {
 	if err != nil {
 		return fmt.Errorf("fscache: could not open cache directory %q: %w", c.base, err)
 	}
-	c.fn = fragmentingFileNamer()
-	c.fnk = fragmentingFileNameKeyer()
+	c.wlocks = newLockSet(".lock-write-")
+	c.klocks = newLockSet(".lock-key-")
+	if err := c.initLayout(); err != nil {
+		_ = c.wlocks.close()
+		_ = c.klocks.close()
+		_ = c.root.Close()
+		return err
+	}
 	c.dw = dirWalkerFunc(filepath.WalkDir)
 	c.timeout = cmp.Or(c.timeout, defaultTimeout)
+	if c.index {
+		// Load the index now, so that a missing one is rebuilt on open.
+		c.idx = newIndex()
+		if err := c.idx.update(c, func() error { return nil }); err != nil {
+			_ = c.idx.lock.close()
+			_ = c.wlocks.close()
+			_ = c.klocks.close()
+			_ = c.root.Close()
+			return fmt.Errorf("fscache: could not load index: %w", err)
+		}
+	}
+	if c.maxSize > 0 || c.maxFiles > 0 {
+		c.ev = newEvictor(c.maxSize, c.maxFiles)
+		c.ev.kick <- struct{}{} // trim a directory that is already over quota
+		go c.ev.run(cmp.Or(c.evictEvery, defaultEvictInterval), func() { _ = c.evict() })
+	}
+	if !c.migrated.Load() || c.reencrypt {
+		var bgCtx context.Context
+		bgCtx, c.stopBG = context.WithCancel(context.Background())
+		c.bg.Go(func() { c.background(bgCtx) })
+	}
 
 	return nil
 }
 
+// initLayout sets up file naming, and determines whether entries need to be
+// migrated from the layout recorded in the cache directory.
+func (c *fsCache) initLayout() error {
+	if !c.hashNamesSet {
+		c.hashNames = c.enc != nil
+	}
+	c.fn = fragmentingFileNamer()
+	c.fnk = unshardingFileNameKeyer(fragmentingFileNameKeyer())
+	if c.hashNames {
+		nameKey, err := c.loadNameKey()
+		if err != nil {
+			return err
+		}
+		c.fn = hmacFileNamer(nameKey)
+	}
+	flat := c.fn
+	if c.shard {
+		c.fn = shardedFileNamer(flat)
+	}
+	have, err := c.readLayout()
+	if err != nil {
+		return err
+	}
+	want := layout{hashed: c.hashNames, sharded: c.shard}
+	// Hashed names are not converted back to plain ones.
+	c.migrated.Store(have == want || (have.hashed && !want.hashed))
+	if !c.migrated.Load() && have.hashed == want.hashed {
+		c.fallback = flat
+		if !c.shard {
+			c.fallback = shardedFileNamer(flat)
+		}
+	}
+	return nil
+}
+
+// background runs the maintenance passes requested on open, one at a time so
+// that they do not rewrite the same files concurrently.
+func (c *fsCache) background(ctx context.Context) {
+	if !c.migrated.Load() {
+		if _, err := c.MigrateLayout(ctx); err != nil {
+			return
+		}
+	}
+	if c.reencrypt {
+		_, _ = c.Reencrypt(ctx)
+	}
+}
+
+// Close stops the eviction loop and background re-encryption, if any, and
+// releases the cache directory. The cache must not be used after Close
+// returns.
+func (c *fsCache) Close() error {
+	if c.stopBG != nil {
+		c.stopBG()
+		c.bg.Wait()
+	}
+	if c.ev != nil {
+		c.ev.close()
+	}
+	var err error
+	if c.idx != nil {
+		err = c.idx.close(c)
+	}
+	return errors.Join(err, c.wlocks.close(), c.klocks.close(), c.root.Close())
+}
+
 type dirWalker interface {
 	WalkDir(root string, fn fs.WalkDirFunc) error
 }
@@ -316,10 +723,22 @@ type dirWalkerFunc func(root string, fn fs.WalkDirFunc) error
 func (f dirWalkerFunc) WalkDir(root string, fn fs.WalkDirFunc) error { return f(root, fn) }
 
 var _ driver.Conn = (*fsCache)(nil)
+var _ driver.ConnContext = (*fsCache)(nil)
 var _ expapi.KeyLister = (*fsCache)(nil)
+var _ driver.Locker = (*fsCache)(nil)
+var _ driver.Streamer = (*fsCache)(nil)
 
 func (c *fsCache) Get(key string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	return c.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get, but returns once ctx is done or the operation
+// timeout elapses, whichever comes first.
+func (c *fsCache) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
 	type result struct {
@@ -348,13 +767,14 @@ func (c *fsCache) Get(key string) ([]byte, error) {
 // when updating modification time.
 var zeroTime time.Time
 
-func (c *fsCache) get(key string) ([]byte, error) {
+func (c *fsCache) get(key string) (_ []byte, err error) {
 	name := c.fn.FileName(key)
-	f, err := c.root.Open(name)
+	if c.ev != nil {
+		c.ev.beginRead(name)
+		defer func() { c.ev.endRead(name, err == nil) }()
+	}
+	f, path, err := c.openEntry(key, name)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil, errors.Join(driver.ErrNotExist, err)
-		}
 		return nil, err
 	}
 	defer f.Close()
@@ -368,23 +788,69 @@ func (c *fsCache) get(key string) ([]byte, error) {
 			return nil, err
 		}
 	}
-	if c.updateMTime {
-		mtime := time.Now()
-		if err := c.root.Chtimes(name, zeroTime, mtime); err != nil {
+	if c.hashNames {
+		var k string
+		k, data, err = decodePayload(data)
+		if err != nil {
 			return nil, err
 		}
+		if k != key {
+			return nil, errors.Join(driver.ErrNotExist, errPayloadKeyMismatch)
+		}
+	}
+	if err := c.touch(key, path); err != nil {
+		return nil, err
 	}
 	return data, nil
 }
 
+// openEntry opens the entry file for key, which is named name, falling back to
+// its name under the previous naming scheme. It returns the path opened.
+func (c *fsCache) openEntry(key, name string) (*os.File, string, error) {
+	path := name
+	f, err := c.root.Open(path)
+	if old, ok := c.fallbackName(key); ok && errors.Is(err, os.ErrNotExist) {
+		path = old
+		f, err = c.root.Open(path)
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", errors.Join(driver.ErrNotExist, err)
+		}
+		return nil, "", err
+	}
+	return f, path, nil
+}
+
+// touch records an access to the entry for key, stored at path.
+func (c *fsCache) touch(key, path string) error {
+	if c.idx != nil {
+		c.idx.touch(key, time.Now())
+	}
+	if c.updateMTime {
+		return c.root.Chtimes(path, zeroTime, time.Now())
+	}
+	return nil
+}
+
 func (c *fsCache) Set(key string, entry []byte) error {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	return c.SetContext(context.Background(), key, entry)
+}
+
+// SetContext is like Set, but returns once ctx is done or the operation
+// timeout elapses, whichever comes first. The entry is not written if ctx
+// is done before the write starts.
+func (c *fsCache) SetContext(ctx context.Context, key string, entry []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
 	errc := make(chan error, 1)
 	go func() {
 		defer close(errc)
-		err := c.set(key, entry)
+		err := c.set(ctx, key, entry)
 		if err != nil {
 			errc <- &Error{"Set", key, err}
 			return
@@ -400,7 +866,10 @@ func (c *fsCache) Set(key string, entry []byte) error {
 	}
 }
 
-func (c *fsCache) set(key string, entry []byte) error {
+func (c *fsCache) set(ctx context.Context, key string, entry []byte) error {
+	if c.hashNames {
+		entry = encodePayload(key, entry)
+	}
 	if c.enc != nil {
 		var err error
 		entry, err = c.enc.Encrypt(entry)
@@ -409,23 +878,39 @@ func (c *fsCache) set(key string, entry []byte) error {
 		}
 	}
 	name := c.fn.FileName(key)
-	if err := c.root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+	unlock, err := c.wlocks.lock(c, name)
+	if err != nil {
 		return err
 	}
-	f, err := c.root.Create(name)
-	if err != nil {
+	defer unlock()
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = f.Write(entry)
-	if err != nil {
+	if err := c.writeFile(name, entry); err != nil {
 		return err
 	}
-	return f.Sync()
+	if c.idx != nil {
+		if err := c.idx.put(c, key, name, int64(len(entry)), time.Now()); err != nil {
+			return err
+		}
+	}
+	if c.ev != nil {
+		c.ev.written(name, int64(len(entry)))
+	}
+	return nil
 }
 
 func (c *fsCache) Delete(key string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	return c.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is like Delete, but returns once ctx is done or the operation
+// timeout elapses, whichever comes first.
+func (c *fsCache) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
 	errc := make(chan error, 1)
@@ -448,16 +933,71 @@ func (c *fsCache) Delete(key string) error {
 }
 
 func (c *fsCache) delete(key string) error {
-	err := c.root.Remove(c.fn.FileName(key))
+	name := c.fn.FileName(key)
+	unlock, err := c.wlocks.lock(c, name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	removedOld, err := c.removeFallback(key)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			err = errors.Join(driver.ErrNotExist, err)
-		}
 		return err
 	}
+	err = c.root.Remove(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if c.idx != nil {
+		if err := c.idx.remove(c, key); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		if removedOld {
+			return nil
+		}
+		return errors.Join(driver.ErrNotExist, err)
+	}
+	if c.ev != nil {
+		c.ev.removed(name)
+	}
+	c.removeEmptyDirs(filepath.Dir(name))
 	return nil
 }
 
+// Lock acquires the advisory lock for key; see [driver.Locker]. Processes
+// sharing the cache directory contend for the same lock on platforms that
+// support flock; elsewhere only goroutines within this process do.
+func (c *fsCache) Lock(key string) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	type result struct {
+		unlock func()
+		err    error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		unlock, err := c.klocks.lock(c, c.fn.FileName(key))
+		if err != nil {
+			err = &Error{"Lock", key, err}
+		}
+		resc <- result{unlock, err}
+	}()
+	select {
+	case <-ctx.Done():
+		// Release the lock if it is acquired after giving up on it.
+		go func() {
+			if res := <-resc; res.unlock != nil {
+				res.unlock()
+			}
+		}()
+		return nil, ctx.Err()
+	case res := <-resc:
+		return res.unlock, res.err
+	}
+}
+
 func (c *fsCache) Keys(prefix string) ([]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
@@ -485,24 +1025,23 @@ func (c *fsCache) Keys(prefix string) ([]string, error) {
 }
 
 func (c *fsCache) keys(prefix string) ([]string, error) {
-	dirname := c.root.Name()
+	if c.idx != nil {
+		return c.idx.list(c, prefix)
+	}
 	keys := make([]string, 0, 10)
-	err := c.dw.WalkDir(dirname, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
+	// While a migration is in progress an entry may exist in both layouts.
+	var seen map[string]bool
+	if _, ok := c.fallbackName(""); ok {
+		seen = make(map[string]bool)
+	}
+	err := c.walkEntries(func(_, key string, _ fs.DirEntry) error {
+		if !strings.HasPrefix(key, prefix) || seen[key] {
 			return nil
 		}
-		key, err := c.fnk.KeyFromFileName(
-			strings.TrimPrefix(path, dirname+string(os.PathSeparator)),
-		)
-		if err != nil {
-			return err
-		}
-		if strings.HasPrefix(key, prefix) {
-			keys = append(keys, key)
+		if seen != nil {
+			seen[key] = true
 		}
+		keys = append(keys, key)
 		return nil
 	})
 	if err != nil {
@@ -513,3 +1052,39 @@ func (c *fsCache) keys(prefix string) ([]string, error) {
 	}
 	return keys, nil
 }
+
+// walkEntries calls fn for every entry file in the cache directory, with its
+// name relative to the directory and its key.
+func (c *fsCache) walkEntries(fn func(name, key string, d fs.DirEntry) error) error {
+	dirname := c.root.Name()
+	return c.dw.WalkDir(dirname, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Another process may remove files and fragment directories
+			// while the walk is in progress.
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || isMetaFile(path) {
+			return nil
+		}
+		name := strings.TrimPrefix(path, dirname+string(os.PathSeparator))
+		if c.hashNames {
+			key, err := c.readKey(name)
+			if err != nil {
+				// Skip entries removed concurrently, not migrated yet, or corrupt.
+				if errors.Is(err, os.ErrNotExist) || errors.Is(err, errUnreadableEntry) {
+					return nil
+				}
+				return err
+			}
+			return fn(name, key, d)
+		}
+		key, err := c.fnk.KeyFromFileName(name)
+		if err != nil {
+			return err
+		}
+		return fn(name, key, d)
+	})
+}
@@ -17,7 +17,6 @@ package fscache
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"io"
 	"io/fs"
 	"net/url"
@@ -31,13 +30,6 @@ import (
 	"github.com/bartventer/httpcache/store/driver"
 )
 
-func (c *fsCache) Close() error {
-	if err := c.root.Close(); err != nil {
-		fmt.Printf("Warning: failed to close cache: %v", err)
-	}
-	return nil
-}
-
 func makeRootURL(t testing.TB) *url.URL {
 	t.Helper()
 	tempDir := filepath.ToSlash(t.TempDir())
@@ -71,6 +63,40 @@ func Test_fsCache_SetError(t *testing.T) {
 	testutil.RequireError(t, err)
 }
 
+func TestFSCache_Context(t *testing.T) {
+	cache, err := fromURL(makeRootURL(t))
+	testutil.RequireNoError(t, err, "Failed to create fscache")
+	t.Cleanup(func() { cache.Close() })
+
+	ctx, cancel := context.WithCancel(t.Context())
+	testutil.RequireNoError(t, cache.SetContext(ctx, "a", []byte("1")))
+	got, err := cache.GetContext(ctx, "a")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "1", string(got))
+
+	cancel()
+	_, err = cache.GetContext(ctx, "a")
+	testutil.RequireErrorIs(t, err, context.Canceled)
+	testutil.RequireErrorIs(t, cache.SetContext(ctx, "b", []byte("2")), context.Canceled)
+	testutil.RequireErrorIs(t, cache.DeleteContext(ctx, "a"), context.Canceled)
+
+	// Cancelled operations leave the cache unchanged.
+	testutil.RequireNoError(t, cache.DeleteContext(t.Context(), "a"))
+	_, err = cache.Get("b")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+}
+
+func Test_fsCache_SetContext_Timeout(t *testing.T) {
+	cache, err := fromURL(makeRootURL(t))
+	testutil.RequireNoError(t, err, "Failed to create fscache")
+	t.Cleanup(func() { cache.Close() })
+
+	// The operation timeout applies along with the caller's context.
+	cache.timeout = time.Nanosecond
+	err = cache.SetContext(t.Context(), "a", bytes.Repeat([]byte("x"), 1<<20))
+	testutil.RequireErrorIs(t, err, context.DeadlineExceeded)
+}
+
 func Test_fsCache_KeysError(t *testing.T) {
 	u := makeRootURL(t)
 	cache, err := fromURL(u)
@@ -171,7 +197,8 @@ func TestOpen(t *testing.T) {
 		{
 			name: "Encryption Enabled with Key",
 			args: args{
-				dsn: "fscache://?appname=myapp&encrypt=aesgcm&encrypt_key=" + mustBase64Key(t, 16),
+				dsn: "fscache://" + filepath.ToSlash(t.TempDir()) +
+					"?appname=myapp&encrypt=aesgcm&encrypt_key=" + mustBase64Key(t, 16),
 			},
 			assertion: func(tt *testing.T, got *fsCache, err error) {
 				testutil.RequireNoError(tt, err)
@@ -182,7 +209,7 @@ func TestOpen(t *testing.T) {
 		{
 			name: "Encryption Enabled with Key (Environment Variable)",
 			args: args{
-				dsn: "fscache://?appname=myapp&encrypt=aesgcm",
+				dsn: "fscache://" + filepath.ToSlash(t.TempDir()) + "?appname=myapp&encrypt=aesgcm",
 			},
 			setup: func(tt *testing.T) {
 				tt.Setenv("FSCACHE_ENCRYPT_KEY", "6S-Ks2YYOW0xMvTzKSv6QD30gZeOi1c6Ydr-As5csWk=")
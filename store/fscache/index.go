@@ -0,0 +1,498 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// indexFile holds the log of the key index; see [index].
+const indexFile = ".index"
+
+// indexCompactMin is the number of records below which the index log is
+// never compacted.
+const indexCompactMin = 1024
+
+// indexMagic starts the index log. It is followed by records, each made of
+// the uvarint length of its body, the body, and the big-endian CRC-32 of the
+// body. Bodies are encrypted when encryption is enabled, as they hold keys.
+var indexMagic = []byte("fsx1")
+
+// Record types, the first byte of a record body. Both are followed by the
+// uvarint length of the key and the key.
+const (
+	indexPut    byte = 1 // then the file name, likewise, its size and access time
+	indexDelete byte = 2
+)
+
+var (
+	errCorruptIndex  = errors.New("fscache: corrupt index")
+	errIndexDisabled = errors.New("fscache: index is not enabled")
+)
+
+type indexEntry struct {
+	name  string    // file name, relative to the cache directory
+	size  int64     // file size
+	atime time.Time // last access
+}
+
+type indexRecord struct {
+	op    byte
+	key   string
+	entry indexEntry // for indexPut
+}
+
+// index maps keys to their entry files, so that keys can be listed and
+// counted without walking the cache directory. It is kept in an append-only
+// log in the cache directory, shared with other processes: changes are
+// appended while holding a lock file, and records appended by other processes
+// are replayed before the index is used. The log is compacted into a snapshot
+// once most of its records are superseded, and rebuilt from a walk of the
+// directory if it is missing or corrupt.
+type index struct {
+	lock *lockSet // serializes access to the log between processes
+
+	mu      sync.Mutex
+	entries map[string]indexEntry // key -> entry
+	names   map[string]string     // file name -> key
+	size    int64                 // total size of the entries
+	records int                   // records in the log
+	log     os.FileInfo           // the log as last read, to detect compaction
+	offset  int64                 // length of the log applied to entries
+
+	tmu     sync.Mutex
+	touched map[string]time.Time // key -> access time not logged yet; guarded by tmu
+}
+
+func newIndex() *index {
+	x := &index{
+		lock:    newLockSet(".lock-index-"),
+		touched: make(map[string]time.Time),
+	}
+	x.reset()
+	return x
+}
+
+func (x *index) reset() {
+	x.entries = make(map[string]indexEntry)
+	x.names = make(map[string]string)
+	x.size, x.records, x.log, x.offset = 0, 0, nil, 0
+}
+
+func (x *index) set(key string, e indexEntry) {
+	x.del(key)
+	x.entries[key] = e
+	x.names[e.name] = key
+	x.size += e.size
+}
+
+func (x *index) del(key string) {
+	if e, ok := x.entries[key]; ok {
+		delete(x.entries, key)
+		delete(x.names, e.name)
+		x.size -= e.size
+	}
+}
+
+// update runs fn while holding the index lock, with the index brought up to
+// date with the log.
+func (x *index) update(c *fsCache, fn func() error) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	unlock, err := x.lock.lock(c, indexFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := x.refresh(c); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// refresh applies the records appended to the log since it was last read. It
+// reloads the log if another process compacted it, and rebuilds it if it is
+// missing or corrupt.
+func (x *index) refresh(c *fsCache) error {
+	info, err := c.root.Stat(indexFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return x.rebuild(c)
+		}
+		return err
+	}
+	if x.log == nil || !os.SameFile(info, x.log) || info.Size() < x.offset {
+		x.reset()
+	}
+	x.log = info
+	if info.Size() == x.offset && x.offset > 0 {
+		return nil
+	}
+	f, err := c.root.Open(indexFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(x.offset, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if err := x.replay(c, data); err != nil {
+		if errors.Is(err, errCorruptIndex) {
+			return x.rebuild(c)
+		}
+		return err
+	}
+	return nil
+}
+
+func (x *index) replay(c *fsCache, data []byte) error {
+	if x.offset == 0 {
+		rest, ok := bytes.CutPrefix(data, indexMagic)
+		if !ok {
+			return errCorruptIndex
+		}
+		x.offset += int64(len(indexMagic))
+		data = rest
+	}
+	for len(data) > 0 {
+		n, size := binary.Uvarint(data)
+		if size <= 0 || n > uint64(len(data)-size) || uint64(len(data)-size)-n < 4 {
+			return errCorruptIndex // including a record cut short by a crash
+		}
+		body := data[size : size+int(n)]
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[size+int(n):]) {
+			return errCorruptIndex
+		}
+		if c.enc != nil {
+			var err error
+			if body, err = c.enc.Decrypt(body); err != nil {
+				return errors.Join(errCorruptIndex, err)
+			}
+		}
+		r, err := decodeIndexRecord(body)
+		if err != nil {
+			return err
+		}
+		x.apply(r)
+		length := size + int(n) + 4
+		data = data[length:]
+		x.offset += int64(length)
+		x.records++
+	}
+	return nil
+}
+
+func (x *index) apply(r indexRecord) {
+	switch r.op {
+	case indexPut:
+		x.set(r.key, r.entry)
+	case indexDelete:
+		x.del(r.key)
+	}
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func cutString(data []byte) (string, []byte, bool) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(len(data)-size) {
+		return "", nil, false
+	}
+	data = data[size:]
+	return string(data[:n]), data[n:], true
+}
+
+// encode returns the framed record, encrypted if encryption is enabled.
+func (r indexRecord) encode(c *fsCache) ([]byte, error) {
+	body := appendString([]byte{r.op}, r.key)
+	if r.op == indexPut {
+		body = appendString(body, r.entry.name)
+		body = binary.AppendUvarint(body, uint64(r.entry.size))
+		body = binary.AppendVarint(body, r.entry.atime.UnixNano())
+	}
+	if c.enc != nil {
+		var err error
+		if body, err = c.enc.Encrypt(body); err != nil {
+			return nil, err
+		}
+	}
+	buf := binary.AppendUvarint(nil, uint64(len(body)))
+	buf = append(buf, body...)
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(body)), nil
+}
+
+func decodeIndexRecord(body []byte) (indexRecord, error) {
+	if len(body) == 0 {
+		return indexRecord{}, errCorruptIndex
+	}
+	r := indexRecord{op: body[0]}
+	key, rest, ok := cutString(body[1:])
+	if !ok {
+		return indexRecord{}, errCorruptIndex
+	}
+	r.key = key
+	switch r.op {
+	case indexDelete:
+		return r, nil
+	case indexPut:
+		name, rest, ok := cutString(rest)
+		if !ok {
+			return indexRecord{}, errCorruptIndex
+		}
+		size, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return indexRecord{}, errCorruptIndex
+		}
+		atime, m := binary.Varint(rest[n:])
+		if m <= 0 {
+			return indexRecord{}, errCorruptIndex
+		}
+		r.entry = indexEntry{name: name, size: int64(size), atime: time.Unix(0, atime)}
+		return r, nil
+	default:
+		return indexRecord{}, errCorruptIndex
+	}
+}
+
+// pendingTouches returns records for the access times not logged yet.
+func (x *index) pendingTouches() []indexRecord {
+	x.tmu.Lock()
+	touched := x.touched
+	x.touched = make(map[string]time.Time)
+	x.tmu.Unlock()
+	var recs []indexRecord
+	for key, t := range touched {
+		if e, ok := x.entries[key]; ok && t.After(e.atime) {
+			e.atime = t
+			recs = append(recs, indexRecord{indexPut, key, e})
+		}
+	}
+	return recs
+}
+
+// append logs recs, along with pending access times, and applies them. It
+// compacts the log if most of its records are superseded.
+func (x *index) append(c *fsCache, recs ...indexRecord) error {
+	recs = append(x.pendingTouches(), recs...)
+	if len(recs) == 0 {
+		return nil
+	}
+	var buf []byte
+	for _, r := range recs {
+		data, err := r.encode(c)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, data...)
+	}
+	f, err := c.root.OpenFile(indexFile, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf)
+	if err == nil && c.durability != DurabilityNone {
+		err = f.Sync()
+	}
+	if err = errors.Join(err, f.Close()); err != nil {
+		return err
+	}
+	for _, r := range recs {
+		x.apply(r)
+	}
+	x.offset += int64(len(buf))
+	x.records += len(recs)
+	if x.records >= indexCompactMin && x.records > 2*len(x.entries) {
+		return x.compact(c)
+	}
+	return nil
+}
+
+// compact replaces the log with a snapshot of the index.
+func (x *index) compact(c *fsCache) error {
+	for _, r := range x.pendingTouches() {
+		x.apply(r)
+	}
+	buf := bytes.Clone(indexMagic)
+	for _, key := range slices.Sorted(maps.Keys(x.entries)) {
+		data, err := indexRecord{indexPut, key, x.entries[key]}.encode(c)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, data...)
+	}
+	if err := c.writeFile(indexFile, buf); err != nil {
+		return err
+	}
+	info, err := c.root.Stat(indexFile)
+	if err != nil {
+		return err
+	}
+	x.log, x.offset, x.records = info, int64(len(buf)), len(x.entries)
+	return nil
+}
+
+// rebuild recreates the index from a walk of the cache directory. An entry
+// found under both its current and old file name, during a layout migration,
+// is indexed under its current one.
+func (x *index) rebuild(c *fsCache) error {
+	x.reset()
+	err := c.walkEntries(func(name, key string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			return ignoreNotExist(err)
+		}
+		if _, ok := x.entries[key]; ok && name != c.fn.FileName(key) {
+			return nil
+		}
+		x.set(key, indexEntry{name: name, size: info.Size(), atime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return x.compact(c)
+}
+
+func (x *index) put(c *fsCache, key, name string, size int64, atime time.Time) error {
+	return x.update(c, func() error {
+		return x.append(c, indexRecord{indexPut, key, indexEntry{name, size, atime}})
+	})
+}
+
+func (x *index) remove(c *fsCache, key string) error {
+	return x.update(c, func() error {
+		if _, ok := x.entries[key]; !ok {
+			return nil
+		}
+		return x.append(c, indexRecord{op: indexDelete, key: key})
+	})
+}
+
+// removeName removes the entry stored in the named file, if indexed.
+func (x *index) removeName(c *fsCache, name string) error {
+	return x.update(c, func() error {
+		key, ok := x.names[name]
+		if !ok {
+			return nil
+		}
+		return x.append(c, indexRecord{op: indexDelete, key: key})
+	})
+}
+
+// resize records the new size of the named file, if indexed.
+func (x *index) resize(c *fsCache, name string, size int64) error {
+	return x.update(c, func() error {
+		key, ok := x.names[name]
+		if !ok {
+			return nil
+		}
+		e := x.entries[key]
+		e.size = size
+		return x.append(c, indexRecord{indexPut, key, e})
+	})
+}
+
+// touch records an access to key, which is logged with the next change.
+func (x *index) touch(key string, t time.Time) {
+	x.tmu.Lock()
+	defer x.tmu.Unlock()
+	x.touched[key] = t
+}
+
+func (x *index) list(c *fsCache, prefix string) ([]string, error) {
+	var keys []string
+	err := x.update(c, func() error {
+		for key := range x.entries {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	slices.Sort(keys)
+	return keys, err
+}
+
+func (x *index) stats(c *fsCache) (Stats, error) {
+	var s Stats
+	err := x.update(c, func() error {
+		s = Stats{Entries: len(x.entries), Size: x.size}
+		return nil
+	})
+	return s, err
+}
+
+// close logs pending access times and closes the lock file.
+func (x *index) close(c *fsCache) error {
+	err := x.update(c, func() error { return x.append(c) })
+	return errors.Join(err, x.lock.close())
+}
+
+// Stats describes the contents of the cache.
+type Stats struct {
+	Entries int   // number of entries
+	Size    int64 // total size of the entry files, in bytes
+}
+
+// Stats returns the number of entries in the cache and their total size. It
+// reads them from the key index, and fails if the index is not enabled.
+func (c *fsCache) Stats() (Stats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	type result struct {
+		stats Stats
+		err   error
+	}
+	errc := make(chan result, 1)
+	go func() {
+		defer close(errc)
+		if c.idx == nil {
+			errc <- result{Stats{}, &Error{Op: "Stats", Err: errIndexDisabled}}
+			return
+		}
+		stats, err := c.idx.stats(c)
+		if err != nil {
+			errc <- result{Stats{}, &Error{Op: "Stats", Err: err}}
+			return
+		}
+		errc <- result{stats, nil}
+	}()
+	select {
+	case <-ctx.Done():
+		return Stats{}, ctx.Err()
+	case res := <-errc:
+		return res.stats, res.err
+	}
+}
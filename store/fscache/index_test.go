@@ -0,0 +1,244 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"bytes"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+func TestFSCache_Acceptance_Index(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		cache, err := Open("testapp", WithBaseDir(t.TempDir()), WithIndex(true))
+		testutil.RequireNoError(t, err, "Failed to create fscache")
+		return cache, func() { cache.Close() }
+	}))
+}
+
+func openIndexed(t *testing.T, base string, opts ...Option) *fsCache {
+	t.Helper()
+	cache, err := Open("testapp", append([]Option{WithBaseDir(base), WithIndex(true)}, opts...)...)
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+// failingWalker fails the test if the cache directory is walked.
+func failingWalker(t *testing.T) dirWalker {
+	return dirWalkerFunc(func(string, fs.WalkDirFunc) error {
+		t.Error("unexpected directory walk")
+		return nil
+	})
+}
+
+func Test_fromURL_Index(t *testing.T) {
+	u := makeRootURL(t)
+	q := u.Query()
+	q.Set("index", "on")
+	u.RawQuery = q.Encode()
+	cache, err := fromURL(u)
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	testutil.AssertNotNil(t, cache.idx)
+}
+
+func Test_fsCache_Index(t *testing.T) {
+	long := "https://example.com/" + strings.Repeat("a", 300)
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"Plain", nil},
+		{"Encrypted", []Option{WithKeyProvider(StaticKeys(mustKey(t, "k")))}},
+		{"Sharded", []Option{WithShardedLayout(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			cache := openIndexed(t, base, tt.opts...)
+			cache.dw = failingWalker(t)
+			for _, k := range []string{"https://example.com/a", "https://example.com/b", long, "other"} {
+				testutil.RequireNoError(t, cache.Set(k, []byte("value")))
+			}
+			testutil.RequireNoError(t, cache.Delete("https://example.com/b"))
+
+			keys, err := cache.Keys("https://example.com/")
+			testutil.RequireNoError(t, err)
+			testutil.AssertTrue(t, slices.Equal(keys, []string{"https://example.com/a", long}), "got %q", keys)
+			stats, err := cache.Stats()
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, 3, stats.Entries)
+			var size int64
+			for _, name := range entryFiles(t, cache) {
+				info, err := cache.root.Stat(name)
+				testutil.RequireNoError(t, err)
+				size += info.Size()
+			}
+			testutil.AssertEqual(t, size, stats.Size)
+			testutil.RequireNoError(t, cache.Close())
+
+			// The index is read back rather than rebuilt.
+			cache = openIndexed(t, base, tt.opts...)
+			cache.dw = failingWalker(t)
+			got, err := cache.Stats()
+			testutil.RequireNoError(t, err)
+			testutil.AssertTrue(t, stats == got, "stats changed on reopen")
+		})
+	}
+}
+
+func Test_fsCache_Index_Encrypted(t *testing.T) {
+	cache := openIndexed(t, t.TempDir(), WithKeyProvider(StaticKeys(mustKey(t, "k"))))
+	testutil.RequireNoError(t, cache.Set("https://example.com/secret", []byte("value")))
+	data, err := cache.root.ReadFile(indexFile)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, !bytes.Contains(data, []byte("secret")), "key stored in the clear")
+}
+
+func Test_fsCache_Index_SharedDirectory(t *testing.T) {
+	base := t.TempDir()
+	a := openIndexed(t, base)
+	b := openIndexed(t, base)
+	testutil.RequireNoError(t, a.Set("x", []byte("1")))
+	testutil.AssertTrue(t, slices.Equal(sortedKeys(t, b), []string{"x"}))
+	testutil.RequireNoError(t, b.Delete("x"))
+	testutil.RequireNoError(t, b.Set("y", []byte("2")))
+	testutil.AssertTrue(t, slices.Equal(sortedKeys(t, a), []string{"y"}))
+
+	// b replays a's records after a compacted the log.
+	for i := range indexCompactMin {
+		testutil.RequireNoError(t, a.Set("z", []byte(strconv.Itoa(i))))
+	}
+	testutil.AssertTrue(t, a.idx.records < indexCompactMin, "log not compacted")
+	testutil.AssertTrue(t, slices.Equal(sortedKeys(t, b), []string{"y", "z"}))
+	stats, err := b.Stats()
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, stats == Stats{Entries: 2, Size: int64(len("2") + len(strconv.Itoa(indexCompactMin-1)))})
+}
+
+func Test_fsCache_Index_Rebuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, c *fsCache)
+	}{
+		{"Missing", func(t *testing.T, c *fsCache) {
+			testutil.RequireNoError(t, c.root.Remove(indexFile))
+		}},
+		{"Garbage", func(t *testing.T, c *fsCache) {
+			testutil.RequireNoError(t, c.root.WriteFile(indexFile, []byte("garbage"), 0o644))
+		}},
+		{"Empty", func(t *testing.T, c *fsCache) {
+			testutil.RequireNoError(t, c.root.WriteFile(indexFile, nil, 0o644))
+		}},
+		{"TornRecord", func(t *testing.T, c *fsCache) {
+			data, err := c.root.ReadFile(indexFile)
+			testutil.RequireNoError(t, err)
+			testutil.RequireNoError(t, c.root.WriteFile(indexFile, data[:len(data)-1], 0o644))
+		}},
+		{"BadChecksum", func(t *testing.T, c *fsCache) {
+			data, err := c.root.ReadFile(indexFile)
+			testutil.RequireNoError(t, err)
+			data[len(indexMagic)+2] ^= 0xff
+			testutil.RequireNoError(t, c.root.WriteFile(indexFile, data, 0o644))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			cache := openIndexed(t, base)
+			testutil.RequireNoError(t, cache.Set("a", []byte("1")))
+			testutil.RequireNoError(t, cache.Set("b", []byte("2")))
+			testutil.RequireNoError(t, cache.Close())
+
+			cache, err := Open("testapp", WithBaseDir(base))
+			testutil.RequireNoError(t, err)
+			tt.corrupt(t, cache)
+			testutil.RequireNoError(t, cache.Set("c", []byte("3"))) // not indexed
+			testutil.RequireNoError(t, cache.Close())
+
+			cache = openIndexed(t, base)
+			testutil.AssertTrue(t, slices.Equal(sortedKeys(t, cache), []string{"a", "b", "c"}))
+			stats, err := cache.Stats()
+			testutil.RequireNoError(t, err)
+			testutil.AssertTrue(t, stats == Stats{Entries: 3, Size: 3})
+		})
+	}
+}
+
+func Test_fsCache_Index_AccessTimes(t *testing.T) {
+	base := t.TempDir()
+	cache := openIndexed(t, base)
+	testutil.RequireNoError(t, cache.Set("a", []byte("1")))
+	set := cache.idx.entries["a"].atime
+	time.Sleep(10 * time.Millisecond)
+	_, err := cache.Get("a")
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, cache.Close())
+
+	cache = openIndexed(t, base)
+	testutil.AssertTrue(t, cache.idx.entries["a"].atime.After(set), "access time not logged")
+}
+
+func Test_fsCache_Index_Evict(t *testing.T) {
+	cache := openIndexed(t, t.TempDir())
+	cache.ev = newEvictor(0, 2)
+	t.Cleanup(func() { cache.ev = nil })
+	for _, k := range []string{"a", "b", "c"} {
+		testutil.RequireNoError(t, cache.Set(k, []byte("v")))
+	}
+	testutil.RequireNoError(t, cache.evict())
+	stats, err := cache.Stats()
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 2, stats.Entries)
+	testutil.AssertEqual(t, 2, len(entryFiles(t, cache)))
+}
+
+func Test_fsCache_Stats_IndexDisabled(t *testing.T) {
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	_, err = cache.Stats()
+	testutil.RequireErrorIs(t, err, errIndexDisabled)
+}
+
+func Test_decodeIndexRecord(t *testing.T) {
+	c := &fsCache{}
+	want := indexRecord{indexPut, "key", indexEntry{"name", 42, time.Unix(0, 1234)}}
+	data, err := want.encode(c)
+	testutil.RequireNoError(t, err)
+	x := newIndex()
+	testutil.RequireNoError(t, x.replay(c, append(bytes.Clone(indexMagic), data...)))
+	testutil.AssertTrue(t, x.entries["key"] == want.entry)
+
+	for _, body := range [][]byte{
+		nil,
+		{9, 1, 'k'},                // unknown type
+		{indexPut, 5, 'k'},         // key cut short
+		{indexPut, 1, 'k'},         // no name
+		{indexPut, 1, 'k', 1, 'n'}, // no size
+	} {
+		_, err := decodeIndexRecord(body)
+		testutil.RequireErrorIs(t, err, errCorruptIndex)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bartventer/httpcache/store/internal/keyring"
+)
+
+// Key is an AES key used to encrypt cache entries.
+type Key = keyring.Key
+
+// ParseKey parses a key of the form "[id:]secret", where secret is base64
+// encoded (RFC 4648 §5, URL-safe variant).
+func ParseKey(s string) (Key, error) {
+	k, err := keyring.Parse(s)
+	if err != nil {
+		return Key{}, fmt.Errorf("fscache: %w", err)
+	}
+	return k, nil
+}
+
+// passphraseIterations is the number of PBKDF2 iterations used by
+// [PassphraseKey]; a variable so that tests can lower it.
+var passphraseIterations = 600_000
+
+// PassphraseKey derives an AES-256 key from a passphrase using PBKDF2 with
+// HMAC-SHA-256 and 600,000 iterations. It is intended for development
+// machines; prefer random keys elsewhere.
+func PassphraseKey(passphrase string, salt []byte) (Key, error) {
+	if passphrase == "" {
+		return Key{}, errors.New("fscache: empty passphrase")
+	}
+	secret, err := pbkdf2.Key(sha256.New, passphrase, salt, passphraseIterations, 32)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Secret: secret}, nil
+}
+
+// KeyProvider supplies the encryption key ring.
+type KeyProvider interface {
+	// Keys returns the keys in the ring. The first key encrypts new entries;
+	// all keys are used to decrypt existing ones.
+	Keys(ctx context.Context) ([]Key, error)
+}
+
+// KeyProviderFunc is an adapter to allow the use of ordinary functions as
+// [KeyProvider].
+type KeyProviderFunc func(ctx context.Context) ([]Key, error)
+
+func (f KeyProviderFunc) Keys(ctx context.Context) ([]Key, error) { return f(ctx) }
+
+// StaticKeys returns a [KeyProvider] for a fixed key ring.
+func StaticKeys(keys ...Key) KeyProvider {
+	return KeyProviderFunc(func(context.Context) ([]Key, error) {
+		return keys, nil
+	})
+}
+
+// EnvKeys returns a [KeyProvider] that reads a comma-separated list of keys,
+// in the format accepted by [ParseKey], from the named environment variable.
+func EnvKeys(name string) KeyProvider {
+	return KeyProviderFunc(func(context.Context) ([]Key, error) {
+		v := os.Getenv(name)
+		if v == "" {
+			return nil, fmt.Errorf("fscache: environment variable %s is not set", name)
+		}
+		return keyring.ParseList(v)
+	})
+}
+
+// FileKeys returns a [KeyProvider] that reads keys, one per line in the
+// format accepted by [ParseKey], from the named file. Blank lines and lines
+// starting with '#' are ignored.
+func FileKeys(path string) KeyProvider {
+	return KeyProviderFunc(func(context.Context) ([]Key, error) {
+		keys, err := keyring.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("fscache: %w", err)
+		}
+		return keys, nil
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+func TestParseKey(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	b64 := base64.URLEncoding.EncodeToString(secret)
+	tests := []struct {
+		name    string
+		in      string
+		wantID  string
+		wantErr bool
+	}{
+		{"WithoutID", b64, "", false},
+		{"WithID", "2026-01:" + b64, "2026-01", false},
+		{"Whitespace", "  k1:" + b64 + "\n", "k1", false},
+		{"InvalidBase64", "k1:not-base64!", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseKey(tt.in)
+			if tt.wantErr {
+				testutil.RequireError(t, err)
+				return
+			}
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, tt.wantID, k.ID)
+			testutil.AssertTrue(t, bytes.Equal(secret, k.Secret))
+		})
+	}
+}
+
+func TestEnvKeys(t *testing.T) {
+	t.Setenv("FSCACHE_TEST_KEYS", "a:"+mustBase64Key(t, 32)+", b:"+mustBase64Key(t, 16))
+	keys, err := EnvKeys("FSCACHE_TEST_KEYS").Keys(context.Background())
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 2, len(keys))
+	testutil.AssertEqual(t, "a", keys[0].ID)
+	testutil.AssertEqual(t, "b", keys[1].ID)
+
+	_, err = EnvKeys("FSCACHE_TEST_UNSET").Keys(context.Background())
+	testutil.RequireError(t, err)
+}
+
+func TestFileKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	content := "# active key first\n\nnew:" + mustBase64Key(t, 32) + "\nold:" + mustBase64Key(t, 32) + "\n"
+	testutil.RequireNoError(t, os.WriteFile(path, []byte(content), 0o600))
+	keys, err := FileKeys(path).Keys(context.Background())
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 2, len(keys))
+	testutil.AssertEqual(t, "new", keys[0].ID)
+
+	testutil.RequireNoError(t, os.WriteFile(path, []byte("bad:!!\n"), 0o600))
+	_, err = FileKeys(path).Keys(context.Background())
+	testutil.RequireError(t, err)
+
+	_, err = FileKeys(filepath.Join(t.TempDir(), "missing")).Keys(context.Background())
+	testutil.RequireErrorIs(t, err, os.ErrNotExist)
+}
+
+// lowerPassphraseIterations speeds up key derivation for the duration of t.
+func lowerPassphraseIterations(t *testing.T) {
+	t.Helper()
+	old := passphraseIterations
+	passphraseIterations = 1000
+	t.Cleanup(func() { passphraseIterations = old })
+}
+
+func TestPassphraseKey(t *testing.T) {
+	lowerPassphraseIterations(t)
+	k1, err := PassphraseKey("correct horse", []byte("fscache/a"))
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 32, len(k1.Secret))
+	k2, err := PassphraseKey("correct horse", []byte("fscache/b"))
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, !bytes.Equal(k1.Secret, k2.Secret), "salt must change the key")
+
+	_, err = PassphraseKey("", nil)
+	testutil.RequireError(t, err)
+}
+
+func TestOpen_KeyProvider(t *testing.T) {
+	t.Run("Passphrase", func(t *testing.T) {
+		lowerPassphraseIterations(t)
+		u := makeRootURL(t)
+		u.RawQuery += "&encrypt=on&encrypt_passphrase=secret"
+		cache, err := fromURL(u)
+		testutil.RequireNoError(t, err)
+		t.Cleanup(func() { cache.Close() })
+		testutil.RequireNoError(t, cache.Set("k", []byte("v")))
+		got, err := cache.Get("k")
+		testutil.RequireNoError(t, err)
+		testutil.AssertEqual(t, "v", string(got))
+	})
+	t.Run("ProviderError", func(t *testing.T) {
+		_, err := Open("testapp", WithBaseDir(t.TempDir()), WithKeyProvider(
+			KeyProviderFunc(func(context.Context) ([]Key, error) { return nil, testutil.ErrSample }),
+		))
+		testutil.RequireErrorIs(t, err, testutil.ErrSample)
+	})
+	t.Run("EmptyRing", func(t *testing.T) {
+		_, err := Open("testapp", WithBaseDir(t.TempDir()), WithKeyProvider(StaticKeys()))
+		testutil.RequireErrorIs(t, err, errNoKeys)
+	})
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errEntryRemoved = errors.New("fscache: entry removed during migration")
+
+// layout describes how entries are named in the cache directory. It is
+// recorded in [layoutFile] once every entry follows it; a directory without
+// one holds plain, unsharded names.
+type layout struct {
+	hashed  bool // files are named after a keyed hash of the key
+	sharded bool // files are spread into two levels of shard directories
+}
+
+func (l layout) String() string {
+	s := "plain"
+	if l.hashed {
+		s = "hmac-sha256"
+	}
+	if l.sharded {
+		s += " sharded"
+	}
+	return s
+}
+
+func parseLayout(data []byte) layout {
+	var l layout
+	for f := range strings.FieldsSeq(string(data)) {
+		switch f {
+		case "hmac-sha256":
+			l.hashed = true
+		case "sharded":
+			l.sharded = true
+		}
+	}
+	return l
+}
+
+// readLayout returns the layout recorded in the cache directory.
+func (c *fsCache) readLayout() (layout, error) {
+	data, err := c.root.ReadFile(layoutFile)
+	if err != nil {
+		return layout{}, ignoreNotExist(err)
+	}
+	return parseLayout(data), nil
+}
+
+// shardedFileNamer returns a fileNamer that places the files named by inner
+// in "ab/cd" shard directories, taken from a hash of the file name, so that
+// no directory holds more than a small fraction of the entries.
+func shardedFileNamer(inner fileNamer) fileNamer {
+	return fileNamerFunc(func(key string) string {
+		name := inner.FileName(key)
+		return filepath.Join(shardDir(name), name)
+	})
+}
+
+func shardDir(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(hex.EncodeToString(sum[:1]), hex.EncodeToString(sum[1:2]))
+}
+
+// unshard strips the shard directories from name, if it has any. Shard
+// directories cannot be mistaken for fragment directories, which are longer.
+func unshard(name string) string {
+	const prefixLen = len("ab/cd/")
+	if len(name) <= prefixLen {
+		return name
+	}
+	if rest := name[prefixLen:]; name[:prefixLen] == shardDir(rest)+string(filepath.Separator) {
+		return rest
+	}
+	return name
+}
+
+// unshardingFileNameKeyer returns a fileNameKeyer that recovers keys from
+// both sharded and unsharded names of inner.
+func unshardingFileNameKeyer(inner fileNameKeyer) fileNameKeyer {
+	return fileNameKeyerFunc(func(name string) (string, error) {
+		return inner.KeyFromFileName(unshard(name))
+	})
+}
+
+// fallbackName returns the name of the file for key in the layout being
+// migrated from, if a migration between sharded and unsharded names is in
+// progress.
+func (c *fsCache) fallbackName(key string) (string, bool) {
+	if c.fallback == nil || c.migrated.Load() {
+		return "", false
+	}
+	return c.fallback.FileName(key), true
+}
+
+// MigrateLayout moves entries that do not follow the configured layout to
+// where it names them, and records the layout in the cache directory. It
+// returns the number of entries moved. It runs in the background when the
+// cache is opened with a layout that differs from the recorded one; until it
+// completes, lookups also try the location an entry had in the old layout
+// when only sharding changed, and report entries that were named otherwise
+// as missing.
+//
+// Moved entries keep their modification time. Entries that cannot be read,
+// and entries replaced by a concurrent Set, in this or another process, are
+// left alone.
+func (c *fsCache) MigrateLayout(ctx context.Context) (int, error) {
+	var n int
+	err := fs.WalkDir(c.root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || isMetaFile(path) {
+			return nil
+		}
+		moved, err := c.migrateEntry(filepath.FromSlash(path))
+		if moved {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		return n, err
+	}
+	want := layout{hashed: c.hashNames, sharded: c.shard}
+	if err := c.writeFile(layoutFile, []byte(want.String()+"\n")); err != nil {
+		return n, err
+	}
+	c.migrated.Store(true)
+	return n, nil
+}
+
+// migrateEntry moves the named file to where the configured layout names it,
+// converting it to a hashed name if needed, and reports whether it did.
+func (c *fsCache) migrateEntry(name string) (bool, error) {
+	info, err := c.root.Stat(name)
+	if err != nil {
+		return false, ignoreNotExist(err)
+	}
+	data, err := c.root.ReadFile(name)
+	if err != nil {
+		return false, ignoreNotExist(err)
+	}
+	key, data, ok, err := c.entryKey(name, data)
+	if err != nil || !ok {
+		return false, err
+	}
+	target := c.fn.FileName(key)
+	if target == name {
+		return false, nil
+	}
+	unlock, err := c.wlocks.lock(c, target)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	err = c.writeFileIf(target, data, func(tmp string) error {
+		if err := c.root.Chtimes(tmp, zeroTime, info.ModTime()); err != nil {
+			return err
+		}
+		// An entry set since the cache was opened is newer; keep it.
+		if _, err := c.root.Stat(target); err == nil {
+			return fs.ErrExist
+		}
+		// Delete removes the old file while holding the lock on target.
+		if _, err := c.root.Stat(name); err != nil {
+			return errors.Join(errEntryRemoved, err)
+		}
+		return nil
+	})
+	moved := err == nil
+	switch {
+	case errors.Is(err, errEntryRemoved):
+		return false, nil
+	case err != nil && !errors.Is(err, fs.ErrExist):
+		return false, err
+	}
+	if moved && c.idx != nil {
+		if err := c.idx.put(c, key, target, int64(len(data)), info.ModTime()); err != nil {
+			return false, err
+		}
+	}
+	if err := c.root.Remove(name); err != nil {
+		return moved, ignoreNotExist(err)
+	}
+	if c.ev != nil {
+		c.ev.removed(name)
+	}
+	c.removeEmptyDirs(filepath.Dir(name))
+	return moved, nil
+}
+
+// entryKey returns the key of the named entry file along with its contents
+// as they should be written in the configured layout. It reports false for
+// files it cannot read, which are left in place.
+func (c *fsCache) entryKey(name string, data []byte) (string, []byte, bool, error) {
+	if !c.hashNames {
+		key, err := c.fnk.KeyFromFileName(name)
+		if err != nil {
+			return "", nil, false, nil //nolint:nilerr // Not an entry written by this package.
+		}
+		return key, data, true, nil
+	}
+	value := data
+	if c.enc != nil {
+		var err error
+		if value, err = c.enc.Decrypt(data); err != nil {
+			return "", nil, false, nil //nolint:nilerr // Undecryptable entries are left in place.
+		}
+	}
+	if key, _, err := decodePayload(value); err == nil {
+		return key, data, true, nil
+	}
+	key, err := c.fnk.KeyFromFileName(name)
+	if err != nil {
+		return "", nil, false, nil //nolint:nilerr // Not an entry written by this package.
+	}
+	data = encodePayload(key, value)
+	if c.enc != nil {
+		if data, err = c.enc.Encrypt(data); err != nil {
+			return "", nil, false, err
+		}
+	}
+	return key, data, true, nil
+}
+
+// removeFallback removes the file for key in the layout being migrated from,
+// if any, and reports whether it existed.
+func (c *fsCache) removeFallback(key string) (bool, error) {
+	name, ok := c.fallbackName(key)
+	if !ok {
+		return false, nil
+	}
+	if err := c.root.Remove(name); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if c.ev != nil {
+		c.ev.removed(name)
+	}
+	c.removeEmptyDirs(filepath.Dir(name))
+	return true, nil
+}
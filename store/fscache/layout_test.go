@@ -0,0 +1,181 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+func TestFSCache_Acceptance_ShardedLayout(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		cache, err := Open("testapp", WithBaseDir(t.TempDir()), WithShardedLayout(true))
+		testutil.RequireNoError(t, err, "Failed to create fscache")
+		return cache, func() { cache.Close() }
+	}))
+}
+
+func Test_fromURL_Layout(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"default", "", false, false},
+		{"flat", "flat", false, false},
+		{"sharded", "sharded", true, false},
+		{"invalid", "deep", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := makeRootURL(t)
+			if tt.value != "" {
+				q := u.Query()
+				q.Set("layout", tt.value)
+				u.RawQuery = q.Encode()
+			}
+			cache, err := fromURL(u)
+			if tt.wantErr {
+				testutil.RequireErrorIs(t, err, ErrInvalidParam)
+				return
+			}
+			testutil.RequireNoError(t, err)
+			t.Cleanup(func() { cache.Close() })
+			testutil.AssertTrue(t, cache.shard == tt.want)
+		})
+	}
+}
+
+func Test_unshard(t *testing.T) {
+	long := fragmentFileName(strings.Repeat("k", 300))
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"Flat", "a2V5", "a2V5"},
+		{"Sharded", filepath.Join(shardDir("a2V5"), "a2V5"), "a2V5"},
+		{"Fragmented", long, long},
+		{"ShardedFragmented", filepath.Join(shardDir(long), long), long},
+		{"WrongShard", filepath.Join("00", "00", "a2V5"), filepath.Join("00", "00", "a2V5")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.AssertEqual(t, tt.want, unshard(tt.in))
+		})
+	}
+}
+
+func Test_fsCache_MigrateLayout(t *testing.T) {
+	long := "https://example.com/" + strings.Repeat("a", 300)
+	keys := []string{"a", "b", long}
+	tests := []struct {
+		name   string
+		opts   []Option
+		layout string
+	}{
+		{"Plain", nil, "plain"},
+		{"Hashed", []Option{WithHashedNames(true)}, "hmac-sha256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			open := func(sharded bool) *fsCache {
+				t.Helper()
+				opts := append([]Option{WithBaseDir(base), WithShardedLayout(sharded)}, tt.opts...)
+				cache, err := Open("testapp", opts...)
+				testutil.RequireNoError(t, err)
+				cache.bg.Wait()
+				return cache
+			}
+			check := func(cache *fsCache, layout string) {
+				t.Helper()
+				for _, k := range keys {
+					got, err := cache.Get(k)
+					testutil.RequireNoError(t, err)
+					testutil.AssertEqual(t, "value "+k[:1], string(got))
+				}
+				var want []string
+				for _, k := range keys {
+					want = append(want, filepath.ToSlash(cache.fn.FileName(k)))
+				}
+				got := entryFiles(t, cache)
+				slices.Sort(want)
+				slices.Sort(got)
+				testutil.AssertTrue(t, slices.Equal(want, got), "unexpected files %q", got)
+				testutil.AssertTrue(t, slices.Equal(sortedKeys(t, cache), keys))
+				data, err := cache.root.ReadFile(layoutFile)
+				testutil.RequireNoError(t, err)
+				testutil.AssertEqual(t, layout+"\n", string(data))
+			}
+
+			cache := open(false)
+			for _, k := range keys {
+				testutil.RequireNoError(t, cache.Set(k, []byte("value "+k[:1])))
+			}
+			testutil.RequireNoError(t, cache.Close())
+
+			cache = open(true)
+			check(cache, tt.layout+" sharded")
+			for _, k := range keys {
+				testutil.AssertTrue(t, strings.Count(cache.fn.FileName(k), string(filepath.Separator)) >= 2)
+			}
+			n, err := cache.MigrateLayout(t.Context())
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, 0, n, "entries moved twice")
+			testutil.RequireNoError(t, cache.Close())
+
+			cache = open(false)
+			t.Cleanup(func() { cache.Close() })
+			check(cache, tt.layout)
+		})
+	}
+}
+
+func Test_fsCache_ShardedLayout_Fallback(t *testing.T) {
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()), WithShardedLayout(true))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	cache.bg.Wait()
+	// Pretend the unsharded entries below were written before the cache was
+	// opened, and have not been migrated yet.
+	cache.migrated.Store(false)
+	testutil.RequireNoError(t, cache.root.WriteFile(fragmentFileName("a"), []byte("old"), 0o644))
+	testutil.RequireNoError(t, cache.root.WriteFile(fragmentFileName("b"), []byte("stale"), 0o644))
+	testutil.RequireNoError(t, cache.Set("b", []byte("new")))
+
+	for k, want := range map[string]string{"a": "old", "b": "new"} {
+		got, err := cache.Get(k)
+		testutil.RequireNoError(t, err)
+		testutil.AssertEqual(t, want, string(got), "key %q", k)
+	}
+	testutil.AssertTrue(t, slices.Equal(sortedKeys(t, cache), []string{"a", "b"}))
+
+	testutil.RequireNoError(t, cache.Delete("a"))
+	testutil.RequireNoError(t, cache.Delete("b"))
+	for _, k := range []string{"a", "b"} {
+		_, err := cache.Get(k)
+		testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	}
+	testutil.AssertEqual(t, 0, len(entryFiles(t, cache)), "old entries left behind")
+	testutil.RequireErrorIs(t, cache.Delete("a"), driver.ErrNotExist)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+)
+
+// lockStripes is the number of lock files per lock set. Names hash to a
+// stripe, so unrelated names occasionally share a lock; in exchange the
+// number of lock files is bounded.
+const lockStripes = 64
+
+// lockSet is a set of striped advisory locks, each backed by a lock file in
+// the cache directory. A stripe is held by at most one goroutine in this
+// process, via its mutex, and by at most one process, via the lock file.
+type lockSet struct {
+	prefix string // lock file name prefix; must start with '.'
+	mu     [lockStripes]sync.Mutex
+	files  [lockStripes]*os.File // opened on first use; guarded by mu
+}
+
+func newLockSet(prefix string) *lockSet {
+	return &lockSet{prefix: prefix}
+}
+
+func stripe(name string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % lockStripes)
+}
+
+// lock acquires the stripe lock for name, and returns a function that
+// releases it.
+func (l *lockSet) lock(c *fsCache, name string) (func(), error) {
+	i := stripe(name)
+	l.mu[i].Lock()
+	f := l.files[i]
+	if f == nil {
+		var err error
+		f, err = c.root.OpenFile(fmt.Sprintf("%s%02x", l.prefix, i), os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			l.mu[i].Unlock()
+			return nil, err
+		}
+		l.files[i] = f
+	}
+	if err := flock(f); err != nil {
+		l.mu[i].Unlock()
+		return nil, err
+	}
+	return func() {
+		_ = funlock(f)
+		l.mu[i].Unlock()
+	}, nil
+}
+
+// close closes the lock files. Locks must not be held.
+func (l *lockSet) close() error {
+	var errs []error
+	for i := range l.files {
+		l.mu[i].Lock()
+		if f := l.files[i]; f != nil {
+			errs = append(errs, f.Close())
+			l.files[i] = nil
+		}
+		l.mu[i].Unlock()
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+// increment adds one to the counter stored under key, holding its lock.
+func increment(t testing.TB, c *fsCache, key string) {
+	t.Helper()
+	unlock, err := c.Lock(key)
+	testutil.RequireNoError(t, err)
+	defer unlock()
+	var n int
+	if data, err := c.Get(key); err == nil {
+		n, err = strconv.Atoi(string(data))
+		testutil.RequireNoError(t, err)
+	}
+	testutil.RequireNoError(t, c.Set(key, []byte(strconv.Itoa(n+1))))
+}
+
+func Test_fsCache_Lock_SharedDirectory(t *testing.T) {
+	base := t.TempDir()
+	const instances, iterations = 4, 50
+	caches := make([]*fsCache, instances)
+	for i := range caches {
+		cache, err := Open("testapp", WithBaseDir(base))
+		testutil.RequireNoError(t, err)
+		t.Cleanup(func() { cache.Close() })
+		caches[i] = cache
+	}
+
+	var wg sync.WaitGroup
+	for _, cache := range caches {
+		wg.Go(func() {
+			for range iterations {
+				increment(t, cache, "counter")
+			}
+		})
+	}
+	wg.Wait()
+
+	data, err := caches[0].Get("counter")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, strconv.Itoa(instances*iterations), string(data), "lost updates")
+}
+
+func Test_fsCache_Lock_Timeout(t *testing.T) {
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()), WithTimeout(50*time.Millisecond))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+
+	unlock, err := cache.Lock("key")
+	testutil.RequireNoError(t, err)
+	_, err = cache.Lock("key")
+	testutil.RequireError(t, err)
+	unlock()
+
+	// The lock acquired after the timeout is released again.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		unlock, err = cache.Lock("key")
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+	}
+	testutil.RequireNoError(t, err)
+	unlock()
+}
+
+func Test_fsCache_Keys_ConcurrentRemoval(t *testing.T) {
+	base := t.TempDir()
+	writer, err := Open("testapp", WithBaseDir(base))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { writer.Close() })
+	reader, err := Open("testapp", WithBaseDir(base))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { reader.Close() })
+
+	long := strings.Repeat("x", 2048) // spans several fragment directories
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			key := long + strconv.Itoa(i%4)
+			_ = writer.Set(key, []byte("v"))
+			_ = writer.Delete(key)
+		}
+	})
+	for range 200 {
+		_, err := reader.Keys("")
+		testutil.RequireNoError(t, err)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func Test_fsCache_Keys_SkipsVanishedPaths(t *testing.T) {
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	cache.dw = dirWalkerFunc(func(root string, fn fs.WalkDirFunc) error {
+		return fn(root+"/gone", nil, fs.ErrNotExist)
+	})
+	keys, err := cache.Keys("")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 0, len(keys))
+}
@@ -0,0 +1,83 @@
+//go:build unix
+
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+// workerDirEnv is set in the environment of worker processes started by
+// TestFSCache_MultiProcess to the base directory of the shared cache.
+const workerDirEnv = "FSCACHE_TEST_WORKER_DIR"
+
+const workerIterations = 25
+
+func TestFSCache_MultiProcess(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping multi-process test in short mode")
+	}
+	base := t.TempDir()
+	const workers = 4
+	cmds := make([]*exec.Cmd, workers)
+	outputs := make([]bytes.Buffer, workers)
+	for i := range cmds {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFSCache_MultiProcessWorker$", "-test.count=1")
+		cmd.Env = append(os.Environ(), workerDirEnv+"="+base)
+		cmd.Stdout, cmd.Stderr = &outputs[i], &outputs[i]
+		testutil.RequireNoError(t, cmd.Start())
+		cmds[i] = cmd
+	}
+	for i, cmd := range cmds {
+		testutil.RequireNoError(t, cmd.Wait(), "worker failed:\n"+outputs[i].String())
+	}
+
+	cache, err := Open("testapp", WithBaseDir(base))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	data, err := cache.Get("counter")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, strconv.Itoa(workers*workerIterations), string(data), "lost updates")
+}
+
+// TestFSCache_MultiProcessWorker is run in a separate process by
+// TestFSCache_MultiProcess.
+func TestFSCache_MultiProcessWorker(t *testing.T) {
+	base := os.Getenv(workerDirEnv)
+	if base == "" {
+		t.Skip("only run as a worker process")
+	}
+	cache, err := Open("testapp", WithBaseDir(base))
+	testutil.RequireNoError(t, err)
+	defer cache.Close()
+
+	long := strings.Repeat("x", 2048)
+	key := long + strconv.Itoa(os.Getpid())
+	for range workerIterations {
+		increment(t, cache, "counter")
+		testutil.RequireNoError(t, cache.Set(key, []byte("v")))
+		_, err := cache.Keys("")
+		testutil.RequireNoError(t, err)
+		testutil.RequireNoError(t, cache.Delete(key))
+	}
+}
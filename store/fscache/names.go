@@ -0,0 +1,197 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files whose names start with a '.' hold cache metadata rather than entries.
+// The prefix cannot occur in an entry file name, as '.' is not part of the
+// URL-safe base64 alphabet.
+const (
+	nameKeyFile = ".namekey" // key for hashed file names, encrypted if enabled
+	layoutFile  = ".layout"  // layout of the entries, once migrated; see [layout]
+)
+
+func isMetaFile(name string) bool {
+	return strings.HasPrefix(filepath.Base(name), ".")
+}
+
+// ErrNameKeyMismatch is returned by [Open] when the key for hashed file names
+// cannot be decrypted with the configured encryption keys.
+var ErrNameKeyMismatch = errors.New("fscache: name key cannot be decrypted with the configured keys")
+
+var (
+	errPayloadKeyMismatch = errors.New("fscache: entry belongs to another key")
+	errUnreadableEntry    = errors.New("fscache: entry cannot be decrypted or decoded")
+)
+
+// hmacFileNamer returns a fileNamer that names files after the keyed hash of
+// the key, so that keys cannot be recovered from the directory listing.
+func hmacFileNamer(nameKey []byte) fileNamer {
+	return fileNamerFunc(func(key string) string {
+		mac := hmac.New(sha256.New, nameKey)
+		mac.Write([]byte(key))
+		return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	})
+}
+
+// payloadMagic prefixes entries that carry their key, which is stored in the
+// file content when file names are hashed. It is followed by the uvarint
+// length of the key, the key, and the value.
+var payloadMagic = []byte("fsn1")
+
+func encodePayload(key string, value []byte) []byte {
+	return append(payloadHeader(key, len(value)), value...)
+}
+
+// payloadHeader returns the header that precedes the value in a payload, with
+// room for n bytes of value.
+func payloadHeader(key string, n int) []byte {
+	buf := make([]byte, 0, len(payloadMagic)+binary.MaxVarintLen64+len(key)+n)
+	buf = append(buf, payloadMagic...)
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	return append(buf, key...)
+}
+
+// maxPayloadKey bounds the key length readPayloadHeader accepts, so that a
+// corrupt header cannot make it allocate without bound.
+const maxPayloadKey = 1 << 20
+
+// readPayloadHeader reads the header of a payload from r, leaving r at the
+// start of the value, and returns the key.
+func readPayloadHeader(r *bufio.Reader) (string, error) {
+	magic := make([]byte, len(payloadMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, payloadMagic) {
+		return "", errors.New("fscache: entry has no key header")
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > maxPayloadKey {
+		return "", errors.New("fscache: malformed key header")
+	}
+	key := make([]byte, n)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return "", errors.New("fscache: malformed key header")
+	}
+	return string(key), nil
+}
+
+func decodePayload(data []byte) (key string, value []byte, err error) {
+	rest, ok := bytes.CutPrefix(data, payloadMagic)
+	if !ok {
+		return "", nil, errors.New("fscache: entry has no key header")
+	}
+	n, size := binary.Uvarint(rest)
+	if size <= 0 || n > uint64(len(rest)-size) {
+		return "", nil, errors.New("fscache: malformed key header")
+	}
+	rest = rest[size:]
+	return string(rest[:n]), rest[n:], nil
+}
+
+// loadNameKey returns the key used to hash file names, creating it if the
+// cache directory does not have one yet.
+//
+// If the existing key cannot be decrypted, the encryption key was replaced
+// rather than rotated, or the key ring is misconfigured; [ErrNameKeyMismatch]
+// is returned rather than orphaning every entry under a new key.
+func (c *fsCache) loadNameKey() ([]byte, error) {
+	for {
+		data, err := c.root.ReadFile(nameKeyFile)
+		if err == nil {
+			key, ok := c.openNameKey(data)
+			if !ok {
+				return nil, ErrNameKeyMismatch
+			}
+			return key, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		key := make([]byte, sha256.Size)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		data = key
+		if c.enc != nil {
+			if data, err = c.enc.Encrypt(key); err != nil {
+				return nil, err
+			}
+		}
+		// Another process may create the key concurrently; the first one wins.
+		err = c.writeNameKey(data)
+		if err == nil {
+			return key, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+	}
+}
+
+func (c *fsCache) writeNameKey(data []byte) error {
+	unlock, err := c.wlocks.lock(c, nameKeyFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return c.writeFileIf(nameKeyFile, data, func(string) error {
+		if _, err := c.root.Stat(nameKeyFile); err == nil {
+			return fs.ErrExist
+		}
+		return nil
+	})
+}
+
+func (c *fsCache) openNameKey(data []byte) ([]byte, bool) {
+	if c.enc != nil {
+		var err error
+		if data, err = c.enc.Decrypt(data); err != nil {
+			return nil, false
+		}
+	}
+	return data, len(data) == sha256.Size
+}
+
+// readKey returns the key stored in the named entry file.
+func (c *fsCache) readKey(name string) (string, error) {
+	data, err := c.root.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	if c.enc != nil {
+		if data, err = c.enc.Decrypt(data); err != nil {
+			return "", errors.Join(errUnreadableEntry, err)
+		}
+	}
+	key, _, err := decodePayload(data)
+	if err != nil {
+		return "", errors.Join(errUnreadableEntry, err)
+	}
+	return key, nil
+}
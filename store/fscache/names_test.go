@@ -0,0 +1,193 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+func TestFSCache_Acceptance_HashedNames(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		cache, err := Open("testapp", WithBaseDir(t.TempDir()), WithHashedNames(true))
+		testutil.RequireNoError(t, err, "Failed to create fscache")
+		return cache, func() { cache.Close() }
+	}))
+}
+
+func Test_encodePayload(t *testing.T) {
+	for _, key := range []string{"", "k", strings.Repeat("k", 300)} {
+		data := encodePayload(key, []byte("value"))
+		gotKey, gotValue, err := decodePayload(data)
+		testutil.RequireNoError(t, err)
+		testutil.AssertEqual(t, key, gotKey)
+		testutil.AssertEqual(t, "value", string(gotValue))
+
+		r := bufio.NewReader(bytes.NewReader(data))
+		gotKey, err = readPayloadHeader(r)
+		testutil.RequireNoError(t, err)
+		testutil.AssertEqual(t, key, gotKey)
+		gotValue, err = io.ReadAll(r)
+		testutil.RequireNoError(t, err)
+		testutil.AssertEqual(t, "value", string(gotValue))
+	}
+	for _, data := range [][]byte{
+		nil,
+		[]byte("value"),
+		append(bytes.Clone(payloadMagic), 0x80), // truncated uvarint
+		append(bytes.Clone(payloadMagic), 10, 'k'), // key longer than data
+	} {
+		_, _, err := decodePayload(data)
+		testutil.RequireError(t, err)
+		_, err = readPayloadHeader(bufio.NewReader(bytes.NewReader(data)))
+		testutil.RequireError(t, err)
+	}
+}
+
+// entryFiles returns the names of the entry files in the cache directory.
+func entryFiles(t *testing.T, c *fsCache) []string {
+	t.Helper()
+	var names []string
+	err := fs.WalkDir(c.root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && !isMetaFile(path) {
+			names = append(names, path)
+		}
+		return err
+	})
+	testutil.RequireNoError(t, err)
+	return names
+}
+
+func Test_fsCache_HashedNames(t *testing.T) {
+	const key = "https://example.com/?token=secret#123"
+	tests := []struct {
+		name string
+		opts []Option
+		want bool
+	}{
+		{"Default", nil, false},
+		{"Encrypted", []Option{WithKeyProvider(StaticKeys(mustKey(t, "k")))}, true},
+		{"EncryptedOptOut", []Option{WithKeyProvider(StaticKeys(mustKey(t, "k"))), WithHashedNames(false)}, false},
+		{"Plain", []Option{WithHashedNames(true)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := Open("testapp", append([]Option{WithBaseDir(t.TempDir())}, tt.opts...)...)
+			testutil.RequireNoError(t, err)
+			t.Cleanup(func() { cache.Close() })
+			testutil.AssertTrue(t, cache.hashNames == tt.want)
+
+			testutil.RequireNoError(t, cache.Set(key, []byte("value")))
+			files := entryFiles(t, cache)
+			testutil.RequireTrue(t, len(files) == 1)
+			encoded := base64.RawURLEncoding.EncodeToString([]byte(key))
+			testutil.AssertTrue(t, (files[0] == encoded) != tt.want, "unexpected file name %q", files[0])
+
+			got, err := cache.Get(key)
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, "value", string(got))
+			testutil.AssertTrue(t, slices.Equal(sortedKeys(t, cache), []string{key}))
+		})
+	}
+}
+
+func Test_fsCache_HashedNames_KeyMismatch(t *testing.T) {
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()), WithHashedNames(true))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	testutil.RequireNoError(t, cache.Set("a", []byte("1")))
+	data, err := cache.root.ReadFile(cache.fn.FileName("a"))
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, cache.root.WriteFile(cache.fn.FileName("b"), data, 0o644))
+
+	_, err = cache.Get("b")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	_, err = cache.OpenReader("b")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+}
+
+func Test_fsCache_MigrateNames(t *testing.T) {
+	base := t.TempDir()
+	key := mustKey(t, "k")
+	long := "https://example.com/" + strings.Repeat("a", 300)
+
+	cache, err := Open("testapp", WithBaseDir(base),
+		WithKeyProvider(StaticKeys(key)), WithHashedNames(false))
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, cache.Set("a", []byte("old")))
+	testutil.RequireNoError(t, cache.Set("b", []byte("old")))
+	testutil.RequireNoError(t, cache.Set(long, []byte("old")))
+	testutil.RequireNoError(t, cache.Close())
+
+	cache, err = Open("testapp", WithBaseDir(base), WithKeyProvider(StaticKeys(key)))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	// Pretend "b" was set after the cache was opened, before migration.
+	cache.bg.Wait()
+	testutil.RequireNoError(t, cache.root.Remove(layoutFile))
+	testutil.RequireNoError(t, cache.Set("b", []byte("new")))
+	testutil.RequireNoError(t, cache.root.WriteFile(
+		fragmentFileName("b"), mustEncrypt(t, cache, []byte("stale")), 0o644))
+	_, err = cache.MigrateLayout(t.Context())
+	testutil.RequireNoError(t, err)
+
+	for k, want := range map[string]string{"a": "old", "b": "new", long: "old"} {
+		got, err := cache.Get(k)
+		testutil.RequireNoError(t, err)
+		testutil.AssertEqual(t, want, string(got), "key %q", k)
+	}
+	testutil.AssertEqual(t, 3, len(entryFiles(t, cache)), "legacy files left behind")
+	testutil.AssertTrue(t, slices.Equal(sortedKeys(t, cache), []string{"a", "b", long}))
+	_, err = cache.root.Stat(layoutFile)
+	testutil.RequireNoError(t, err)
+}
+
+func mustEncrypt(t *testing.T, c *fsCache, data []byte) []byte {
+	t.Helper()
+	data, err := c.enc.Encrypt(data)
+	testutil.RequireNoError(t, err)
+	return data
+}
+
+func Test_fsCache_loadNameKey(t *testing.T) {
+	base := t.TempDir()
+	key := mustKey(t, "a")
+	open := func(keys ...Key) string {
+		t.Helper()
+		cache, err := Open("testapp", WithBaseDir(base), WithKeyProvider(StaticKeys(keys...)))
+		testutil.RequireNoError(t, err)
+		defer cache.Close()
+		return cache.fn.FileName("key")
+	}
+
+	name := open(key)
+	testutil.AssertEqual(t, name, open(key), "name key not reused")
+	testutil.AssertEqual(t, name, open(mustKey(t, "b"), key), "name key lost on rotation")
+
+	// A replaced key is an error, rather than a reason to start over.
+	_, err := Open("testapp", WithBaseDir(base), WithKeyProvider(StaticKeys(mustKey(t, "c"))))
+	testutil.RequireErrorIs(t, err, ErrNameKeyMismatch)
+	testutil.AssertEqual(t, name, open(key), "name key replaced by a failed open")
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var (
+	errEncryptionDisabled = errors.New("fscache: encryption is not enabled")
+	errEntryChanged       = errors.New("fscache: entry changed concurrently")
+)
+
+// Reencrypt rewrites every entry that is not encrypted with the active key,
+// along with the key used for hashed file names, so that older keys can be
+// removed from the key ring afterwards. It returns the number of entries
+// rewritten. Entries that no key in the ring can decrypt are left in place.
+//
+// Rewritten entries keep their modification time, so re-encryption does not
+// affect eviction order. An entry replaced by a concurrent Set, in this or
+// another process, is skipped.
+func (c *fsCache) Reencrypt(ctx context.Context) (int, error) {
+	kr, ok := c.enc.(*keyRing)
+	if !ok {
+		return 0, errEncryptionDisabled
+	}
+	var n int
+	err := fs.WalkDir(c.root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || isTempFile(path) {
+			return nil
+		}
+		rewritten, err := c.reencryptFile(kr, filepath.FromSlash(path))
+		if err != nil {
+			return err
+		}
+		if rewritten && !isMetaFile(path) {
+			n++
+		}
+		return nil
+	})
+	if err == nil && c.idx != nil {
+		// Rewrite the index log, which holds keys, with the active key too.
+		err = c.idx.update(c, func() error { return c.idx.compact(c) })
+	}
+	return n, err
+}
+
+// reencryptFile rewrites the named file with the active key if it was
+// encrypted with another one, and reports whether it did.
+func (c *fsCache) reencryptFile(kr *keyRing, name string) (bool, error) {
+	info, err := c.root.Stat(name)
+	if err != nil {
+		return false, ignoreNotExist(err)
+	}
+	data, err := c.root.ReadFile(name)
+	if err != nil {
+		return false, ignoreNotExist(err)
+	}
+	plaintext, active, err := kr.open(data)
+	if err != nil || active {
+		return false, nil //nolint:nilerr // Undecryptable entries are left in place.
+	}
+	ciphertext, err := kr.Encrypt(plaintext)
+	if err != nil {
+		return false, err
+	}
+	unlock, err := c.wlocks.lock(c, name)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	err = c.writeFileIf(name, ciphertext, func(tmp string) error {
+		if err := c.root.Chtimes(tmp, zeroTime, info.ModTime()); err != nil {
+			return err
+		}
+		cur, err := c.root.Stat(name)
+		if err != nil {
+			return err
+		}
+		if !cur.ModTime().Equal(info.ModTime()) || cur.Size() != info.Size() {
+			return errEntryChanged
+		}
+		return nil
+	})
+	if errors.Is(err, errEntryChanged) {
+		return false, nil
+	}
+	if err != nil {
+		return false, ignoreNotExist(err)
+	}
+	if c.idx != nil {
+		if err := c.idx.resize(c, name, int64(len(ciphertext))); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func ignoreNotExist(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
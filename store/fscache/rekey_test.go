@@ -0,0 +1,106 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+func Test_fsCache_Reencrypt(t *testing.T) {
+	base := t.TempDir()
+	oldKey, newKey := mustKey(t, "old"), mustKey(t, "new")
+
+	cache, err := Open("testapp", WithBaseDir(base), WithKeyProvider(StaticKeys(oldKey)))
+	testutil.RequireNoError(t, err)
+	for i := range 3 {
+		testutil.RequireNoError(t, cache.Set(fmt.Sprintf("key%d", i), []byte("value")))
+	}
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	name := cache.fn.FileName("key0")
+	testutil.RequireNoError(t, cache.root.Chtimes(name, zeroTime, mtime))
+	testutil.RequireNoError(t, cache.Close())
+
+	cache, err = Open("testapp", WithBaseDir(base), WithKeyProvider(StaticKeys(newKey, oldKey)))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	testutil.RequireNoError(t, cache.Set("key3", []byte("value")))
+
+	n, err := cache.Reencrypt(context.Background())
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 3, n)
+	info, err := cache.root.Stat(name)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, info.ModTime().Equal(mtime), "modification time not preserved")
+
+	n, err = cache.Reencrypt(context.Background())
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 0, n, "second pass should find nothing to do")
+
+	// The old key can now be dropped.
+	cache.enc, err = newKeyRing(rand.Reader, []Key{newKey})
+	testutil.RequireNoError(t, err)
+	for i := range 4 {
+		got, err := cache.Get(fmt.Sprintf("key%d", i))
+		testutil.RequireNoError(t, err)
+		testutil.AssertEqual(t, "value", string(got))
+	}
+}
+
+func Test_fsCache_Reencrypt_Background(t *testing.T) {
+	base := t.TempDir()
+	oldKey, newKey := mustKey(t, "old"), mustKey(t, "new")
+	cache, err := Open("testapp", WithBaseDir(base), WithKeyProvider(StaticKeys(oldKey)))
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, cache.Set("key", []byte("value")))
+	testutil.RequireNoError(t, cache.Close())
+
+	cache, err = Open("testapp", WithBaseDir(base),
+		WithKeyProvider(StaticKeys(newKey, oldKey)), WithReencrypt(true))
+	testutil.RequireNoError(t, err)
+	cache.bg.Wait()
+	kr := cache.enc.(*keyRing)
+	data, err := cache.root.ReadFile(cache.fn.FileName("key"))
+	testutil.RequireNoError(t, err)
+	_, active, err := kr.open(data)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, active, "entry was not re-encrypted")
+	testutil.RequireNoError(t, cache.Close())
+}
+
+func Test_fsCache_Reencrypt_Errors(t *testing.T) {
+	t.Run("EncryptionDisabled", func(t *testing.T) {
+		cache, err := Open("testapp", WithBaseDir(t.TempDir()))
+		testutil.RequireNoError(t, err)
+		t.Cleanup(func() { cache.Close() })
+		_, err = cache.Reencrypt(context.Background())
+		testutil.RequireErrorIs(t, err, errEncryptionDisabled)
+	})
+	t.Run("ContextCanceled", func(t *testing.T) {
+		cache, err := Open("testapp", WithBaseDir(t.TempDir()),
+			WithKeyProvider(StaticKeys(mustKey(t, "k"))))
+		testutil.RequireNoError(t, err)
+		t.Cleanup(func() { cache.Close() })
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = cache.Reencrypt(ctx)
+		testutil.RequireErrorIs(t, err, context.Canceled)
+	})
+}
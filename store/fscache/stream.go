@@ -0,0 +1,213 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+// OpenReader opens the entry for key for reading; see [driver.Streamer]. The
+// entry is read from disk as the reader is consumed, unless encryption is
+// enabled, in which case it is decrypted in memory first. The entry is not
+// evicted while the reader is open.
+func (c *fsCache) OpenReader(key string) (io.ReadCloser, error) {
+	if c.enc != nil {
+		data, err := c.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	r, err := c.openReader(key)
+	if err != nil {
+		return nil, &Error{"OpenReader", key, err}
+	}
+	return r, nil
+}
+
+func (c *fsCache) openReader(key string) (_ io.ReadCloser, err error) {
+	name := c.fn.FileName(key)
+	if c.ev != nil {
+		c.ev.beginRead(name)
+		defer func() {
+			if err != nil {
+				c.ev.endRead(name, false)
+			}
+		}()
+	}
+	f, path, err := c.openEntry(key, name)
+	if err != nil {
+		return nil, err
+	}
+	r := &entryReader{c: c, f: f, name: name, r: f}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+		}
+	}()
+	if c.hashNames {
+		br := bufio.NewReader(f)
+		k, err := readPayloadHeader(br)
+		if err != nil {
+			return nil, err
+		}
+		if k != key {
+			return nil, errors.Join(driver.ErrNotExist, errPayloadKeyMismatch)
+		}
+		r.r = br
+	}
+	if err := c.touch(key, path); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// entryReader reads the value of an entry file.
+type entryReader struct {
+	c    *fsCache
+	f    *os.File
+	name string    // entry file name, for eviction accounting
+	r    io.Reader // reads the value from f
+	once sync.Once
+}
+
+func (r *entryReader) Read(p []byte) (int, error) { return r.r.Read(p) }
+
+func (r *entryReader) Close() error {
+	err := os.ErrClosed
+	r.once.Do(func() {
+		err = r.f.Close()
+		if r.c.ev != nil {
+			r.c.ev.endRead(r.name, true)
+		}
+	})
+	return err
+}
+
+// OpenWriter opens a writer for the entry for key; see [driver.Streamer]. The
+// value is written to a temporary file, which replaces the entry on Close,
+// unless encryption is enabled, in which case it is buffered in memory and
+// encrypted on Close.
+func (c *fsCache) OpenWriter(key string) (io.WriteCloser, error) {
+	if c.enc != nil {
+		return &bufferedWriter{c: c, key: key}, nil
+	}
+	name := c.fn.FileName(key)
+	t, err := c.createTemp(name)
+	if err != nil {
+		return nil, &Error{"OpenWriter", key, err}
+	}
+	w := &entryWriter{c: c, t: t, key: key}
+	if c.hashNames {
+		if _, err := w.Write(payloadHeader(key, 0)); err != nil {
+			t.abort()
+			return nil, &Error{"OpenWriter", key, err}
+		}
+	}
+	return w, nil
+}
+
+// entryWriter writes an entry to a temporary file, which replaces the entry
+// file once closed.
+type entryWriter struct {
+	c    *fsCache
+	t    *tempFile
+	key  string
+	size int64
+	err  error // first write error; sticky
+	done bool
+}
+
+func (w *entryWriter) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, os.ErrClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.t.f.Write(p)
+	w.size += int64(n)
+	w.err = err
+	return n, err
+}
+
+func (w *entryWriter) Close() error {
+	if w.done {
+		return os.ErrClosed
+	}
+	w.done = true
+	if w.err != nil {
+		w.t.abort()
+		return &Error{"OpenWriter", w.key, w.err}
+	}
+	if err := w.commit(); err != nil {
+		return &Error{"OpenWriter", w.key, err}
+	}
+	return nil
+}
+
+func (w *entryWriter) commit() error {
+	c, name := w.c, w.t.name
+	unlock, err := c.wlocks.lock(c, name)
+	if err != nil {
+		w.t.abort()
+		return err
+	}
+	defer unlock()
+	if err := w.t.commit(nil); err != nil {
+		return err
+	}
+	if c.idx != nil {
+		if err := c.idx.put(c, w.key, name, w.size, time.Now()); err != nil {
+			return err
+		}
+	}
+	if c.ev != nil {
+		c.ev.written(name, w.size)
+	}
+	return nil
+}
+
+// bufferedWriter buffers a value in memory, and stores it on Close.
+type bufferedWriter struct {
+	c    *fsCache
+	key  string
+	buf  bytes.Buffer
+	done bool
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, os.ErrClosed
+	}
+	return w.buf.Write(p)
+}
+
+func (w *bufferedWriter) Close() error {
+	if w.done {
+		return os.ErrClosed
+	}
+	w.done = true
+	return w.c.SetContext(context.Background(), w.key, w.buf.Bytes())
+}
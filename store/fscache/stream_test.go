@@ -0,0 +1,110 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fscache
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+func writeStream(t *testing.T, c *fsCache, key string, value []byte) {
+	t.Helper()
+	w, err := c.OpenWriter(key)
+	testutil.RequireNoError(t, err)
+	_, err = w.Write(value)
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, w.Close())
+}
+
+func readStream(t *testing.T, c *fsCache, key string) []byte {
+	t.Helper()
+	r, err := c.OpenReader(key)
+	testutil.RequireNoError(t, err)
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	testutil.RequireNoError(t, err)
+	return data
+}
+
+func Test_fsCache_Stream_Encrypted(t *testing.T) {
+	cache, err := Open("testapp", WithBaseDir(t.TempDir()),
+		WithEncryption("6S-Ks2YYOW0xMvTzKSv6QD30gZeOi1c6Ydr-As5csWk="))
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+
+	value := []byte("super secret value")
+	writeStream(t, cache, "k", value)
+	testutil.AssertTrue(t, bytes.Equal(value, readStream(t, cache, "k")), "decrypted value mismatch")
+
+	ciphertext, err := cache.root.ReadFile(cache.fn.FileName("k"))
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, !bytes.Contains(ciphertext, value), "ciphertext should not contain plaintext")
+}
+
+func Test_fsCache_OpenWriter_WriteError(t *testing.T) {
+	cache := openManualEvict(t, 0, 1)
+	testutil.RequireNoError(t, cache.Set("k", []byte("old")))
+
+	w, err := cache.OpenWriter("k")
+	testutil.RequireNoError(t, err)
+	_ = w.(*entryWriter).t.f.Close() // make the next write fail
+	_, err = w.Write([]byte("new"))
+	testutil.RequireError(t, err)
+	_, err = w.Write([]byte("more"))
+	testutil.RequireError(t, err, "write error not sticky")
+
+	err = w.Close()
+	var ferr *Error
+	testutil.RequireErrorAs(t, err, &ferr)
+	testutil.RequireErrorIs(t, w.Close(), os.ErrClosed)
+
+	got, err := cache.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "old", string(got), "failed write replaced the entry")
+	testutil.AssertEqual(t, 0, len(tempFiles(t, cache)), "temporary files left behind")
+}
+
+func Test_fsCache_OpenReader(t *testing.T) {
+	cache := openManualEvict(t, 0, 1)
+	value := bytes.Repeat([]byte("v"), 64<<10)
+	writeStream(t, cache, "a", value)
+
+	r, err := cache.OpenReader("a")
+	testutil.RequireNoError(t, err)
+
+	// The entry being read is neither replaced under the reader nor evicted.
+	writeStream(t, cache, "a", []byte("new"))
+	testutil.RequireNoError(t, cache.Set("b", []byte("2")))
+	testutil.RequireNoError(t, cache.evict())
+	got, err := io.ReadAll(r)
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, bytes.Equal(got, value), "reader saw a replaced value")
+	testutil.RequireNoError(t, r.Close())
+	testutil.RequireErrorIs(t, r.Close(), os.ErrClosed)
+	keys := sortedKeys(t, cache)
+	testutil.AssertTrue(t, slices.Equal(keys, []string{"a"}), "got %v", keys)
+	testutil.AssertEqual(t, "new", string(readStream(t, cache, "a")))
+
+	_, err = cache.OpenReader("missing")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	var ferr *Error
+	testutil.RequireErrorAs(t, err, &ferr)
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bytesize
+
+import (
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"10b", 10, false},
+		{"64KiB", 64 << 10, false},
+		{"256MiB", 256 << 20, false},
+		{"2gib", 2 << 30, false},
+		{"1TiB", 1 << 40, false},
+		{"5KB", 5000, false},
+		{"1.5GB", 1_500_000_000, false},
+		{" 3 MB ", 3_000_000, false},
+		{"", 0, true},
+		{"MiB", 0, true},
+		{"-1", 0, true},
+		{"12XB", 0, true},
+		{"1.2.3", 0, true},
+		{"9223372036854775807KiB", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr {
+				testutil.RequireErrorIs(t, err, ErrInvalidSize)
+				return
+			}
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, tt.want, got)
+		})
+	}
+}
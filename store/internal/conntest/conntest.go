@@ -0,0 +1,58 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conntest provides caches for testing which optional interfaces a
+// driver forwards from the caches it wraps.
+package conntest
+
+import (
+	"errors"
+	"io"
+
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+// Plain returns a cache that hides the optional interfaces of conn.
+func Plain(conn driver.Conn) driver.Conn { return plain{conn} }
+
+type plain struct{ driver.Conn }
+
+// Streaming returns a cache that claims to stream values, but fails to open
+// them.
+func Streaming(conn driver.Conn) driver.Conn { return streaming{conn} }
+
+type streaming struct{ driver.Conn }
+
+var _ driver.Streamer = streaming{}
+
+func (streaming) OpenReader(string) (io.ReadCloser, error)  { return nil, errors.ErrUnsupported }
+func (streaming) OpenWriter(string) (io.WriteCloser, error) { return nil, errors.ErrUnsupported }
+
+// Locking returns a cache that records the keys it is asked to lock in locked.
+// Its locks do not exclude each other.
+func Locking(conn driver.Conn, locked *[]string) driver.Conn {
+	return locking{conn, locked}
+}
+
+type locking struct {
+	driver.Conn
+	locked *[]string
+}
+
+var _ driver.Locker = locking{}
+
+func (l locking) Lock(key string) (func(), error) {
+	*l.locked = append(*l.locked, key)
+	return func() {}, nil
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keylock serializes callers by key within a process, for wrapper
+// drivers whose backend is not a driver.Locker.
+package keylock
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// numStripes is the number of locks that keys are spread over.
+const numStripes = 64
+
+// Stripes spreads keys over a fixed set of locks. The zero value is ready
+// to use.
+type Stripes [numStripes]sync.Mutex
+
+// Lock acquires the lock for key, and returns a function that releases it.
+func (s *Stripes) Lock(key string) func() {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	mu := &s[h.Sum32()%numStripes]
+	mu.Lock()
+	return mu.Unlock
+}
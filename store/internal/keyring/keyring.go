@@ -0,0 +1,102 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keyring parses the encryption key rings of the drivers that
+// encrypt values, in the formats accepted by their DSNs.
+package keyring
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Key is an AES key.
+type Key struct {
+	// ID identifies the key in the ciphertexts it produces, so that they can
+	// be decrypted after the key is rotated. If empty, an ID is derived from
+	// Secret.
+	ID string
+	// Secret is a 16, 24 or 32 byte key, selecting AES-128, AES-192 or
+	// AES-256.
+	Secret []byte
+}
+
+// ID returns the ID of k, derived from its secret if k has none.
+func ID(k Key) string {
+	if k.ID != "" {
+		return k.ID
+	}
+	sum := sha256.Sum256(k.Secret)
+	return hex.EncodeToString(sum[:4])
+}
+
+// Parse parses a key of the form "[id:]secret", where secret is base64
+// encoded (RFC 4648 §5, URL-safe variant).
+func Parse(s string) (Key, error) {
+	id, secret, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		id, secret = "", id
+	}
+	b, err := base64.URLEncoding.DecodeString(secret)
+	if err != nil {
+		return Key{}, fmt.Errorf("invalid key: %w", err)
+	}
+	return Key{ID: id, Secret: b}, nil
+}
+
+// ParseList parses a comma-separated list of keys in the format accepted by
+// [Parse].
+func ParseList(s string) ([]Key, error) {
+	var keys []Key
+	for part := range strings.SplitSeq(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		k, err := Parse(part)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// ReadFile reads keys, one per line in the format accepted by [Parse], from
+// the named file. Blank lines and lines starting with '#' are ignored.
+func ReadFile(name string) ([]Key, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var keys []Key
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		k, err := Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, sc.Err()
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyring
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+func TestParseList(t *testing.T) {
+	secret := base64.URLEncoding.EncodeToString(make([]byte, 32))
+	tests := []struct {
+		in      string
+		wantIDs []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{secret, []string{"66687aad"}, false},
+		{"new:" + secret + ", ,old:" + secret, []string{"new", "old"}, false},
+		{"new:not base64!", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			keys, err := ParseList(tt.in)
+			if tt.wantErr {
+				testutil.RequireError(t, err)
+				return
+			}
+			testutil.RequireNoError(t, err)
+			testutil.RequireTrue(t, len(keys) == len(tt.wantIDs))
+			for i, k := range keys {
+				testutil.AssertEqual(t, tt.wantIDs[i], ID(k))
+				testutil.AssertEqual(t, 32, len(k.Secret))
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	secret := base64.URLEncoding.EncodeToString(make([]byte, 16))
+	path := filepath.Join(t.TempDir(), "keys")
+	testutil.RequireNoError(t, os.WriteFile(path, []byte("# rotated 2026-07\nnew:"+secret+"\n\nold:"+secret+"\n"), 0o600))
+	keys, err := ReadFile(path)
+	testutil.RequireNoError(t, err)
+	testutil.RequireTrue(t, len(keys) == 2)
+	testutil.AssertEqual(t, "new", keys[0].ID)
+	testutil.AssertEqual(t, "old", keys[1].ID)
+
+	testutil.RequireNoError(t, os.WriteFile(path, []byte(secret+"\n!\n"), 0o600))
+	_, err = ReadFile(path)
+	testutil.RequireError(t, err)
+	testutil.AssertTrue(t, strings.HasPrefix(err.Error(), path+":2: "), "error %q has no line number", err)
+
+	_, err = ReadFile(filepath.Join(t.TempDir(), "missing"))
+	testutil.RequireErrorIs(t, err, os.ErrNotExist)
+}
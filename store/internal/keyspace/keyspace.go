@@ -0,0 +1,284 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keyspace confines the keys of a cache to a namespace of another
+// cache. It implements the namespace driver and [store.WithNamespace].
+//
+// [store.WithNamespace]: https://pkg.go.dev/github.com/bartventer/httpcache/store#WithNamespace
+package keyspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+	"github.com/bartventer/httpcache/store/internal/keylock"
+)
+
+var ErrInvalidNamespace = errors.New("namespace: invalid namespace")
+
+// Separator ends the namespace in a stored key.
+const Separator = ":"
+
+// Validate reports whether ns can be used as a namespace. Besides the
+// separator, it rejects the URL schemes of the keys httpcache stores, since
+// "https:" + "//example.com/" is also the un-namespaced key of a URL.
+func Validate(ns string) error {
+	if ns == "" || strings.Contains(ns, Separator) {
+		return fmt.Errorf("%w %q: must be non-empty and cannot contain %q", ErrInvalidNamespace, ns, Separator)
+	}
+	if strings.EqualFold(ns, "http") || strings.EqualFold(ns, "https") {
+		return fmt.Errorf("%w %q: overlaps the keys of URLs", ErrInvalidNamespace, ns)
+	}
+	return nil
+}
+
+// New returns a cache that stores its keys in namespace ns of conn. The
+// returned Conn implements [driver.ConnContext], [driver.Batcher],
+// [driver.Locker], [expapi.KeyLister] and [Clearer] if conn implements
+// [expapi.KeyLister], [driver.TTLSetter] and [driver.Streamer] if conn does,
+// and [io.Closer]. Closing it does not close conn, which may be shared by
+// other namespaces.
+func New(conn driver.Conn, ns string) (driver.Conn, error) {
+	return newCache(conn, ns, false)
+}
+
+// Adopt is like [New], but the returned cache owns conn: closing it closes
+// conn if it is an [io.Closer].
+func Adopt(conn driver.Conn, ns string) (driver.Conn, error) {
+	return newCache(conn, ns, true)
+}
+
+func newCache(conn driver.Conn, ns string, owned bool) (driver.Conn, error) {
+	if err := Validate(ns); err != nil {
+		return nil, err
+	}
+	c := &namespaceCache{
+		conn:   conn,
+		cc:     driver.AsConnContext(conn),
+		b:      driver.AsBatcher(conn),
+		prefix: ns + Separator,
+		owned:  owned,
+	}
+	kl, _ := conn.(expapi.KeyLister)
+	ts, _ := conn.(driver.TTLSetter)
+	st, _ := conn.(driver.Streamer)
+	l, e, s := lister{c, kl}, expirer{c, ts}, streamer{c, st}
+	switch {
+	case kl != nil && ts != nil && st != nil:
+		return &struct {
+			*namespaceCache
+			lister
+			expirer
+			streamer
+		}{c, l, e, s}, nil
+	case kl != nil && ts != nil:
+		return &struct {
+			*namespaceCache
+			lister
+			expirer
+		}{c, l, e}, nil
+	case kl != nil && st != nil:
+		return &struct {
+			*namespaceCache
+			lister
+			streamer
+		}{c, l, s}, nil
+	case ts != nil && st != nil:
+		return &struct {
+			*namespaceCache
+			expirer
+			streamer
+		}{c, e, s}, nil
+	case kl != nil:
+		return &struct {
+			*namespaceCache
+			lister
+		}{c, l}, nil
+	case ts != nil:
+		return &struct {
+			*namespaceCache
+			expirer
+		}{c, e}, nil
+	case st != nil:
+		return &struct {
+			*namespaceCache
+			streamer
+		}{c, s}, nil
+	}
+	return c, nil
+}
+
+// Clearer is implemented by the caches returned by [New] that can delete
+// all the keys of their namespace.
+type Clearer interface {
+	Clear() error
+}
+
+var (
+	_ driver.Conn        = (*namespaceCache)(nil)
+	_ driver.ConnContext = (*namespaceCache)(nil)
+	_ driver.Batcher     = (*namespaceCache)(nil)
+	_ driver.Locker      = (*namespaceCache)(nil)
+	_ io.Closer          = (*namespaceCache)(nil)
+	_ expapi.KeyLister   = lister{}
+	_ Clearer            = lister{}
+	_ driver.TTLSetter   = expirer{}
+	_ driver.Streamer    = streamer{}
+)
+
+type namespaceCache struct {
+	conn   driver.Conn
+	cc     driver.ConnContext // conn, or an adapter if it does not support cancellation
+	b      driver.Batcher     // conn, or an adapter if it does not support batching
+	prefix string             // namespace and separator
+	owned  bool               // whether Close closes conn
+	klocks keylock.Stripes    // back Lock when conn is not a driver.Locker
+}
+
+func (c *namespaceCache) Get(key string) ([]byte, error) { return c.conn.Get(c.prefix + key) }
+
+func (c *namespaceCache) Set(key string, value []byte) error {
+	return c.conn.Set(c.prefix+key, value)
+}
+
+func (c *namespaceCache) Delete(key string) error { return c.conn.Delete(c.prefix + key) }
+
+func (c *namespaceCache) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return c.cc.GetContext(ctx, c.prefix+key)
+}
+
+func (c *namespaceCache) SetContext(ctx context.Context, key string, value []byte) error {
+	return c.cc.SetContext(ctx, c.prefix+key, value)
+}
+
+func (c *namespaceCache) DeleteContext(ctx context.Context, key string) error {
+	return c.cc.DeleteContext(ctx, c.prefix+key)
+}
+
+// keys returns keys in the namespace.
+func (c *namespaceCache) keys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+	return prefixed
+}
+
+func (c *namespaceCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values, err := c.b.GetMulti(ctx, c.keys(keys))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]byte, len(values))
+	for key, value := range values {
+		result[strings.TrimPrefix(key, c.prefix)] = value
+	}
+	return result, nil
+}
+
+func (c *namespaceCache) SetMulti(ctx context.Context, values map[string][]byte) error {
+	prefixed := make(map[string][]byte, len(values))
+	for key, value := range values {
+		prefixed[c.prefix+key] = value
+	}
+	return c.b.SetMulti(ctx, prefixed)
+}
+
+func (c *namespaceCache) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.b.DeleteMulti(ctx, c.keys(keys))
+}
+
+// Lock acquires the lock for key in the namespace from conn if it is a
+// [driver.Locker], so that it is shared with other users of conn; otherwise it
+// only serializes callers within this process.
+func (c *namespaceCache) Lock(key string) (func(), error) {
+	if l, ok := c.conn.(driver.Locker); ok {
+		return l.Lock(c.prefix + key)
+	}
+	return c.klocks.Lock(key), nil
+}
+
+// Close closes the backend if the cache owns it and it is an [io.Closer].
+func (c *namespaceCache) Close() error {
+	if !c.owned {
+		return nil
+	}
+	if closer, ok := c.conn.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// lister lists the keys of a namespace whose backend can list its keys.
+type lister struct {
+	c  *namespaceCache
+	kl expapi.KeyLister
+}
+
+func (l lister) Keys(prefix string) ([]string, error) {
+	keys, err := l.kl.Keys(l.c.prefix + prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = strings.TrimPrefix(key, l.c.prefix)
+	}
+	return names, nil
+}
+
+// Clear deletes every key in the namespace.
+func (l lister) Clear() error {
+	keys, err := l.kl.Keys(l.c.prefix)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, key := range keys {
+		if err := l.c.conn.Delete(key); err != nil && !errors.Is(err, driver.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expirer stores values in a namespace whose backend can expire them.
+type expirer struct {
+	c  *namespaceCache
+	ts driver.TTLSetter
+}
+
+func (e expirer) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return e.ts.SetWithTTL(ctx, e.c.prefix+key, value, ttl)
+}
+
+// streamer streams values in a namespace whose backend can stream them.
+type streamer struct {
+	c *namespaceCache
+	s driver.Streamer
+}
+
+func (s streamer) OpenReader(key string) (io.ReadCloser, error) {
+	return s.s.OpenReader(s.c.prefix + key)
+}
+
+func (s streamer) OpenWriter(key string) (io.WriteCloser, error) {
+	return s.s.OpenWriter(s.c.prefix + key)
+}
@@ -21,6 +21,7 @@ import (
 	"maps"
 	"net/url"
 	"slices"
+	"strings"
 	"sync"
 
 	"github.com/bartventer/httpcache/store/driver"
@@ -28,12 +29,13 @@ import (
 
 var ErrUnknownDriver = errors.New("store: unknown driver")
 
-type driverRegistry struct {
+// Registry maps driver names to drivers.
+type Registry struct {
 	mu      sync.RWMutex
 	drivers map[string]driver.Driver
 }
 
-func (dr *driverRegistry) RegisterDriver(name string, driver driver.Driver) {
+func (dr *Registry) RegisterDriver(name string, driver driver.Driver) {
 	dr.mu.Lock()
 	defer dr.mu.Unlock()
 	if driver == nil {
@@ -45,23 +47,79 @@ func (dr *driverRegistry) RegisterDriver(name string, driver driver.Driver) {
 	dr.drivers[name] = driver
 }
 
-func (dr *driverRegistry) OpenConn(dsn string) (driver.Conn, error) {
+func (dr *Registry) UnregisterDriver(name string) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	delete(dr.drivers, name)
+}
+
+func (dr *Registry) OpenConn(dsn string) (driver.Conn, error) {
 	u, err := url.Parse(dsn)
 	if err != nil {
 		return nil, err
 	}
 
+	// A "wrapper+scheme" DSN is opened by the wrapper driver, which opens
+	// the wrapped DSN itself; see Unwrap.
+	name := u.Scheme
 	dr.mu.RLock()
-	driver, ok := dr.drivers[u.Scheme]
+	d, ok := dr.drivers[name]
+	if !ok {
+		if wrapper, _, found := strings.Cut(name, "+"); found {
+			name = wrapper
+			d, ok = dr.drivers[name]
+		}
+	}
 	dr.mu.RUnlock()
 	if !ok {
-		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, u.Scheme)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, name)
+	}
+
+	// Wrapper drivers open the DSNs they name in this registry.
+	if w, ok := d.(driver.WrapperDriver); ok {
+		return w.OpenWith(u, dr)
 	}
+	return d.Open(u)
+}
 
-	return driver.Open(u)
+// WrapperFunc adapts a function to a [driver.WrapperDriver]. Open opens the
+// DSNs named by u in the default registry.
+type WrapperFunc func(u *url.URL, o driver.Opener) (driver.Conn, error)
+
+func (f WrapperFunc) Open(u *url.URL) (driver.Conn, error) {
+	return f(u, Default())
+}
+
+func (f WrapperFunc) OpenWith(u *url.URL, o driver.Opener) (driver.Conn, error) {
+	return f(u, o)
+}
+
+// Unwrap returns the DSN wrapped by u, a DSN of the form
+// "wrapper+scheme://...", with the scheme after the first '+' and without the
+// given query parameters, which belong to the wrapper.
+func Unwrap(u *url.URL, params ...string) (string, error) {
+	wrapper, scheme, ok := strings.Cut(u.Scheme, "+")
+	if !ok || scheme == "" {
+		return "", fmt.Errorf("store: DSN %q does not wrap another scheme (%s+scheme://...)", u.Redacted(), wrapper)
+	}
+	inner := *u
+	inner.Scheme = scheme
+	if len(params) > 0 {
+		q := inner.Query()
+		for _, p := range params {
+			q.Del(p)
+		}
+		inner.RawQuery = q.Encode()
+	}
+	// URL.String omits the empty authority of DSNs like "memcache://?...".
+	dsn := inner.String()
+	if rest, ok := strings.CutPrefix(dsn, scheme+":"); ok && !strings.HasPrefix(rest, "//") {
+		dsn = scheme + "://" + rest
+	}
+	return dsn, nil
 }
 
-func (dr *driverRegistry) Drivers() []string {
+func (dr *Registry) Drivers() []string {
 	dr.mu.RLock()
 	defer dr.mu.RUnlock()
 	return slices.Sorted(maps.Keys(dr.drivers))
@@ -69,10 +127,10 @@ func (dr *driverRegistry) Drivers() []string {
 
 var defaultRegistry = New()
 
-func Default() *driverRegistry {
+func Default() *Registry {
 	return defaultRegistry
 }
 
-func New() *driverRegistry {
-	return &driverRegistry{drivers: make(map[string]driver.Driver)}
+func New() *Registry {
+	return &Registry{drivers: make(map[string]driver.Driver)}
 }
@@ -65,7 +65,7 @@ func TestRegistry_RegisterDriverAndOpenConn(t *testing.T) {
 }
 
 func TestRegistry_RegisterDriver_nilPanic(t *testing.T) {
-	reg := &driverRegistry{drivers: make(map[string]driver.Driver)}
+	reg := &Registry{drivers: make(map[string]driver.Driver)}
 	testutil.RequirePanics(t, func() { reg.RegisterDriver("nil", nil) })
 }
 
@@ -77,7 +77,7 @@ func TestRegistry_RegisterDriver_duplicatePanic(t *testing.T) {
 }
 
 func TestRegistry_Drivers(t *testing.T) {
-	reg := &driverRegistry{drivers: make(map[string]driver.Driver)}
+	reg := &Registry{drivers: make(map[string]driver.Driver)}
 	reg.RegisterDriver("foo", &mockDriver{cache: &mockCache{}})
 	reg.RegisterDriver("bar", &mockDriver{cache: &mockCache{}})
 	got := reg.Drivers()
@@ -87,3 +87,81 @@ func TestRegistry_Drivers(t *testing.T) {
 	slices.Sort(want)
 	testutil.AssertTrue(t, slices.Equal(got, want))
 }
+
+func TestRegistry_UnregisterDriver(t *testing.T) {
+	reg := New()
+	reg.RegisterDriver("foo", &mockDriver{cache: &mockCache{}})
+	reg.UnregisterDriver("foo")
+	reg.UnregisterDriver("unknown") // no-op
+	_, err := reg.OpenConn("foo://")
+	testutil.RequireErrorIs(t, err, ErrUnknownDriver)
+	testutil.AssertEqual(t, 0, len(reg.Drivers()))
+
+	// The name can be registered again.
+	reg.RegisterDriver("foo", &mockDriver{cache: &mockCache{}})
+	_, err = reg.OpenConn("foo://")
+	testutil.RequireNoError(t, err)
+}
+
+func TestRegistry_OpenConn_Wrapper(t *testing.T) {
+	reg := New()
+	var opened *url.URL
+	reg.RegisterDriver("wrap", driver.DriverFunc(func(u *url.URL) (driver.Conn, error) {
+		opened = u
+		return &mockCache{}, nil
+	}))
+	_, err := reg.OpenConn("wrap+inner://?a=1")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "wrap+inner", opened.Scheme)
+
+	_, err = reg.OpenConn("other+inner://")
+	testutil.RequireErrorIs(t, err, ErrUnknownDriver)
+}
+
+func TestRegistry_OpenConn_WrapperDriver(t *testing.T) {
+	reg := New()
+	reg.RegisterDriver("inner", &mockDriver{cache: &mockCache{}})
+	reg.RegisterDriver("wrap", WrapperFunc(func(u *url.URL, o driver.Opener) (driver.Conn, error) {
+		dsn, err := Unwrap(u)
+		if err != nil {
+			return nil, err
+		}
+		return o.OpenConn(dsn)
+	}))
+	_, err := reg.OpenConn("wrap+inner://")
+	testutil.RequireNoError(t, err, "wrapped DSN not opened in the same registry")
+
+	// Open opens the wrapped DSN in the default registry.
+	u, _ := url.Parse("wrap+inner://")
+	_, err = WrapperFunc(func(u *url.URL, o driver.Opener) (driver.Conn, error) {
+		testutil.AssertTrue(t, o == Default(), "not the default registry")
+		return &mockCache{}, nil
+	}).Open(u)
+	testutil.RequireNoError(t, err)
+}
+
+func TestUnwrap(t *testing.T) {
+	tests := []struct {
+		dsn    string
+		params []string
+		want   string
+	}{
+		{"wrap+memcache://", nil, "memcache://"},
+		{"wrap+memcache://?key=k&max_entries=10", []string{"key"}, "memcache://?max_entries=10"},
+		{"wrap+fscache:///tmp/cache?appname=x", nil, "fscache:///tmp/cache?appname=x"},
+		{"wrap+redis://user:pw@host:6379/1?key=k", []string{"key"}, "redis://user:pw@host:6379/1"},
+		{"a+b+memcache://?x=1", nil, "b+memcache://?x=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			u, err := url.Parse(tt.dsn)
+			testutil.RequireNoError(t, err)
+			got, err := Unwrap(u, tt.params...)
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, tt.want, got)
+		})
+	}
+	u, _ := url.Parse("wrap://")
+	_, err := Unwrap(u)
+	testutil.RequireError(t, err)
+}
@@ -16,16 +16,60 @@
 //
 // It is suitable for testing or ephemeral caching needs, but does not persist data
 // across process restarts.
+//
+// By default the cache is unbounded. Long-running processes should bound it
+// by entry count, by size, or both; once a bound is exceeded, entries are
+// evicted according to the configured [Policy] (least recently used by
+// default). The size of an entry is the length of its key plus the length of
+// its value. Entries expire after the configured time to live, or sooner if
+// stored with a shorter one through [driver.TTLSetter]. Expired entries are
+// removed when they are next read or listed, or when the cache is next
+// written to; they are always removed before any live entry is evicted.
+//
+// Storing an entry that exceeds max_bytes fails with [ErrEntryTooLarge], and
+// removes any previous entry for the same key.
+//
+// # Configuration Parameters
+//
+// The following DSN query parameters are supported:
+//
+//   - max_entries (optional): Maximum number of entries (default: unbounded)
+//   - max_bytes (optional): Maximum total size, e.g. "64MiB" or "1GB" (default: unbounded)
+//   - ttl (optional): Per-entry time to live, e.g. "10m" (default: no expiry)
+//   - policy (optional): Eviction policy, "lru" or "fifo" (default: "lru")
+//
+// # Usage Examples
+//
+//	The DSN and equivalent programmatic usage are shown below.
+//
+//	 Unbounded cache:
+//
+//		memcache://
+//		memcache.Open()
+//
+//	 Bounded cache with expiry:
+//
+//		memcache://?max_entries=10000&max_bytes=256MiB&ttl=10m
+//		memcache.Open(memcache.WithMaxEntries(10000), memcache.WithMaxBytes(256<<20), memcache.WithTTL(10*time.Minute))
 package memcache
 
 import (
+	"container/heap"
+	"container/list"
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/bartventer/httpcache/store"
 	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+	"github.com/bartventer/httpcache/store/internal/bytesize"
 )
 
 const Scheme = "memcache"
@@ -33,61 +77,476 @@ const Scheme = "memcache"
 //nolint:gochecknoinits // We use init to register the driver.
 func init() {
 	store.Register(Scheme, driver.DriverFunc(func(u *url.URL) (driver.Conn, error) {
-		return Open(), nil
+		return fromURL(u)
 	}))
 }
 
+var (
+	ErrInvalidParam  = errors.New("memcache: invalid DSN parameter")
+	ErrEntryTooLarge = errors.New("memcache: entry exceeds max_bytes")
+)
+
+// Policy selects which entry is evicted when the cache is full.
+type Policy int
+
+const (
+	// LRU evicts the least recently used entry; reads count as a use.
+	LRU Policy = iota
+	// FIFO evicts the oldest entry; reads do not affect eviction order.
+	FIFO
+)
+
+func (p Policy) String() string {
+	switch p {
+	case LRU:
+		return "lru"
+	case FIFO:
+		return "fifo"
+	default:
+		return "Policy(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// Stats holds a snapshot of the cache counters.
+type Stats struct {
+	Entries     int    // current number of entries
+	Bytes       int64  // current total size of keys and values
+	Evictions   uint64 // entries removed to satisfy max_entries or max_bytes
+	Expirations uint64 // entries removed because their ttl elapsed
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time // zero if the entry does not expire
+	index   int       // index in the expiry heap; -1 if the entry does not expire
+}
+
+func (e *entry) size() int64 { return int64(len(e.key) + len(e.value)) }
+
+// expiryHeap orders expiring entries by expiry time, soonest first.
+type expiryHeap []*entry
+
+var _ heap.Interface = (*expiryHeap)(nil)
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
 type memCache struct {
-	mu    sync.RWMutex
-	store map[string][]byte
+	// configurable options
+
+	maxEntries int           // maximum number of entries; 0 means unbounded
+	maxBytes   int64         // maximum total size; 0 means unbounded
+	ttl        time.Duration // per-entry time to live; 0 means no expiry
+	policy     Policy        // eviction policy
+
+	// internal state
+
+	mu     sync.Mutex
+	items  map[string]*list.Element // values are *entry
+	ll     *list.List               // front is the next entry to evict
+	expiry expiryHeap               // entries that expire
+	stats  Stats
+	now    func() time.Time
+}
+
+type Option interface {
+	apply(*memCache)
+}
+
+type optionFunc func(*memCache)
+
+func (f optionFunc) apply(c *memCache) {
+	f(c)
+}
+
+// WithMaxEntries bounds the number of entries; default: unbounded.
+func WithMaxEntries(n int) Option {
+	return optionFunc(func(c *memCache) {
+		c.maxEntries = max(n, 0)
+	})
+}
+
+// WithMaxBytes bounds the total size of keys and values; default: unbounded.
+func WithMaxBytes(n int64) Option {
+	return optionFunc(func(c *memCache) {
+		c.maxBytes = max(n, 0)
+	})
+}
+
+// WithTTL sets the time to live of each entry, measured from when it was last
+// set; default: entries do not expire.
+func WithTTL(ttl time.Duration) Option {
+	return optionFunc(func(c *memCache) {
+		c.ttl = max(ttl, 0)
+	})
+}
+
+// WithPolicy sets the eviction policy; default: [LRU].
+func WithPolicy(p Policy) Option {
+	return optionFunc(func(c *memCache) {
+		c.policy = p
+	})
+}
+
+func parsePolicy(v string) (Policy, error) {
+	switch strings.ToLower(v) {
+	case "lru":
+		return LRU, nil
+	case "fifo":
+		return FIFO, nil
+	default:
+		return 0, fmt.Errorf("%w: policy %q", ErrInvalidParam, v)
+	}
+}
+
+func fromURL(u *url.URL) (*memCache, error) {
+	q := u.Query()
+	opts := make([]Option, 0, 4)
+	if v := q.Get("max_entries"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: max_entries %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithMaxEntries(n))
+	}
+	if v := q.Get("max_bytes"); v != "" {
+		n, err := bytesize.Parse(v)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidParam, err)
+		}
+		opts = append(opts, WithMaxBytes(n))
+	}
+	if v := q.Get("ttl"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("%w: ttl %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithTTL(ttl))
+	}
+	if v := q.Get("policy"); v != "" {
+		p, err := parsePolicy(v)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithPolicy(p))
+	}
+	return Open(opts...), nil
 }
 
 // Open creates a new in-memory cache.
 //
 // This cache is not persistent and will lose all data when the process exits.
-func Open() *memCache {
-	return &memCache{
-		store: make(map[string][]byte),
+// See the package documentation for supported options.
+func Open(opts ...Option) *memCache {
+	c := &memCache{
+		items: make(map[string]*list.Element),
+		ll:    list.New(),
+		now:   time.Now,
+	}
+	for _, opt := range opts {
+		opt.apply(c)
 	}
+	return c
 }
 
 var _ driver.Conn = (*memCache)(nil)
+var _ driver.ConnContext = (*memCache)(nil)
+var _ driver.Batcher = (*memCache)(nil)
+var _ driver.TTLSetter = (*memCache)(nil)
+var _ expapi.KeyLister = (*memCache)(nil)
+
+func errNotExist(key string) error {
+	return errors.Join(
+		driver.ErrNotExist,
+		fmt.Errorf("memcache: key %q does not exist", key),
+	)
+}
+
+// lookup returns the live element for key, removing it first if it has
+// expired. The caller must hold c.mu.
+func (c *memCache) lookup(key string) (*list.Element, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if c.expired(el.Value.(*entry)) {
+		c.remove(el)
+		c.stats.Expirations++
+		return nil, false
+	}
+	return el, true
+}
+
+func (c *memCache) expired(e *entry) bool {
+	return !e.expires.IsZero() && !c.now().Before(e.expires)
+}
+
+// remove drops el from the cache. The caller must hold c.mu.
+func (c *memCache) remove(el *list.Element) {
+	e := c.ll.Remove(el).(*entry)
+	delete(c.items, e.key)
+	if e.index >= 0 {
+		heap.Remove(&c.expiry, e.index)
+	}
+	c.stats.Bytes -= e.size()
+}
+
+// sweep removes all expired entries. The caller must hold c.mu.
+func (c *memCache) sweep() {
+	for len(c.expiry) > 0 && c.expired(c.expiry[0]) {
+		c.remove(c.items[c.expiry[0].key])
+		c.stats.Expirations++
+	}
+}
+
+// evict removes expired entries, then live ones until the configured bounds
+// are satisfied. The caller must hold c.mu.
+func (c *memCache) evict() {
+	c.sweep()
+	for {
+		overEntries := c.maxEntries > 0 && c.ll.Len() > c.maxEntries
+		overBytes := c.maxBytes > 0 && c.stats.Bytes > c.maxBytes
+		if !overEntries && !overBytes {
+			return
+		}
+		c.remove(c.ll.Front())
+		c.stats.Evictions++
+	}
+}
 
 func (c *memCache) Get(key string) ([]byte, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	val, ok := c.store[key]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.lookup(key)
 	if !ok {
-		return nil, errors.Join(
-			driver.ErrNotExist,
-			fmt.Errorf("memcache: key %q does not exist", key),
-		)
+		return nil, errNotExist(key)
+	}
+	if c.policy == LRU {
+		c.ll.MoveToBack(el)
 	}
 	// Return a copy to prevent mutation
-	cp := make([]byte, len(val))
-	copy(cp, val)
-	return cp, nil
+	return slices.Clone(el.Value.(*entry).value), nil
 }
 
 func (c *memCache) Set(key string, value []byte) error {
+	if err := c.checkSize(key, value); err != nil {
+		return err
+	}
+	e := newEntry(key, value)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.insert(e)
+	c.evict()
+	return nil
+}
+
+// checkSize returns [ErrEntryTooLarge] if the entry for key and value would
+// exceed max_bytes, after removing any previous entry for key, which the
+// failed write would otherwise leave behind stale.
+func (c *memCache) checkSize(key string, value []byte) error {
+	if c.maxBytes <= 0 || int64(len(key)+len(value)) <= c.maxBytes {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	return fmt.Errorf("%w: key %q", ErrEntryTooLarge, key)
+}
+
+// newEntry returns an entry holding a copy of value, to prevent external
+// mutation.
+func newEntry(key string, value []byte) *entry {
+	e := &entry{key: key, value: make([]byte, len(value)), index: -1}
+	copy(e.value, value)
+	return e
+}
+
+// insert adds e to the cache, replacing any entry for the same key. The
+// caller must hold c.mu.
+func (c *memCache) insert(e *entry) {
+	if c.ttl > 0 {
+		if expires := c.now().Add(c.ttl); e.expires.IsZero() || expires.Before(e.expires) {
+			e.expires = expires
+		}
+	}
+	if el, ok := c.items[e.key]; ok {
+		c.remove(el)
+	}
+	c.items[e.key] = c.ll.PushBack(e)
+	if !e.expires.IsZero() {
+		heap.Push(&c.expiry, e)
+	}
+	c.stats.Bytes += e.size()
+}
+
+// SetWithTTL is like Set, but the entry expires once ttl has elapsed; see
+// [driver.TTLSetter]. The shorter of ttl and the cache's own TTL applies.
+func (c *memCache) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.checkSize(key, value); err != nil {
+		return err
+	}
+	e := newEntry(key, value)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	// Store a copy to prevent external mutation
-	cp := make([]byte, len(value))
-	copy(cp, value)
-	c.store[key] = cp
+	if ttl > 0 {
+		e.expires = c.now().Add(ttl)
+	}
+	c.insert(e)
+	c.evict()
 	return nil
 }
 
 func (c *memCache) Delete(key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if _, ok := c.store[key]; !ok {
-		return errors.Join(
-			driver.ErrNotExist,
-			fmt.Errorf("memcache: key %q does not exist", key),
-		)
+	el, ok := c.lookup(key)
+	if !ok {
+		return errNotExist(key)
+	}
+	c.remove(el)
+	return nil
+}
+
+// GetMulti retrieves the values of the given keys under a single lock; see
+// [driver.Batcher].
+func (c *memCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		el, ok := c.lookup(key)
+		if !ok {
+			continue
+		}
+		if c.policy == LRU {
+			c.ll.MoveToBack(el)
+		}
+		values[key] = slices.Clone(el.Value.(*entry).value)
+	}
+	return values, nil
+}
+
+// SetMulti stores the given values under a single lock; see
+// [driver.Batcher]. No value is stored if one of them is too large, and the
+// previous entry for that one is removed.
+func (c *memCache) SetMulti(ctx context.Context, values map[string][]byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	entries := make([]*entry, 0, len(values))
+	for key, value := range values {
+		if err := c.checkSize(key, value); err != nil {
+			return err
+		}
+		entries = append(entries, newEntry(key, value))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range entries {
+		c.insert(e)
+	}
+	c.evict()
+	return nil
+}
+
+// DeleteMulti removes the values of the given keys under a single lock; see
+// [driver.Batcher].
+func (c *memCache) DeleteMulti(ctx context.Context, keys []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
 	}
-	delete(c.store, key)
 	return nil
 }
+
+// GetContext is like Get, but fails without reading the entry if ctx is
+// done. Operations on memory do not block, so they are not interrupted.
+func (c *memCache) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Get(key)
+}
+
+// SetContext is like Set, but fails without storing the entry if ctx is done.
+func (c *memCache) SetContext(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Set(key, value)
+}
+
+// DeleteContext is like Delete, but fails without removing the entry if ctx
+// is done.
+func (c *memCache) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Delete(key)
+}
+
+func (c *memCache) Keys(prefix string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.items))
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*entry)
+		if c.expired(e) {
+			c.remove(el)
+			c.stats.Expirations++
+		} else if strings.HasPrefix(e.key, prefix) {
+			keys = append(keys, e.key)
+		}
+		el = next
+	}
+	return slices.Clip(keys), nil
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *memCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.ll.Len()
+	return s
+}
@@ -15,8 +15,13 @@
 package memcache
 
 import (
+	"context"
+	"net/url"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/bartventer/httpcache/internal/testutil"
 	"github.com/bartventer/httpcache/store/acceptance"
 	"github.com/bartventer/httpcache/store/driver"
 )
@@ -25,5 +30,266 @@ func TestMemCache_Acceptance(t *testing.T) {
 	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
 		cache := Open()
 		return cache, func() {}
-	}))
+	}), acceptance.WithExpiry(advance))
+}
+
+// advance makes d elapse for the cache, freezing its clock.
+func advance(cache driver.Conn, d time.Duration) {
+	c := cache.(*memCache)
+	now := c.now().Add(d)
+	c.now = func() time.Time { return now }
+}
+
+func TestMemCache_Acceptance_Bounded(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		cache := Open(WithMaxEntries(100), WithMaxBytes(1<<20), WithTTL(time.Hour))
+		return cache, func() {}
+	}), acceptance.WithMaxValueSize(1<<19), acceptance.WithExpiry(advance))
+}
+
+func Test_fromURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		dsn       string
+		assertion func(tt *testing.T, got *memCache, err error)
+	}{
+		{
+			name: "defaults",
+			dsn:  "memcache://",
+			assertion: func(tt *testing.T, got *memCache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, 0, got.maxEntries)
+				testutil.AssertEqual(tt, 0, got.maxBytes)
+				testutil.AssertEqual(tt, 0, got.ttl)
+				testutil.AssertEqual(tt, LRU, got.policy)
+			},
+		},
+		{
+			name: "all parameters",
+			dsn:  "memcache://?max_entries=10&max_bytes=256MiB&ttl=5m&policy=FIFO",
+			assertion: func(tt *testing.T, got *memCache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, 10, got.maxEntries)
+				testutil.AssertEqual(tt, 256<<20, got.maxBytes)
+				testutil.AssertEqual(tt, 5*time.Minute, got.ttl)
+				testutil.AssertEqual(tt, FIFO, got.policy)
+			},
+		},
+		{
+			name: "invalid max_entries",
+			dsn:  "memcache://?max_entries=-1",
+			assertion: func(tt *testing.T, got *memCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid max_bytes",
+			dsn:  "memcache://?max_bytes=lots",
+			assertion: func(tt *testing.T, got *memCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid ttl",
+			dsn:  "memcache://?ttl=forever",
+			assertion: func(tt *testing.T, got *memCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid policy",
+			dsn:  "memcache://?policy=random",
+			assertion: func(tt *testing.T, got *memCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.dsn)
+			testutil.RequireNoError(t, err)
+			got, err := fromURL(u)
+			tt.assertion(t, got, err)
+		})
+	}
+}
+
+func mustKeys(t *testing.T, c *memCache) []string {
+	t.Helper()
+	keys, err := c.Keys("")
+	testutil.RequireNoError(t, err)
+	slices.Sort(keys)
+	return keys
+}
+
+func TestMemCache_EvictMaxEntries(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		want   []string
+	}{
+		{LRU, []string{"a", "c"}}, // "a" was read, so "b" is least recently used
+		{FIFO, []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			c := Open(WithMaxEntries(2), WithPolicy(tt.policy))
+			testutil.RequireNoError(t, c.Set("a", []byte("1")))
+			testutil.RequireNoError(t, c.Set("b", []byte("2")))
+			_, err := c.Get("a")
+			testutil.RequireNoError(t, err)
+			testutil.RequireNoError(t, c.Set("c", []byte("3")))
+
+			testutil.AssertTrue(t, slices.Equal(mustKeys(t, c), tt.want), "got %v", mustKeys(t, c))
+			stats := c.Stats()
+			testutil.AssertEqual(t, 2, stats.Entries)
+			testutil.AssertEqual(t, uint64(1), stats.Evictions)
+		})
+	}
+}
+
+func TestMemCache_EvictMaxBytes(t *testing.T) {
+	c := Open(WithMaxBytes(10))
+	testutil.RequireNoError(t, c.Set("a", []byte("1234"))) // 5 bytes
+	testutil.RequireNoError(t, c.Set("b", []byte("1234"))) // 10 bytes
+	testutil.AssertEqual(t, int64(10), c.Stats().Bytes)
+
+	testutil.RequireNoError(t, c.Set("c", []byte("12"))) // 13 bytes, evicts "a"
+	testutil.AssertTrue(t, slices.Equal(mustKeys(t, c), []string{"b", "c"}))
+	testutil.AssertEqual(t, int64(8), c.Stats().Bytes)
+
+	// Overwrites replace the accounted size rather than adding to it.
+	testutil.RequireNoError(t, c.Set("c", []byte("1")))
+	testutil.AssertEqual(t, int64(7), c.Stats().Bytes)
+
+	err := c.Set("d", []byte("0123456789"))
+	testutil.RequireErrorIs(t, err, ErrEntryTooLarge)
+	testutil.AssertEqual(t, uint64(1), c.Stats().Evictions)
+
+	// A rejected overwrite does not leave the previous value behind.
+	err = c.SetWithTTL(t.Context(), "c", []byte("0123456789"), time.Minute)
+	testutil.RequireErrorIs(t, err, ErrEntryTooLarge)
+	_, err = c.Get("c")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	testutil.AssertEqual(t, int64(5), c.Stats().Bytes)
+}
+
+func TestMemCache_SweepExpired(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := Open(WithMaxEntries(3))
+	c.now = func() time.Time { return now }
+
+	testutil.RequireNoError(t, c.Set("live", []byte("1")))
+	testutil.RequireNoError(t, c.SetWithTTL(t.Context(), "a", []byte("2"), time.Minute))
+	testutil.RequireNoError(t, c.SetWithTTL(t.Context(), "b", []byte("3"), time.Hour))
+	now = now.Add(time.Minute)
+
+	// Expired entries are swept on write, before the oldest live entry would
+	// be evicted.
+	testutil.RequireNoError(t, c.Set("c", []byte("4")))
+	stats := c.Stats()
+	testutil.AssertEqual(t, 3, stats.Entries)
+	testutil.AssertEqual(t, uint64(1), stats.Expirations)
+	testutil.AssertEqual(t, uint64(0), stats.Evictions)
+
+	// Unread entries do not accumulate once they expire.
+	now = now.Add(time.Hour)
+	testutil.RequireNoError(t, c.Set("d", []byte("5")))
+	stats = c.Stats()
+	testutil.AssertEqual(t, 3, stats.Entries)
+	testutil.AssertEqual(t, uint64(2), stats.Expirations)
+	testutil.AssertEqual(t, uint64(0), stats.Evictions)
+}
+
+func TestMemCache_TTL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := Open(WithTTL(time.Minute))
+	c.now = func() time.Time { return now }
+
+	testutil.RequireNoError(t, c.Set("a", []byte("1")))
+	testutil.RequireNoError(t, c.Set("b", []byte("2")))
+	now = now.Add(30 * time.Second)
+	testutil.RequireNoError(t, c.Set("b", []byte("2"))) // refreshes the ttl of "b"
+	now = now.Add(30 * time.Second)
+
+	_, err := c.Get("a")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	testutil.RequireErrorIs(t, c.Delete("a"), driver.ErrNotExist)
+	got, err := c.Get("b")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "2", string(got))
+
+	now = now.Add(30 * time.Second)
+	testutil.AssertEqual(t, 0, len(mustKeys(t, c)))
+	stats := c.Stats()
+	testutil.AssertEqual(t, uint64(2), stats.Expirations)
+	testutil.AssertEqual(t, 0, stats.Entries)
+	testutil.AssertEqual(t, int64(0), stats.Bytes)
+}
+
+func TestMemCache_SetWithTTL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := Open(WithTTL(time.Minute))
+	c.now = func() time.Time { return now }
+
+	testutil.RequireNoError(t, c.SetWithTTL(t.Context(), "short", []byte("1"), 10*time.Second))
+	testutil.RequireNoError(t, c.SetWithTTL(t.Context(), "long", []byte("2"), time.Hour))
+	testutil.RequireNoError(t, c.SetWithTTL(t.Context(), "none", []byte("3"), 0))
+	now = now.Add(10 * time.Second)
+	_, err := c.Get("short")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	testutil.AssertEqual(t, 2, len(mustKeys(t, c)))
+
+	// The cache's own TTL bounds longer ones.
+	now = now.Add(50 * time.Second)
+	testutil.AssertEqual(t, 0, len(mustKeys(t, c)))
+
+	// Without a TTL of its own, the cache keeps entries stored without one.
+	c = Open()
+	c.now = func() time.Time { return now }
+	testutil.RequireNoError(t, c.SetWithTTL(t.Context(), "none", []byte("3"), 0))
+	now = now.Add(24 * time.Hour)
+	_, err = c.Get("none")
+	testutil.RequireNoError(t, err)
+}
+
+func TestMemCache_Keys(t *testing.T) {
+	c := Open()
+	for _, k := range []string{"https://a/1", "https://a/2", "https://b/1"} {
+		testutil.RequireNoError(t, c.Set(k, nil))
+	}
+	keys, err := c.Keys("https://a/")
+	testutil.RequireNoError(t, err)
+	slices.Sort(keys)
+	testutil.AssertTrue(t, slices.Equal(keys, []string{"https://a/1", "https://a/2"}))
+}
+
+func TestMemCache_Context(t *testing.T) {
+	c := Open()
+	ctx, cancel := context.WithCancel(t.Context())
+	testutil.RequireNoError(t, c.SetContext(ctx, "a", []byte("1")))
+	got, err := c.GetContext(ctx, "a")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "1", string(got))
+
+	cancel()
+	_, err = c.GetContext(ctx, "a")
+	testutil.RequireErrorIs(t, err, context.Canceled)
+	testutil.RequireErrorIs(t, c.SetContext(ctx, "b", []byte("2")), context.Canceled)
+	testutil.RequireErrorIs(t, c.DeleteContext(ctx, "a"), context.Canceled)
+
+	// Cancelled operations leave the cache unchanged.
+	testutil.RequireNoError(t, c.DeleteContext(t.Context(), "a"))
+	_, err = c.Get("b")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+}
+
+func TestMemCache_SetMulti_TooLarge(t *testing.T) {
+	c := Open(WithMaxBytes(10))
+	err := c.SetMulti(t.Context(), map[string][]byte{
+		"small": []byte("1"),
+		"large": []byte("0123456789abcdef"),
+	})
+	testutil.RequireErrorIs(t, err, ErrEntryTooLarge)
+	_, err = c.Get("small")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
 }
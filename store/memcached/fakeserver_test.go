@@ -0,0 +1,265 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memcached
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+// fakeMaxItem is the largest value the fake server accepts by default, as
+// memcached's default item size limit (-I 1m).
+const fakeMaxItem = 1 << 20
+
+// fakeServer is an in-process stand-in for a memcached server, implementing
+// the text protocol commands used by the driver, including its key rules.
+type fakeServer struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	items   map[string]fakeItem
+	conns   map[net.Conn]bool
+	now     time.Time      // zero for the wall clock
+	maxItem int            // largest value accepted
+	cmds    map[string]int // number of commands executed, by name
+	wg      sync.WaitGroup
+}
+
+type fakeItem struct {
+	flags   uint32
+	value   []byte
+	expires time.Time
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	testutil.RequireNoError(t, err)
+	s := &fakeServer{
+		ln:      ln,
+		items:   make(map[string]fakeItem),
+		conns:   make(map[net.Conn]bool),
+		maxItem: fakeMaxItem,
+		cmds:    make(map[string]int),
+	}
+	s.wg.Go(s.serve)
+	t.Cleanup(func() {
+		ln.Close()
+		s.dropConns()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *fakeServer) addr() string { return s.ln.Addr().String() }
+
+func (s *fakeServer) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[nc] = true
+		s.mu.Unlock()
+		s.wg.Go(func() { s.handle(nc) })
+	}
+}
+
+// dropConns closes all client connections, as a server restart would.
+func (s *fakeServer) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for nc := range s.conns {
+		nc.Close()
+	}
+}
+
+func (s *fakeServer) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.clock().Add(d)
+}
+
+func (s *fakeServer) clock() time.Time {
+	if s.now.IsZero() {
+		return time.Now()
+	}
+	return s.now
+}
+
+// keys returns the keys stored in the server.
+func (s *fakeServer) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.items {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// count returns the number of commands with the given name executed.
+func (s *fakeServer) count(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cmds[name]
+}
+
+func (s *fakeServer) item(key string) (fakeItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[key]
+	return it, ok
+}
+
+func (s *fakeServer) handle(nc net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, nc)
+		s.mu.Unlock()
+		nc.Close()
+	}()
+	br, bw := bufio.NewReader(nc), bufio.NewWriter(nc)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			bw.WriteString("ERROR\r\n")
+		} else if !s.exec(f, br, bw) {
+			return
+		}
+		if bw.Flush() != nil {
+			return
+		}
+	}
+}
+
+func validFakeKey(k string) bool {
+	if len(k) > maxKeyLen {
+		return false
+	}
+	for i := range len(k) {
+		if k[i] <= ' ' || k[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// exec runs a command, and reports whether the connection may be kept.
+func (s *fakeServer) exec(f []string, br *bufio.Reader, bw *bufio.Writer) bool {
+	s.mu.Lock()
+	s.cmds[f[0]]++
+	s.mu.Unlock()
+	switch f[0] {
+	case "version":
+		bw.WriteString("VERSION 1.6.0-fake\r\n")
+	case "get", "gets":
+		for _, k := range f[1:] {
+			if !validFakeKey(k) {
+				bw.WriteString("CLIENT_ERROR bad command line format\r\n")
+				return true
+			}
+		}
+		for _, k := range f[1:] {
+			if it, ok := s.lookup(k); ok {
+				bw.WriteString("VALUE " + k + " " + strconv.FormatUint(uint64(it.flags), 10) +
+					" " + strconv.Itoa(len(it.value)) + "\r\n")
+				bw.Write(it.value)
+				bw.WriteString("\r\n")
+			}
+		}
+		bw.WriteString("END\r\n")
+	case "set":
+		if len(f) != 5 {
+			bw.WriteString("ERROR\r\n")
+			return true
+		}
+		flags, err1 := strconv.ParseUint(f[2], 10, 32)
+		exptime, err2 := strconv.ParseInt(f[3], 10, 64)
+		size, err3 := strconv.Atoi(f[4])
+		if err1 != nil || err2 != nil || err3 != nil || size < 0 || !validFakeKey(f[1]) {
+			bw.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return false
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return false
+		}
+		if string(data[size:]) != "\r\n" {
+			bw.WriteString("CLIENT_ERROR bad data chunk\r\n")
+			return false
+		}
+		s.mu.Lock()
+		if size > s.maxItem {
+			s.mu.Unlock()
+			bw.WriteString("SERVER_ERROR object too large for cache\r\n")
+			return true
+		}
+		it := fakeItem{flags: uint32(flags), value: data[:size]}
+		switch now := s.clock(); {
+		case exptime < 0:
+			it.expires = now
+		case exptime > int64(maxRelativeExpiry/time.Second):
+			it.expires = time.Unix(exptime, 0)
+		case exptime > 0:
+			it.expires = now.Add(time.Duration(exptime) * time.Second)
+		}
+		s.items[f[1]] = it
+		s.mu.Unlock()
+		bw.WriteString("STORED\r\n")
+	case "delete":
+		if len(f) != 2 || !validFakeKey(f[1]) {
+			bw.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return true
+		}
+		if _, ok := s.lookup(f[1]); !ok {
+			bw.WriteString("NOT_FOUND\r\n")
+			return true
+		}
+		s.mu.Lock()
+		delete(s.items, f[1])
+		s.mu.Unlock()
+		bw.WriteString("DELETED\r\n")
+	default:
+		bw.WriteString("ERROR\r\n")
+	}
+	return true
+}
+
+func (s *fakeServer) lookup(key string) (fakeItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[key]
+	if ok && !it.expires.IsZero() && !s.clock().Before(it.expires) {
+		delete(s.items, key)
+		return fakeItem{}, false
+	}
+	return it, ok
+}
@@ -0,0 +1,827 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memcached implements a cache backend that stores entries in one or
+// more memcached servers, using the memcached text protocol.
+//
+// Keys are distributed over the servers with consistent hashing (ketama), so
+// adding or removing a server only moves a share of the keys. A server that
+// is down makes the keys it holds unavailable; they are not moved to other
+// servers.
+//
+// Memcached keys are limited to 250 bytes without spaces or control
+// characters. Keys that do not fit, such as long URLs, are stored under a
+// SHA-256 hash of the key instead, with the original key kept in the value
+// and checked on reads. Batch operations ([driver.Batcher]) send one
+// multi-key get, or one pipeline of commands, per server. Memcached cannot
+// list its keys, so unlike other backends this one does not implement
+// [github.com/bartventer/httpcache/store/expapi.KeyLister].
+//
+// Memcached limits the size of an item, key and value included, to 1 MB by
+// default; raise it with the server's -I option. Responses larger than the
+// limit fail to be stored with a server error, and are not cached.
+//
+// # Configuration Parameters
+//
+// The DSN has the form memcached://host[:port][,host[:port]...]. The
+// following query parameters are supported:
+//
+//   - prefix (optional): Prefix prepended to every key (default: none)
+//   - timeout (optional): Timeout for dialing and for each command, e.g. "2s" (default: 5s)
+//   - ttl (optional): Time to live of every entry, enforced by the server, e.g. "1h" (default: no expiry)
+//   - pool_size (optional): Maximum number of idle connections kept open per server (default: 10)
+//
+// # Usage Examples
+//
+//	The DSN and equivalent programmatic usage are shown below.
+//
+//	 Single server:
+//
+//		memcached://localhost:11211
+//		memcached.Open([]string{"localhost:11211"})
+//
+//	 Several servers, with expiry:
+//
+//		memcached://cache1:11211,cache2:11211?prefix=myapp:&ttl=1h
+//		memcached.Open([]string{"cache1:11211", "cache2:11211"}, memcached.WithPrefix("myapp:"), memcached.WithTTL(time.Hour))
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+const Scheme = "memcached"
+
+//nolint:gochecknoinits // We use init to register the driver.
+func init() {
+	store.Register(Scheme, driver.DriverFunc(func(u *url.URL) (driver.Conn, error) {
+		return fromURL(u)
+	}))
+}
+
+var (
+	ErrInvalidParam = errors.New("memcached: invalid DSN parameter")
+	ErrClosed       = errors.New("memcached: cache closed")
+	errProtocol     = errors.New("memcached: protocol error")
+)
+
+const (
+	defaultPort     = "11211"
+	defaultTimeout  = 5 * time.Second
+	defaultPoolSize = 10
+
+	maxKeyLen = 250
+	// maxValueLen is the largest value accepted from the server, as in the
+	// upper bound of memcached's item size limit (-I), so that a corrupt
+	// length cannot make readValue allocate without bound.
+	maxValueLen = 1 << 30
+	// batchSize is the number of keys sent to a server in one multi-key get
+	// or pipeline.
+	batchSize = 100
+	// maxRelativeExpiry is the longest expiry memcached accepts as a number
+	// of seconds; longer ones must be given as a Unix time.
+	maxRelativeExpiry = 30 * 24 * time.Hour
+	// hashedKeyPrefix marks keys stored under a hash; keys that start with it
+	// are always hashed, so they never collide with hashed ones.
+	hashedKeyPrefix = "sha256:"
+)
+
+// Item flags.
+const (
+	flagPlain  = 0 // value is stored as is
+	flagHashed = 1 // value is prefixed with the key, which is stored hashed
+)
+
+type memcachedCache struct {
+	// configurable options
+
+	prefix   string        // prepended to every key
+	timeout  time.Duration // dial and per-command timeout
+	ttl      time.Duration // per-entry time to live; 0 means no expiry
+	poolSize int           // maximum number of idle connections per server
+
+	// internal state
+
+	nodes []*node
+	ring  *ring
+	now   func() time.Time
+}
+
+type Option interface {
+	apply(*memcachedCache)
+}
+
+type optionFunc func(*memcachedCache)
+
+func (f optionFunc) apply(c *memcachedCache) {
+	f(c)
+}
+
+// WithPrefix sets a prefix prepended to every key; default: none.
+func WithPrefix(prefix string) Option {
+	return optionFunc(func(c *memcachedCache) {
+		c.prefix = prefix
+	})
+}
+
+// WithTimeout sets the timeout for dialing and for each command; default: 5s.
+func WithTimeout(d time.Duration) Option {
+	return optionFunc(func(c *memcachedCache) {
+		if d > 0 {
+			c.timeout = d
+		}
+	})
+}
+
+// WithTTL sets the time to live of every entry, measured from when it was
+// last set; default: entries do not expire. Memcached counts in seconds, so
+// the time to live is rounded up to a whole second.
+func WithTTL(ttl time.Duration) Option {
+	return optionFunc(func(c *memcachedCache) {
+		c.ttl = max(ttl, 0)
+	})
+}
+
+// WithPoolSize sets the maximum number of idle connections kept open per
+// server; default: 10.
+func WithPoolSize(n int) Option {
+	return optionFunc(func(c *memcachedCache) {
+		c.poolSize = max(n, 0)
+	})
+}
+
+func fromURL(u *url.URL) (*memcachedCache, error) {
+	q := u.Query()
+	opts := make([]Option, 0, 4)
+	if v := q.Get("prefix"); v != "" {
+		opts = append(opts, WithPrefix(v))
+	}
+	if v := q.Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: timeout %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithTimeout(d))
+	}
+	if v := q.Get("ttl"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("%w: ttl %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithTTL(ttl))
+	}
+	if v := q.Get("pool_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: pool_size %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithPoolSize(n))
+	}
+	var servers []string
+	for s := range strings.SplitSeq(u.Host, ",") {
+		if s == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(s, defaultPort)
+		}
+		servers = append(servers, s)
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("%w: no servers", ErrInvalidParam)
+	}
+	return Open(servers, opts...)
+}
+
+// Open returns a cache backed by the memcached servers at the given
+// addresses ("host:port"). It fails if a server cannot be reached.
+//
+// See the package documentation for supported options.
+func Open(servers []string, opts ...Option) (*memcachedCache, error) {
+	if len(servers) == 0 {
+		return nil, errors.New("memcached: no servers")
+	}
+	c := &memcachedCache{
+		timeout:  defaultTimeout,
+		poolSize: defaultPoolSize,
+		ring:     newRing(servers),
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+	if len(c.prefix)+len(hashedKeyPrefix)+sha256.Size*2 > maxKeyLen || !validKey(c.prefix) {
+		return nil, fmt.Errorf("memcached: invalid prefix %q", c.prefix)
+	}
+	for _, addr := range servers {
+		c.nodes = append(c.nodes, &node{c: c, addr: addr})
+	}
+	for _, n := range c.nodes {
+		if err := n.do(func(mc *mcConn) error {
+			line, err := mc.command("version\r\n")
+			if err == nil && !strings.HasPrefix(line, "VERSION ") {
+				err = replyError(line)
+			}
+			return err
+		}); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+var (
+	_ driver.Conn      = (*memcachedCache)(nil)
+	_ driver.TTLSetter = (*memcachedCache)(nil)
+	_ driver.Batcher   = (*memcachedCache)(nil)
+)
+
+func errNotExist(key string) error {
+	return errors.Join(
+		driver.ErrNotExist,
+		fmt.Errorf("memcached: key %q does not exist", key),
+	)
+}
+
+// validKey reports whether s may be used in a memcached key.
+func validKey(s string) bool {
+	for i := range len(s) {
+		if s[i] <= ' ' || s[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// itemKey returns the memcached key that key is stored under, and whether it
+// is a hash of the key.
+func (c *memcachedCache) itemKey(key string) (string, bool) {
+	name := c.prefix + key
+	if len(name) <= maxKeyLen && validKey(key) && !strings.HasPrefix(key, hashedKeyPrefix) {
+		return name, false
+	}
+	sum := sha256.Sum256([]byte(key))
+	return c.prefix + hashedKeyPrefix + hex.EncodeToString(sum[:]), true
+}
+
+// expiry returns the expiration time sent with set commands for an entry
+// that expires after ttl, or 0 for none.
+func (c *memcachedCache) expiry(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	secs := int64((ttl + time.Second - 1) / time.Second)
+	if ttl > maxRelativeExpiry {
+		return c.now().Unix() + secs
+	}
+	return secs
+}
+
+func (c *memcachedCache) nodeFor(item string) *node {
+	return c.nodes[c.ring.node(item)]
+}
+
+func (c *memcachedCache) Get(key string) ([]byte, error) {
+	item, hashed := c.itemKey(key)
+	var (
+		value []byte
+		flags uint32
+		found bool
+	)
+	err := c.nodeFor(item).do(func(mc *mcConn) error {
+		value, flags, found = nil, 0, false
+		if _, err := mc.bw.WriteString("get " + item + "\r\n"); err != nil {
+			return err
+		}
+		if err := mc.bw.Flush(); err != nil {
+			return err
+		}
+		for {
+			line, err := mc.readLine()
+			if err != nil {
+				return err
+			}
+			if line == "END" {
+				return nil
+			}
+			if _, value, flags, err = mc.readValue(line); err != nil {
+				return err
+			}
+			found = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errNotExist(key)
+	}
+	value, ok := decodeItem(key, hashed, value, flags)
+	if !ok {
+		return nil, errNotExist(key)
+	}
+	return value, nil
+}
+
+// decodeItem returns the value of key stored in an item with the given data
+// and flags, and reports whether the item holds key.
+func decodeItem(key string, hashed bool, data []byte, flags uint32) ([]byte, bool) {
+	if !hashed {
+		return data, flags == flagPlain
+	}
+	stored, value, ok := decodeHashed(data)
+	if flags != flagHashed || !ok || stored != key {
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *memcachedCache) Set(key string, value []byte) error {
+	return c.set(key, value, c.ttl)
+}
+
+// SetWithTTL is like Set, but the server expires the entry once ttl has
+// elapsed, rounded up to whole seconds; see [driver.TTLSetter]. The shorter
+// of ttl and the cache's own TTL applies.
+func (c *memcachedCache) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ttl <= 0 || (c.ttl > 0 && c.ttl < ttl) {
+		ttl = c.ttl
+	}
+	return c.set(key, value, ttl)
+}
+
+func (c *memcachedCache) set(key string, value []byte, ttl time.Duration) error {
+	item, header, value := c.setCommand(key, value, ttl)
+	return c.nodeFor(item).do(func(mc *mcConn) error {
+		mc.bw.WriteString(header)
+		mc.bw.Write(value)
+		line, err := mc.command("\r\n")
+		if err == nil && line != "STORED" {
+			err = replyError(line)
+		}
+		return err
+	})
+}
+
+// setCommand returns the item key is stored under, and the header and data
+// of the set command storing value in it.
+func (c *memcachedCache) setCommand(key string, value []byte, ttl time.Duration) (string, string, []byte) {
+	item, hashed := c.itemKey(key)
+	flags := flagPlain
+	if hashed {
+		flags = flagHashed
+		value = encodeHashed(key, value)
+	}
+	header := fmt.Sprintf("set %s %d %d %d\r\n", item, flags, c.expiry(ttl), len(value))
+	return item, header, value
+}
+
+func (c *memcachedCache) Delete(key string) error {
+	item, hashed := c.itemKey(key)
+	if hashed {
+		// Make sure the item is this key's before deleting it.
+		if _, err := c.Get(key); err != nil {
+			return err
+		}
+	}
+	var deleted bool
+	err := c.nodeFor(item).do(func(mc *mcConn) error {
+		line, err := mc.command("delete " + item + "\r\n")
+		switch {
+		case err != nil:
+			return err
+		case line == "DELETED":
+			deleted = true
+		case line != "NOT_FOUND":
+			return replyError(line)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errNotExist(key)
+	}
+	return nil
+}
+
+// batchItem is a key of a batch operation, along with the item it is stored
+// under.
+type batchItem struct {
+	key    string
+	item   string
+	hashed bool
+}
+
+// groupByNode groups keys by the node holding them, dropping duplicates.
+func (c *memcachedCache) groupByNode(keys []string) map[*node][]batchItem {
+	groups := make(map[*node][]batchItem)
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		item, hashed := c.itemKey(key)
+		n := c.nodeFor(item)
+		groups[n] = append(groups[n], batchItem{key, item, hashed})
+	}
+	return groups
+}
+
+// GetMulti retrieves the values of keys with one multi-key get per server,
+// batchSize keys at a time.
+func (c *memcachedCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	for n, items := range c.groupByNode(keys) {
+		for chunk := range slices.Chunk(items, batchSize) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if err := n.getMulti(chunk, values); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// getMulti retrieves items from n, adding the values found to values.
+func (n *node) getMulti(items []batchItem, values map[string][]byte) error {
+	var cmd strings.Builder
+	cmd.WriteString("get")
+	for _, it := range items {
+		cmd.WriteString(" " + it.item)
+	}
+	cmd.WriteString("\r\n")
+	type stored struct {
+		data  []byte
+		flags uint32
+	}
+	var found map[string]stored
+	err := n.do(func(mc *mcConn) error {
+		found = make(map[string]stored, len(items))
+		if _, err := mc.bw.WriteString(cmd.String()); err != nil {
+			return err
+		}
+		if err := mc.bw.Flush(); err != nil {
+			return err
+		}
+		for {
+			line, err := mc.readLine()
+			if err != nil {
+				return err
+			}
+			if line == "END" {
+				return nil
+			}
+			item, data, flags, err := mc.readValue(line)
+			if err != nil {
+				return err
+			}
+			found[item] = stored{data, flags}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		if s, ok := found[it.item]; ok {
+			if value, ok := decodeItem(it.key, it.hashed, s.data, s.flags); ok {
+				values[it.key] = value
+			}
+		}
+	}
+	return nil
+}
+
+// SetMulti stores values with one pipeline of set commands per server,
+// batchSize values at a time. The cache's TTL applies as it does to Set.
+func (c *memcachedCache) SetMulti(ctx context.Context, values map[string][]byte) error {
+	groups := make(map[*node][][]byte)
+	for key, value := range values {
+		item, header, value := c.setCommand(key, value, c.ttl)
+		cmd := make([]byte, 0, len(header)+len(value)+2)
+		cmd = append(append(append(cmd, header...), value...), "\r\n"...)
+		n := c.nodeFor(item)
+		groups[n] = append(groups[n], cmd)
+	}
+	for n, cmds := range groups {
+		for chunk := range slices.Chunk(cmds, batchSize) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := n.pipeline(chunk, "STORED"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteMulti removes keys with one pipeline of delete commands per server,
+// batchSize keys at a time. Hashed keys are only deleted if their items hold
+// them.
+func (c *memcachedCache) DeleteMulti(ctx context.Context, keys []string) error {
+	var hashed []string
+	for _, key := range keys {
+		if _, h := c.itemKey(key); h {
+			hashed = append(hashed, key)
+		}
+	}
+	var owned map[string][]byte
+	if len(hashed) > 0 {
+		var err error
+		if owned, err = c.GetMulti(ctx, hashed); err != nil {
+			return err
+		}
+	}
+	for n, items := range c.groupByNode(keys) {
+		cmds := make([][]byte, 0, len(items))
+		for _, it := range items {
+			if _, ok := owned[it.key]; it.hashed && !ok {
+				continue
+			}
+			cmds = append(cmds, []byte("delete "+it.item+"\r\n"))
+		}
+		for chunk := range slices.Chunk(cmds, batchSize) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := n.pipeline(chunk, "DELETED", "NOT_FOUND"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pipeline sends cmds to n and then reads their replies, so that they take a
+// single round trip. Replies other than the expected ones are errors; all
+// replies are read, and the first error is returned.
+func (n *node) pipeline(cmds [][]byte, expected ...string) error {
+	return n.do(func(mc *mcConn) error {
+		for _, cmd := range cmds {
+			if _, err := mc.bw.Write(cmd); err != nil {
+				return err
+			}
+		}
+		if err := mc.bw.Flush(); err != nil {
+			return err
+		}
+		var firstErr error
+		for range cmds {
+			line, err := mc.readLine()
+			if err != nil {
+				return err
+			}
+			if slices.Contains(expected, line) {
+				continue
+			}
+			err = replyError(line)
+			var serr serverError
+			if !errors.As(err, &serr) {
+				return err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	})
+}
+
+// Close closes the idle connections; connections in use are closed when
+// they are returned.
+func (c *memcachedCache) Close() error {
+	var errs []error
+	for _, n := range c.nodes {
+		errs = append(errs, n.close())
+	}
+	return errors.Join(errs...)
+}
+
+func encodeHashed(key string, value []byte) []byte {
+	b := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(key)+len(value)), uint64(len(key)))
+	b = append(b, key...)
+	return append(b, value...)
+}
+
+func decodeHashed(data []byte) (string, []byte, bool) {
+	n, m := binary.Uvarint(data)
+	if m <= 0 || n > uint64(len(data)-m) {
+		return "", nil, false
+	}
+	data = data[m:]
+	return string(data[:n]), data[n:], true
+}
+
+// replyError returns the error for an unexpected reply line.
+func replyError(line string) error {
+	switch {
+	case line == "ERROR",
+		strings.HasPrefix(line, "CLIENT_ERROR "),
+		strings.HasPrefix(line, "SERVER_ERROR "):
+		return serverError(line)
+	default:
+		return fmt.Errorf("%w: unexpected reply %q", errProtocol, line)
+	}
+}
+
+// serverError is an error reply sent by the server. Unlike other errors, it
+// leaves the connection usable.
+type serverError string
+
+func (e serverError) Error() string { return "memcached: " + string(e) }
+
+// node is a server, with its pool of idle connections.
+type node struct {
+	c    *memcachedCache
+	addr string
+
+	mu     sync.Mutex
+	idle   []*mcConn
+	closed bool
+}
+
+// mcConn is a connection to a server.
+type mcConn struct {
+	nc net.Conn
+	br *bufio.Reader
+	bw *bufio.Writer
+}
+
+func (n *node) dial() (*mcConn, error) {
+	nc, err := net.DialTimeout("tcp", n.addr, n.c.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("memcached: failed to connect to %s: %w", n.addr, err)
+	}
+	return &mcConn{nc: nc, br: bufio.NewReader(nc), bw: bufio.NewWriter(nc)}, nil
+}
+
+// get returns an idle connection, or dials a new one, and reports whether
+// the connection was idle.
+func (n *node) get() (*mcConn, bool, error) {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil, false, ErrClosed
+	}
+	if k := len(n.idle); k > 0 {
+		mc := n.idle[k-1]
+		n.idle = n.idle[:k-1]
+		n.mu.Unlock()
+		return mc, true, nil
+	}
+	n.mu.Unlock()
+	mc, err := n.dial()
+	return mc, false, err
+}
+
+// put returns mc to the pool, unless err shows that the connection can no
+// longer be used or the pool is full.
+func (n *node) put(mc *mcConn, err error) {
+	var serr serverError
+	if err == nil || errors.As(err, &serr) {
+		n.mu.Lock()
+		if !n.closed && len(n.idle) < n.c.poolSize {
+			n.idle = append(n.idle, mc)
+			n.mu.Unlock()
+			return
+		}
+		n.mu.Unlock()
+	}
+	mc.nc.Close()
+}
+
+// do runs fn on a pooled connection. The commands sent are idempotent, so fn
+// is retried once on a new connection if the server closed an idle one.
+func (n *node) do(fn func(*mcConn) error) error {
+	mc, idle, err := n.get()
+	if err != nil {
+		return err
+	}
+	err = n.run(mc, fn)
+	if err != nil && idle && isClosedConn(err) {
+		if mc, err = n.dial(); err != nil {
+			return err
+		}
+		err = n.run(mc, fn)
+	}
+	return err
+}
+
+func (n *node) run(mc *mcConn, fn func(*mcConn) error) error {
+	err := mc.nc.SetDeadline(time.Now().Add(n.c.timeout))
+	if err == nil {
+		err = fn(mc)
+	}
+	n.put(mc, err)
+	return err
+}
+
+func isClosedConn(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+func (n *node) close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	var errs []error
+	for _, mc := range n.idle {
+		errs = append(errs, mc.nc.Close())
+	}
+	n.idle = nil
+	return errors.Join(errs...)
+}
+
+// command writes s, flushes the pending output, and reads a reply line.
+func (mc *mcConn) command(s string) (string, error) {
+	if _, err := mc.bw.WriteString(s); err != nil {
+		return "", err
+	}
+	if err := mc.bw.Flush(); err != nil {
+		return "", err
+	}
+	return mc.readLine()
+}
+
+// readLine reads a CRLF-terminated line, without the terminator.
+func (mc *mcConn) readLine() (string, error) {
+	line, err := mc.br.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return "", fmt.Errorf("%w: line too long", errProtocol)
+		}
+		return "", err
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return "", fmt.Errorf("%w: line not terminated by CRLF", errProtocol)
+	}
+	return string(line[:len(line)-2]), nil
+}
+
+// readValue reads the data of the "VALUE <key> <flags> <bytes> [<cas>]" line
+// of a get reply, and returns it along with the key and flags.
+func (mc *mcConn) readValue(line string) (string, []byte, uint32, error) {
+	f := strings.Fields(line)
+	if len(f) < 4 || f[0] != "VALUE" {
+		return "", nil, 0, replyError(line)
+	}
+	flags, err := strconv.ParseUint(f[2], 10, 32)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("%w: bad flags in %q", errProtocol, line)
+	}
+	size, err := strconv.Atoi(f[3])
+	if err != nil || size < 0 || size > maxValueLen {
+		return "", nil, 0, fmt.Errorf("%w: bad length in %q", errProtocol, line)
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(mc.br, data); err != nil {
+		return "", nil, 0, err
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		return "", nil, 0, fmt.Errorf("%w: value not terminated by CRLF", errProtocol)
+	}
+	return f[1], data[:size:size], uint32(flags), nil
+}
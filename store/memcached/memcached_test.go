@@ -0,0 +1,409 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+func openCache(t *testing.T, servers []string, opts ...Option) *memcachedCache {
+	t.Helper()
+	cache, err := Open(servers, opts...)
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestMemcached_Acceptance(t *testing.T) {
+	a, b := newFakeServer(t), newFakeServer(t)
+	var n int
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		// A fresh prefix per test keeps the shared servers' keys apart.
+		n++
+		cache, err := Open([]string{a.addr(), b.addr()}, WithPrefix("test"+strconv.Itoa(n)+":"))
+		testutil.RequireNoError(t, err)
+		return cache, func() { cache.Close() }
+	}), acceptance.WithExpiry(func(_ driver.Conn, d time.Duration) {
+		a.advance(d)
+		b.advance(d)
+	}), acceptance.WithMaxValueSize(fakeMaxItem))
+}
+
+func TestMemcached_Acceptance_DSN(t *testing.T) {
+	a, b := newFakeServer(t), newFakeServer(t)
+	var n int
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		n++
+		u, err := url.Parse("memcached://" + a.addr() + "," + b.addr() + "?ttl=1h&prefix=dsn" + strconv.Itoa(n))
+		testutil.RequireNoError(t, err)
+		cache, err := fromURL(u)
+		testutil.RequireNoError(t, err)
+		return cache, func() { cache.Close() }
+	}), acceptance.WithExpiry(func(_ driver.Conn, d time.Duration) {
+		a.advance(d)
+		b.advance(d)
+	}), acceptance.WithMaxValueSize(fakeMaxItem))
+}
+
+func Test_fromURL(t *testing.T) {
+	srv := newFakeServer(t)
+	tests := []struct {
+		name      string
+		dsn       string
+		assertion func(tt *testing.T, got *memcachedCache, err error)
+	}{
+		{
+			name: "all parameters",
+			dsn:  "memcached://" + srv.addr() + "," + srv.addr() + "?prefix=p:&timeout=2s&ttl=5m&pool_size=4",
+			assertion: func(tt *testing.T, got *memcachedCache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, "p:", got.prefix)
+				testutil.AssertEqual(tt, 2*time.Second, got.timeout)
+				testutil.AssertEqual(tt, 5*time.Minute, got.ttl)
+				testutil.AssertEqual(tt, 4, got.poolSize)
+				testutil.AssertEqual(tt, 2, len(got.nodes))
+			},
+		},
+		{
+			name: "no servers",
+			dsn:  "memcached://",
+			assertion: func(tt *testing.T, got *memcachedCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "unreachable server",
+			dsn:  "memcached://" + srv.addr() + ",127.0.0.1:1?timeout=100ms",
+			assertion: func(tt *testing.T, got *memcachedCache, err error) {
+				testutil.RequireError(tt, err)
+			},
+		},
+		{
+			name: "invalid prefix",
+			dsn:  "memcached://" + srv.addr() + "?prefix=" + url.QueryEscape("a b"),
+			assertion: func(tt *testing.T, got *memcachedCache, err error) {
+				testutil.RequireError(tt, err)
+			},
+		},
+		{
+			name: "invalid timeout",
+			dsn:  "memcached://" + srv.addr() + "?timeout=soon",
+			assertion: func(tt *testing.T, got *memcachedCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid ttl",
+			dsn:  "memcached://" + srv.addr() + "?ttl=-1s",
+			assertion: func(tt *testing.T, got *memcachedCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid pool_size",
+			dsn:  "memcached://" + srv.addr() + "?pool_size=many",
+			assertion: func(tt *testing.T, got *memcachedCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.dsn)
+			testutil.RequireNoError(t, err)
+			got, err := fromURL(u)
+			if err == nil {
+				t.Cleanup(func() { got.Close() })
+			}
+			tt.assertion(t, got, err)
+		})
+	}
+}
+
+func TestMemcached_LongKeys(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := openCache(t, []string{srv.addr()}, WithPrefix("p:"))
+	tests := []struct {
+		name   string
+		key    string
+		hashed bool
+	}{
+		{"Short", "https://example.com/#1", false},
+		{"Long", "https://example.com/" + strings.Repeat("a", 300) + "#1", true},
+		{"Spaces", "https://example.com/a b", true},
+		{"Control", "https://example.com/\x01", true},
+		{"HashedPrefix", hashedKeyPrefix + strings.Repeat("0", 64), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.RequireNoError(t, cache.Set(tt.key, []byte("value")))
+			item, hashed := cache.itemKey(tt.key)
+			testutil.AssertTrue(t, tt.hashed == hashed)
+			testutil.AssertTrue(t, len(item) <= maxKeyLen && validKey(item), "invalid memcached key "+item)
+			stored, ok := srv.item(item)
+			testutil.RequireTrue(t, ok)
+			if hashed {
+				key, _, ok := decodeHashed(stored.value)
+				testutil.AssertTrue(t, ok && key == tt.key, "original key not kept in the value")
+			}
+			got, err := cache.Get(tt.key)
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, "value", string(got))
+			testutil.RequireNoError(t, cache.Delete(tt.key))
+			_, err = cache.Get(tt.key)
+			testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+		})
+	}
+}
+
+func TestMemcached_HashedKeyMismatch(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := openCache(t, []string{srv.addr()})
+	long := strings.Repeat("a", 300)
+	item, _ := cache.itemKey(long)
+	other := openCache(t, []string{srv.addr()})
+
+	// An item under the hashed name that holds another key, or was not
+	// stored hashed, is not returned or deleted.
+	srv.mu.Lock()
+	srv.items[item] = fakeItem{flags: flagHashed, value: encodeHashed("other", []byte("v"))}
+	srv.mu.Unlock()
+	_, err := cache.Get(long)
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	testutil.RequireErrorIs(t, other.Delete(long), driver.ErrNotExist)
+	_, ok := srv.item(item)
+	testutil.AssertTrue(t, ok, "deleted another key's item")
+
+	srv.mu.Lock()
+	srv.items[item] = fakeItem{flags: flagPlain, value: []byte("v")}
+	srv.mu.Unlock()
+	_, err = cache.Get(long)
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+}
+
+func TestMemcached_Batch(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := openCache(t, []string{srv.addr()}, WithPrefix("p:"), WithTTL(time.Minute))
+	long := strings.Repeat("a", 300)
+	values := map[string][]byte{long: []byte("long")}
+	keys := []string{long}
+	for i := range batchSize {
+		k := strconv.Itoa(i)
+		values[k] = []byte("v" + k)
+		keys = append(keys, k)
+	}
+	testutil.RequireNoError(t, cache.SetMulti(t.Context(), values))
+	testutil.AssertEqual(t, len(values), len(srv.keys()))
+	it, ok := srv.item("p:0")
+	testutil.RequireTrue(t, ok, "value not stored under the prefix")
+	testutil.AssertTrue(t, !it.expires.IsZero(), "cache TTL not applied")
+
+	got, err := cache.GetMulti(t.Context(), append(keys, "missing", "0"))
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, maps.EqualFunc(got, values, bytes.Equal), "GetMulti returned %d values", len(got))
+	testutil.AssertEqual(t, 2, srv.count("get"))
+
+	// Another key's item under a hashed name is not deleted.
+	other := strings.Repeat("b", 300)
+	item, _ := cache.itemKey(other)
+	srv.mu.Lock()
+	srv.items[item] = fakeItem{flags: flagHashed, value: encodeHashed("other", []byte("v"))}
+	srv.mu.Unlock()
+	testutil.RequireNoError(t, cache.DeleteMulti(t.Context(), append(keys, "missing", other)))
+	testutil.AssertTrue(t, slices.Equal(srv.keys(), []string{item}), "left %q", srv.keys())
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = cache.GetMulti(ctx, keys)
+	testutil.RequireErrorIs(t, err, context.Canceled)
+}
+
+func TestMemcached_TTL(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := openCache(t, []string{srv.addr()}, WithTTL(1500*time.Millisecond))
+	testutil.RequireNoError(t, cache.Set("k", []byte("v")))
+	srv.advance(time.Second)
+	_, err := cache.Get("k")
+	testutil.RequireNoError(t, err, "expired before its time to live")
+	srv.advance(time.Second)
+	_, err = cache.Get("k")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+}
+
+func TestMemcached_SetWithTTL(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := openCache(t, []string{srv.addr()}, WithTTL(time.Minute))
+	testutil.RequireNoError(t, cache.SetWithTTL(t.Context(), "short", []byte("v"), 1500*time.Millisecond))
+	testutil.RequireNoError(t, cache.SetWithTTL(t.Context(), "long", []byte("v"), time.Hour))
+
+	srv.advance(3 * time.Second)
+	_, err := cache.Get("short")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	_, err = cache.Get("long")
+	testutil.RequireNoError(t, err)
+	srv.advance(time.Minute)
+	_, err = cache.Get("long")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "outlived the cache TTL")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	testutil.RequireErrorIs(t, cache.SetWithTTL(ctx, "k", []byte("v"), time.Second), context.Canceled)
+}
+
+func Test_memcachedCache_expiry(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		ttl  time.Duration
+		want int64
+	}{
+		{0, 0},
+		{time.Millisecond, 1},
+		{time.Hour, 3600},
+		{maxRelativeExpiry, int64(maxRelativeExpiry / time.Second)},
+		{maxRelativeExpiry + time.Second, now.Unix() + int64(maxRelativeExpiry/time.Second) + 1},
+	}
+	for _, tt := range tests {
+		c := &memcachedCache{now: func() time.Time { return now }}
+		testutil.AssertEqual(t, tt.want, c.expiry(tt.ttl), tt.ttl.String())
+	}
+}
+
+func TestMemcached_Distribution(t *testing.T) {
+	var servers []*fakeServer
+	var addrs []string
+	for range 3 {
+		srv := newFakeServer(t)
+		servers = append(servers, srv)
+		addrs = append(addrs, srv.addr())
+	}
+	cache := openCache(t, addrs)
+	const n = 300
+	for i := range n {
+		testutil.RequireNoError(t, cache.Set("key"+strconv.Itoa(i), []byte("v")))
+	}
+	for i, srv := range servers {
+		got := len(srv.keys())
+		testutil.AssertTrue(t, got > n/6, "server "+strconv.Itoa(i)+" holds "+strconv.Itoa(got)+" keys")
+	}
+
+	// Adding a server moves about a quarter of the keys.
+	grown := newRing(append(addrs, "127.0.0.1:1"))
+	var moved int
+	for i := range n {
+		key := "key" + strconv.Itoa(i)
+		if grown.node(key) != cache.ring.node(key) {
+			moved++
+		}
+	}
+	testutil.AssertTrue(t, moved > 0 && moved < n/2, strconv.Itoa(moved)+" of the keys moved")
+}
+
+func TestMemcached_ServerError(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.maxItem = 4
+	cache := openCache(t, []string{srv.addr()})
+	var serr serverError
+	testutil.RequireErrorAs(t, cache.Set("k", []byte("too large")), &serr)
+	// The connection stays usable.
+	testutil.RequireNoError(t, cache.Set("k", []byte("ok")))
+}
+
+func Test_mcConn_readValue(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		data string
+		want string
+		err  error
+	}{
+		{name: "value", line: "VALUE k 0 2", data: "ok\r\n", want: "ok"},
+		{name: "bad length", line: "VALUE k 0 -1", err: errProtocol},
+		{name: "length over limit", line: "VALUE k 0 " + strconv.Itoa(maxValueLen+1), err: errProtocol},
+		{name: "not terminated", line: "VALUE k 0 2", data: "okay", err: errProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := &mcConn{br: bufio.NewReader(strings.NewReader(tt.data))}
+			_, got, _, err := mc.readValue(tt.line)
+			if tt.err != nil {
+				testutil.RequireErrorIs(t, err, tt.err)
+				return
+			}
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestMemcached_Pool(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := openCache(t, []string{srv.addr()}, WithPoolSize(2))
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Go(func() {
+			key := strconv.Itoa(i)
+			for range 20 {
+				if err := cache.Set(key, []byte(key)); err != nil {
+					t.Error(err)
+					return
+				}
+				got, err := cache.Get(key)
+				if err != nil || string(got) != key {
+					t.Errorf("Get(%q) = %q, %v", key, got, err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	n := cache.nodes[0]
+	n.mu.Lock()
+	idle := len(n.idle)
+	n.mu.Unlock()
+	testutil.AssertTrue(t, idle <= 2, "pool exceeds pool_size")
+}
+
+func TestMemcached_ReconnectsAfterServerClosedConnection(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := openCache(t, []string{srv.addr()})
+	testutil.RequireNoError(t, cache.Set("k", []byte("v")))
+	srv.dropConns()
+	got, err := cache.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "v", string(got))
+}
+
+func TestMemcached_Closed(t *testing.T) {
+	srv := newFakeServer(t)
+	cache, err := Open([]string{srv.addr()})
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, cache.Close())
+	_, err = cache.Get("k")
+	testutil.RequireErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memcached
+
+import (
+	"cmp"
+	"crypto/md5" //nolint:gosec // (G501) Used for key distribution, as in ketama.
+	"encoding/binary"
+	"slices"
+	"strconv"
+)
+
+// pointsPerNode is the number of points each node has on the ring; each MD5
+// digest yields four.
+const pointsPerNode = 160
+
+type point struct {
+	hash uint32
+	node int
+}
+
+// ring distributes keys over nodes with consistent hashing, compatible with
+// the ketama scheme used by most memcached clients: adding or removing a node
+// only moves the keys that hash to its points.
+type ring struct {
+	points []point
+}
+
+func newRing(addrs []string) *ring {
+	r := &ring{points: make([]point, 0, len(addrs)*pointsPerNode)}
+	for i, addr := range addrs {
+		for j := range pointsPerNode / 4 {
+			sum := md5.Sum([]byte(addr + "-" + strconv.Itoa(j))) //nolint:gosec // See import.
+			for k := range 4 {
+				h := binary.LittleEndian.Uint32(sum[k*4:])
+				r.points = append(r.points, point{h, i})
+			}
+		}
+	}
+	slices.SortFunc(r.points, func(a, b point) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(a.node, b.node))
+	})
+	return r
+}
+
+// node returns the index of the node that key belongs to.
+func (r *ring) node(key string) int {
+	sum := md5.Sum([]byte(key)) //nolint:gosec // See import.
+	h := binary.LittleEndian.Uint32(sum[:4])
+	i, _ := slices.BinarySearchFunc(r.points, h, func(p point, h uint32) int {
+		return cmp.Compare(p.hash, h)
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package namespace implements a cache backend that confines its keys to a
+// namespace of another backend, so that several services, or several
+// transports with different policies, can share one backend without their
+// keys colliding.
+//
+// Keys are stored as "<namespace>:<key>". Namespaces cannot contain ':', so
+// no namespace overlaps another, and cannot be "http" or "https", so none
+// overlaps the URL keys of a backend shared without a namespace. Listing keys
+// returns only those of the namespace, without the namespace, and [Clear]
+// deletes them all.
+//
+// [github.com/bartventer/httpcache/store.WithNamespace] applies a namespace
+// to a backend of any scheme when opening it, without this package.
+//
+// # Configuration Parameters
+//
+// The following DSN query parameters are supported:
+//
+//   - ns (required): The namespace
+//   - dsn (required): Query-escaped DSN of the backend shared; its driver must be registered
+//
+// # Usage Examples
+//
+//	The DSN and equivalent programmatic usage are shown below.
+//
+//	 Namespace "billing" of a Redis server:
+//
+//		namespace://?ns=billing&dsn=redis%3A%2F%2Flocalhost%3A6379
+//		namespace.New(redisConn, "billing")
+//		store.Open("redis://localhost:6379", store.WithNamespace("billing"))
+package namespace
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/internal/keyspace"
+	"github.com/bartventer/httpcache/store/internal/registry"
+)
+
+const Scheme = "namespace"
+
+// Driver opens namespace:// DSNs. It is registered in the default registry
+// under [Scheme]; register it in a [store.Registry] to open the DSNs it names
+// with the drivers of that registry.
+var Driver driver.WrapperDriver = registry.WrapperFunc(fromURL)
+
+//nolint:gochecknoinits // We use init to register the driver.
+func init() {
+	store.Register(Scheme, Driver)
+}
+
+var (
+	ErrInvalidParam = errors.New("namespace: invalid DSN parameter")
+
+	// ErrInvalidNamespace is returned for an empty namespace, one
+	// containing ':', or "http" or "https".
+	ErrInvalidNamespace = keyspace.ErrInvalidNamespace
+)
+
+func fromURL(u *url.URL, o driver.Opener) (driver.Conn, error) {
+	q := u.Query()
+	ns := q.Get("ns")
+	if err := keyspace.Validate(ns); err != nil {
+		return nil, errors.Join(ErrInvalidParam, err)
+	}
+	dsn := q.Get("dsn")
+	if dsn == "" {
+		return nil, fmt.Errorf("%w: dsn is required", ErrInvalidParam)
+	}
+	conn, err := o.OpenConn(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("namespace: failed to open backend: %w", err)
+	}
+	return keyspace.Adopt(conn, ns)
+}
+
+// New returns a cache that stores its keys in namespace ns of conn. The
+// returned Conn implements [driver.ConnContext], [driver.Batcher],
+// [driver.Locker], [expapi.KeyLister] if conn does, listing the keys of the
+// namespace, [driver.TTLSetter] and [driver.Streamer] if conn does, and
+// [io.Closer].
+// Closing it does not close conn, which may be shared by other namespaces; a
+// Conn opened from a DSN closes its backend.
+//
+// [expapi.KeyLister]: https://pkg.go.dev/github.com/bartventer/httpcache/store/expapi#KeyLister
+func New(conn driver.Conn, ns string) (driver.Conn, error) {
+	return keyspace.New(conn, ns)
+}
+
+// Clear deletes every key in the namespace of conn, a Conn returned by [New]
+// or opened with [store.WithNamespace]. It fails with an error wrapping
+// [errors.ErrUnsupported] if the shared backend cannot list its keys.
+func Clear(conn driver.Conn) error {
+	c, ok := conn.(keyspace.Clearer)
+	if !ok {
+		return fmt.Errorf("namespace: cannot clear %T: %w", conn, errors.ErrUnsupported)
+	}
+	return c.Clear()
+}
@@ -0,0 +1,375 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+	"github.com/bartventer/httpcache/store/fscache"
+	"github.com/bartventer/httpcache/store/internal/conntest"
+	"github.com/bartventer/httpcache/store/internal/registry"
+	"github.com/bartventer/httpcache/store/memcache"
+)
+
+func newCache(t *testing.T, conn driver.Conn, ns string) driver.Conn {
+	t.Helper()
+	c, err := New(conn, ns)
+	testutil.RequireNoError(t, err)
+	return c
+}
+
+func TestNamespace_Acceptance(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		shared := memcache.Open()
+		// Keys of another namespace must not leak into this one.
+		testutil.RequireNoError(t, newCache(t, shared, "other").Set("foo", []byte("other")))
+		return newCache(t, shared, "test"), func() {}
+	}))
+}
+
+func TestNamespace_Acceptance_DSN(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		u, err := url.Parse("namespace://?ns=test&dsn=" + url.QueryEscape("memcache://?max_entries=100"))
+		testutil.RequireNoError(t, err)
+		conn, err := fromURL(u, registry.Default())
+		testutil.RequireNoError(t, err)
+		return conn, func() {}
+	}))
+}
+
+func TestNamespace_Acceptance_TTL(t *testing.T) {
+	var inner *expiringConn // backend of the cache under test
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		inner = newExpiringConn()
+		return newCache(t, inner, "test"), func() {}
+	}), acceptance.WithExpiry(func(_ driver.Conn, d time.Duration) { inner.advance(d) }))
+}
+
+func TestNamespace_Acceptance_Stream(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		fc, err := fscache.Open("test", fscache.WithBaseDir(t.TempDir()))
+		testutil.RequireNoError(t, err)
+		return newCache(t, fc, "test"), func() { fc.Close() }
+	}))
+}
+
+func Test_fromURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		dsn       string
+		assertion func(tt *testing.T, got driver.Conn, err error)
+	}{
+		{
+			name: "valid",
+			dsn:  "namespace://?ns=a&dsn=memcache%3A%2F%2F",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireNoError(tt, err)
+				_, ok := got.(expapi.KeyLister)
+				testutil.AssertTrue(tt, ok, "backend keys cannot be listed")
+			},
+		},
+		{
+			name: "missing ns",
+			dsn:  "namespace://?dsn=memcache%3A%2F%2F",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "ns with separator",
+			dsn:  "namespace://?ns=a:b&dsn=memcache%3A%2F%2F",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+				testutil.RequireErrorIs(tt, err, ErrInvalidNamespace)
+			},
+		},
+		{
+			name: "ns overlapping URL keys",
+			dsn:  "namespace://?ns=https&dsn=memcache%3A%2F%2F",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidNamespace)
+			},
+		},
+		{
+			name: "missing dsn",
+			dsn:  "namespace://?ns=a",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "unknown backend",
+			dsn:  "namespace://?ns=a&dsn=nosuchscheme%3A%2F%2F",
+			assertion: func(tt *testing.T, got driver.Conn, err error) {
+				testutil.RequireError(tt, err)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.dsn)
+			testutil.RequireNoError(t, err)
+			got, err := fromURL(u, registry.Default())
+			tt.assertion(t, got, err)
+		})
+	}
+}
+
+func TestNamespace_Isolation(t *testing.T) {
+	shared := memcache.Open()
+	a := newCache(t, shared, "a")
+	ab := newCache(t, shared, "ab")
+	testutil.RequireNoError(t, a.Set("https://api/x#0", []byte("a")))
+	testutil.RequireNoError(t, ab.Set("https://api/x#0", []byte("ab")))
+
+	got, err := a.Get("https://api/x#0")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "a", string(got))
+	got, err = shared.Get("ab:https://api/x#0")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "ab", string(got))
+
+	keys, err := a.(expapi.KeyLister).Keys("")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, slices.Equal([]string{"https://api/x#0"}, keys), "Keys = "+strings.Join(keys, ", "))
+
+	testutil.RequireNoError(t, ab.Delete("https://api/x#0"))
+	_, err = a.Get("https://api/x#0")
+	testutil.RequireNoError(t, err, "delete crossed namespaces")
+}
+
+func TestClear(t *testing.T) {
+	shared := memcache.Open()
+	a := newCache(t, shared, "a")
+	b := newCache(t, shared, "b")
+	for _, key := range []string{"k1", "k2", "k3"} {
+		testutil.RequireNoError(t, a.Set(key, []byte("v")))
+		testutil.RequireNoError(t, b.Set(key, []byte("v")))
+	}
+	testutil.RequireNoError(t, Clear(a))
+	keys, err := a.(expapi.KeyLister).Keys("")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 0, len(keys))
+	keys, err = b.(expapi.KeyLister).Keys("")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 3, len(keys))
+}
+
+// closingConn counts the calls to Close.
+type closingConn struct {
+	driver.Conn
+	closed int
+}
+
+func (c *closingConn) Close() error { c.closed++; return nil }
+
+func TestNew_Close(t *testing.T) {
+	shared := &closingConn{Conn: memcache.Open()}
+	a := newCache(t, shared, "a")
+	b := newCache(t, shared, "b")
+	testutil.RequireNoError(t, a.(io.Closer).Close())
+	testutil.AssertEqual(t, 0, shared.closed, "shared backend closed")
+	testutil.RequireNoError(t, b.Set("k", []byte("v")))
+
+	store.Register("closingtest", driver.DriverFunc(func(*url.URL) (driver.Conn, error) {
+		return shared, nil
+	}))
+	t.Cleanup(func() { store.Unregister("closingtest") })
+	u, err := url.Parse("namespace://?ns=a&dsn=closingtest%3A%2F%2F")
+	testutil.RequireNoError(t, err)
+	c, err := fromURL(u, registry.Default())
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, c.(io.Closer).Close())
+	testutil.AssertEqual(t, 1, shared.closed, "backend opened from the DSN not closed")
+}
+
+func TestDriver_Registry(t *testing.T) {
+	backend := memcache.Open()
+	r := store.NewRegistry()
+	r.Register("private", driver.DriverFunc(func(*url.URL) (driver.Conn, error) {
+		return backend, nil
+	}))
+	r.Register(Scheme, Driver)
+	const dsn = "namespace://?ns=a&dsn=private%3A%2F%2F"
+
+	// The wrapped DSN is opened in the registry that opens the namespace.
+	c, err := r.Open(dsn)
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, c.Set("k", []byte("v")))
+	_, err = backend.Get("a:k")
+	testutil.RequireNoError(t, err)
+	_, err = store.Open(dsn)
+	testutil.RequireErrorIs(t, err, registry.ErrUnknownDriver)
+}
+
+type ctxKey struct{}
+
+// ctxConn records the keys read with a context carrying ctxKey.
+type ctxConn struct {
+	driver.ConnContext
+	driver.Conn
+	keys []string
+}
+
+func (c *ctxConn) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if ctx.Value(ctxKey{}) != nil {
+		c.keys = append(c.keys, key)
+	}
+	return c.ConnContext.GetContext(ctx, key)
+}
+
+func TestNew_ForwardsContext(t *testing.T) {
+	mc := memcache.Open()
+	inner := &ctxConn{ConnContext: mc, Conn: mc}
+	c := newCache(t, inner, "a")
+	ctx := context.WithValue(t.Context(), ctxKey{}, true)
+	_, err := c.(driver.ConnContext).GetContext(ctx, "k")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	testutil.AssertTrue(t, slices.Equal(inner.keys, []string{"a:k"}), "context not passed to the backend")
+}
+
+// batchConn records the keys of batch reads.
+type batchConn struct {
+	driver.Conn
+	keys []string
+}
+
+func (c *batchConn) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	c.keys = append(c.keys, keys...)
+	return driver.AsBatcher(c.Conn).GetMulti(ctx, keys)
+}
+
+func (c *batchConn) SetMulti(ctx context.Context, values map[string][]byte) error {
+	return driver.AsBatcher(c.Conn).SetMulti(ctx, values)
+}
+
+func (c *batchConn) DeleteMulti(ctx context.Context, keys []string) error {
+	return driver.AsBatcher(c.Conn).DeleteMulti(ctx, keys)
+}
+
+func TestNew_ForwardsBatch(t *testing.T) {
+	inner := &batchConn{Conn: memcache.Open()}
+	c := newCache(t, inner, "a").(driver.Batcher)
+	testutil.RequireNoError(t, c.SetMulti(t.Context(), map[string][]byte{"k": []byte("v")}))
+	values, err := c.GetMulti(t.Context(), []string{"k", "missing"})
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 1, len(values))
+	testutil.AssertEqual(t, "v", string(values["k"]))
+	testutil.AssertTrue(t, slices.Equal(inner.keys, []string{"a:k", "a:missing"}), "batch not passed to the backend")
+	_, err = inner.Get("a:k")
+	testutil.RequireNoError(t, err)
+}
+
+func TestNew_ForwardsLock(t *testing.T) {
+	var locked []string
+	c := newCache(t, conntest.Locking(memcache.Open(), &locked), "a")
+	unlock, err := c.(driver.Locker).Lock("k")
+	testutil.RequireNoError(t, err)
+	unlock()
+	testutil.AssertTrue(t, slices.Equal(locked, []string{"a:k"}), "lock not taken from the backend")
+
+	// Without a locking backend, the lock only serializes this process.
+	c = newCache(t, memcache.Open(), "a")
+	unlock, err = c.(driver.Locker).Lock("k")
+	testutil.RequireNoError(t, err)
+	unlock()
+}
+
+func TestClear_Unsupported(t *testing.T) {
+	c := newCache(t, conntest.Plain(memcache.Open()), "a")
+	_, ok := c.(expapi.KeyLister)
+	testutil.AssertTrue(t, !ok, "keys listed without a listing backend")
+	testutil.RequireErrorIs(t, Clear(c), errors.ErrUnsupported)
+	testutil.RequireErrorIs(t, Clear(memcache.Open()), errors.ErrUnsupported)
+}
+
+// expiringConn is a cache implementing driver.TTLSetter whose entries expire
+// on a clock advanced by hand.
+type expiringConn struct {
+	mu      sync.Mutex
+	now     time.Time
+	values  map[string][]byte
+	expires map[string]time.Time
+}
+
+func newExpiringConn() *expiringConn {
+	return &expiringConn{
+		now:     time.Unix(1_700_000_000, 0),
+		values:  make(map[string][]byte),
+		expires: make(map[string]time.Time),
+	}
+}
+
+func (c *expiringConn) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *expiringConn) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if exp, ok := c.expires[key]; ok && !c.now.Before(exp) {
+		delete(c.values, key)
+		delete(c.expires, key)
+	}
+	value, ok := c.values[key]
+	if !ok {
+		return nil, driver.ErrNotExist
+	}
+	return slices.Clone(value), nil
+}
+
+func (c *expiringConn) Set(key string, value []byte) error {
+	return c.SetWithTTL(context.Background(), key, value, 0)
+}
+
+func (c *expiringConn) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = slices.Clone(value)
+	delete(c.expires, key)
+	if ttl > 0 {
+		c.expires[key] = c.now.Add(ttl)
+	}
+	return nil
+}
+
+func (c *expiringConn) Delete(key string) error {
+	if _, err := c.Get(key); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+	delete(c.expires, key)
+	return nil
+}
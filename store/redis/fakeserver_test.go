@@ -0,0 +1,373 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"maps"
+	"math/big"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+// fakeServer is an in-process stand-in for a Redis server, implementing the
+// commands used by the driver.
+type fakeServer struct {
+	ln       net.Listener
+	user     string // empty for the default user
+	password string // empty if AUTH is not required
+
+	mu       sync.Mutex
+	dbs      map[int]map[string]fakeEntry
+	conns    map[net.Conn]bool
+	accepted int
+	commands map[string]int // number of commands executed, by name
+	now      time.Time      // zero for the wall clock
+	wg       sync.WaitGroup
+}
+
+type fakeEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+type fakeOption func(*fakeServer)
+
+func withPassword(user, password string) fakeOption {
+	return func(s *fakeServer) { s.user, s.password = user, password }
+}
+
+// newFakeServer starts a fake server, serving TLS if cfg is not nil.
+func newFakeServer(t *testing.T, cfg *tls.Config, opts ...fakeOption) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	testutil.RequireNoError(t, err)
+	if cfg != nil {
+		ln = tls.NewListener(ln, cfg)
+	}
+	s := &fakeServer{
+		ln:       ln,
+		dbs:      make(map[int]map[string]fakeEntry),
+		conns:    make(map[net.Conn]bool),
+		commands: make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.wg.Go(s.serve)
+	t.Cleanup(func() {
+		ln.Close()
+		s.dropConns()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *fakeServer) addr() string { return s.ln.Addr().String() }
+
+func (s *fakeServer) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[nc] = true
+		s.accepted++
+		s.mu.Unlock()
+		s.wg.Go(func() { s.handle(nc) })
+	}
+}
+
+// dropConns closes all client connections, as a server restart would.
+func (s *fakeServer) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for nc := range s.conns {
+		nc.Close()
+	}
+}
+
+func (s *fakeServer) accepts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accepted
+}
+
+// count returns the number of commands with the given name executed.
+func (s *fakeServer) count(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands[name]
+}
+
+func (s *fakeServer) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.clock().Add(d)
+}
+
+func (s *fakeServer) clock() time.Time {
+	if s.now.IsZero() {
+		return time.Now()
+	}
+	return s.now
+}
+
+// entry returns the entry stored under key in db, for inspection.
+func (s *fakeServer) entry(db int, key string) (fakeEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.dbs[db][key]
+	return e, ok
+}
+
+func (s *fakeServer) handle(nc net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, nc)
+		s.mu.Unlock()
+		nc.Close()
+	}()
+	br, bw := bufio.NewReader(nc), bufio.NewWriter(nc)
+	authed, db := s.password == "", 0
+	for {
+		reply, err := readReply(br)
+		if err != nil {
+			return
+		}
+		arr, _ := reply.([]any)
+		cmd := make([][]byte, len(arr))
+		for i, a := range arr {
+			cmd[i], _ = a.([]byte)
+		}
+		if len(cmd) == 0 {
+			return
+		}
+		name := strings.ToUpper(string(cmd[0]))
+		var out string
+		switch {
+		case name == "AUTH":
+			out, authed = s.auth(cmd[1:])
+		case !authed:
+			out = "-NOAUTH Authentication required.\r\n"
+		case name == "SELECT":
+			n, err := strconv.Atoi(string(cmd[1]))
+			if err != nil {
+				out = "-ERR invalid DB index\r\n"
+				break
+			}
+			db, out = n, "+OK\r\n"
+		default:
+			out = s.exec(db, name, cmd[1:])
+		}
+		bw.WriteString(out)
+		if bw.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) auth(args [][]byte) (string, bool) {
+	user, password := "default", ""
+	switch len(args) {
+	case 1:
+		password = string(args[0])
+	case 2:
+		user, password = string(args[0]), string(args[1])
+	default:
+		return "-ERR wrong number of arguments for 'auth' command\r\n", false
+	}
+	if user != cmp.Or(s.user, "default") || password != s.password {
+		return "-WRONGPASS invalid username-password pair or user is disabled.\r\n", false
+	}
+	return "+OK\r\n", true
+}
+
+func (s *fakeServer) exec(db int, name string, args [][]byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.dbs[db]
+	if m == nil {
+		m = make(map[string]fakeEntry)
+		s.dbs[db] = m
+	}
+	s.commands[name]++
+	now := s.clock()
+	for k, e := range m {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(m, k)
+		}
+	}
+	switch name {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		e, ok := m[string(args[0])]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(e.value)
+	case "MGET":
+		var b strings.Builder
+		b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+		for _, k := range args {
+			if e, ok := m[string(k)]; ok {
+				b.WriteString(bulk(e.value))
+			} else {
+				b.WriteString("$-1\r\n")
+			}
+		}
+		return b.String()
+	case "SET":
+		e := fakeEntry{value: slices.Clone(args[1])}
+		if len(args) == 4 && strings.EqualFold(string(args[2]), "PX") {
+			ms, err := strconv.ParseInt(string(args[3]), 10, 64)
+			if err != nil || ms <= 0 {
+				return "-ERR invalid expire time in 'set' command\r\n"
+			}
+			e.expires = now.Add(time.Duration(ms) * time.Millisecond)
+		}
+		m[string(args[0])] = e
+		return "+OK\r\n"
+	case "DEL":
+		var n int
+		for _, k := range args {
+			if _, ok := m[string(k)]; ok {
+				delete(m, string(k))
+				n++
+			}
+		}
+		return ":" + strconv.Itoa(n) + "\r\n"
+	case "SCAN":
+		return s.scan(m, args)
+	default:
+		return "-ERR unknown command '" + name + "'\r\n"
+	}
+}
+
+// scan pages through the sorted keys two at a time, so that clients have to
+// follow the cursor.
+func (s *fakeServer) scan(m map[string]fakeEntry, args [][]byte) string {
+	const page = 2
+	cursor, _ := strconv.Atoi(string(args[0]))
+	pattern := "*"
+	for i := 1; i+1 < len(args); i += 2 {
+		if strings.EqualFold(string(args[i]), "MATCH") {
+			pattern = string(args[i+1])
+		}
+	}
+	keys := slices.Sorted(maps.Keys(m))
+	end := min(cursor+page, len(keys))
+	next := strconv.Itoa(end)
+	if end == len(keys) {
+		next = "0"
+	}
+	var matched []string
+	for _, k := range keys[min(cursor, len(keys)):end] {
+		if globMatch(pattern, k) {
+			matched = append(matched, k)
+		}
+	}
+	var b strings.Builder
+	b.WriteString("*2\r\n")
+	b.WriteString(bulk([]byte(next)))
+	b.WriteString("*" + strconv.Itoa(len(matched)) + "\r\n")
+	for _, k := range matched {
+		b.WriteString(bulk([]byte(k)))
+	}
+	return b.String()
+}
+
+func bulk(b []byte) string {
+	return "$" + strconv.Itoa(len(b)) + "\r\n" + string(b) + "\r\n"
+}
+
+// globMatch reports whether s matches the Redis glob pattern, supporting
+// '*', '?' and backslash escapes.
+func globMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// newTLSConfigs returns a server configuration with a self-signed
+// certificate for 127.0.0.1, and a client configuration trusting it.
+func newTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutil.RequireNoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake redis"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	testutil.RequireNoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	testutil.RequireNoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}
+	client = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return server, client
+}
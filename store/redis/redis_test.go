@@ -0,0 +1,337 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+func openCache(t *testing.T, addr string, opts ...Option) *redisCache {
+	t.Helper()
+	cache, err := Open(addr, opts...)
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestRedis_Acceptance(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	var n int
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		// A fresh prefix per test keeps the shared server's keys apart.
+		n++
+		cache, err := Open(srv.addr(), WithPrefix("test"+strconv.Itoa(n)+":"))
+		testutil.RequireNoError(t, err)
+		return cache, func() { cache.Close() }
+	}), acceptance.WithExpiry(func(_ driver.Conn, d time.Duration) { srv.advance(d) }))
+}
+
+func TestRedis_Acceptance_DSN(t *testing.T) {
+	srv := newFakeServer(t, nil, withPassword("", "secret"))
+	var n int
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		n++
+		u, err := url.Parse("redis://:secret@" + srv.addr() + "/" + strconv.Itoa(n) + "?ttl=1h&pool_size=2")
+		testutil.RequireNoError(t, err)
+		cache, err := fromURL(u)
+		testutil.RequireNoError(t, err)
+		return cache, func() { cache.Close() }
+	}), acceptance.WithExpiry(func(_ driver.Conn, d time.Duration) { srv.advance(d) }))
+}
+
+func Test_fromURL(t *testing.T) {
+	srv := newFakeServer(t, nil, withPassword("app", "pw"))
+	tests := []struct {
+		name      string
+		dsn       string
+		assertion func(tt *testing.T, got *redisCache, err error)
+	}{
+		{
+			name: "all parameters",
+			dsn:  "redis://app:pw@" + srv.addr() + "/3?prefix=p:&timeout=2s&ttl=5m&pool_size=4",
+			assertion: func(tt *testing.T, got *redisCache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, "p:", got.prefix)
+				testutil.AssertEqual(tt, 2*time.Second, got.timeout)
+				testutil.AssertEqual(tt, 5*time.Minute, got.ttl)
+				testutil.AssertEqual(tt, 4, got.poolSize)
+				testutil.AssertEqual(tt, 3, got.db)
+				testutil.AssertEqual(tt, "app", got.user)
+				testutil.AssertEqual(tt, srv.addr(), got.addr)
+				testutil.AssertTrue(tt, got.tlsConfig == nil)
+			},
+		},
+		{
+			name: "wrong password",
+			dsn:  "redis://app:nope@" + srv.addr(),
+			assertion: func(tt *testing.T, got *redisCache, err error) {
+				var serr serverError
+				testutil.RequireErrorAs(tt, err, &serr)
+			},
+		},
+		{
+			name: "no password",
+			dsn:  "redis://" + srv.addr(),
+			assertion: func(tt *testing.T, got *redisCache, err error) {
+				testutil.RequireError(tt, err)
+			},
+		},
+		{
+			name: "invalid database",
+			dsn:  "redis://" + srv.addr() + "/zero",
+			assertion: func(tt *testing.T, got *redisCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid timeout",
+			dsn:  "redis://" + srv.addr() + "?timeout=soon",
+			assertion: func(tt *testing.T, got *redisCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid ttl",
+			dsn:  "redis://" + srv.addr() + "?ttl=-1s",
+			assertion: func(tt *testing.T, got *redisCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid pool_size",
+			dsn:  "redis://" + srv.addr() + "?pool_size=many",
+			assertion: func(tt *testing.T, got *redisCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.dsn)
+			testutil.RequireNoError(t, err)
+			got, err := fromURL(u)
+			if err == nil {
+				t.Cleanup(func() { got.Close() })
+			}
+			tt.assertion(t, got, err)
+		})
+	}
+}
+
+func Test_fromURL_TLS(t *testing.T) {
+	u, err := url.Parse("rediss://cache.example.com?timeout=1ms")
+	testutil.RequireNoError(t, err)
+	_, err = fromURL(u) // nothing listens there; only the configuration matters
+	testutil.RequireError(t, err)
+
+	serverCfg, clientCfg := newTLSConfigs(t)
+	srv := newFakeServer(t, serverCfg)
+	cache := openCache(t, srv.addr(), WithTLSConfig(clientCfg))
+	testutil.RequireNoError(t, cache.Set("k", []byte("v")))
+	got, err := cache.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "v", string(got))
+
+	// A plain client cannot talk to a TLS server.
+	_, err = Open(srv.addr(), WithTimeout(time.Second))
+	testutil.RequireError(t, err)
+}
+
+func TestRedis_DB(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	a := openCache(t, srv.addr(), WithDB(1))
+	b := openCache(t, srv.addr(), WithDB(2))
+	testutil.RequireNoError(t, a.Set("k", []byte("a")))
+	_, err := b.Get("k")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	_, ok := srv.entry(1, "k")
+	testutil.AssertTrue(t, ok, "not stored in database 1")
+}
+
+func TestRedis_TTL(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	cache := openCache(t, srv.addr(), WithTTL(time.Minute), WithPrefix("p:"))
+	testutil.RequireNoError(t, cache.Set("k", []byte("v")))
+	e, ok := srv.entry(0, "p:k")
+	testutil.RequireTrue(t, ok)
+	testutil.AssertTrue(t, !e.expires.IsZero(), "no expiry set")
+
+	srv.advance(30 * time.Second)
+	_, err := cache.Get("k")
+	testutil.RequireNoError(t, err)
+	srv.advance(31 * time.Second)
+	_, err = cache.Get("k")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+}
+
+func TestRedis_SetWithTTL(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	cache := openCache(t, srv.addr(), WithTTL(time.Minute))
+	testutil.RequireNoError(t, cache.SetWithTTL(t.Context(), "short", []byte("v"), 10*time.Second))
+	testutil.RequireNoError(t, cache.SetWithTTL(t.Context(), "long", []byte("v"), time.Hour))
+	testutil.RequireNoError(t, cache.SetWithTTL(t.Context(), "none", []byte("v"), 0))
+
+	srv.advance(30 * time.Second)
+	_, err := cache.Get("short")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	for _, key := range []string{"long", "none"} {
+		_, err = cache.Get(key)
+		testutil.RequireNoError(t, err, key+" expired before the cache TTL")
+	}
+	srv.advance(31 * time.Second)
+	for _, key := range []string{"long", "none"} {
+		_, err = cache.Get(key)
+		testutil.RequireErrorIs(t, err, driver.ErrNotExist, key+" outlived the cache TTL")
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	testutil.RequireErrorIs(t, cache.SetWithTTL(ctx, "k", []byte("v"), time.Second), context.Canceled)
+}
+
+func TestRedis_Batch(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	cache := openCache(t, srv.addr(), WithPrefix("p:"), WithTTL(time.Minute))
+	values := make(map[string][]byte, batchSize+1)
+	keys := make([]string, 0, batchSize+2)
+	for i := range batchSize + 1 {
+		k := strconv.Itoa(i)
+		values[k] = []byte("v" + k)
+		keys = append(keys, k)
+	}
+	testutil.RequireNoError(t, cache.SetMulti(t.Context(), values))
+	e, ok := srv.entry(0, "p:0")
+	testutil.RequireTrue(t, ok, "value not stored under the prefix")
+	testutil.AssertTrue(t, !e.expires.IsZero(), "cache TTL not applied")
+
+	got, err := cache.GetMulti(t.Context(), append(keys, "missing"))
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, maps.EqualFunc(got, values, bytes.Equal), "GetMulti returned %d values", len(got))
+	testutil.AssertEqual(t, 2, srv.count("MGET"))
+	testutil.AssertEqual(t, 0, srv.count("GET"))
+
+	testutil.RequireNoError(t, cache.DeleteMulti(t.Context(), append(keys, "missing")))
+	testutil.AssertEqual(t, 2, srv.count("DEL"))
+	got, err = cache.GetMulti(t.Context(), keys)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 0, len(got))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = cache.GetMulti(ctx, keys)
+	testutil.RequireErrorIs(t, err, context.Canceled)
+}
+
+func TestRedis_Keys(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	cache := openCache(t, srv.addr(), WithPrefix("app*:"))
+	other := openCache(t, srv.addr(), WithPrefix("app:"))
+	for _, k := range []string{"https://a/1", "https://a/2", "https://a/3", "https://b/1", "x?y"} {
+		testutil.RequireNoError(t, cache.Set(k, []byte("v")))
+		testutil.RequireNoError(t, other.Set(k, []byte("v")))
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"https://a/", []string{"https://a/1", "https://a/2", "https://a/3"}},
+		{"x?", []string{"x?y"}},
+		{"x*", nil},
+		{"", []string{"https://a/1", "https://a/2", "https://a/3", "https://b/1", "x?y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			keys, err := cache.Keys(tt.prefix)
+			testutil.RequireNoError(t, err)
+			slices.Sort(keys)
+			testutil.AssertTrue(t, slices.Equal(keys, tt.want), "got %q", keys)
+		})
+	}
+}
+
+func TestRedis_Pool(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	cache := openCache(t, srv.addr(), WithPoolSize(2))
+	for range 10 {
+		testutil.RequireNoError(t, cache.Set("k", []byte("v")))
+	}
+	testutil.AssertEqual(t, 1, srv.accepts(), "connections not reused")
+
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Go(func() {
+			key := strconv.Itoa(i)
+			for range 20 {
+				if err := cache.Set(key, []byte(key)); err != nil {
+					t.Error(err)
+					return
+				}
+				got, err := cache.Get(key)
+				if err != nil || string(got) != key {
+					t.Errorf("Get(%q) = %q, %v", key, got, err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	cache.mu.Lock()
+	idle := len(cache.idle)
+	cache.mu.Unlock()
+	testutil.AssertTrue(t, idle <= 2, "pool exceeds pool_size")
+}
+
+func TestRedis_ReconnectsAfterServerClosedConnection(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	cache := openCache(t, srv.addr())
+	testutil.RequireNoError(t, cache.Set("k", []byte("v")))
+	srv.dropConns()
+	got, err := cache.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "v", string(got))
+}
+
+func TestRedis_Closed(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	cache, err := Open(srv.addr())
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, cache.Close())
+	_, err = cache.Get("k")
+	testutil.RequireErrorIs(t, err, ErrClosed)
+}
+
+func Test_escapeGlob(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "plain"},
+		{"a*b?c", `a\*b\?c`},
+		{`[x]\`, `\[x\]\\`},
+	}
+	for _, tt := range tests {
+		testutil.AssertEqual(t, tt.want, escapeGlob(tt.in))
+		testutil.AssertTrue(t, globMatch(escapeGlob(tt.in)+"*", tt.in+"suffix"))
+	}
+}
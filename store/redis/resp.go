@@ -0,0 +1,141 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxBulkLen is the largest bulk string accepted from the server, as in
+// Redis' proto-max-bulk-len default.
+const maxBulkLen = 512 << 20
+
+// maxArrayLen is the largest array accepted from the server, so that a corrupt
+// length cannot make readReply allocate without bound. Replies to the commands
+// the driver sends are far smaller.
+const maxArrayLen = 1 << 20
+
+var errProtocol = errors.New("redis: protocol error")
+
+// serverError is an error reply sent by the server. Unlike other errors, it
+// leaves the connection usable.
+type serverError string
+
+func (e serverError) Error() string { return "redis: " + string(e) }
+
+// writeCommand writes a command as a RESP array of bulk strings.
+func writeCommand(w *bufio.Writer, args [][]byte) error {
+	w.WriteByte('*')
+	w.WriteString(strconv.Itoa(len(args)))
+	w.WriteString("\r\n")
+	for _, arg := range args {
+		w.WriteByte('$')
+		w.WriteString(strconv.Itoa(len(arg)))
+		w.WriteString("\r\n")
+		w.Write(arg)
+		w.WriteString("\r\n")
+	}
+	return w.Flush()
+}
+
+// readReply reads a RESP2 reply. Simple strings are returned as string, bulk
+// strings as []byte, integers as int64, arrays as []any, and null bulk
+// strings and arrays as nil. Error replies are returned as a [serverError]
+// along with a nil value; an array with error elements is still read to its
+// end, so that the connection stays usable, and returns the first of them.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("%w: empty reply", errProtocol)
+	}
+	switch typ, rest := line[0], string(line[1:]); typ {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, serverError(rest)
+	case ':':
+		n, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad integer %q", errProtocol, rest)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < -1 || n > maxBulkLen {
+			return nil, fmt.Errorf("%w: bad bulk length %q", errProtocol, rest)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string not terminated", errProtocol)
+		}
+		return buf[:n:n], nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < -1 || n > maxArrayLen {
+			return nil, fmt.Errorf("%w: bad array length %q", errProtocol, rest)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		arr := make([]any, n)
+		var first error
+		for i := range arr {
+			var serr serverError
+			arr[i], err = readReply(r)
+			switch {
+			case err == nil:
+			case errors.As(err, &serr):
+				first = cmp.Or(first, err)
+			default:
+				return nil, err
+			}
+		}
+		if first != nil {
+			return nil, first
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected reply type %q", errProtocol, typ)
+	}
+}
+
+// readLine reads a CRLF-terminated line, without the terminator.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return nil, fmt.Errorf("%w: line too long", errProtocol)
+		}
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: line not terminated by CRLF", errProtocol)
+	}
+	return line[:len(line)-2], nil
+}
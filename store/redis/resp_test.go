@@ -0,0 +1,86 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+)
+
+func Test_writeCommand(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	testutil.RequireNoError(t, writeCommand(w, [][]byte{[]byte("SET"), []byte("k"), {}}))
+	testutil.AssertEqual(t, "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$0\r\n\r\n", buf.String())
+}
+
+func Test_readReply(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // fmt %v of the reply
+	}{
+		{"+OK\r\n", "OK"},
+		{":42\r\n", "42"},
+		{"$3\r\na\r\n\r\n", "[97 13 10]"},
+		{"$0\r\n\r\n", "[]"},
+		{"$-1\r\n", "<nil>"},
+		{"*-1\r\n", "<nil>"},
+		{"*2\r\n$1\r\n0\r\n*1\r\n$1\r\nk\r\n", "[[48] [[107]]]"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q", tt.in), func(t *testing.T) {
+			got, err := readReply(bufio.NewReader(strings.NewReader(tt.in)))
+			testutil.RequireNoError(t, err)
+			testutil.AssertEqual(t, tt.want, fmt.Sprintf("%v", got))
+		})
+	}
+}
+
+func Test_readReply_Errors(t *testing.T) {
+	_, err := readReply(bufio.NewReader(strings.NewReader("-ERR boom\r\n")))
+	var serr serverError
+	testutil.RequireErrorAs(t, err, &serr)
+	testutil.AssertEqual(t, "redis: ERR boom", err.Error())
+
+	// An array with error elements is read to its end.
+	r := bufio.NewReader(strings.NewReader("*3\r\n-ERR one\r\n:1\r\n-ERR two\r\n+OK\r\n"))
+	_, err = readReply(r)
+	testutil.RequireErrorAs(t, err, &serr)
+	testutil.AssertEqual(t, "redis: ERR one", err.Error())
+	got, err := readReply(r)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "OK", got.(string))
+
+	for _, in := range []string{
+		"\r\n",
+		"+OK\n",
+		"?\r\n",
+		":x\r\n",
+		"$-2\r\n",
+		"$999999999999\r\n",
+		"$1\r\nab\r\n",
+		"*x\r\n",
+		"*-2\r\n",
+		"*999999999999\r\n",
+	} {
+		_, err := readReply(bufio.NewReader(strings.NewReader(in)))
+		testutil.RequireErrorIs(t, err, errProtocol, fmt.Sprintf("%q", in))
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+)
+
+// Paths of the API served by [NewHandler].
+const (
+	healthPath = "/v1/health"
+	entryPath  = "/v1/entry"
+	keysPath   = "/v1/keys"
+	batchPath  = "/v1/batch"
+)
+
+const (
+	defaultMaxBodySize = 256 << 20
+	maxBatchOps        = 1000
+)
+
+// Operations of a batch request.
+const (
+	opGet    = "get"
+	opSet    = "set"
+	opDelete = "delete"
+)
+
+type batchOp struct {
+	Op    string `json:"op"`              // opGet, opSet or opDelete
+	Key   string `json:"key"`             // key operated on
+	Value []byte `json:"value,omitempty"` // value stored by opSet
+}
+
+type batchRequest struct {
+	Ops []batchOp `json:"ops"`
+}
+
+type batchResult struct {
+	Value    []byte `json:"value,omitempty"`     // value retrieved by opGet
+	NotFound bool   `json:"not_found,omitempty"` // whether the key does not exist
+	Error    string `json:"error,omitempty"`     // any other failure
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+type handler struct {
+	// configurable options
+
+	token       string // bearer token required of clients; empty for none
+	maxBodySize int64  // largest request body accepted
+
+	// internal state
+
+	conn driver.Conn
+	cc   driver.ConnContext // conn, or an adapter if it does not support cancellation
+	b    driver.Batcher     // conn, or an adapter if it does not support batching
+	mux  *http.ServeMux
+}
+
+type HandlerOption interface {
+	apply(*handler)
+}
+
+type handlerOptionFunc func(*handler)
+
+func (f handlerOptionFunc) apply(h *handler) {
+	f(h)
+}
+
+// RequireToken makes the handler reject requests without the given bearer
+// token; default: no authentication.
+func RequireToken(token string) HandlerOption {
+	return handlerOptionFunc(func(h *handler) {
+		h.token = token
+	})
+}
+
+// WithMaxBodySize sets the largest request body, and so the largest value,
+// the handler accepts; default: 256 MiB.
+func WithMaxBodySize(n int64) HandlerOption {
+	return handlerOptionFunc(func(h *handler) {
+		if n > 0 {
+			h.maxBodySize = n
+		}
+	})
+}
+
+// NewHandler returns an HTTP handler that serves conn to the clients of this
+// package, with the following API:
+//
+//	GET    /v1/health          -- Check availability and credentials
+//	GET    /v1/entry?key=...   -- Retrieve the value of a key
+//	PUT    /v1/entry?key=...   -- Store the request body as the value of a key
+//	DELETE /v1/entry?key=...   -- Delete a key
+//	GET    /v1/keys?prefix=... -- List keys, if conn implements [expapi.KeyLister]
+//	POST   /v1/batch           -- Run a JSON-encoded sequence of operations
+//
+// Missing keys are reported with 404 Not Found. Operations are canceled with
+// their request if conn implements [driver.ConnContext], and batches are run
+// with [driver.Batcher] if conn implements it.
+func NewHandler(conn driver.Conn, opts ...HandlerOption) http.Handler {
+	h := &handler{
+		maxBodySize: defaultMaxBodySize,
+		conn:        conn,
+		cc:          driver.AsConnContext(conn),
+		b:           driver.AsBatcher(conn),
+		mux:         http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt.apply(h)
+	}
+	h.mux.HandleFunc("GET "+healthPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h.mux.HandleFunc("GET "+entryPath, h.get)
+	h.mux.HandleFunc("PUT "+entryPath, h.set)
+	h.mux.HandleFunc("DELETE "+entryPath, h.delete)
+	h.mux.HandleFunc("GET "+keysPath, h.keys)
+	h.mux.HandleFunc("POST "+batchPath, h.batch)
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="httpcache"`)
+			http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+			return
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBodySize)
+	h.mux.ServeHTTP(w, r)
+}
+
+// keyFromRequest returns the key parameter, reporting a missing one to the
+// client.
+func keyFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "missing key parameter", http.StatusBadRequest)
+		return "", false
+	}
+	return key, true
+}
+
+// writeError reports err, a failure to operate on key, to the client.
+func writeError(w http.ResponseWriter, key string, err error) {
+	if errors.Is(err, driver.ErrNotExist) {
+		http.Error(w, fmt.Sprintf("key %q not found", key), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// readBody reads the request body, reporting a failure to the client.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "failed to read request body: "+err.Error(), http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return body, true
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	key, ok := keyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	value, err := h.cc.GetContext(r.Context(), key)
+	if err != nil {
+		writeError(w, key, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	//nolint:gosec // (G705) Intended: serve binary cache bytes.
+	_, _ = w.Write(value)
+}
+
+func (h *handler) set(w http.ResponseWriter, r *http.Request) {
+	key, ok := keyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	value, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	if err := h.cc.SetContext(r.Context(), key, value); err != nil {
+		writeError(w, key, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) delete(w http.ResponseWriter, r *http.Request) {
+	key, ok := keyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := h.cc.DeleteContext(r.Context(), key); err != nil {
+		writeError(w, key, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) keys(w http.ResponseWriter, r *http.Request) {
+	kl, ok := h.conn.(expapi.KeyLister)
+	if !ok {
+		http.Error(w, "cache does not support listing keys", http.StatusNotImplemented)
+		return
+	}
+	keys, err := kl.Keys(r.URL.Query().Get("prefix"))
+	if err != nil {
+		http.Error(w, "failed to list keys: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]string{"keys": keys})
+}
+
+// batch runs the operations of a batch request. Each run of consecutive
+// operations of the same kind is passed to the cache as one batch (see
+// [driver.Batcher]), so a failure fails every operation of its run, and
+// deletes of missing keys succeed.
+func (h *handler) batch(w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	var req batchRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid batch request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Ops) > maxBatchOps {
+		http.Error(w, fmt.Sprintf("batch exceeds %d operations", maxBatchOps), http.StatusBadRequest)
+		return
+	}
+	resp := batchResponse{Results: make([]batchResult, len(req.Ops))}
+	for i := 0; i < len(req.Ops); {
+		n := 1
+		for i+n < len(req.Ops) && req.Ops[i+n].Op == req.Ops[i].Op {
+			n++
+		}
+		h.runBatch(r.Context(), req.Ops[i:i+n], resp.Results[i:i+n])
+		i += n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// runBatch runs ops, which are all of the same kind, and records their
+// outcome in results.
+func (h *handler) runBatch(ctx context.Context, ops []batchOp, results []batchResult) {
+	keys := make([]string, len(ops))
+	for i, op := range ops {
+		keys[i] = op.Key
+	}
+	var err error
+	switch op := ops[0].Op; op {
+	case opGet:
+		var values map[string][]byte
+		if values, err = h.b.GetMulti(ctx, keys); err == nil {
+			for i, key := range keys {
+				value, ok := values[key]
+				results[i] = batchResult{Value: value, NotFound: !ok}
+			}
+		}
+	case opSet:
+		values := make(map[string][]byte, len(ops))
+		for _, op := range ops {
+			values[op.Key] = op.Value
+		}
+		err = h.b.SetMulti(ctx, values)
+	case opDelete:
+		err = h.b.DeleteMulti(ctx, keys)
+	default:
+		err = fmt.Errorf("unknown operation %q", op)
+	}
+	if err != nil {
+		for i := range results {
+			results[i].Error = err.Error()
+		}
+	}
+}
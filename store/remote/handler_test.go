@@ -0,0 +1,119 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/memcache"
+)
+
+func TestHandler_Batch(t *testing.T) {
+	conn := memcache.Open()
+	testutil.RequireNoError(t, conn.Set("a", []byte("1")))
+	h := NewHandler(conn)
+
+	body, err := json.Marshal(batchRequest{Ops: []batchOp{
+		{Op: opGet, Key: "a"},
+		{Op: opSet, Key: "b", Value: []byte("2")},
+		{Op: opGet, Key: "b"},
+		{Op: opDelete, Key: "a"},
+		{Op: opGet, Key: "a"},
+		{Op: opDelete, Key: "missing"},
+		{Op: "rename", Key: "b"},
+	}})
+	testutil.RequireNoError(t, err)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, batchPath, strings.NewReader(string(body))))
+	testutil.AssertEqual(t, http.StatusOK, rec.Code)
+
+	var resp batchResponse
+	testutil.RequireNoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	testutil.RequireTrue(t, len(resp.Results) == 7, "got "+strconv.Itoa(len(resp.Results))+" results")
+	testutil.AssertEqual(t, "1", string(resp.Results[0].Value))
+	testutil.AssertEqual(t, "2", string(resp.Results[2].Value))
+	testutil.AssertTrue(t, resp.Results[4].NotFound, "get of a deleted key")
+	testutil.AssertTrue(t, resp.Results[6].Error != "", "unknown operation accepted")
+	for _, i := range []int{0, 1, 2, 3, 5} {
+		r := resp.Results[i]
+		testutil.AssertTrue(t, !r.NotFound && r.Error == "", "operation "+strconv.Itoa(i)+" failed: "+r.Error)
+	}
+}
+
+func TestHandler_Context(t *testing.T) {
+	h := NewHandler(memcache.Open())
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequestWithContext(ctx, http.MethodPut, entryPath+"?key=k", strings.NewReader("v")))
+	testutil.AssertEqual(t, http.StatusInternalServerError, rec.Code, rec.Body.String())
+
+	body, err := json.Marshal(batchRequest{Ops: []batchOp{{Op: opSet, Key: "k"}, {Op: opGet, Key: "k"}}})
+	testutil.RequireNoError(t, err)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequestWithContext(ctx, http.MethodPost, batchPath, strings.NewReader(string(body))))
+	testutil.AssertEqual(t, http.StatusOK, rec.Code)
+	var resp batchResponse
+	testutil.RequireNoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	for i, r := range resp.Results {
+		testutil.AssertTrue(t, r.Error != "", "operation "+strconv.Itoa(i)+" ran after the request was canceled")
+	}
+}
+
+func TestHandler_BadRequests(t *testing.T) {
+	tooMany, err := json.Marshal(batchRequest{Ops: make([]batchOp, maxBatchOps+1)})
+	testutil.RequireNoError(t, err)
+	h := NewHandler(memcache.Open(), RequireToken("secret"), WithMaxBodySize(int64(len(tooMany))))
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		token  string
+		want   int
+	}{
+		{"missing token", http.MethodGet, healthPath, "", "", http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, healthPath, "", "wrong", http.StatusUnauthorized},
+		{"health", http.MethodGet, healthPath, "", "secret", http.StatusNoContent},
+		{"missing key", http.MethodGet, entryPath, "", "secret", http.StatusBadRequest},
+		{"missing entry", http.MethodGet, entryPath + "?key=k", "", "secret", http.StatusNotFound},
+		{"value too large", http.MethodPut, entryPath + "?key=k", string(tooMany) + "x", "secret", http.StatusRequestEntityTooLarge},
+		{"invalid batch", http.MethodPost, batchPath, "{", "secret", http.StatusBadRequest},
+		{"batch too large", http.MethodPost, batchPath, string(tooMany), "secret", http.StatusBadRequest},
+		{"unknown method", http.MethodPost, entryPath + "?key=k", "", "secret", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			testutil.AssertEqual(t, tt.want, rec.Code, rec.Body.String())
+			if tt.want == http.StatusUnauthorized {
+				testutil.AssertTrue(t, rec.Header().Get("WWW-Authenticate") != "", "missing challenge")
+			}
+		})
+	}
+}
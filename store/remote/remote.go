@@ -0,0 +1,471 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remote implements a cache backend that stores entries in a cache
+// served over HTTP by another process, so that several processes on a host
+// can share one cache without deploying a dedicated cache server.
+//
+// [NewHandler] serves any [driver.Conn] over a small HTTP API, and the
+// httpcache-server command (github.com/bartventer/httpcache/cmd/httpcache-server)
+// runs it as a standalone server. The client reuses connections, sends a
+// bearer token if configured, and supports TLS, including client
+// certificates, with the "remotes" scheme.
+//
+// # Configuration Parameters
+//
+// The DSN has the form remote://host:port, or remotes://host:port for TLS.
+// The following query parameters are supported:
+//
+//   - token (optional): Bearer token sent with every request (default: none)
+//   - timeout (optional): Timeout for each request, e.g. "2s" (default: 5s)
+//   - pool_size (optional): Maximum number of idle connections kept open (default: 10)
+//   - ca_file (optional): PEM file of the certificate authorities trusted to verify the server (default: the system roots)
+//   - cert_file, key_file (optional): PEM files of the client certificate and key presented to the server (default: none)
+//
+// # Usage Examples
+//
+//	The DSN and equivalent programmatic usage are shown below.
+//
+//	 Local server:
+//
+//		remote://localhost:7420?token=secret
+//		remote.Open("localhost:7420", remote.WithToken("secret"))
+//
+//	 Server requiring a client certificate:
+//
+//		remotes://cache.internal:7420?ca_file=/etc/ca.pem&cert_file=/etc/client.pem&key_file=/etc/client-key.pem
+//		remote.Open("cache.internal:7420", remote.WithTLSConfig(tlsConfig))
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+)
+
+const (
+	Scheme    = "remote"
+	TLSScheme = "remotes"
+)
+
+//nolint:gochecknoinits // We use init to register the driver.
+func init() {
+	opener := driver.DriverFunc(func(u *url.URL) (driver.Conn, error) {
+		return fromURL(u)
+	})
+	store.Register(Scheme, opener)
+	store.Register(TLSScheme, opener)
+}
+
+var (
+	ErrInvalidParam = errors.New("remote: invalid DSN parameter")
+	ErrClosed       = errors.New("remote: cache closed")
+	ErrUnauthorized = errors.New("remote: unauthorized")
+)
+
+const (
+	defaultTimeout  = 5 * time.Second
+	defaultPoolSize = 10
+)
+
+type remoteCache struct {
+	// configurable options
+
+	token     string        // bearer token; empty for none
+	timeout   time.Duration // dial and per-request timeout
+	poolSize  int           // maximum number of idle connections
+	tlsConfig *tls.Config   // nil for plain HTTP
+
+	// internal state
+
+	base      *url.URL // URL of the server
+	transport *http.Transport
+	client    *http.Client
+	closed    atomic.Bool
+}
+
+type Option interface {
+	apply(*remoteCache)
+}
+
+type optionFunc func(*remoteCache)
+
+func (f optionFunc) apply(c *remoteCache) {
+	f(c)
+}
+
+// WithToken sets the bearer token sent with every request; default: none.
+func WithToken(token string) Option {
+	return optionFunc(func(c *remoteCache) {
+		c.token = token
+	})
+}
+
+// WithTimeout sets the timeout for dialing and for each request; default: 5s.
+func WithTimeout(d time.Duration) Option {
+	return optionFunc(func(c *remoteCache) {
+		if d > 0 {
+			c.timeout = d
+		}
+	})
+}
+
+// WithPoolSize sets the maximum number of idle connections kept open;
+// default: 10.
+func WithPoolSize(n int) Option {
+	return optionFunc(func(c *remoteCache) {
+		c.poolSize = max(n, 0)
+	})
+}
+
+// WithTLSConfig connects to the server with TLS, using the given
+// configuration; default: plain HTTP.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return optionFunc(func(c *remoteCache) {
+		c.tlsConfig = cfg
+	})
+}
+
+func fromURL(u *url.URL) (*remoteCache, error) {
+	q := u.Query()
+	opts := make([]Option, 0, 4)
+	if v := q.Get("token"); v != "" {
+		opts = append(opts, WithToken(v))
+	}
+	if v := q.Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: timeout %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithTimeout(d))
+	}
+	if v := q.Get("pool_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: pool_size %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithPoolSize(n))
+	}
+	if u.Scheme == TLSScheme {
+		cfg, err := tlsConfigFromQuery(q)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ServerName = u.Hostname()
+		opts = append(opts, WithTLSConfig(cfg))
+	}
+	return Open(u.Host, opts...)
+}
+
+func tlsConfigFromQuery(q url.Values) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if v := q.Get("ca_file"); v != "" {
+		pem, err := os.ReadFile(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: ca_file: %w", ErrInvalidParam, err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: ca_file %q holds no certificates", ErrInvalidParam, v)
+		}
+	}
+	certFile, keyFile := q.Get("cert_file"), q.Get("key_file")
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("%w: cert_file and key_file must be given together", ErrInvalidParam)
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: client certificate: %w", ErrInvalidParam, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// Open returns a cache served by the server at addr ("host:port"). It fails
+// if the server cannot be reached or rejects the credentials.
+//
+// See the package documentation for supported options.
+func Open(addr string, opts ...Option) (*remoteCache, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("%w: missing address", ErrInvalidParam)
+	}
+	c := &remoteCache{
+		timeout:  defaultTimeout,
+		poolSize: defaultPoolSize,
+	}
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+	c.base = &url.URL{Scheme: "http", Host: addr}
+	if c.tlsConfig != nil {
+		c.base.Scheme = "https"
+	}
+	c.transport = &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: c.timeout}).DialContext,
+		TLSClientConfig:     c.tlsConfig,
+		TLSHandshakeTimeout: c.timeout,
+		MaxIdleConnsPerHost: c.poolSize,
+		ForceAttemptHTTP2:   true,
+	}
+	if c.poolSize == 0 {
+		c.transport.DisableKeepAlives = true
+	}
+	c.client = &http.Client{Transport: c.transport, Timeout: c.timeout}
+	if _, err := c.do(context.Background(), http.MethodGet, healthPath, nil, nil); err != nil {
+		c.transport.CloseIdleConnections()
+		return nil, err
+	}
+	return c, nil
+}
+
+var (
+	_ driver.Conn      = (*remoteCache)(nil)
+	_ driver.Batcher   = (*remoteCache)(nil)
+	_ expapi.KeyLister = (*remoteCache)(nil)
+)
+
+func errNotExist(key string) error {
+	return errors.Join(
+		driver.ErrNotExist,
+		fmt.Errorf("remote: key %q does not exist", key),
+	)
+}
+
+// statusError is an unexpected response from the server.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("remote: server responded %d %s: %s", e.code, http.StatusText(e.code), e.msg)
+}
+
+func (e *statusError) Unwrap() error {
+	switch e.code {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusNotImplemented:
+		return errors.ErrUnsupported
+	}
+	return nil
+}
+
+// do sends a request with the given query and body to path, and returns the
+// body of a 2xx response.
+func (c *remoteCache) do(
+	ctx context.Context,
+	method, path string,
+	query url.Values,
+	body []byte,
+) ([]byte, error) {
+	if c.closed.Load() {
+		return nil, ErrClosed
+	}
+	u := c.base.JoinPath(path)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &statusError{code: resp.StatusCode, msg: strings.TrimSpace(string(respBody))}
+	}
+	return respBody, nil
+}
+
+// isNotFound reports whether err is a 404 Not Found response.
+func isNotFound(err error) bool {
+	var serr *statusError
+	return errors.As(err, &serr) && serr.code == http.StatusNotFound
+}
+
+func (c *remoteCache) Get(key string) ([]byte, error) {
+	value, err := c.do(context.Background(), http.MethodGet, entryPath, url.Values{"key": {key}}, nil)
+	if isNotFound(err) {
+		return nil, errNotExist(key)
+	}
+	return value, err
+}
+
+func (c *remoteCache) Set(key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := c.do(context.Background(), http.MethodPut, entryPath, url.Values{"key": {key}}, value)
+	return err
+}
+
+func (c *remoteCache) Delete(key string) error {
+	_, err := c.do(context.Background(), http.MethodDelete, entryPath, url.Values{"key": {key}}, nil)
+	if isNotFound(err) {
+		return errNotExist(key)
+	}
+	return err
+}
+
+// Keys lists the keys with the given prefix. It fails with an error wrapping
+// [errors.ErrUnsupported] if the server's cache cannot list keys.
+func (c *remoteCache) Keys(prefix string) ([]string, error) {
+	body, err := c.do(context.Background(), http.MethodGet, keysPath, url.Values{"prefix": {prefix}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("remote: failed to decode key list: %w", err)
+	}
+	if resp.Keys == nil {
+		resp.Keys = []string{}
+	}
+	return resp.Keys, nil
+}
+
+// batch runs ops on the server, in requests of at most maxBatchOps
+// operations, and returns their results in order.
+func (c *remoteCache) batch(ctx context.Context, ops []batchOp) ([]batchResult, error) {
+	results := make([]batchResult, 0, len(ops))
+	for chunk := range slices.Chunk(ops, maxBatchOps) {
+		body, err := json.Marshal(batchRequest{Ops: chunk})
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := c.do(ctx, http.MethodPost, batchPath, nil, body)
+		if err != nil {
+			return nil, err
+		}
+		var resp batchResponse
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			return nil, fmt.Errorf("remote: failed to decode batch response: %w", err)
+		}
+		if len(resp.Results) != len(chunk) {
+			return nil, fmt.Errorf("remote: batch of %d operations returned %d results", len(chunk), len(resp.Results))
+		}
+		results = append(results, resp.Results...)
+	}
+	return results, nil
+}
+
+// batchError returns the error of the failed operation op of a batch.
+func batchError(op batchOp, res batchResult) error {
+	return fmt.Errorf("remote: %s of key %q failed: %s", op.Op, op.Key, res.Error)
+}
+
+// GetMulti retrieves the values of the given keys in a single request per
+// 1000 keys; see [driver.Batcher].
+func (c *remoteCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	ops := make([]batchOp, len(keys))
+	for i, key := range keys {
+		ops[i] = batchOp{Op: opGet, Key: key}
+	}
+	results, err := c.batch(ctx, ops)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(keys))
+	for i, res := range results {
+		switch {
+		case res.Error != "":
+			return nil, batchError(ops[i], res)
+		case res.NotFound:
+		case res.Value == nil:
+			values[ops[i].Key] = []byte{}
+		default:
+			values[ops[i].Key] = res.Value
+		}
+	}
+	return values, nil
+}
+
+// SetMulti stores the given values in a single request per 1000 values; see
+// [driver.Batcher].
+func (c *remoteCache) SetMulti(ctx context.Context, values map[string][]byte) error {
+	ops := make([]batchOp, 0, len(values))
+	for key, value := range values {
+		ops = append(ops, batchOp{Op: opSet, Key: key, Value: value})
+	}
+	results, err := c.batch(ctx, ops)
+	if err != nil {
+		return err
+	}
+	for i, res := range results {
+		if res.Error != "" {
+			return batchError(ops[i], res)
+		}
+	}
+	return nil
+}
+
+// DeleteMulti removes the values of the given keys in a single request per
+// 1000 keys; see [driver.Batcher].
+func (c *remoteCache) DeleteMulti(ctx context.Context, keys []string) error {
+	ops := make([]batchOp, len(keys))
+	for i, key := range keys {
+		ops[i] = batchOp{Op: opDelete, Key: key}
+	}
+	results, err := c.batch(ctx, ops)
+	if err != nil {
+		return err
+	}
+	for i, res := range results {
+		if res.Error != "" {
+			return batchError(ops[i], res)
+		}
+	}
+	return nil
+}
+
+// Close closes the idle connections; requests in flight complete.
+func (c *remoteCache) Close() error {
+	c.closed.Store(true)
+	c.transport.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,285 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/internal/conntest"
+	"github.com/bartventer/httpcache/store/memcache"
+)
+
+// newServer serves conn, or a fresh in-memory cache if nil, and returns the
+// server's address.
+func newServer(t *testing.T, conn driver.Conn, opts ...HandlerOption) *httptest.Server {
+	t.Helper()
+	if conn == nil {
+		conn = memcache.Open()
+	}
+	srv := httptest.NewServer(NewHandler(conn, opts...))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func serverAddr(srv *httptest.Server) string {
+	return strings.TrimPrefix(strings.TrimPrefix(srv.URL, "http://"), "https://")
+}
+
+func openCache(t *testing.T, addr string, opts ...Option) *remoteCache {
+	t.Helper()
+	cache, err := Open(addr, opts...)
+	testutil.RequireNoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestRemote_Acceptance(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		srv := httptest.NewServer(NewHandler(memcache.Open(), RequireToken("secret")))
+		cache, err := Open(serverAddr(srv), WithToken("secret"))
+		testutil.RequireNoError(t, err)
+		return cache, func() {
+			cache.Close()
+			srv.Close()
+		}
+	}))
+}
+
+func TestRemote_Acceptance_DSN(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		srv := httptest.NewServer(NewHandler(memcache.Open(), RequireToken("secret")))
+		u, err := url.Parse("remote://" + serverAddr(srv) + "?token=secret&timeout=2s&pool_size=2")
+		testutil.RequireNoError(t, err)
+		cache, err := fromURL(u)
+		testutil.RequireNoError(t, err)
+		return cache, func() {
+			cache.Close()
+			srv.Close()
+		}
+	}))
+}
+
+func Test_fromURL(t *testing.T) {
+	srv := newServer(t, nil)
+	addr := serverAddr(srv)
+	tests := []struct {
+		name      string
+		dsn       string
+		assertion func(tt *testing.T, got *remoteCache, err error)
+	}{
+		{
+			name: "all parameters",
+			dsn:  "remote://" + addr + "?token=t&timeout=2s&pool_size=4",
+			assertion: func(tt *testing.T, got *remoteCache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, "t", got.token)
+				testutil.AssertEqual(tt, 2*time.Second, got.timeout)
+				testutil.AssertEqual(tt, 4, got.poolSize)
+				testutil.AssertEqual(tt, "http://"+addr, got.base.String())
+			},
+		},
+		{
+			name: "missing address",
+			dsn:  "remote://",
+			assertion: func(tt *testing.T, got *remoteCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "unreachable server",
+			dsn:  "remote://127.0.0.1:1?timeout=100ms",
+			assertion: func(tt *testing.T, got *remoteCache, err error) {
+				testutil.RequireError(tt, err)
+			},
+		},
+		{
+			name: "invalid timeout",
+			dsn:  "remote://" + addr + "?timeout=soon",
+			assertion: func(tt *testing.T, got *remoteCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid pool_size",
+			dsn:  "remote://" + addr + "?pool_size=-1",
+			assertion: func(tt *testing.T, got *remoteCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "missing ca_file",
+			dsn:  "remotes://" + addr + "?ca_file=/does/not/exist.pem",
+			assertion: func(tt *testing.T, got *remoteCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "cert_file without key_file",
+			dsn:  "remotes://" + addr + "?cert_file=client.pem",
+			assertion: func(tt *testing.T, got *remoteCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.dsn)
+			testutil.RequireNoError(t, err)
+			got, err := fromURL(u)
+			if err == nil {
+				t.Cleanup(func() { got.Close() })
+			}
+			tt.assertion(t, got, err)
+		})
+	}
+}
+
+func TestRemote_Token(t *testing.T) {
+	srv := newServer(t, nil, RequireToken("secret"))
+	for _, token := range []string{"", "wrong"} {
+		_, err := Open(serverAddr(srv), WithToken(token))
+		testutil.RequireErrorIs(t, err, ErrUnauthorized)
+	}
+	openCache(t, serverAddr(srv), WithToken("secret"))
+}
+
+func TestRemote_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(NewHandler(memcache.Open()))
+	t.Cleanup(srv.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	_, err := Open(serverAddr(srv), WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}))
+	testutil.RequireError(t, err, "connected to a server with an unknown certificate")
+
+	cache := openCache(t, serverAddr(srv), WithTLSConfig(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}))
+	testutil.AssertEqual(t, "https", cache.base.Scheme)
+	testutil.RequireNoError(t, cache.Set("k", []byte("v")))
+	got, err := cache.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "v", string(got))
+}
+
+func TestRemote_KeysUnsupported(t *testing.T) {
+	srv := newServer(t, conntest.Plain(memcache.Open()))
+	cache := openCache(t, serverAddr(srv))
+	_, err := cache.Keys("")
+	testutil.RequireErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestRemote_ServerError(t *testing.T) {
+	srv := newServer(t, nil, WithMaxBodySize(4))
+	cache := openCache(t, serverAddr(srv))
+	err := cache.Set("k", []byte("too large"))
+	var serr *statusError
+	testutil.RequireErrorAs(t, err, &serr)
+	testutil.AssertEqual(t, 413, serr.code)
+	// The connection stays usable.
+	testutil.RequireNoError(t, cache.Set("k", []byte("ok")))
+}
+
+// slowConn delays every operation.
+type slowConn struct {
+	driver.Conn
+	delay time.Duration
+}
+
+func (c slowConn) Get(key string) ([]byte, error) {
+	time.Sleep(c.delay)
+	return c.Conn.Get(key)
+}
+
+func TestRemote_Timeout(t *testing.T) {
+	srv := newServer(t, slowConn{memcache.Open(), 200 * time.Millisecond})
+	cache := openCache(t, serverAddr(srv), WithTimeout(50*time.Millisecond))
+	_, err := cache.Get("k")
+	testutil.RequireError(t, err)
+	testutil.AssertTrue(t, !errors.Is(err, driver.ErrNotExist), "timeout reported as a missing key")
+}
+
+func TestRemote_Closed(t *testing.T) {
+	srv := newServer(t, nil)
+	cache, err := Open(serverAddr(srv))
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, cache.Close())
+	_, err = cache.Get("k")
+	testutil.RequireErrorIs(t, err, ErrClosed)
+}
+
+// failConn fails to store the key "fail".
+type failConn struct{ driver.Conn }
+
+func (c failConn) Set(key string, value []byte) error {
+	if key == "fail" {
+		return errors.New("disk full")
+	}
+	return c.Conn.Set(key, value)
+}
+
+func TestRemote_Batch(t *testing.T) {
+	var batches atomic.Int32
+	h := NewHandler(failConn{memcache.Open()})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == batchPath {
+			batches.Add(1)
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	cache := openCache(t, serverAddr(srv))
+	ctx := t.Context()
+
+	values := make(map[string][]byte, 2*maxBatchOps+1)
+	keys := make([]string, 0, len(values))
+	for i := range 2*maxBatchOps + 1 {
+		key := "k" + strconv.Itoa(i)
+		values[key] = []byte(strconv.Itoa(i))
+		keys = append(keys, key)
+	}
+	testutil.RequireNoError(t, cache.SetMulti(ctx, values))
+	testutil.AssertEqual(t, int32(3), batches.Load(), "requests for a batch of "+strconv.Itoa(len(values)))
+
+	got, err := cache.GetMulti(ctx, append(keys, "missing"))
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, len(values), len(got))
+	testutil.AssertEqual(t, "1234", string(got["k1234"]))
+
+	testutil.RequireNoError(t, cache.DeleteMulti(ctx, []string{"k1", "missing"}))
+	_, err = cache.Get("k1")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+
+	err = cache.SetMulti(ctx, map[string][]byte{"ok": nil, "fail": []byte("x")})
+	testutil.RequireError(t, err)
+	testutil.AssertTrue(t, strings.Contains(err.Error(), "disk full"), "unexpected error: "+err.Error())
+
+	batches.Store(0)
+	got, err = cache.GetMulti(ctx, nil)
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 0, len(got))
+	testutil.AssertEqual(t, int32(0), batches.Load(), "request sent for an empty batch")
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testCreds = Credentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+// fakeServer is an in-process stand-in for an S3 service holding a single
+// bucket. It verifies request signatures and supports the object operations
+// and ListObjectsV2 requests the driver sends, addressed either path-style or
+// virtual-hosted.
+type fakeServer struct {
+	*httptest.Server
+	bucket   string
+	signer   *signer
+	pageSize int // keys per ListObjectsV2 page
+
+	mu       sync.Mutex
+	objects  map[string]fakeObject
+	failures []int // statuses returned, in order, before handling requests
+	requests int
+}
+
+type fakeObject struct {
+	data   []byte
+	header http.Header // the PUT request's headers
+}
+
+func newFakeServer(t *testing.T, bucket string) *fakeServer {
+	t.Helper()
+	s := &fakeServer{
+		bucket:   bucket,
+		signer:   &signer{creds: testCreds, region: defaultRegion},
+		pageSize: 2,
+		objects:  make(map[string]fakeObject),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// fail makes the server respond to the next requests with the given statuses.
+func (s *fakeServer) fail(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+func (s *fakeServer) object(name string) (fakeObject, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[name]
+	return obj, ok
+}
+
+func (s *fakeServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: "fake " + code})
+}
+
+func (s *fakeServer) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+	s.mu.Lock()
+	s.requests++
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		s.mu.Unlock()
+		writeError(w, r, status, "InternalError")
+		return
+	}
+	s.mu.Unlock()
+	if !s.verify(r, body) {
+		writeError(w, r, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	// Resolve the bucket from the host name or the first path segment.
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if host, _, _ := strings.Cut(r.Host, ":"); strings.HasPrefix(host, s.bucket+".") {
+		// virtual-hosted
+	} else if bucket, rest, _ := strings.Cut(name, "/"); bucket == s.bucket {
+		name = rest
+	} else {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case name == "" && r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		s.list(w, r.URL.Query())
+	case name == "":
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		obj, ok := s.objects[name]
+		if !ok {
+			writeError(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case r.Method == http.MethodPut:
+		if len(name) > 1024 {
+			writeError(w, r, http.StatusBadRequest, "KeyTooLongError")
+			return
+		}
+		s.objects[name] = fakeObject{data: body, header: r.Header.Clone()}
+	case r.Method == http.MethodDelete:
+		delete(s.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// verify recomputes the request's signature and payload hash.
+func (s *fakeServer) verify(r *http.Request, body []byte) bool {
+	if r.Header.Get("X-Amz-Content-Sha256") != hashHex(body) {
+		return false
+	}
+	date, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	req := r.Clone(r.Context())
+	req.Header.Del("Authorization")
+	s.signer.sign(req, hashHex(body), date)
+	return req.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+func (s *fakeServer) list(w http.ResponseWriter, q url.Values) {
+	type contents struct {
+		Key  string `xml:"Key"`
+		Size int    `xml:"Size"`
+	}
+	result := struct {
+		XMLName               xml.Name   `xml:"ListBucketResult"`
+		Name                  string     `xml:"Name"`
+		Prefix                string     `xml:"Prefix"`
+		KeyCount              int        `xml:"KeyCount"`
+		IsTruncated           bool       `xml:"IsTruncated"`
+		NextContinuationToken string     `xml:"NextContinuationToken,omitempty"`
+		EncodingType          string     `xml:"EncodingType,omitempty"`
+		Contents              []contents `xml:"Contents"`
+	}{Name: s.bucket, Prefix: q.Get("prefix"), EncodingType: q.Get("encoding-type")}
+
+	var names []string
+	for name := range s.objects {
+		if strings.HasPrefix(name, q.Get("prefix")) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	start := 0
+	if token := q.Get("continuation-token"); token != "" {
+		start, _ = strconv.Atoi(strings.TrimPrefix(token, "page-"))
+	}
+	end := min(start+s.pageSize, len(names))
+	for _, name := range names[start:end] {
+		c := contents{Key: name, Size: len(s.objects[name].data)}
+		if result.EncodingType == "url" {
+			c.Key = url.QueryEscape(name)
+		}
+		result.Contents = append(result.Contents, c)
+	}
+	result.KeyCount = len(result.Contents)
+	if end < len(names) {
+		result.IsTruncated = true
+		result.NextContinuationToken = "page-" + strconv.Itoa(end)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,525 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package s3 implements a cache backend that stores entries as objects in an
+// Amazon S3 bucket, or any storage service with an S3-compatible API, so that
+// ephemeral machines can share a cache.
+//
+// Requests are signed with AWS Signature Version 4, implemented in this
+// package without dependencies. Credentials are taken from the
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment
+// variables unless given with [WithCredentials]; without credentials requests
+// are sent unsigned. Requests that fail with a network error, a 5xx status or
+// 429 Too Many Requests are retried with exponential backoff.
+//
+// Each entry is stored as an object named after its key, under an optional
+// prefix. S3 limits object names to 1024 bytes, so longer keys cannot be
+// stored. Keys are listed with ListObjectsV2.
+//
+// # Configuration Parameters
+//
+// The DSN has the form s3://bucket[/prefix]. The following query parameters
+// are supported:
+//
+//   - region (optional): Region of the bucket (default: $AWS_REGION, $AWS_DEFAULT_REGION or "us-east-1")
+//   - endpoint (optional): URL of an S3-compatible service, e.g. "http://localhost:9000" (default: AWS)
+//   - path_style (optional): Address the bucket in the URL path rather than the host name (default: true with a custom endpoint, false otherwise)
+//   - sse (optional): Server-side encryption of new objects, "AES256" or "aws:kms" (default: the bucket's setting)
+//   - sse_kms_key_id (optional): KMS key used with sse=aws:kms (default: the AWS managed key)
+//   - timeout (optional): Timeout for each request attempt, e.g. "10s" (default: 30s)
+//   - max_retries (optional): Maximum number of retries of a failed request (default: 3)
+//
+// # Usage Examples
+//
+//	The DSN and equivalent programmatic usage are shown below.
+//
+//	 AWS, with objects under "httpcache/":
+//
+//		s3://my-bucket/httpcache/?region=eu-west-1&sse=AES256
+//		s3.Open("my-bucket", s3.WithPrefix("httpcache/"), s3.WithRegion("eu-west-1"), s3.WithServerSideEncryption("AES256", ""))
+//
+//	 S3-compatible service:
+//
+//		s3://cache?endpoint=http%3A%2F%2Flocalhost%3A9000
+//		s3.Open("cache", s3.WithEndpoint("http://localhost:9000"))
+package s3
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+)
+
+const Scheme = "s3"
+
+//nolint:gochecknoinits // We use init to register the driver.
+func init() {
+	store.Register(Scheme, driver.DriverFunc(func(u *url.URL) (driver.Conn, error) {
+		return fromURL(u)
+	}))
+}
+
+var ErrInvalidParam = errors.New("s3: invalid DSN parameter")
+
+const (
+	defaultRegion     = "us-east-1"
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	retryBaseDelay    = 100 * time.Millisecond
+	retryMaxDelay     = 5 * time.Second
+
+	// emptyPayloadHash is the SHA-256 hash of an empty request body.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// ResponseError is an error response from the service.
+type ResponseError struct {
+	StatusCode int    // HTTP status code
+	Code       string // S3 error code, such as "AccessDenied"; empty for responses without a body
+	Message    string // description of the error
+}
+
+func (e *ResponseError) Error() string {
+	if e.Code == "" {
+		return "s3: " + http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+type s3Cache struct {
+	// configurable options
+
+	prefix     string        // prepended to every object name
+	region     string        // region requests are signed for
+	endpoint   *url.URL      // service URL; nil for AWS
+	pathStyle  *bool         // nil for the default
+	sse        string        // x-amz-server-side-encryption value; empty for none
+	sseKMSKey  string        // KMS key ID used with aws:kms
+	creds      *Credentials  // nil to read the environment
+	timeout    time.Duration // per-attempt timeout
+	maxRetries int           // maximum number of retries per request
+	client     *http.Client
+
+	// internal state
+
+	bucket string
+	base   *url.URL // URL of the bucket
+	signer *signer  // nil to send unsigned requests
+	sleep  func(time.Duration)
+}
+
+type Option interface {
+	apply(*s3Cache)
+}
+
+type optionFunc func(*s3Cache)
+
+func (f optionFunc) apply(c *s3Cache) {
+	f(c)
+}
+
+// WithPrefix sets a prefix prepended to every object name; default: none.
+func WithPrefix(prefix string) Option {
+	return optionFunc(func(c *s3Cache) {
+		c.prefix = prefix
+	})
+}
+
+// WithRegion sets the region of the bucket; default: $AWS_REGION,
+// $AWS_DEFAULT_REGION or "us-east-1".
+func WithRegion(region string) Option {
+	return optionFunc(func(c *s3Cache) {
+		c.region = region
+	})
+}
+
+// WithEndpoint sets the URL of an S3-compatible service; default: AWS.
+// Buckets of custom endpoints are addressed in the URL path unless
+// [WithPathStyle] says otherwise.
+func WithEndpoint(endpoint string) Option {
+	return optionFunc(func(c *s3Cache) {
+		c.endpoint, _ = url.Parse(endpoint) // validated by Open
+		if c.endpoint == nil {
+			c.endpoint = &url.URL{}
+		}
+	})
+}
+
+// WithPathStyle selects whether the bucket is addressed in the URL path
+// ("endpoint/bucket/key") or in the host name ("bucket.endpoint/key").
+func WithPathStyle(pathStyle bool) Option {
+	return optionFunc(func(c *s3Cache) {
+		c.pathStyle = &pathStyle
+	})
+}
+
+// WithServerSideEncryption requests server-side encryption of new objects
+// with the given algorithm, "AES256" or "aws:kms", and for "aws:kms", the
+// given KMS key ID, or the AWS managed key if empty. Default: the bucket's
+// setting.
+func WithServerSideEncryption(algorithm, kmsKeyID string) Option {
+	return optionFunc(func(c *s3Cache) {
+		c.sse = algorithm
+		c.sseKMSKey = kmsKeyID
+	})
+}
+
+// WithCredentials sets the credentials requests are signed with; default:
+// read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables.
+func WithCredentials(creds Credentials) Option {
+	return optionFunc(func(c *s3Cache) {
+		c.creds = &creds
+	})
+}
+
+// WithTimeout sets the timeout of each request attempt; default: 30s.
+func WithTimeout(d time.Duration) Option {
+	return optionFunc(func(c *s3Cache) {
+		if d > 0 {
+			c.timeout = d
+		}
+	})
+}
+
+// WithMaxRetries sets the maximum number of retries of a failed request;
+// default: 3.
+func WithMaxRetries(n int) Option {
+	return optionFunc(func(c *s3Cache) {
+		c.maxRetries = max(n, 0)
+	})
+}
+
+// WithHTTPClient sets the HTTP client requests are sent with; default:
+// [http.DefaultClient].
+func WithHTTPClient(client *http.Client) Option {
+	return optionFunc(func(c *s3Cache) {
+		if client != nil {
+			c.client = client
+		}
+	})
+}
+
+func fromURL(u *url.URL) (*s3Cache, error) {
+	q := u.Query()
+	opts := make([]Option, 0, 8)
+	if p := strings.TrimPrefix(u.Path, "/"); p != "" {
+		opts = append(opts, WithPrefix(p))
+	}
+	if v := q.Get("region"); v != "" {
+		opts = append(opts, WithRegion(v))
+	}
+	if v := q.Get("endpoint"); v != "" {
+		opts = append(opts, WithEndpoint(v))
+	}
+	if v := q.Get("path_style"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: path_style %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithPathStyle(b))
+	}
+	if v := q.Get("sse"); v != "" {
+		if v != "AES256" && v != "aws:kms" {
+			return nil, fmt.Errorf("%w: sse %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithServerSideEncryption(v, q.Get("sse_kms_key_id")))
+	}
+	if v := q.Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: timeout %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithTimeout(d))
+	}
+	if v := q.Get("max_retries"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: max_retries %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithMaxRetries(n))
+	}
+	return Open(u.Host, opts...)
+}
+
+// Open returns a cache that stores entries in the given bucket. It does not
+// contact the service.
+//
+// See the package documentation for supported options.
+func Open(bucket string, opts ...Option) (*s3Cache, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("%w: missing bucket", ErrInvalidParam)
+	}
+	c := &s3Cache{
+		bucket:     bucket,
+		timeout:    defaultTimeout,
+		maxRetries: defaultMaxRetries,
+		client:     http.DefaultClient,
+		sleep:      time.Sleep,
+	}
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+	if c.region == "" {
+		c.region = cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), defaultRegion)
+	}
+	if c.creds == nil {
+		c.creds = &Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	}
+	if c.creds.AccessKeyID != "" {
+		c.signer = &signer{creds: *c.creds, region: c.region}
+	}
+	base, err := c.bucketURL()
+	if err != nil {
+		return nil, err
+	}
+	c.base = base
+	return c, nil
+}
+
+// bucketURL returns the URL of the bucket, with an encoded path ending in a
+// slash.
+func (c *s3Cache) bucketURL() (*url.URL, error) {
+	endpoint := c.endpoint
+	pathStyle := endpoint != nil
+	if endpoint == nil {
+		endpoint = &url.URL{Scheme: "https", Host: "s3." + c.region + ".amazonaws.com"}
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, fmt.Errorf("%w: endpoint %q", ErrInvalidParam, endpoint)
+	}
+	if c.pathStyle != nil {
+		pathStyle = *c.pathStyle
+	}
+	u := &url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host}
+	basePath := strings.TrimSuffix(endpoint.EscapedPath(), "/") + "/"
+	if pathStyle {
+		basePath += uriEncode(c.bucket, true) + "/"
+	} else {
+		u.Host = c.bucket + "." + u.Host
+	}
+	return u.Parse(basePath)
+}
+
+var (
+	_ driver.Conn      = (*s3Cache)(nil)
+	_ expapi.KeyLister = (*s3Cache)(nil)
+)
+
+func errNotExist(key string) error {
+	return errors.Join(
+		driver.ErrNotExist,
+		fmt.Errorf("s3: key %q does not exist", key),
+	)
+}
+
+// objectURL returns the URL of the object for key.
+func (c *s3Cache) objectURL(key string) *url.URL {
+	u := *c.base
+	u.RawPath = c.base.EscapedPath() + uriEncode(c.prefix+key, false)
+	u.Path, _ = url.PathUnescape(u.RawPath) // valid by construction
+	return &u
+}
+
+// request is a request to the service, which may be sent more than once.
+type request struct {
+	method string
+	url    *url.URL
+	header http.Header
+	body   []byte
+}
+
+// response is a response from the service, with its body read.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// do sends r, retrying network errors, 5xx and 429 responses with
+// exponential backoff and full jitter. It returns an error for responses
+// other than 2xx, except those whose status is in accept.
+func (c *s3Cache) do(r request, accept ...int) (*response, error) {
+	payloadHash := emptyPayloadHash
+	if len(r.body) > 0 {
+		payloadHash = hashHex(r.body)
+	}
+	var (
+		resp *response
+		err  error
+	)
+	for attempt := 0; ; attempt++ {
+		resp, err = c.send(r, payloadHash)
+		if !retryable(resp, err) || attempt >= c.maxRetries {
+			break
+		}
+		delay := min(retryBaseDelay<<attempt, retryMaxDelay)
+		c.sleep(rand.N(delay) + 1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.status/100 == 2 || slices.Contains(accept, resp.status) {
+		return resp, nil
+	}
+	return nil, parseError(resp)
+}
+
+func retryable(resp *response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.status >= 500 || resp.status == http.StatusTooManyRequests
+}
+
+func (c *s3Cache) send(r request, payloadHash string) (*response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url.String(), bytes.NewReader(r.body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if c.signer != nil {
+		c.signer.sign(req, payloadHash, time.Now())
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+func parseError(resp *response) error {
+	e := &ResponseError{StatusCode: resp.status}
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(resp.body, &body) == nil {
+		e.Code, e.Message = body.Code, body.Message
+	}
+	return e
+}
+
+func (c *s3Cache) Get(key string) ([]byte, error) {
+	resp, err := c.do(request{method: http.MethodGet, url: c.objectURL(key)})
+	if err != nil {
+		var rerr *ResponseError
+		if errors.As(err, &rerr) && rerr.Code == "NoSuchKey" {
+			return nil, errNotExist(key)
+		}
+		return nil, err
+	}
+	return resp.body, nil
+}
+
+func (c *s3Cache) Set(key string, value []byte) error {
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	if c.sse != "" {
+		header.Set("X-Amz-Server-Side-Encryption", c.sse)
+		if c.sseKMSKey != "" {
+			header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", c.sseKMSKey)
+		}
+	}
+	_, err := c.do(request{method: http.MethodPut, url: c.objectURL(key), header: header, body: value})
+	return err
+}
+
+// Delete removes the object for key. S3 reports success when deleting a
+// missing object, so its existence is checked first.
+func (c *s3Cache) Delete(key string) error {
+	u := c.objectURL(key)
+	resp, err := c.do(request{method: http.MethodHead, url: u}, http.StatusNotFound)
+	if err != nil {
+		return err
+	}
+	if resp.status == http.StatusNotFound {
+		return errNotExist(key)
+	}
+	_, err = c.do(request{method: http.MethodDelete, url: u})
+	return err
+}
+
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	EncodingType          string `xml:"EncodingType"`
+	Contents              []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+}
+
+// Keys lists the keys with the given prefix, following ListObjectsV2
+// continuation tokens.
+func (c *s3Cache) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	var token string
+	for {
+		query := "encoding-type=url&list-type=2&prefix=" + uriEncode(c.prefix+prefix, true)
+		if token != "" {
+			query += "&continuation-token=" + uriEncode(token, true)
+		}
+		u := *c.base
+		u.RawQuery = query
+		resp, err := c.do(request{method: http.MethodGet, url: &u})
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		if err := xml.Unmarshal(resp.body, &result); err != nil {
+			return nil, fmt.Errorf("s3: failed to decode object list: %w", err)
+		}
+		for _, obj := range result.Contents {
+			name := obj.Key
+			if result.EncodingType == "url" {
+				if name, err = url.QueryUnescape(name); err != nil {
+					return nil, fmt.Errorf("s3: failed to decode object name %q: %w", obj.Key, err)
+				}
+			}
+			keys = append(keys, strings.TrimPrefix(name, c.prefix))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
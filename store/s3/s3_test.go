@@ -0,0 +1,312 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+)
+
+func openCache(t *testing.T, srv *fakeServer, opts ...Option) *s3Cache {
+	t.Helper()
+	opts = append([]Option{WithEndpoint(srv.URL), WithCredentials(testCreds)}, opts...)
+	cache, err := Open(srv.bucket, opts...)
+	testutil.RequireNoError(t, err)
+	cache.sleep = func(time.Duration) {}
+	return cache
+}
+
+func TestS3_Acceptance(t *testing.T) {
+	srv := newFakeServer(t, "bucket")
+	var n int
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		// A fresh prefix per test keeps the shared bucket's keys apart.
+		n++
+		return openCache(t, srv, WithPrefix("test"+strconv.Itoa(n)+"/")), func() {}
+	}), acceptance.WithMaxKeyLen(1000)) // object names are limited to 1024 bytes, including the prefix
+}
+
+func TestS3_Acceptance_DSN(t *testing.T) {
+	srv := newFakeServer(t, "bucket")
+	t.Setenv("AWS_ACCESS_KEY_ID", testCreds.AccessKeyID)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", testCreds.SecretAccessKey)
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	var n int
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		n++
+		u, err := url.Parse("s3://bucket/dsn" + strconv.Itoa(n) + "/?region=us-east-1&endpoint=" + url.QueryEscape(srv.URL))
+		testutil.RequireNoError(t, err)
+		cache, err := fromURL(u)
+		testutil.RequireNoError(t, err)
+		return cache, func() {}
+	}), acceptance.WithMaxKeyLen(1000))
+}
+
+func Test_fromURL(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	tests := []struct {
+		name      string
+		dsn       string
+		assertion func(tt *testing.T, got *s3Cache, err error)
+	}{
+		{
+			name: "AWS",
+			dsn:  "s3://my-bucket/cache/?region=eu-west-1&sse=aws:kms&sse_kms_key_id=key-1&timeout=10s&max_retries=5",
+			assertion: func(tt *testing.T, got *s3Cache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, "my-bucket", got.bucket)
+				testutil.AssertEqual(tt, "cache/", got.prefix)
+				testutil.AssertEqual(tt, "eu-west-1", got.region)
+				testutil.AssertEqual(tt, "aws:kms", got.sse)
+				testutil.AssertEqual(tt, "key-1", got.sseKMSKey)
+				testutil.AssertEqual(tt, 10*time.Second, got.timeout)
+				testutil.AssertEqual(tt, 5, got.maxRetries)
+				testutil.AssertEqual(tt, "https://my-bucket.s3.eu-west-1.amazonaws.com/", got.base.String())
+			},
+		},
+		{
+			name: "defaults",
+			dsn:  "s3://my-bucket",
+			assertion: func(tt *testing.T, got *s3Cache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, "", got.prefix)
+				testutil.AssertEqual(tt, defaultRegion, got.region)
+				testutil.AssertEqual(tt, defaultTimeout, got.timeout)
+				testutil.AssertEqual(tt, defaultMaxRetries, got.maxRetries)
+			},
+		},
+		{
+			name: "custom endpoint",
+			dsn:  "s3://my-bucket?endpoint=" + url.QueryEscape("http://localhost:9000/storage/"),
+			assertion: func(tt *testing.T, got *s3Cache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, "http://localhost:9000/storage/my-bucket/", got.base.String())
+			},
+		},
+		{
+			name: "custom endpoint, virtual-hosted",
+			dsn:  "s3://my-bucket?path_style=false&endpoint=" + url.QueryEscape("http://storage.test"),
+			assertion: func(tt *testing.T, got *s3Cache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, "http://my-bucket.storage.test/", got.base.String())
+			},
+		},
+		{
+			name: "missing bucket",
+			dsn:  "s3:///prefix",
+			assertion: func(tt *testing.T, got *s3Cache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid endpoint",
+			dsn:  "s3://my-bucket?endpoint=localhost",
+			assertion: func(tt *testing.T, got *s3Cache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid path_style",
+			dsn:  "s3://my-bucket?path_style=sometimes",
+			assertion: func(tt *testing.T, got *s3Cache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid sse",
+			dsn:  "s3://my-bucket?sse=rot13",
+			assertion: func(tt *testing.T, got *s3Cache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid timeout",
+			dsn:  "s3://my-bucket?timeout=soon",
+			assertion: func(tt *testing.T, got *s3Cache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid max_retries",
+			dsn:  "s3://my-bucket?max_retries=-1",
+			assertion: func(tt *testing.T, got *s3Cache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.dsn)
+			testutil.RequireNoError(t, err)
+			got, err := fromURL(u)
+			tt.assertion(t, got, err)
+		})
+	}
+}
+
+func TestS3_ObjectNames(t *testing.T) {
+	srv := newFakeServer(t, "bucket")
+	cache := openCache(t, srv, WithPrefix("p/"))
+	keys := []string{
+		"https://example.com/a?b=c&d=e#1",
+		"https://example.com/a b/%41/ü",
+		"https://example.com//double//slash",
+		"https://example.com/+plus*star",
+	}
+	for _, key := range keys {
+		testutil.RequireNoError(t, cache.Set(key, []byte(key)))
+		_, ok := srv.object("p/" + key)
+		testutil.RequireTrue(t, ok, "object not stored under its key: "+key)
+		got, err := cache.Get(key)
+		testutil.RequireNoError(t, err)
+		testutil.AssertEqual(t, key, string(got))
+	}
+	got, err := cache.Keys("https://example.com/")
+	testutil.RequireNoError(t, err)
+	slices.Sort(got)
+	slices.Sort(keys)
+	testutil.AssertTrue(t, slices.Equal(keys, got), "Keys = "+strings.Join(got, ", "))
+}
+
+func TestS3_KeysPaging(t *testing.T) {
+	srv := newFakeServer(t, "bucket")
+	cache := openCache(t, srv)
+	for i := range 7 {
+		testutil.RequireNoError(t, cache.Set("key"+strconv.Itoa(i), []byte("v")))
+	}
+	testutil.RequireNoError(t, cache.Set("other", []byte("v")))
+	before := srv.requestCount()
+	got, err := cache.Keys("key")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, 7, len(got))
+	testutil.AssertEqual(t, 4, srv.requestCount()-before, "pages requested")
+}
+
+func TestS3_ServerSideEncryption(t *testing.T) {
+	srv := newFakeServer(t, "bucket")
+	tests := []struct {
+		name, algorithm, kmsKeyID string
+	}{
+		{"None", "", ""},
+		{"AES256", "AES256", ""},
+		{"KMS", "aws:kms", "arn:aws:kms:us-east-1:111122223333:key/1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := openCache(t, srv, WithServerSideEncryption(tt.algorithm, tt.kmsKeyID))
+			testutil.RequireNoError(t, cache.Set(tt.name, []byte("v")))
+			obj, ok := srv.object(tt.name)
+			testutil.RequireTrue(t, ok)
+			testutil.AssertEqual(t, tt.algorithm, obj.header.Get("X-Amz-Server-Side-Encryption"))
+			testutil.AssertEqual(t, tt.kmsKeyID, obj.header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+		})
+	}
+}
+
+func TestS3_Retry(t *testing.T) {
+	srv := newFakeServer(t, "bucket")
+	cache := openCache(t, srv, WithMaxRetries(2))
+	var delays []time.Duration
+	cache.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	srv.fail(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	testutil.RequireNoError(t, cache.Set("k", []byte("v")))
+	testutil.AssertEqual(t, 2, len(delays))
+	for i, d := range delays {
+		testutil.AssertTrue(t, d > 0 && d <= retryBaseDelay<<i, "delay "+d.String()+" out of range")
+	}
+
+	// Retries are exhausted.
+	srv.fail(http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	_, err := cache.Get("k")
+	var rerr *ResponseError
+	testutil.RequireErrorAs(t, err, &rerr)
+	testutil.AssertEqual(t, http.StatusInternalServerError, rerr.StatusCode)
+	testutil.AssertEqual(t, "InternalError", rerr.Code)
+
+	// Client errors are not retried.
+	srv.fail(http.StatusBadRequest)
+	before := srv.requestCount()
+	_, err = cache.Get("k")
+	testutil.RequireErrorAs(t, err, &rerr)
+	testutil.AssertEqual(t, 1, srv.requestCount()-before)
+}
+
+func TestS3_Errors(t *testing.T) {
+	srv := newFakeServer(t, "bucket")
+
+	// Unsigned and wrongly signed requests are rejected.
+	for _, cache := range []*s3Cache{
+		openCache(t, srv, WithCredentials(Credentials{})),
+		openCache(t, srv, WithCredentials(Credentials{AccessKeyID: testCreds.AccessKeyID, SecretAccessKey: "wrong"})),
+	} {
+		err := cache.Set("k", []byte("v"))
+		var rerr *ResponseError
+		testutil.RequireErrorAs(t, err, &rerr)
+		testutil.AssertEqual(t, http.StatusForbidden, rerr.StatusCode)
+		testutil.AssertEqual(t, "SignatureDoesNotMatch", rerr.Code)
+	}
+
+	// A missing bucket is an error, not a missing key.
+	cache, err := Open("missing", WithEndpoint(srv.URL), WithCredentials(testCreds))
+	testutil.RequireNoError(t, err)
+	_, err = cache.Get("k")
+	var rerr *ResponseError
+	testutil.RequireErrorAs(t, err, &rerr)
+	testutil.AssertEqual(t, "NoSuchBucket", rerr.Code)
+	testutil.AssertTrue(t, !strings.Contains(err.Error(), "does not exist"))
+
+	// Object names are limited to 1024 bytes.
+	cache = openCache(t, srv)
+	testutil.RequireErrorAs(t, cache.Set(strings.Repeat("a", 1025), []byte("v")), &rerr)
+	testutil.AssertEqual(t, "KeyTooLongError", rerr.Code)
+}
+
+func TestS3_VirtualHosted(t *testing.T) {
+	srv := newFakeServer(t, "bucket")
+	// Route every host name to the fake server.
+	dialer := &net.Dialer{}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}}
+	t.Cleanup(client.CloseIdleConnections)
+	cache := openCache(t, srv,
+		WithEndpoint("http://s3.test"),
+		WithPathStyle(false),
+		WithHTTPClient(client),
+	)
+	testutil.AssertEqual(t, "http://bucket.s3.test/", cache.base.String())
+	testutil.RequireNoError(t, cache.Set("k", []byte("v")))
+	got, err := cache.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "v", string(got))
+	keys, err := cache.Keys("")
+	testutil.RequireNoError(t, err)
+	testutil.AssertTrue(t, slices.Equal([]string{"k"}, keys))
+}
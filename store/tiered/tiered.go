@@ -0,0 +1,715 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tiered implements a cache backend that layers a fast cache (L1),
+// typically in memory, in front of a persistent one (L2).
+//
+// Reads are served from L1 when possible; entries read from L2 are promoted
+// into L1. Writes go to both tiers: to L2 before returning in write-through
+// mode (the default), or from a background loop in write-back mode, where
+// pending writes are also served to readers. Entries stored with a time to
+// live are always written through. Deletes are applied to both tiers. L1
+// should be bounded (see [github.com/bartventer/httpcache/store/memcache.WithMaxBytes]);
+// values larger than max_item_size are kept out of it altogether.
+//
+// The tiers are kept consistent for writes made through the same tiered
+// cache. Writes made to L2 by other processes are only seen once the entry
+// is evicted from L1, so L1 should also expire its entries if L2 is shared.
+//
+// The cache does not implement [driver.Streamer], even if L2 does: values
+// are promoted into L1 and held pending in write-back mode as a whole.
+//
+// # Configuration Parameters
+//
+// The following DSN query parameters are supported. The DSNs of the tiers
+// must be query-escaped, and their drivers registered in the registry that
+// opens the tiered DSN:
+//
+//   - l2 (required): DSN of the persistent tier
+//   - l1 (optional): DSN of the fast tier (default: "memcache://?max_bytes=64MiB")
+//   - mode (optional): "write-through" or "write-back" (default: "write-through")
+//   - flush_interval (optional): How often write-back mode writes pending entries to L2 (default: 1s)
+//   - max_pending (optional): Maximum number of pending entries in write-back mode; further writes go through (default: 1024)
+//   - max_item_size (optional): Values larger than this, e.g. "1MiB", are not stored in L1 (default: unbounded)
+//
+// # Usage Examples
+//
+//	The DSN and equivalent programmatic usage are shown below.
+//
+//	 Memory in front of the file system:
+//
+//		tiered://?l1=memcache%3A%2F%2F%3Fmax_bytes%3D64MiB&l2=fscache%3A%2F%2F%3Fappname%3Dmyapp
+//		tiered.New(memcache.Open(memcache.WithMaxBytes(64<<20)), fscacheConn)
+//
+//	 Write-back:
+//
+//		tiered://?l2=fscache%3A%2F%2F%3Fappname%3Dmyapp&mode=write-back&flush_interval=5s
+//		tiered.New(l1, l2, tiered.WithMode(tiered.WriteBack), tiered.WithFlushInterval(5*time.Second))
+package tiered
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bartventer/httpcache/store"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+	"github.com/bartventer/httpcache/store/internal/bytesize"
+	"github.com/bartventer/httpcache/store/internal/registry"
+	_ "github.com/bartventer/httpcache/store/memcache" // default l1
+)
+
+const Scheme = "tiered"
+
+// Driver opens tiered:// DSNs. It is registered in the default registry
+// under [Scheme]; register it in a [store.Registry] to open the DSNs it names
+// with the drivers of that registry.
+var Driver driver.WrapperDriver = registry.WrapperFunc(fromURL)
+
+//nolint:gochecknoinits // We use init to register the driver.
+func init() {
+	store.Register(Scheme, Driver)
+}
+
+var ErrInvalidParam = errors.New("tiered: invalid DSN parameter")
+
+const (
+	defaultL1DSN         = "memcache://?max_bytes=64MiB"
+	defaultFlushInterval = time.Second
+	defaultMaxPending    = 1024
+)
+
+// Mode selects when writes reach L2.
+type Mode int
+
+const (
+	// WriteThrough writes to L2 before returning.
+	WriteThrough Mode = iota
+	// WriteBack writes to L2 from a background loop; writes still pending
+	// when the process exits are lost unless the cache is flushed or closed.
+	WriteBack
+)
+
+func (m Mode) String() string {
+	switch m {
+	case WriteThrough:
+		return "write-through"
+	case WriteBack:
+		return "write-back"
+	default:
+		return "Mode(" + strconv.Itoa(int(m)) + ")"
+	}
+}
+
+func parseMode(v string) (Mode, error) {
+	switch strings.ToLower(v) {
+	case "write-through":
+		return WriteThrough, nil
+	case "write-back":
+		return WriteBack, nil
+	default:
+		return 0, fmt.Errorf("%w: mode %q", ErrInvalidParam, v)
+	}
+}
+
+// numStripes is the number of locks that writes and promotions of keys are
+// spread over.
+const numStripes = 64
+
+type tieredCache struct {
+	// configurable options
+
+	mode          Mode          // when writes reach L2
+	flushInterval time.Duration // write-back flush interval
+	maxPending    int           // maximum number of pending write-back entries
+	maxItemSize   int64         // values larger than this bypass L1; 0 means unbounded
+
+	// internal state
+
+	l1, l2  driver.Conn
+	l2cc    driver.ConnContext     // l2, or an adapter if it does not support cancellation
+	l2b     driver.Batcher         // l2, or an adapter if it does not support batching
+	stripes [numStripes]sync.Mutex // serialize updates of a key across tiers
+	klocks  [numStripes]sync.Mutex // back Lock when L2 is not a driver.Locker
+
+	mu      sync.Mutex
+	pending map[string][]byte // write-back entries not yet in L2
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type Option interface {
+	apply(*tieredCache)
+}
+
+type optionFunc func(*tieredCache)
+
+func (f optionFunc) apply(c *tieredCache) {
+	f(c)
+}
+
+// WithMode sets when writes reach L2; default: [WriteThrough].
+func WithMode(m Mode) Option {
+	return optionFunc(func(c *tieredCache) {
+		c.mode = m
+	})
+}
+
+// WithFlushInterval sets how often pending entries are written to L2 in
+// write-back mode; default: 1s.
+func WithFlushInterval(d time.Duration) Option {
+	return optionFunc(func(c *tieredCache) {
+		if d > 0 {
+			c.flushInterval = d
+		}
+	})
+}
+
+// WithMaxPending bounds the number of entries waiting to be written to L2 in
+// write-back mode; once reached, writes of other keys go through to L2
+// directly. Default: 1024.
+func WithMaxPending(n int) Option {
+	return optionFunc(func(c *tieredCache) {
+		if n > 0 {
+			c.maxPending = n
+		}
+	})
+}
+
+// WithMaxItemSize keeps values larger than n bytes out of L1, so that a few
+// large entries do not displace many small ones; default: unbounded.
+func WithMaxItemSize(n int64) Option {
+	return optionFunc(func(c *tieredCache) {
+		c.maxItemSize = max(n, 0)
+	})
+}
+
+func fromURL(u *url.URL, o driver.Opener) (driver.Conn, error) {
+	q := u.Query()
+	opts := make([]Option, 0, 4)
+	if v := q.Get("mode"); v != "" {
+		m, err := parseMode(v)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithMode(m))
+	}
+	if v := q.Get("flush_interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: flush_interval %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithFlushInterval(d))
+	}
+	if v := q.Get("max_pending"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%w: max_pending %q", ErrInvalidParam, v)
+		}
+		opts = append(opts, WithMaxPending(n))
+	}
+	if v := q.Get("max_item_size"); v != "" {
+		n, err := bytesize.Parse(v)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidParam, err)
+		}
+		opts = append(opts, WithMaxItemSize(n))
+	}
+	l2DSN := q.Get("l2")
+	if l2DSN == "" {
+		return nil, fmt.Errorf("%w: l2 is required", ErrInvalidParam)
+	}
+	l1DSN := cmp.Or(q.Get("l1"), defaultL1DSN)
+	l1, err := o.OpenConn(l1DSN)
+	if err != nil {
+		return nil, fmt.Errorf("tiered: failed to open l1: %w", err)
+	}
+	l2, err := o.OpenConn(l2DSN)
+	if err != nil {
+		_ = closeConn(l1)
+		return nil, fmt.Errorf("tiered: failed to open l2: %w", err)
+	}
+	return New(l1, l2, opts...), nil
+}
+
+// New returns a cache that serves reads from l1 where possible and persists
+// writes to l2. The returned Conn implements [driver.ConnContext] and
+// [driver.Batcher], whose contexts apply to the operations on l2,
+// [driver.TTLSetter] if l2 does, [expapi.KeyLister] if l2 does,
+// listing the keys of l2, and [io.Closer], which flushes pending writes and
+// closes l1 and l2 if they implement it. It does not implement
+// [driver.Streamer]; see the package documentation.
+//
+// See the package documentation for supported options.
+func New(l1, l2 driver.Conn, opts ...Option) driver.Conn {
+	c := &tieredCache{
+		flushInterval: defaultFlushInterval,
+		maxPending:    defaultMaxPending,
+		l1:            l1,
+		l2:            l2,
+		l2cc:          driver.AsConnContext(l2),
+		l2b:           driver.AsBatcher(l2),
+		pending:       make(map[string][]byte),
+	}
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+	if c.mode == WriteBack {
+		c.stop = make(chan struct{})
+		c.done = make(chan struct{})
+		go c.flushLoop()
+	}
+	_, listing := l2.(expapi.KeyLister)
+	ts, _ := l2.(driver.TTLSetter)
+	switch {
+	case listing && ts != nil:
+		return &listingExpiringCache{c, lister{c}, expirer{c, ts}}
+	case listing:
+		return &listingCache{c, lister{c}}
+	case ts != nil:
+		return &expiringCache{c, expirer{c, ts}}
+	}
+	return c
+}
+
+var (
+	_ driver.Conn        = (*tieredCache)(nil)
+	_ driver.ConnContext = (*tieredCache)(nil)
+	_ driver.Batcher     = (*tieredCache)(nil)
+	_ driver.Locker      = (*tieredCache)(nil)
+	_ io.Closer          = (*tieredCache)(nil)
+	_ expapi.KeyLister   = (*listingCache)(nil)
+	_ driver.TTLSetter   = (*expiringCache)(nil)
+	_ expapi.KeyLister   = (*listingExpiringCache)(nil)
+	_ driver.TTLSetter   = (*listingExpiringCache)(nil)
+)
+
+func errNotExist(key string) error {
+	return errors.Join(
+		driver.ErrNotExist,
+		fmt.Errorf("tiered: key %q does not exist", key),
+	)
+}
+
+func stripe(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32() % numStripes
+}
+
+// lockKey acquires the lock serializing updates of key across the tiers.
+func (c *tieredCache) lockKey(key string) func() {
+	mu := &c.stripes[stripe(key)]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// lockKeys acquires the locks for keys, in stripe order so that concurrent
+// callers cannot deadlock.
+func (c *tieredCache) lockKeys(keys []string) func() {
+	var locked [numStripes]bool
+	for _, key := range keys {
+		locked[stripe(key)] = true
+	}
+	for i := range locked {
+		if locked[i] {
+			c.stripes[i].Lock()
+		}
+	}
+	return func() {
+		for i := range locked {
+			if locked[i] {
+				c.stripes[i].Unlock()
+			}
+		}
+	}
+}
+
+// setL1 stores value in L1, or removes key from it if the value is too large
+// or cannot be stored, so that L1 never holds an outdated value. The caller
+// must hold the lock for key.
+func (c *tieredCache) setL1(key string, value []byte) {
+	if c.maxItemSize > 0 && int64(len(value)) > c.maxItemSize {
+		_ = c.l1.Delete(key)
+		return
+	}
+	if err := c.l1.Set(key, value); err != nil {
+		_ = c.l1.Delete(key)
+	}
+}
+
+// pendingValue returns the value of key waiting to be written to L2.
+func (c *tieredCache) pendingValue(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.pending[key]
+	return slices.Clone(value), ok
+}
+
+func (c *tieredCache) Get(key string) ([]byte, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+func (c *tieredCache) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if value, err := c.l1.Get(key); err == nil {
+		return value, nil
+	}
+	unlock := c.lockKey(key)
+	defer unlock()
+	if value, ok := c.pendingValue(key); ok {
+		return value, nil
+	}
+	value, err := c.l2cc.GetContext(ctx, key)
+	if err != nil {
+		if errors.Is(err, driver.ErrNotExist) {
+			return nil, errNotExist(key)
+		}
+		return nil, err
+	}
+	c.setL1(key, value)
+	return value, nil
+}
+
+func (c *tieredCache) Set(key string, value []byte) error {
+	return c.SetContext(context.Background(), key, value)
+}
+
+func (c *tieredCache) SetContext(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := c.lockKey(key)
+	defer unlock()
+	if c.mode == WriteBack && c.enqueue(key, value) {
+		c.setL1(key, value)
+		return nil
+	}
+	if err := c.l2cc.SetContext(ctx, key, value); err != nil {
+		_ = c.l1.Delete(key)
+		return err
+	}
+	c.setL1(key, value)
+	return nil
+}
+
+func (c *tieredCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(keys))
+	var missing []string
+	for _, key := range keys {
+		if value, err := c.l1.Get(key); err == nil {
+			values[key] = value
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	unlock := c.lockKeys(missing)
+	defer unlock()
+	fetch := missing[:0:0]
+	for _, key := range missing {
+		if value, ok := c.pendingValue(key); ok {
+			values[key] = value
+		} else {
+			fetch = append(fetch, key)
+		}
+	}
+	if len(fetch) == 0 {
+		return values, nil
+	}
+	fetched, err := c.l2b.GetMulti(ctx, fetch)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range fetched {
+		c.setL1(key, value)
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (c *tieredCache) SetMulti(ctx context.Context, values map[string][]byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := c.lockKeys(slices.Collect(maps.Keys(values)))
+	defer unlock()
+	through := values
+	if c.mode == WriteBack {
+		through = make(map[string][]byte)
+		for key, value := range values {
+			if c.enqueue(key, value) {
+				c.setL1(key, value)
+			} else {
+				through[key] = value
+			}
+		}
+	}
+	if len(through) == 0 {
+		return nil
+	}
+	if err := c.l2b.SetMulti(ctx, through); err != nil {
+		for key := range through {
+			_ = c.l1.Delete(key)
+		}
+		return err
+	}
+	for key, value := range through {
+		c.setL1(key, value)
+	}
+	return nil
+}
+
+func (c *tieredCache) DeleteMulti(ctx context.Context, keys []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := c.lockKeys(keys)
+	defer unlock()
+	c.mu.Lock()
+	for _, key := range keys {
+		delete(c.pending, key)
+	}
+	c.mu.Unlock()
+	for _, key := range keys {
+		_ = c.l1.Delete(key)
+	}
+	return c.l2b.DeleteMulti(ctx, keys)
+}
+
+// enqueue records value as pending for key, and reports whether it did; it
+// does not if too many entries are pending already. The caller must hold the
+// lock for key.
+func (c *tieredCache) enqueue(key string, value []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.pending[key]; !ok && len(c.pending) >= c.maxPending {
+		return false
+	}
+	c.pending[key] = slices.Clone(value)
+	return true
+}
+
+func (c *tieredCache) Delete(key string) error {
+	return c.DeleteContext(context.Background(), key)
+}
+
+func (c *tieredCache) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := c.lockKey(key)
+	defer unlock()
+	c.mu.Lock()
+	_, pending := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+	errL1 := c.l1.Delete(key)
+	errL2 := c.l2cc.DeleteContext(ctx, key)
+	switch {
+	case errL2 == nil:
+		return nil
+	case !errors.Is(errL2, driver.ErrNotExist):
+		return errL2
+	case pending || errL1 == nil:
+		return nil
+	}
+	return errNotExist(key)
+}
+
+// Lock acquires the lock for key from L2 if it is a [driver.Locker], so that
+// it is shared with other users of L2; otherwise it only serializes callers
+// within this process.
+func (c *tieredCache) Lock(key string) (func(), error) {
+	if l, ok := c.l2.(driver.Locker); ok {
+		return l.Lock(key)
+	}
+	mu := &c.klocks[stripe(key)]
+	mu.Lock()
+	return mu.Unlock, nil
+}
+
+// Flush writes the entries pending in write-back mode to L2. Entries that
+// fail to be written stay pending.
+func (c *tieredCache) Flush() error {
+	c.mu.Lock()
+	keys := slices.Collect(maps.Keys(c.pending))
+	c.mu.Unlock()
+	var errs []error
+	for _, key := range keys {
+		if err := c.flushKey(key); err != nil {
+			errs = append(errs, fmt.Errorf("tiered: failed to write %q to l2: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *tieredCache) flushKey(key string) error {
+	unlock := c.lockKey(key)
+	defer unlock()
+	c.mu.Lock()
+	value, ok := c.pending[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil // deleted, or flushed concurrently
+	}
+	if err := c.l2.Set(key, value); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	delete(c.pending, key)
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *tieredCache) flushLoop() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			_ = c.Flush() // failed entries are retried on the next tick
+		}
+	}
+}
+
+// Close stops the write-back loop, flushes pending entries, and closes the
+// tiers that implement [io.Closer].
+func (c *tieredCache) Close() error {
+	c.closeOnce.Do(func() {
+		if c.stop != nil {
+			close(c.stop)
+			<-c.done
+		}
+		c.closeErr = errors.Join(c.Flush(), closeConn(c.l1), closeConn(c.l2))
+	})
+	return c.closeErr
+}
+
+func closeConn(conn driver.Conn) error {
+	if cl, ok := conn.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// lister lists the keys of a tieredCache whose L2 can list its keys.
+type lister struct{ c *tieredCache }
+
+// Keys lists the keys in L2 with the given prefix, along with those pending
+// in write-back mode.
+func (l lister) Keys(prefix string) ([]string, error) {
+	c := l.c
+	keys, err := c.l2.(expapi.KeyLister).Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 {
+		return keys, nil
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		seen[k] = true
+	}
+	for k := range c.pending {
+		if strings.HasPrefix(k, prefix) && !seen[k] {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// expirer stores entries in a tieredCache whose L2 can expire them.
+type expirer struct {
+	c  *tieredCache
+	ts driver.TTLSetter
+}
+
+// SetWithTTL is like SetContext, but the entry expires once ttl has elapsed.
+// It is written to L2 before returning, even in write-back mode, and kept in
+// L1 only if L1 can expire it too.
+func (e expirer) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c := e.c
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := c.lockKey(key)
+	defer unlock()
+	c.mu.Lock()
+	delete(c.pending, key)
+	c.mu.Unlock()
+	if err := e.ts.SetWithTTL(ctx, key, value, ttl); err != nil {
+		_ = c.l1.Delete(key)
+		return err
+	}
+	if ttl <= 0 {
+		c.setL1(key, value)
+		return nil
+	}
+	ts, ok := c.l1.(driver.TTLSetter)
+	if !ok || (c.maxItemSize > 0 && int64(len(value)) > c.maxItemSize) {
+		_ = c.l1.Delete(key)
+		return nil
+	}
+	if err := ts.SetWithTTL(ctx, key, value, ttl); err != nil {
+		_ = c.l1.Delete(key)
+	}
+	return nil
+}
+
+// listingCache is a tieredCache whose L2 can list its keys.
+type listingCache struct {
+	*tieredCache
+	lister
+}
+
+// expiringCache is a tieredCache whose L2 can expire its entries.
+type expiringCache struct {
+	*tieredCache
+	expirer
+}
+
+// listingExpiringCache is a tieredCache whose L2 can list its keys and
+// expire its entries.
+type listingExpiringCache struct {
+	*tieredCache
+	lister
+	expirer
+}
@@ -0,0 +1,367 @@
+// Copyright (c) 2026 Bart Venter <72999113+bartventer@users.noreply.github.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tiered
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bartventer/httpcache/internal/testutil"
+	"github.com/bartventer/httpcache/store/acceptance"
+	"github.com/bartventer/httpcache/store/driver"
+	"github.com/bartventer/httpcache/store/expapi"
+	_ "github.com/bartventer/httpcache/store/fscache"
+	"github.com/bartventer/httpcache/store/internal/conntest"
+	"github.com/bartventer/httpcache/store/internal/registry"
+	"github.com/bartventer/httpcache/store/memcache"
+)
+
+func TestTiered_Acceptance(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"WriteThrough", nil},
+		{"WriteBack", []Option{WithMode(WriteBack)}},
+		{"MaxItemSize", []Option{WithMaxItemSize(2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+				cache := New(memcache.Open(), memcache.Open(), tt.opts...)
+				return cache, func() { cache.(io.Closer).Close() }
+			}))
+		})
+	}
+}
+
+func TestTiered_Acceptance_DSN(t *testing.T) {
+	acceptance.Run(t, acceptance.FactoryFunc(func() (driver.Conn, func()) {
+		l2 := "fscache://" + t.TempDir() + "?appname=testapp"
+		cache, err := fromURL(mustParse(t, "tiered://?mode=write-back&l2="+url.QueryEscape(l2)), registry.Default())
+		testutil.RequireNoError(t, err)
+		return cache, func() { cache.(io.Closer).Close() }
+	}))
+}
+
+func mustParse(t *testing.T, dsn string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(dsn)
+	testutil.RequireNoError(t, err)
+	return u
+}
+
+func Test_fromURL(t *testing.T) {
+	l2 := "&l2=" + url.QueryEscape("memcache://?max_entries=10")
+	tests := []struct {
+		name      string
+		dsn       string
+		assertion func(tt *testing.T, got *tieredCache, err error)
+	}{
+		{
+			name: "defaults",
+			dsn:  "tiered://?" + l2,
+			assertion: func(tt *testing.T, got *tieredCache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, WriteThrough, got.mode)
+				testutil.AssertEqual(tt, defaultFlushInterval, got.flushInterval)
+				testutil.AssertEqual(tt, defaultMaxPending, got.maxPending)
+				testutil.AssertEqual(tt, 0, got.maxItemSize)
+			},
+		},
+		{
+			name: "all parameters",
+			dsn: "tiered://?mode=Write-Back&flush_interval=5s&max_pending=10&max_item_size=1KiB" +
+				"&l1=" + url.QueryEscape("memcache://?max_entries=1") + l2,
+			assertion: func(tt *testing.T, got *tieredCache, err error) {
+				testutil.RequireNoError(tt, err)
+				testutil.AssertEqual(tt, WriteBack, got.mode)
+				testutil.AssertEqual(tt, 5*time.Second, got.flushInterval)
+				testutil.AssertEqual(tt, 10, got.maxPending)
+				testutil.AssertEqual(tt, 1024, got.maxItemSize)
+			},
+		},
+		{
+			name: "missing l2",
+			dsn:  "tiered://",
+			assertion: func(tt *testing.T, got *tieredCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid mode",
+			dsn:  "tiered://?mode=sometimes" + l2,
+			assertion: func(tt *testing.T, got *tieredCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid flush_interval",
+			dsn:  "tiered://?flush_interval=0s" + l2,
+			assertion: func(tt *testing.T, got *tieredCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid max_pending",
+			dsn:  "tiered://?max_pending=-1" + l2,
+			assertion: func(tt *testing.T, got *tieredCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "invalid max_item_size",
+			dsn:  "tiered://?max_item_size=huge" + l2,
+			assertion: func(tt *testing.T, got *tieredCache, err error) {
+				testutil.RequireErrorIs(tt, err, ErrInvalidParam)
+			},
+		},
+		{
+			name: "unknown l2 driver",
+			dsn:  "tiered://?l2=nope%3A%2F%2F",
+			assertion: func(tt *testing.T, got *tieredCache, err error) {
+				testutil.RequireError(tt, err)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := fromURL(mustParse(t, tt.dsn), registry.Default())
+			var got *tieredCache
+			if err == nil {
+				t.Cleanup(func() { conn.(io.Closer).Close() })
+				got = conn.(*listingExpiringCache).tieredCache
+			}
+			tt.assertion(t, got, err)
+		})
+	}
+}
+
+type listingConn interface {
+	driver.Conn
+	expapi.KeyLister
+}
+
+// failingConn fails writes while failing is set.
+type failingConn struct {
+	listingConn
+	mu      sync.Mutex
+	failing bool
+}
+
+var errWrite = errors.New("write failed")
+
+func (f *failingConn) Set(key string, value []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing {
+		return errWrite
+	}
+	return f.listingConn.Set(key, value)
+}
+
+func (f *failingConn) fail(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func TestTiered_Promotion(t *testing.T) {
+	l1, l2 := memcache.Open(), memcache.Open()
+	cache := New(l1, l2, WithMaxItemSize(3))
+	testutil.RequireNoError(t, l2.Set("small", []byte("abc")))
+	testutil.RequireNoError(t, l2.Set("large", []byte("abcd")))
+
+	for _, key := range []string{"small", "large"} {
+		_, err := cache.Get(key)
+		testutil.RequireNoError(t, err)
+	}
+	_, err := l1.Get("small")
+	testutil.RequireNoError(t, err, "not promoted")
+	_, err = l1.Get("large")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "promoted above max_item_size")
+
+	// A write too large for L1 drops its previous value there.
+	testutil.RequireNoError(t, cache.Set("small", []byte("abcdef")))
+	_, err = l1.Get("small")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	got, err := cache.Get("small")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "abcdef", string(got))
+}
+
+func TestTiered_WriteThrough_FailedWrite(t *testing.T) {
+	l1, l2 := memcache.Open(), &failingConn{listingConn: memcache.Open()}
+	cache := New(l1, l2)
+	testutil.RequireNoError(t, cache.Set("k", []byte("old")))
+	l2.fail(true)
+	testutil.RequireErrorIs(t, cache.Set("k", []byte("new")), errWrite)
+	got, err := cache.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "old", string(got), "L1 disagrees with L2")
+}
+
+func TestTiered_WriteBack(t *testing.T) {
+	l1, l2 := memcache.Open(memcache.WithMaxEntries(1)), &failingConn{listingConn: memcache.Open()}
+	cache := New(l1, l2, WithMode(WriteBack), WithFlushInterval(time.Hour)).(*listingCache)
+	t.Cleanup(func() { cache.Close() })
+	l2.fail(true)
+	testutil.RequireNoError(t, cache.Set("a", []byte("1")))
+	testutil.RequireNoError(t, cache.Set("b", []byte("2"))) // evicts a from L1
+
+	// Pending entries are served and listed before they reach L2.
+	got, err := cache.Get("a")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "1", string(got))
+	keys, err := cache.Keys("")
+	testutil.RequireNoError(t, err)
+	slices.Sort(keys)
+	testutil.AssertTrue(t, slices.Equal(keys, []string{"a", "b"}), "got %q", keys)
+	_, err = l2.Get("a")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+
+	testutil.RequireErrorIs(t, cache.Flush(), errWrite)
+	testutil.AssertEqual(t, 2, len(cache.pending), "failed writes dropped")
+	l2.fail(false)
+	testutil.RequireNoError(t, cache.Delete("b"))
+	testutil.RequireNoError(t, cache.Close())
+	got, err = l2.Get("a")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "1", string(got))
+	_, err = l2.Get("b")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "deleted entry flushed")
+}
+
+func TestTiered_WriteBack_MaxPending(t *testing.T) {
+	l2 := memcache.Open()
+	cache := New(memcache.Open(), l2, WithMode(WriteBack), WithMaxPending(1), WithFlushInterval(time.Hour))
+	t.Cleanup(func() { cache.(io.Closer).Close() })
+	testutil.RequireNoError(t, cache.Set("a", []byte("1")))
+	testutil.RequireNoError(t, cache.Set("b", []byte("2")))
+	_, err := l2.Get("a")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist, "first write not pending")
+	_, err = l2.Get("b")
+	testutil.RequireNoError(t, err, "write beyond max_pending not written through")
+}
+
+func TestTiered_WriteBack_FlushLoop(t *testing.T) {
+	l2 := memcache.Open()
+	cache := New(memcache.Open(), l2, WithMode(WriteBack), WithFlushInterval(time.Millisecond))
+	t.Cleanup(func() { cache.(io.Closer).Close() })
+	testutil.RequireNoError(t, cache.Set("a", []byte("1")))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := l2.Get("a"); err == nil {
+			return
+		}
+		testutil.RequireTrue(t, time.Now().Before(deadline), "entry not flushed")
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTiered_Delete(t *testing.T) {
+	l1, l2 := memcache.Open(), memcache.Open()
+	cache := New(l1, l2)
+	testutil.RequireNoError(t, l2.Set("a", []byte("1")))
+	_, err := cache.Get("a")
+	testutil.RequireNoError(t, err)
+	testutil.RequireNoError(t, cache.Delete("a"))
+	for _, conn := range []driver.Conn{l1, l2} {
+		_, err := conn.Get("a")
+		testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	}
+	testutil.RequireErrorIs(t, cache.Delete("a"), driver.ErrNotExist)
+}
+
+func TestTiered_KeyLister(t *testing.T) {
+	_, ok := New(memcache.Open(), conntest.Plain(memcache.Open())).(expapi.KeyLister)
+	testutil.AssertTrue(t, !ok, "lists keys of an L2 that cannot")
+	_, ok = New(conntest.Plain(memcache.Open()), memcache.Open()).(expapi.KeyLister)
+	testutil.AssertTrue(t, ok, "does not list keys of L2")
+}
+
+func TestTiered_Streamer(t *testing.T) {
+	// Values are promoted into L1 as a whole, so streaming is not forwarded.
+	c := New(memcache.Open(), conntest.Streaming(memcache.Open()))
+	_, ok := c.(driver.Streamer)
+	testutil.AssertTrue(t, !ok, "streams values past L1")
+}
+
+func TestTiered_SetWithTTL(t *testing.T) {
+	_, ok := New(memcache.Open(), conntest.Plain(memcache.Open())).(driver.TTLSetter)
+	testutil.AssertTrue(t, !ok, "expires entries of an L2 that cannot")
+
+	l1, l2 := conntest.Plain(memcache.Open()), memcache.Open()
+	cache := New(l1, l2, WithMode(WriteBack), WithFlushInterval(time.Hour))
+	t.Cleanup(func() { cache.(io.Closer).Close() })
+	testutil.RequireNoError(t, cache.Set("k", []byte("old")))
+	ts, ok := cache.(driver.TTLSetter)
+	testutil.RequireTrue(t, ok, "does not expire entries of L2")
+	testutil.RequireNoError(t, ts.SetWithTTL(t.Context(), "k", []byte("new"), time.Hour))
+
+	// The entry is written through, and kept out of an L1 that cannot
+	// expire it.
+	got, err := l2.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "new", string(got))
+	_, err = l1.Get("k")
+	testutil.RequireErrorIs(t, err, driver.ErrNotExist)
+	testutil.RequireNoError(t, cache.(*listingExpiringCache).Flush())
+	got, err = cache.Get("k")
+	testutil.RequireNoError(t, err)
+	testutil.AssertEqual(t, "new", string(got), "pending write overwrote the entry")
+}
+
+func TestTiered_Concurrent(t *testing.T) {
+	for _, mode := range []Mode{WriteThrough, WriteBack} {
+		t.Run(mode.String(), func(t *testing.T) {
+			l1, l2 := memcache.Open(memcache.WithMaxEntries(4)), memcache.Open()
+			cache := New(l1, l2, WithMode(mode), WithFlushInterval(time.Millisecond))
+			var wg sync.WaitGroup
+			for i := range 8 {
+				wg.Go(func() {
+					for j := range 200 {
+						key := strconv.Itoa(j % 8)
+						switch (i + j) % 3 {
+						case 0:
+							_ = cache.Set(key, []byte(strconv.Itoa(i)))
+						case 1:
+							_, _ = cache.Get(key)
+						case 2:
+							_ = cache.Delete(key)
+						}
+					}
+				})
+			}
+			wg.Wait()
+			testutil.RequireNoError(t, cache.(io.Closer).Close())
+			// Whatever L1 holds agrees with L2.
+			for j := range 8 {
+				key := strconv.Itoa(j)
+				want, errL2 := l2.Get(key)
+				if got, err := l1.Get(key); err == nil {
+					testutil.RequireNoError(t, errL2)
+					testutil.AssertEqual(t, string(want), string(got), "key "+key)
+				}
+			}
+		})
+	}
+}